@@ -14,6 +14,8 @@ import (
 	"github.com/spf13/cobra"
 	"gopkg.in/yaml.v3"
 
+	"github.com/k8s-school/home-ci/internal/config"
+	"github.com/k8s-school/home-ci/internal/gitrepo"
 	"github.com/k8s-school/home-ci/internal/logging"
 )
 
@@ -48,9 +50,8 @@ Provides insights into test execution, concurrency compliance, and branch timeli
 		isRemoteRepo := strings.HasPrefix(config.Repository, "http://") || strings.HasPrefix(config.Repository, "https://")
 
 		if isRemoteRepo {
-			// For remote repositories, use cache directory with URL-based naming (same as GitRepository)
-			repoName := strings.ReplaceAll(strings.ReplaceAll(config.Repository, "/", "_"), ":", "_")
-			repoPath = filepath.Join(config.CacheDir, repoName)
+			// For remote repositories, use the mirror kept in the cache directory (same as GitRepository)
+			repoPath = gitrepo.MirrorPath(config.GetCacheDir(), config.Repository)
 		} else {
 			// For local repositories, use repository path directly
 			repoPath = config.Repository
@@ -61,10 +62,12 @@ Provides insights into test execution, concurrency compliance, and branch timeli
 			return fmt.Errorf("repository path does not exist: %s", repoPath)
 		}
 
-		// Check if it's a git repository
+		// Check if it's a git repository, mirrors are bare repositories
 		gitDir := filepath.Join(repoPath, ".git")
 		if _, err := os.Stat(gitDir); os.IsNotExist(err) {
-			return fmt.Errorf("not a git repository: %s", repoPath)
+			if _, err := os.Stat(filepath.Join(repoPath, "HEAD")); os.IsNotExist(err) {
+				return fmt.Errorf("not a git repository: %s", repoPath)
+			}
 		}
 
 		if checkConcurrency {
@@ -140,11 +143,16 @@ func showBranchesWithTestResults(repoPath string) {
 
 		for _, result := range results {
 			actual := "❌ FAILED"
-			if result.Success {
+			if result.Success && result.Flaky {
+				actual = fmt.Sprintf("⚠️ FLAKY (passed on attempt %d)", result.Attempt)
+			} else if result.Success {
 				actual = "✅ PASSED"
 			} else if result.TimedOut {
 				actual = "⏰ TIMEOUT"
 			}
+			if !result.Success && result.FailedStep != "" {
+				actual += fmt.Sprintf(" at step %s", result.FailedStep)
+			}
 
 			// Get commit message
 			commitMessage := getCommitMessage(repoPath, result.Commit)
@@ -152,8 +160,8 @@ func showBranchesWithTestResults(repoPath string) {
 			// Build log file path - need to get config to determine log directory
 			config, err := readConfig(configPath)
 			logPath := result.LogFile // fallback to just filename
-			if err == nil && config.LogDir != "" && config.RepoName != "" {
-				logPath = filepath.Join(config.LogDir, config.RepoName, result.LogFile)
+			if err == nil {
+				logPath = filepath.Join(config.GetLogsDir(result.Branch, result.Commit), result.LogFile)
 			}
 
 			duration := result.EndTime.Sub(result.StartTime)
@@ -235,7 +243,7 @@ func showHomeciState(repoPath string) {
 
 	// Try to read config to get state directory
 	config, err := readConfig(configPath)
-	if err != nil || config.StateDir == "" || config.RepoName == "" {
+	if err != nil {
 		// Fallback to old location
 		stateFile := filepath.Join(repoPath, ".home-ci", "state.json")
 		if _, err := os.Stat(stateFile); os.IsNotExist(err) {
@@ -252,7 +260,7 @@ func showHomeciState(repoPath string) {
 	}
 
 	// Use new architecture location
-	stateFile := filepath.Join(config.StateDir, config.RepoName+".json")
+	stateFile := filepath.Join(config.GetStateDir(), config.RepoName+".json")
 	if _, err := os.Stat(stateFile); os.IsNotExist(err) {
 		fmt.Println("No state file found")
 		return
@@ -265,16 +273,6 @@ func showHomeciState(repoPath string) {
 	}
 }
 
-// Config represents the home-ci configuration structure
-type Config struct {
-	Repository        string `yaml:"repository"`
-	RepoName          string `yaml:"repo_name"`
-	MaxConcurrentRuns int    `yaml:"max_concurrent_runs"`
-	StateDir          string `yaml:"state_dir"`
-	LogDir            string `yaml:"log_dir"`
-	CacheDir          string `yaml:"cache_dir"`
-}
-
 // TestResult represents a test execution result
 type TestResult struct {
 	Branch                    string        `json:"branch"`
@@ -292,6 +290,9 @@ type TestResult struct {
 	ErrorMessage              string        `json:"error_message,omitempty"`
 	CleanupErrorMessage       string        `json:"cleanup_error_message,omitempty"`
 	GitHubActionsErrorMessage string        `json:"github_actions_error_message,omitempty"`
+	Attempt                   int           `json:"attempt"`
+	Flaky                     bool          `json:"flaky"`
+	FailedStep                string        `json:"failed_step,omitempty"`
 }
 
 // checkConcurrencyCompliance verifies that max_concurrent_runs was respected
@@ -347,49 +348,30 @@ func checkConcurrencyCompliance(repoPath, configPath string) {
 	}
 }
 
-// readConfig reads and parses the home-ci configuration file
-func readConfig(configPath string) (*Config, error) {
-	data, err := os.ReadFile(configPath)
+// readConfig reads the home-ci configuration file with its defaults, so that the
+// directories are derived from work_dir the same way as the daemon does
+func readConfig(configPath string) (*config.Config, error) {
+	cfg, err := config.Load(configPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read config file: %w", err)
-	}
-
-	var config Config
-	if err := yaml.Unmarshal(data, &config); err != nil {
-		return nil, fmt.Errorf("failed to parse config: %w", err)
+		return nil, err
 	}
-
-	return &config, nil
+	return &cfg, nil
 }
 
 // readTestResults reads all test result JSON files from the new architecture directories
 func readTestResults(repoPath string) ([]TestResult, error) {
 	// Try to read config to get repo name
 	config, err := readConfig(configPath)
-	if err != nil || config.RepoName == "" {
+	if err != nil {
 		// Fallback to old location
 		return readTestResultsOld(repoPath)
 	}
 
-	// The actual test results are stored in the global /tmp/home-ci/{repo-name}/ directory structure
-	// Look for pattern: /tmp/home-ci/{repo-name}/{branch}_{commit}/logs/run.json
-	globalRepoDir := filepath.Join("/tmp/home-ci", config.RepoName)
-
-	// First, try to find subdirectories that match {branch}_{commit} pattern
-	pattern := filepath.Join(globalRepoDir, "*", "logs", "run.json")
+	// The test results are stored in the workspaces: <work_dir>/{repo-name}/{branch}_{commit}/logs/run.json
+	pattern := filepath.Join(config.WorkDir, config.RepoName, "*", "logs", "run.json")
 	files, err := filepath.Glob(pattern)
 	if err != nil || len(files) == 0 {
-		// If global location fails, try configured log directory
-		if config.LogDir != "" {
-			resultsDir := filepath.Join(config.LogDir, config.RepoName)
-			files, err = filepath.Glob(filepath.Join(resultsDir, "*.json"))
-			if err != nil || len(files) == 0 {
-				// Finally, try fallback
-				return readTestResultsOld(repoPath)
-			}
-		} else {
-			return readTestResultsOld(repoPath)
-		}
+		return readTestResultsOld(repoPath)
 	}
 
 	var results []TestResult
@@ -142,7 +142,7 @@ func (th *E2ETestHarness) loadTestExpectations() (*TestExpectationConfig, error)
 	return &config, nil
 }
 
-// getCacheLocalConfig returns config for cache-local test (fetchRemote: false)
+// getCacheLocalConfig returns config for cache-local test
 func (th *E2ETestHarness) getCacheLocalConfig() string {
 	return `repository: ` + th.testRepoPath + `
 check_interval: 5s
@@ -151,7 +151,6 @@ max_concurrent_runs: 2
 options: ""
 recent_commits_within: 240h
 test_timeout: 30s
-fetch_remote: false
 keep_time: 0
 cleanup:
   after_e2e: true
@@ -164,7 +163,7 @@ github_actions_dispatch:
 `
 }
 
-// getCacheRemoteConfig returns config for cache-remote test (fetchRemote: true)
+// getCacheRemoteConfig returns config for cache-remote test
 func (th *E2ETestHarness) getCacheRemoteConfig() string {
 	return `repository: ` + th.testRepoPath + `
 check_interval: 5s
@@ -173,7 +172,6 @@ max_concurrent_runs: 2
 options: ""
 recent_commits_within: 240h
 test_timeout: 30s
-fetch_remote: true
 keep_time: 0
 cleanup:
   after_e2e: true
@@ -0,0 +1,159 @@
+// Package api serves the runs of the daemon over HTTP.
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/k8s-school/home-ci/internal/config"
+	"github.com/k8s-school/home-ci/internal/logstream"
+	"github.com/k8s-school/home-ci/internal/runner"
+)
+
+// Run is a running test, as listed by the API
+type Run struct {
+	ID        string    `json:"id"`
+	Branch    string    `json:"branch"`
+	Commit    string    `json:"commit"`
+	StartTime time.Time `json:"start_time"`
+}
+
+// Server is the HTTP API of the daemon
+type Server struct {
+	config       *config.Config
+	stateManager runner.StateManager
+	token        string // Token of the clients, none required when empty
+}
+
+// NewServer creates the API serving the runs tracked by stateManager
+func NewServer(cfg *config.Config, stateManager runner.StateManager, token string) *Server {
+	return &Server{config: cfg, stateManager: stateManager, token: token}
+}
+
+// Handler returns the HTTP handler of the API
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/runs", s.handleRuns)
+	mux.HandleFunc("GET /api/v1/runs/{run}/log", s.handleLog)
+	return s.authenticate(mux)
+}
+
+// Serve runs the API on listener until ctx is done
+func (s *Server) Serve(ctx context.Context, listener net.Listener) error {
+	server := &http.Server{
+		Handler:     s.Handler(),
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+
+	slog.Info("API listening", "address", listener.Addr().String())
+	if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("API failed: %w", err)
+	}
+	return nil
+}
+
+// authenticate rejects the requests without the token of the clients
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.token != "" {
+			token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+				http.Error(w, "invalid token", http.StatusUnauthorized)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handleRuns lists the running tests
+func (s *Server) handleRuns(w http.ResponseWriter, r *http.Request) {
+	runs := []Run{}
+	for _, test := range s.stateManager.GetRunningTests() {
+		runs = append(runs, Run{
+			ID:        s.config.GetRunID(test.Branch, test.Commit),
+			Branch:    test.Branch,
+			Commit:    test.Commit,
+			StartTime: test.StartTime,
+		})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(runs); err != nil {
+		slog.Error("Failed to send response", "error", err)
+	}
+}
+
+// handleLog streams the log of a run as server-sent events, one event per line.
+// The event ID is the log offset after the line, so that a client reconnecting
+// with Last-Event-ID resumes where it stopped. The stream follows the log until
+// the run is over, unless follow=false, and ends with an "end" event.
+func (s *Server) handleLog(w http.ResponseWriter, r *http.Request) {
+	runID := r.PathValue("run")
+	path, err := logstream.RunLogPath(s.config, runID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var offset int64
+	if lastID := r.Header.Get("Last-Event-ID"); lastID != "" {
+		if offset, err = strconv.ParseInt(lastID, 10, 64); err != nil || offset < 0 {
+			http.Error(w, "invalid Last-Event-ID", http.StatusBadRequest)
+			return
+		}
+	}
+
+	running := func() bool {
+		return logstream.IsRunning(s.config, s.stateManager.GetRunningTests(), runID)
+	}
+	if _, err := os.Stat(path); err != nil && !running() {
+		http.Error(w, fmt.Sprintf("no log for run %s", runID), http.StatusNotFound)
+		return
+	}
+	if r.URL.Query().Get("follow") == "false" {
+		running = nil
+	}
+
+	flusher, _ := w.(http.Flusher)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	if flusher != nil {
+		flusher.Flush()
+	}
+
+	err = logstream.Follow(r.Context(), path, offset, running, func(line logstream.Line) error {
+		data, err := json.Marshal(line)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "id: %d\ndata: %s\n\n", line.Offset, data); err != nil {
+			return err
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return nil
+	})
+	if err != nil {
+		if r.Context().Err() == nil {
+			slog.Debug("Log stream failed", "run", runID, "error", err)
+		}
+		return
+	}
+	fmt.Fprintf(w, "event: end\ndata: {}\n\n")
+}
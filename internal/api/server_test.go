@@ -0,0 +1,116 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/k8s-school/home-ci/internal/config"
+	"github.com/k8s-school/home-ci/internal/runner"
+	"github.com/k8s-school/home-ci/internal/state"
+)
+
+func TestLogStream(t *testing.T) {
+	cfg := &config.Config{RepoName: "test-repo", WorkDir: t.TempDir()}
+	branch, commit := "feature/login", "0123456789abcdef"
+	logsDir := cfg.GetLogsDir(branch, commit)
+	if err := os.MkdirAll(logsDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(logsDir, "run.log"), []byte("setup\ntest passed\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	stateManager := state.NewStateManager(cfg.GetStateDir(), cfg.RepoName)
+	stateManager.AddRunningTest(runner.RunningTest{Branch: branch, Commit: commit, StartTime: time.Now()})
+	server := httptest.NewServer(NewServer(cfg, stateManager, "").Handler())
+	defer server.Close()
+
+	// The running tests are listed with their run ID
+	resp, err := http.Get(server.URL + "/api/v1/runs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var runs []Run
+	json.NewDecoder(resp.Body).Decode(&runs)
+	resp.Body.Close()
+	if len(runs) != 1 || runs[0].ID != "feature_login_01234567" {
+		t.Fatalf("unexpected runs %+v", runs)
+	}
+
+	// The stream ends once the run is over
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		stateManager.RemoveRunningTest(branch, commit)
+	}()
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/api/v1/runs/feature_login_01234567/log", nil)
+	req.Header.Set("Last-Event-ID", "6")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	stream := string(body)
+	if strings.Contains(stream, `"text":"setup"`) {
+		t.Errorf("the lines before Last-Event-ID must be skipped:\n%s", stream)
+	}
+	if !strings.Contains(stream, "id: 18\ndata: {") || !strings.Contains(stream, `"text":"test passed"`) {
+		t.Errorf("missing log line event:\n%s", stream)
+	}
+	if !strings.HasSuffix(stream, "event: end\ndata: {}\n\n") {
+		t.Errorf("missing end event:\n%s", stream)
+	}
+
+	// Unknown and invalid runs
+	for path, status := range map[string]int{
+		"/api/v1/runs/main_fedcba98/log": http.StatusNotFound,
+		"/api/v1/runs/../log":            http.StatusNotFound,
+		"/api/v1/runs/..%2Fetc/log":      http.StatusBadRequest,
+	} {
+		resp, err := http.Get(server.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != status {
+			t.Errorf("GET %s = %d, want %d", path, resp.StatusCode, status)
+		}
+	}
+}
+
+func TestAuthentication(t *testing.T) {
+	cfg := &config.Config{RepoName: "test-repo", WorkDir: t.TempDir()}
+	stateManager := state.NewStateManager(cfg.GetStateDir(), cfg.RepoName)
+	server := httptest.NewServer(NewServer(cfg, stateManager, "secret").Handler())
+	defer server.Close()
+
+	for token, status := range map[string]int{
+		"":       http.StatusUnauthorized,
+		"wrong":  http.StatusUnauthorized,
+		"secret": http.StatusOK,
+	} {
+		req, _ := http.NewRequest(http.MethodGet, server.URL+"/api/v1/runs", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != status {
+			t.Errorf("GET /api/v1/runs with token %q = %d, want %d", token, resp.StatusCode, status)
+		}
+	}
+}
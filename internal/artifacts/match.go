@@ -0,0 +1,56 @@
+package artifacts
+
+import (
+	"path"
+	"strings"
+)
+
+// matchAny reports whether a slash-separated path matches one of the patterns
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if Match(pattern, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Match reports whether a slash-separated path matches a glob pattern.
+// Patterns use the path.Match syntax, plus '**' segments matching any number of directories.
+// A pattern matching a directory matches all the files below it.
+func Match(pattern, name string) bool {
+	pattern = strings.TrimPrefix(strings.TrimSuffix(pattern, "/"), "./")
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+// matchSegments matches path segments, a pattern fully consumed before the path matches a parent directory
+func matchSegments(pattern, name []string) bool {
+	if len(pattern) == 0 {
+		return true
+	}
+	if len(name) == 0 {
+		// Only trailing '**' segments can match nothing
+		for _, segment := range pattern {
+			if segment != "**" {
+				return false
+			}
+		}
+		return true
+	}
+
+	if pattern[0] == "**" {
+		// Skip any number of directories
+		for i := 0; i <= len(name); i++ {
+			if matchSegments(pattern[1:], name[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+
+	matched, err := path.Match(pattern[0], name[0])
+	if err != nil || !matched {
+		return false
+	}
+	return matchSegments(pattern[1:], name[1:])
+}
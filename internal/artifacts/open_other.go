@@ -0,0 +1,6 @@
+//go:build !unix
+
+package artifacts
+
+// openFlags are not supported on this platform, the file type is still checked once opened
+const openFlags = 0
@@ -0,0 +1,8 @@
+//go:build unix
+
+package artifacts
+
+import "syscall"
+
+// openFlags make OpenRegular refuse a symbolic link, and not block on a FIFO
+const openFlags = syscall.O_NOFOLLOW | syscall.O_NONBLOCK
@@ -0,0 +1,274 @@
+package artifacts
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// File is an artifact stored for a run, its path is relative to the run directory
+type File struct {
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+}
+
+// Run summarizes the artifacts stored for a run
+type Run struct {
+	ID      string    `json:"id"`
+	Files   int       `json:"files"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+// Store keeps the artifacts of the runs of a repository, one directory per run.
+// It is independent from the workspaces, so that artifacts outlive them.
+type Store struct {
+	dir string
+}
+
+// NewStore creates a store rooted at dir
+func NewStore(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// Dir returns the root directory of the store
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+// RunDir returns the directory of the artifacts of a run
+func (s *Store) RunDir(runID string) string {
+	return filepath.Join(s.dir, runID)
+}
+
+// RemoveRun removes the stored artifacts of a run
+func (s *Store) RemoveRun(runID string) error {
+	if err := os.RemoveAll(s.RunDir(runID)); err != nil {
+		return fmt.Errorf("failed to remove artifacts of run %s: %w", runID, err)
+	}
+	return nil
+}
+
+// FindFiles returns the files of dir matching the glob patterns, as slash-separated relative paths.
+// A pattern matching a directory matches all the files below it, the .git directory is skipped.
+func FindFiles(dir string, patterns []string) ([]string, error) {
+	var found []string
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if entry.IsDir() {
+			if rel == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if entry.Type().IsRegular() && matchAny(patterns, rel) {
+			found = append(found, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return found, fmt.Errorf("failed to search files in %s: %w", dir, err)
+	}
+	return found, nil
+}
+
+// Collect copies the files of srcDir matching the glob patterns to the run directory
+func (s *Store) Collect(runID, srcDir string, patterns []string) ([]File, error) {
+	found, err := FindFiles(srcDir, patterns)
+
+	var collected []File
+	for _, rel := range found {
+		size, copyErr := s.copyFile(filepath.Join(srcDir, filepath.FromSlash(rel)), filepath.Join(s.RunDir(runID), filepath.FromSlash(rel)))
+		if copyErr != nil {
+			return collected, fmt.Errorf("failed to collect artifacts from %s: %w", srcDir, copyErr)
+		}
+		collected = append(collected, File{Path: rel, Size: size})
+	}
+
+	return collected, err
+}
+
+// AddFile copies a single file to the run directory under the given name
+func (s *Store) AddFile(runID, src, name string) (File, error) {
+	size, err := s.copyFile(src, filepath.Join(s.RunDir(runID), name))
+	if err != nil {
+		return File{}, err
+	}
+	return File{Path: filepath.ToSlash(name), Size: size}, nil
+}
+
+// OpenRegular opens the regular file at path for reading. A symbolic link or any
+// other kind of file is refused, the type is checked on the opened file so that a
+// file swapped by a process of the run cannot make the daemon read another one.
+func OpenRegular(path string) (*os.File, error) {
+	file, err := os.OpenFile(path, os.O_RDONLY|openFlags, 0)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if !info.Mode().IsRegular() {
+		file.Close()
+		return nil, fmt.Errorf("%s is not a regular file", path)
+	}
+	return file, nil
+}
+
+// copyFile copies src to dst, creating the parent directories. src must be a regular file.
+func (s *Store) copyFile(src, dst string) (int64, error) {
+	in, err := OpenRegular(src)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open %s: %w", src, err)
+	}
+	defer in.Close()
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return 0, fmt.Errorf("failed to create artifact directory: %w", err)
+	}
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create artifact %s: %w", dst, err)
+	}
+	defer out.Close()
+
+	size, err := io.Copy(out, in)
+	if err != nil {
+		return size, fmt.Errorf("failed to copy %s: %w", src, err)
+	}
+	return size, nil
+}
+
+// Runs lists the runs having artifacts, most recent first
+func (s *Store) Runs() ([]Run, error) {
+	entries, err := os.ReadDir(s.dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read artifact store %s: %w", s.dir, err)
+	}
+
+	var runs []Run
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files, err := s.Files(entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		run := Run{ID: entry.Name(), Files: len(files), ModTime: info.ModTime()}
+		for _, file := range files {
+			run.Size += file.Size
+		}
+		runs = append(runs, run)
+	}
+
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[i].ModTime.After(runs[j].ModTime)
+	})
+	return runs, nil
+}
+
+// Files lists the artifacts of a run
+func (s *Store) Files(runID string) ([]File, error) {
+	runDir, err := s.safeJoin(runID)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []File
+	err = filepath.WalkDir(runDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(runDir, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, File{Path: filepath.ToSlash(rel), Size: info.Size()})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list artifacts of run %s: %w", runID, err)
+	}
+
+	return files, nil
+}
+
+// Path returns the location of an artifact, rejecting paths outside of the run directory
+func (s *Store) Path(runID, name string) (string, error) {
+	path, err := s.safeJoin(runID, name)
+	if err != nil {
+		return "", err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("artifact %s not found for run %s", name, runID)
+	}
+	if !info.Mode().IsRegular() {
+		return "", fmt.Errorf("artifact %s of run %s is not a file", name, runID)
+	}
+	return path, nil
+}
+
+// safeJoin joins elements to the store directory, and checks the result stays inside it
+func (s *Store) safeJoin(elements ...string) (string, error) {
+	path := filepath.Join(append([]string{s.dir}, elements...)...)
+	rel, err := filepath.Rel(s.dir, path)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid artifact path %s", filepath.Join(elements...))
+	}
+	return path, nil
+}
+
+// Prune removes the runs whose artifacts are older than the retention, it returns the number of removed runs
+func (s *Store) Prune(retention time.Duration) (int, error) {
+	runs, err := s.Runs()
+	if err != nil {
+		return 0, err
+	}
+
+	cutoff := time.Now().Add(-retention)
+	removed := 0
+	for _, run := range runs {
+		if !run.ModTime.Before(cutoff) {
+			continue
+		}
+		if err := os.RemoveAll(s.RunDir(run.ID)); err != nil {
+			return removed, fmt.Errorf("failed to remove artifacts of run %s: %w", run.ID, err)
+		}
+		removed++
+	}
+	return removed, nil
+}
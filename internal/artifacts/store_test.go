@@ -0,0 +1,149 @@
+package artifacts
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{"coverage.out", "coverage.out", true},
+		{"*.xml", "report.xml", true},
+		{"*.xml", "reports/report.xml", false},
+		{"**/*.xml", "reports/unit/report.xml", true},
+		{"**/*.xml", "report.xml", true},
+		{"dumps", "dumps/pods/api.log", true},
+		{"./dumps/", "dumps/kubectl.txt", true},
+		{"e2e/**/logs/*.log", "e2e/a/b/logs/pod.log", true},
+		{"e2e/**/logs/*.log", "e2e/logs/pod.log", true},
+		{"e2e/**/logs/*.log", "other/logs/pod.log", false},
+	}
+
+	for _, tt := range tests {
+		if got := Match(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("Match(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestStoreCollectAndList(t *testing.T) {
+	src := t.TempDir()
+	files := map[string]string{
+		"coverage.out":           "coverage",
+		"dumps/pods/api.log":     "api logs",
+		"dumps/kubectl.txt":      "kubectl",
+		"src/main.go":            "package main",
+		".git/config":            "[core]",
+		"reports/unit/junit.xml": "<testsuite/>",
+	}
+	for name, content := range files {
+		path := filepath.Join(src, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	store := NewStore(t.TempDir())
+	collected, err := store.Collect("main_1a2b3c4d", src, []string{"coverage.out", "dumps", "**/*.xml", "**/config"})
+	if err != nil {
+		t.Fatalf("Collect() failed: %v", err)
+	}
+	if len(collected) != 4 {
+		t.Fatalf("expected 4 collected files, got %+v", collected)
+	}
+
+	listed, err := store.Files("main_1a2b3c4d")
+	if err != nil || len(listed) != 4 {
+		t.Fatalf("Files() = %+v, %v", listed, err)
+	}
+
+	runs, err := store.Runs()
+	if err != nil || len(runs) != 1 || runs[0].Files != 4 {
+		t.Fatalf("Runs() = %+v, %v", runs, err)
+	}
+
+	path, err := store.Path("main_1a2b3c4d", "dumps/pods/api.log")
+	if err != nil {
+		t.Fatalf("Path() failed: %v", err)
+	}
+	if content, _ := os.ReadFile(path); string(content) != "api logs" {
+		t.Errorf("unexpected artifact content %q", content)
+	}
+
+	for _, name := range []string{"../../etc/passwd", "dumps", "missing.txt"} {
+		if _, err := store.Path("main_1a2b3c4d", name); err == nil {
+			t.Errorf("Path(%q) should fail", name)
+		}
+	}
+	if _, err := store.Files(".."); err == nil {
+		t.Error("Files(\"..\") should fail")
+	}
+
+	// A new attempt of the run starts from an empty directory
+	if err := store.RemoveRun("main_1a2b3c4d"); err != nil {
+		t.Fatalf("RemoveRun() failed: %v", err)
+	}
+	if runs, err := store.Runs(); err != nil || len(runs) != 0 {
+		t.Errorf("Runs() after RemoveRun() = %+v, %v", runs, err)
+	}
+}
+
+func TestStorePrune(t *testing.T) {
+	store := NewStore(t.TempDir())
+	src := filepath.Join(t.TempDir(), "run.log")
+	if err := os.WriteFile(src, []byte("log"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, run := range []string{"old_11111111", "new_22222222"} {
+		if _, err := store.AddFile(run, src, "logs/run.log"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	old := time.Now().Add(-48 * time.Hour)
+	if err := os.Chtimes(store.RunDir("old_11111111"), old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	removed, err := store.Prune(24 * time.Hour)
+	if err != nil || removed != 1 {
+		t.Fatalf("Prune() = %d, %v", removed, err)
+	}
+	if _, err := os.Stat(store.RunDir("new_22222222")); err != nil {
+		t.Errorf("recent run should be kept: %v", err)
+	}
+}
+
+func TestStoreAddFileSymlink(t *testing.T) {
+	src := t.TempDir()
+	secret := filepath.Join(src, "secret")
+	if err := os.WriteFile(secret, []byte("secret"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// A file swapped for a symbolic link after it was listed is not followed
+	link := filepath.Join(src, "report.txt")
+	if err := os.Symlink(secret, link); err != nil {
+		t.Fatal(err)
+	}
+	store := NewStore(t.TempDir())
+	if _, err := store.AddFile("main_1a2b3c4d", link, "report.txt"); err == nil {
+		t.Error("AddFile() of a symbolic link should fail")
+	}
+	if _, err := store.Path("main_1a2b3c4d", "report.txt"); err == nil {
+		t.Error("the target of the symbolic link was stored")
+	}
+
+	if _, err := OpenRegular(src); err == nil {
+		t.Error("OpenRegular() of a directory should fail")
+	}
+}
@@ -0,0 +1,230 @@
+package cgroup
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MountPoint is where the cgroup v2 hierarchy is mounted
+const MountPoint = "/sys/fs/cgroup"
+
+// daemonLeaf is the child cgroup the daemon moves to when it manages its own cgroup
+const daemonLeaf = "daemon"
+
+// cpuPeriod is the cpu.max period, in microseconds
+const cpuPeriod = 100000
+
+// minCPUQuota is the smallest cpu.max quota accepted by the kernel, in microseconds
+const minCPUQuota = 1000
+
+// Limits of a cgroup, zero values are unlimited
+type Limits struct {
+	MemoryMax int64   // Bytes
+	CPUs      float64 // Number of CPUs
+	PidsMax   int
+}
+
+// controllers returns the cgroup controllers needed to enforce the limits
+func (l Limits) controllers() []string {
+	var controllers []string
+	if l.MemoryMax > 0 {
+		controllers = append(controllers, "memory")
+	}
+	if l.CPUs > 0 {
+		controllers = append(controllers, "cpu")
+	}
+	if l.PidsMax > 0 {
+		controllers = append(controllers, "pids")
+	}
+	return controllers
+}
+
+// Manager creates the cgroups of the runs below a delegated cgroup v2 directory
+type Manager struct {
+	dir string
+}
+
+// NewManager prepares dir to hold cgroups enforcing the limits. When dir is empty,
+// the cgroup of the current process is used. cgroup v2 does not allow processes in
+// a cgroup distributing controllers to its children, so when the current process
+// is in dir, it first moves to a "daemon" child.
+func NewManager(dir string, limits Limits) (*Manager, error) {
+	own, err := ownCgroup()
+	if dir == "" {
+		if err != nil {
+			return nil, err
+		}
+		dir = own
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "cgroup.controllers")); err != nil {
+		return nil, fmt.Errorf("%s is not a cgroup v2 directory: %w", dir, err)
+	}
+
+	if own != "" && filepath.Clean(own) == filepath.Clean(dir) {
+		leaf := filepath.Join(dir, daemonLeaf)
+		if err := os.MkdirAll(leaf, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create cgroup %s: %w", leaf, err)
+		}
+		if err := writeFile(leaf, "cgroup.procs", strconv.Itoa(os.Getpid())); err != nil {
+			return nil, fmt.Errorf("failed to move the daemon to %s: %w", leaf, err)
+		}
+	}
+
+	controllers := limits.controllers()
+	if len(controllers) > 0 {
+		enable := "+" + strings.Join(controllers, " +")
+		if err := writeFile(dir, "cgroup.subtree_control", enable); err != nil {
+			return nil, fmt.Errorf("failed to enable the %s controllers in %s: %w", strings.Join(controllers, ", "), dir, err)
+		}
+	}
+
+	return &Manager{dir: dir}, nil
+}
+
+// Dir returns the directory holding the cgroups of the runs
+func (m *Manager) Dir() string {
+	return m.dir
+}
+
+// Create creates a cgroup enforcing the limits. A leftover cgroup with the same
+// name, from an interrupted run, is removed first.
+func (m *Manager) Create(name string, limits Limits) (*Group, error) {
+	group := &Group{path: filepath.Join(m.dir, name)}
+	if _, err := os.Stat(group.path); err == nil {
+		if err := group.Remove(); err != nil {
+			return nil, err
+		}
+	}
+	if err := os.Mkdir(group.path, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create cgroup %s: %w", group.path, err)
+	}
+
+	settings := map[string]string{}
+	if limits.MemoryMax > 0 {
+		settings["memory.max"] = strconv.FormatInt(limits.MemoryMax, 10)
+		// Without swap, exceeding the limit triggers the OOM killer instead of swapping
+		if _, err := os.Stat(filepath.Join(group.path, "memory.swap.max")); err == nil {
+			settings["memory.swap.max"] = "0"
+		}
+	}
+	if limits.CPUs > 0 {
+		settings["cpu.max"] = cpuMax(limits.CPUs)
+	}
+	if limits.PidsMax > 0 {
+		settings["pids.max"] = strconv.Itoa(limits.PidsMax)
+	}
+	for file, value := range settings {
+		if err := writeFile(group.path, file, value); err != nil {
+			group.Remove()
+			return nil, fmt.Errorf("failed to set %s of cgroup %s: %w", file, group.path, err)
+		}
+	}
+
+	return group, nil
+}
+
+// Group is a cgroup holding the processes of a run
+type Group struct {
+	path string
+	dir  *os.File // Open directory used to start processes in the cgroup
+}
+
+// Path returns the directory of the cgroup
+func (g *Group) Path() string {
+	return g.path
+}
+
+// OOMKills returns the number of processes of the cgroup killed by the OOM killer
+func (g *Group) OOMKills() (int, error) {
+	file, err := os.Open(filepath.Join(g.path, "memory.events"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[0] == "oom_kill" {
+			return strconv.Atoi(fields[1])
+		}
+	}
+	return 0, scanner.Err()
+}
+
+// Remove kills the processes left in the cgroup and removes it
+func (g *Group) Remove() error {
+	if g.dir != nil {
+		g.dir.Close()
+		g.dir = nil
+	}
+
+	// cgroup.kill is only available from Linux 5.14
+	if _, err := os.Stat(filepath.Join(g.path, "cgroup.kill")); err == nil {
+		writeFile(g.path, "cgroup.kill", "1")
+	}
+
+	// The cgroup can only be removed once its processes are gone
+	var err error
+	for i := 0; i < 50; i++ {
+		if err = removeCgroupDir(g.path); err == nil || errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return fmt.Errorf("failed to remove cgroup %s: %w", g.path, err)
+}
+
+// removeCgroupDir removes a cgroup directory. The interface files of a cgroup go
+// away with it, files left in a regular directory are removed first.
+func removeCgroupDir(path string) error {
+	if err := os.Remove(path); err == nil || errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if entries, err := os.ReadDir(path); err == nil {
+		for _, entry := range entries {
+			if entry.Type().IsRegular() {
+				os.Remove(filepath.Join(path, entry.Name()))
+			}
+		}
+	}
+	return os.Remove(path)
+}
+
+// ownCgroup returns the cgroup v2 directory of the current process
+func ownCgroup() (string, error) {
+	data, err := os.ReadFile("/proc/self/cgroup")
+	if err != nil {
+		return "", fmt.Errorf("failed to read the cgroup of the process: %w", err)
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if path, ok := strings.CutPrefix(line, "0::"); ok {
+			return filepath.Join(MountPoint, path), nil
+		}
+	}
+	return "", fmt.Errorf("the process is not in a cgroup v2 hierarchy")
+}
+
+// writeFile writes a value to an interface file of a cgroup
+func writeFile(dir, name, value string) error {
+	return os.WriteFile(filepath.Join(dir, name), []byte(value), 0644)
+}
+
+// cpuMax returns the cpu.max value limiting to the number of CPUs
+func cpuMax(cpus float64) string {
+	quota := int64(cpus * cpuPeriod)
+	if quota < minCPUQuota {
+		quota = minCPUQuota
+	}
+	return fmt.Sprintf("%d %d", quota, cpuPeriod)
+}
@@ -0,0 +1,26 @@
+package cgroup
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// Attach makes cmd start directly in the cgroup, so that its children cannot escape the limits
+func (g *Group) Attach(cmd *exec.Cmd) error {
+	if g.dir == nil {
+		dir, err := os.Open(g.path)
+		if err != nil {
+			return fmt.Errorf("failed to open cgroup %s: %w", g.path, err)
+		}
+		g.dir = dir
+	}
+
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.UseCgroupFD = true
+	cmd.SysProcAttr.CgroupFD = int(g.dir.Fd())
+	return nil
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package cgroup
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+// Attach is not supported on platforms without cgroups
+func (g *Group) Attach(cmd *exec.Cmd) error {
+	return fmt.Errorf("cgroups are only supported on Linux")
+}
@@ -0,0 +1,104 @@
+package cgroup
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fakeCgroupDir returns a regular directory looking like a delegated cgroup v2 directory
+func fakeCgroupDir(t *testing.T) string {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "cgroup.controllers"), []byte("cpu memory pids"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func readFile(t *testing.T, path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestManagerCreate(t *testing.T) {
+	dir := fakeCgroupDir(t)
+	limits := Limits{MemoryMax: 512 << 20, CPUs: 1.5, PidsMax: 256}
+
+	manager, err := NewManager(dir, limits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, filepath.Join(dir, "cgroup.subtree_control")); got != "+memory +cpu +pids" {
+		t.Errorf("subtree_control = %q", got)
+	}
+
+	group, err := manager.Create("main_01234567.1", limits)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{
+		"memory.max": "536870912",
+		"cpu.max":    "150000 100000",
+		"pids.max":   "256",
+	}
+	for file, want := range expected {
+		if got := readFile(t, filepath.Join(group.Path(), file)); got != want {
+			t.Errorf("%s = %q, want %q", file, got, want)
+		}
+	}
+
+	// A leftover cgroup of an interrupted run is replaced
+	if _, err := manager.Create("main_01234567.1", Limits{PidsMax: 10}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(group.Path(), "memory.max")); !os.IsNotExist(err) {
+		t.Error("expected the leftover cgroup to be removed")
+	}
+
+	if err := group.Remove(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(group.Path()); !os.IsNotExist(err) {
+		t.Error("expected the cgroup to be removed")
+	}
+}
+
+func TestNewManagerRejectsNonCgroupDir(t *testing.T) {
+	if _, err := NewManager(t.TempDir(), Limits{PidsMax: 10}); err == nil {
+		t.Error("expected an error for a directory that is not a cgroup")
+	}
+}
+
+func TestOOMKills(t *testing.T) {
+	group := &Group{path: t.TempDir()}
+
+	if kills, err := group.OOMKills(); err != nil || kills != 0 {
+		t.Fatalf("OOMKills() without memory.events = %d, %v", kills, err)
+	}
+
+	events := "low 0\nhigh 0\nmax 12\noom 2\noom_kill 2\noom_group_kill 0\n"
+	if err := os.WriteFile(filepath.Join(group.path, "memory.events"), []byte(events), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if kills, err := group.OOMKills(); err != nil || kills != 2 {
+		t.Errorf("OOMKills() = %d, %v, want 2", kills, err)
+	}
+}
+
+func TestCPUMax(t *testing.T) {
+	tests := map[float64]string{
+		1.5:    "150000 100000",
+		0.5:    "50000 100000",
+		0.001:  "1000 100000", // The kernel rejects quotas below 1ms
+		0.0001: "1000 100000",
+	}
+	for cpus, want := range tests {
+		if got := cpuMax(cpus); got != want {
+			t.Errorf("cpuMax(%v) = %q, want %q", cpus, got, want)
+		}
+	}
+}
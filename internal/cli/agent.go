@@ -0,0 +1,99 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	"github.com/k8s-school/home-ci/internal/config"
+	"github.com/k8s-school/home-ci/internal/coordinator"
+	"github.com/k8s-school/home-ci/internal/logging"
+	"github.com/k8s-school/home-ci/internal/runner"
+)
+
+var (
+	agentCoordinator string
+	agentName        string
+	agentLabels      []string
+)
+
+var agentCmd = &cobra.Command{
+	Use:   "agent",
+	Short: "Run the jobs leased from a coordinator",
+	Long: `Run as an agent of a coordinator daemon: lease the queued jobs matching the
+agent labels, run them with the local configuration and report their log and
+result to the coordinator.
+
+Up to max_concurrent_runs jobs run at the same time. On SIGINT or SIGTERM the
+agent stops leasing jobs and waits for the running ones.
+
+Examples:
+  home-ci agent -c /etc/home-ci/agent.yaml --coordinator http://ci.example.org:8480
+
+  # Advertise the labels required by the coordinator rules
+  home-ci agent --coordinator http://ci.example.org:8480 --label linux --label gpu`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		logging.InitLogging(verbose)
+
+		cfg, err := config.Load(configPath)
+		if err != nil {
+			return fmt.Errorf("failed to load config from '%s': %w", configPath, err)
+		}
+
+		// The flags override the agent section of the configuration
+		if agentCoordinator != "" {
+			cfg.Agent.Coordinator = agentCoordinator
+		}
+		if agentName != "" {
+			cfg.Agent.Name = agentName
+		}
+		if len(agentLabels) > 0 {
+			cfg.Agent.Labels = agentLabels
+		}
+		if cfg.Agent.Coordinator == "" {
+			return fmt.Errorf("coordinator URL must be specified using --coordinator or agent.coordinator")
+		}
+		if cfg.Agent.Name == "" {
+			if cfg.Agent.Name, err = os.Hostname(); err != nil {
+				return fmt.Errorf("failed to get host name, use --name: %w", err)
+			}
+		}
+		token := ""
+		if cfg.Agent.TokenFile != "" {
+			if token, err = coordinator.ReadToken(cfg.Agent.TokenFile, configPath); err != nil {
+				return fmt.Errorf("failed to load coordinator token: %w", err)
+			}
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		sigCh := make(chan os.Signal, 1)
+		signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+		go func() {
+			<-sigCh
+			slog.Info("Received shutdown signal, waiting for the running jobs")
+			cancel()
+		}()
+
+		// The test runner has no state manager: the coordinator tracks the runs
+		testRunner, err := runner.NewTestRunner(cfg, configPath, cfg.WorkDir, context.Background(), nil)
+		if err != nil {
+			return err
+		}
+		agent := coordinator.NewAgent(cfg.Agent.Coordinator, cfg.Agent.Name, cfg.Agent.Labels, token, testRunner)
+		agent.Run(ctx, cfg.MaxConcurrentRuns)
+		return nil
+	},
+}
+
+func init() {
+	agentCmd.Flags().StringVar(&agentCoordinator, "coordinator", "", "URL of the coordinator, e.g. http://ci.example.org:8480")
+	agentCmd.Flags().StringVar(&agentName, "name", "", "Agent name reported to the coordinator (default: host name)")
+	agentCmd.Flags().StringArrayVar(&agentLabels, "label", nil, "Label of the agent, can be repeated")
+	RootCmd.AddCommand(agentCmd)
+}
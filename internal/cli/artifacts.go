@@ -0,0 +1,133 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/k8s-school/home-ci/internal/artifacts"
+	"github.com/k8s-school/home-ci/internal/config"
+	"github.com/k8s-school/home-ci/internal/logging"
+)
+
+var artifactsOutput string
+
+var artifactsCmd = &cobra.Command{
+	Use:   "artifacts",
+	Short: "List and download the artifacts of test runs",
+	Long: `List and download the files kept in the artifact store of a repository.
+
+Runs are identified by <branch>_<commit8>, with slashes of the branch
+replaced by underscores, e.g. feature_login_1a2b3c4d.
+
+Examples:
+  home-ci artifacts list -c config.yaml
+  home-ci artifacts list main_1a2b3c4d -c config.yaml
+  home-ci artifacts get main_1a2b3c4d logs/run.log -o run.log -c config.yaml`,
+}
+
+var artifactsListCmd = &cobra.Command{
+	Use:   "list [run-id]",
+	Short: "List the runs having artifacts, or the artifacts of a run",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := loadArtifactStore()
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+		if len(args) == 0 {
+			runs, err := store.Runs()
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(w, "RUN\tFILES\tSIZE\tAGE\n")
+			for _, run := range runs {
+				fmt.Fprintf(w, "%s\t%d\t%d\t%s\n", run.ID, run.Files, run.Size, time.Since(run.ModTime).Round(time.Second))
+			}
+			return w.Flush()
+		}
+
+		files, err := store.Files(args[0])
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "PATH\tSIZE\n")
+		for _, file := range files {
+			fmt.Fprintf(w, "%s\t%d\n", file.Path, file.Size)
+		}
+		return w.Flush()
+	},
+}
+
+var artifactsGetCmd = &cobra.Command{
+	Use:   "get <run-id> <path>",
+	Short: "Download an artifact of a run",
+	Long: `Copy an artifact of a run to a local file, by default named after the
+artifact in the current directory. Use -o - to write it to stdout.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := loadArtifactStore()
+		if err != nil {
+			return err
+		}
+
+		path, err := store.Path(args[0], args[1])
+		if err != nil {
+			return err
+		}
+
+		in, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("failed to open artifact: %w", err)
+		}
+		defer in.Close()
+
+		output := artifactsOutput
+		if output == "" {
+			output = filepath.Base(path)
+		}
+		if output == "-" {
+			_, err := io.Copy(os.Stdout, in)
+			return err
+		}
+
+		out, err := os.Create(output)
+		if err != nil {
+			return fmt.Errorf("failed to create %s: %w", output, err)
+		}
+		defer out.Close()
+
+		if _, err := io.Copy(out, in); err != nil {
+			return fmt.Errorf("failed to write %s: %w", output, err)
+		}
+		fmt.Fprintf(os.Stderr, "Saved %s\n", output)
+		return nil
+	},
+}
+
+// loadArtifactStore opens the artifact store of the configured repository
+func loadArtifactStore() (*artifacts.Store, error) {
+	logging.InitLogging(verbose)
+
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config from '%s': %w", configPath, err)
+	}
+	return artifacts.NewStore(cfg.GetArtifactsDir()), nil
+}
+
+func init() {
+	RootCmd.AddCommand(artifactsCmd)
+	artifactsCmd.AddCommand(artifactsListCmd)
+	artifactsCmd.AddCommand(artifactsGetCmd)
+
+	artifactsGetCmd.Flags().StringVarP(&artifactsOutput, "output", "o", "", "Output file, - for stdout")
+}
@@ -0,0 +1,116 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/k8s-school/home-ci/internal/config"
+	"github.com/k8s-school/home-ci/internal/control"
+	"github.com/k8s-school/home-ci/internal/logging"
+	"github.com/k8s-school/home-ci/internal/utils"
+)
+
+var (
+	triggerBranch string
+	triggerCommit string
+)
+
+var triggerCmd = &cobra.Command{
+	Use:   "trigger",
+	Short: "Queue a test run in the running daemon",
+	Long: `Queue a test run of a branch in the running daemon, at its latest commit or
+at the given commit. The run goes through the scheduler of the daemon, like the
+runs of new commits: it waits for a free slot and for its resources.
+
+Examples:
+  home-ci trigger --branch main -c config.yaml
+  home-ci trigger --branch feature/login --commit 1a2b3c4d -c config.yaml`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := newControlClient()
+		if err != nil {
+			return err
+		}
+		queued, err := client.Trigger(triggerBranch, triggerCommit)
+		if err != nil {
+			return fmt.Errorf("failed to trigger run: %w", err)
+		}
+		fmt.Printf("Queued run %s (branch %s, commit %s)\n", queued.ID, queued.Branch, utils.ShortCommit(queued.Commit))
+		return nil
+	},
+}
+
+var cancelCmd = &cobra.Command{
+	Use:   "cancel <run-id>",
+	Short: "Cancel a queued or running test run of the daemon",
+	Long: `Cancel a test run of the running daemon: its jobs waiting in the queue are
+removed, and its commands are stopped if it is running. A cancelled run is
+recorded with the failure reason "cancelled" and is not retried.
+
+Examples:
+  home-ci cancel main_1a2b3c4d -c config.yaml`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := newControlClient()
+		if err != nil {
+			return err
+		}
+		cancelled, err := client.Cancel(args[0])
+		if err != nil {
+			return fmt.Errorf("failed to cancel run %s: %w", args[0], err)
+		}
+		if cancelled.Dequeued > 0 {
+			fmt.Printf("Removed %d queued job(s) of run %s\n", cancelled.Dequeued, args[0])
+		}
+		if cancelled.Stopped {
+			fmt.Printf("Stopping run %s\n", args[0])
+		}
+		return nil
+	},
+}
+
+var rerunCmd = &cobra.Command{
+	Use:   "rerun <run-id>",
+	Short: "Queue a new run of the commit of a previous run",
+	Long: `Queue in the running daemon a new run of the branch and commit of a previous
+run. The run is found among the running and queued runs, the kept workspaces
+and the artifact store. A new run of a running commit starts once it is over.
+
+Examples:
+  home-ci rerun main_1a2b3c4d -c config.yaml`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := newControlClient()
+		if err != nil {
+			return err
+		}
+		queued, err := client.Rerun(args[0])
+		if err != nil {
+			return fmt.Errorf("failed to rerun %s: %w", args[0], err)
+		}
+		fmt.Printf("Queued run %s (branch %s, commit %s)\n", queued.ID, queued.Branch, utils.ShortCommit(queued.Commit))
+		return nil
+	},
+}
+
+// newControlClient returns the client of the control socket of the configured daemon
+func newControlClient() (*control.Client, error) {
+	logging.InitLogging(verbose)
+
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config from '%s': %w", configPath, err)
+	}
+	return control.NewClient(cfg.GetControlSocket()), nil
+}
+
+func init() {
+	triggerCmd.Flags().StringVarP(&triggerBranch, "branch", "b", "", "Branch to test (required)")
+	triggerCmd.Flags().StringVar(&triggerCommit, "commit", "", "Commit to test, full or short hash (default: latest commit of the branch)")
+	triggerCmd.MarkFlagRequired("branch")
+
+	RootCmd.AddCommand(triggerCmd)
+	RootCmd.AddCommand(cancelCmd)
+	RootCmd.AddCommand(rerunCmd)
+}
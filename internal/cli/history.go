@@ -0,0 +1,156 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/k8s-school/home-ci/internal/config"
+	"github.com/k8s-school/home-ci/internal/history"
+	"github.com/k8s-school/home-ci/internal/logging"
+	"github.com/k8s-school/home-ci/internal/runner"
+	"github.com/k8s-school/home-ci/internal/utils"
+)
+
+var (
+	historyBranch string
+	historyResult string
+	historyCommit string
+	historySince  string
+	historyUntil  string
+	historyLimit  int
+	historyOutput string
+)
+
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "List the finished test runs",
+	Long: `List the finished test runs of a repository, most recent first, with their
+duration and the step where they failed.
+
+Runs are read from the history file the daemon appends to once all the attempts
+of a run are over, and from the run.json files of the kept workspaces and of the
+artifact store for the runs missing from it, such as manual runs.
+
+--since and --until take a date (2006-01-02), a time (RFC 3339), or a duration
+before now such as 12h or 7d.
+
+Examples:
+  home-ci history --branch main --limit 20 -c config.yaml
+  home-ci history --result failed --since 7d -c config.yaml
+  home-ci history --branch 'release/*' --since 2026-01-01 --until 2026-02-01 -o csv -c config.yaml
+  home-ci history --since 7d --limit 0 -o markdown -c config.yaml > weekly-report.md`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		logging.InitLogging(verbose)
+
+		now := time.Now()
+		filter := history.Filter{Branch: historyBranch, Commit: historyCommit, Limit: historyLimit}
+		switch historyResult {
+		case "", history.ResultPassed, history.ResultFlaky, history.ResultFailed:
+			filter.Result = historyResult
+		default:
+			return fmt.Errorf("invalid result %q, expected passed, flaky or failed", historyResult)
+		}
+		var err error
+		if filter.Since, err = parseTimeFlag(historySince, now); err != nil {
+			return fmt.Errorf("invalid --since: %w", err)
+		}
+		if filter.Until, err = parseTimeFlag(historyUntil, now); err != nil {
+			return fmt.Errorf("invalid --until: %w", err)
+		}
+
+		cfg, err := config.Load(configPath)
+		if err != nil {
+			return fmt.Errorf("failed to load config from '%s': %w", configPath, err)
+		}
+
+		entries, err := runner.LoadHistory(&cfg)
+		if err != nil {
+			return err
+		}
+		entries = filter.Apply(entries)
+
+		switch historyOutput {
+		case "table":
+			return printHistory(entries)
+		case "json":
+			return history.WriteJSON(os.Stdout, entries)
+		case "csv":
+			return history.WriteCSV(os.Stdout, entries)
+		case "markdown", "md":
+			return history.WriteMarkdown(os.Stdout, entries)
+		default:
+			return fmt.Errorf("invalid output format %q, expected table, json, csv or markdown", historyOutput)
+		}
+	},
+}
+
+// printHistory renders the entries as a table followed by their summary
+func printHistory(entries []history.Entry) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "STARTED\tBRANCH\tCOMMIT\tRESULT\tDURATION\tATTEMPTS\tFAILURE\n")
+	for _, entry := range entries {
+		failure := entry.Failure()
+		if failure == "" {
+			failure = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%d\t%s\n", entry.StartTime.Format("2006-01-02 15:04:05"), entry.Branch, utils.ShortCommit(entry.Commit), entry.Result, entry.Duration.Round(time.Second), entry.Attempts, failure)
+
+		// The attempts of a retried run are listed below it
+		for _, attempt := range entry.AttemptResults {
+			result, failure := "passed", attempt.Failure()
+			if !attempt.Success {
+				result = "failed"
+			}
+			if failure == "" {
+				failure = "-"
+			}
+			fmt.Fprintf(w, "%s\t\t\t  #%d %s\t%s\t\t%s\n", attempt.StartTime.Format("2006-01-02 15:04:05"), attempt.Attempt, result, attempt.Duration.Round(time.Second), failure)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Printf("\n%s\n", history.Summarize(entries))
+	return nil
+}
+
+// parseTimeFlag parses a date, an RFC 3339 time or a duration before now, in hours
+// or in days with the d suffix. An empty value returns the zero time.
+func parseTimeFlag(value string, now time.Time) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t, nil
+	}
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n >= 0 {
+			return now.AddDate(0, 0, -n), nil
+		}
+	}
+	if d, err := time.ParseDuration(value); err == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("%q is not a date, a time or a duration", value)
+}
+
+func init() {
+	historyCmd.Flags().StringVarP(&historyBranch, "branch", "b", "", "Branch name or glob pattern, '*' does not match '/'")
+	historyCmd.Flags().StringVar(&historyResult, "result", "", "Result of the runs: passed, flaky or failed")
+	historyCmd.Flags().StringVar(&historyCommit, "commit", "", "Commit hash or prefix")
+	historyCmd.Flags().StringVar(&historySince, "since", "", "Runs started at or after this date, time or duration ago")
+	historyCmd.Flags().StringVar(&historyUntil, "until", "", "Runs started before this date, time or duration ago")
+	historyCmd.Flags().IntVarP(&historyLimit, "limit", "n", 20, "Most recent runs listed, 0 lists all of them")
+	historyCmd.Flags().StringVarP(&historyOutput, "output", "o", "table", "Output format: table, json, csv or markdown")
+	RootCmd.AddCommand(historyCmd)
+}
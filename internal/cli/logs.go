@@ -0,0 +1,103 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/k8s-school/home-ci/internal/config"
+	"github.com/k8s-school/home-ci/internal/logging"
+	"github.com/k8s-school/home-ci/internal/logstream"
+	"github.com/k8s-school/home-ci/internal/state"
+	"github.com/k8s-school/home-ci/internal/utils"
+)
+
+var (
+	logsFollow     bool
+	logsTimestamps bool
+)
+
+var logsCmd = &cobra.Command{
+	Use:   "logs [run-id]",
+	Short: "Show or follow the log of a test run",
+	Long: `Show the run.log of a test run, or list the running tests when no run is given.
+
+Runs are identified by <branch>_<commit8>, with slashes of the branch
+replaced by underscores, e.g. feature_login_1a2b3c4d. The log of a finished
+run is read from the artifact store once its workspace is removed.
+
+With --follow, the new lines are printed as the run writes them, until it ends.
+
+Examples:
+  home-ci logs -c config.yaml
+  home-ci logs -f main_1a2b3c4d -c config.yaml
+  home-ci logs -f -t feature_login_1a2b3c4d -c config.yaml`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		logging.InitLogging(verbose)
+
+		cfg, err := config.Load(configPath)
+		if err != nil {
+			return fmt.Errorf("failed to load config from '%s': %w", configPath, err)
+		}
+
+		stateManager := state.NewStateManager(cfg.GetStateDir(), cfg.RepoName)
+		if err := stateManager.LoadState(); err != nil {
+			return fmt.Errorf("failed to load state: %w", err)
+		}
+
+		if len(args) == 0 {
+			now := time.Now()
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintf(w, "RUN\tBRANCH\tCOMMIT\tELAPSED\n")
+			for _, test := range stateManager.GetRunningTests() {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", cfg.GetRunID(test.Branch, test.Commit), test.Branch, utils.ShortCommit(test.Commit), now.Sub(test.StartTime).Round(time.Second))
+			}
+			return w.Flush()
+		}
+
+		runID := args[0]
+		path, err := logstream.RunLogPath(&cfg, runID)
+		if err != nil {
+			return err
+		}
+
+		// The state is saved by the daemon, it is read again to know when the run ends
+		var running func() bool
+		if logsFollow {
+			running = func() bool {
+				if err := stateManager.LoadState(); err != nil {
+					return false
+				}
+				return logstream.IsRunning(&cfg, stateManager.GetRunningTests(), runID)
+			}
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		defer stop()
+		err = logstream.Follow(ctx, path, 0, running, func(line logstream.Line) error {
+			if logsTimestamps {
+				_, err := fmt.Printf("%s %s\n", line.Time.Format(time.RFC3339), line.Text)
+				return err
+			}
+			_, err := fmt.Println(line.Text)
+			return err
+		})
+		if ctx.Err() != nil {
+			return nil
+		}
+		return err
+	},
+}
+
+func init() {
+	logsCmd.Flags().BoolVarP(&logsFollow, "follow", "f", false, "Follow the log until the run ends")
+	logsCmd.Flags().BoolVarP(&logsTimestamps, "timestamps", "t", false, "Prefix each line with the time it was written")
+	RootCmd.AddCommand(logsCmd)
+}
@@ -0,0 +1,110 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/k8s-school/home-ci/internal/config"
+	"github.com/k8s-school/home-ci/internal/control"
+	"github.com/k8s-school/home-ci/internal/logging"
+	"github.com/k8s-school/home-ci/internal/runner"
+	"github.com/k8s-school/home-ci/internal/state"
+	"github.com/k8s-school/home-ci/internal/utils"
+)
+
+var queueCmd = &cobra.Command{
+	Use:   "queue",
+	Short: "Show running and queued test jobs",
+	Long: `Show the test jobs currently running and the jobs waiting in the queue,
+in the order in which they will be started.
+
+The queue is read from the running daemon through its control socket, or from
+the state file when the daemon is not running.
+
+The effective priority of a waiting job is its base priority (branch rules,
+manual trigger or retry bonus) plus one point per aging interval spent waiting.
+
+Examples:
+  home-ci queue -c /etc/home-ci/config.yaml`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		logging.InitLogging(verbose)
+
+		cfg, err := config.Load(configPath)
+		if err != nil {
+			return fmt.Errorf("failed to load config from '%s': %w", configPath, err)
+		}
+
+		queue, err := control.NewClient(cfg.GetControlSocket()).Queue()
+		if errors.Is(err, control.ErrDaemonNotRunning) {
+			slog.Debug("Daemon not running, reading the queue from the state file", "error", err)
+			queue, err = queueFromState(&cfg)
+		}
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+		fmt.Fprintf(w, "RUNNING\tBRANCH\tCOMMIT\tELAPSED\n")
+		for _, run := range queue.Running {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", run.ID, run.Branch, utils.ShortCommit(run.Commit), now.Sub(run.StartTime).Round(time.Second))
+		}
+		fmt.Fprintf(w, "\n")
+
+		fmt.Fprintf(w, "POSITION\tBRANCH\tCOMMIT\tTRIGGER\tPRIORITY\tWAITING\tRESOURCES\n")
+		for i, job := range queue.Queued {
+			// Aging is uniform, so the saved order is still valid and only priorities need refreshing
+			priority := runner.EffectivePriority(cfg.Scheduling, job.BasePriority, job.QueuedAt, now)
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%d (base %d)\t%s\t%s\n", i+1, job.Branch, utils.ShortCommit(job.Commit), formatTrigger(job), priority, job.BasePriority, now.Sub(job.QueuedAt).Round(time.Second), formatResources(job.Resources))
+		}
+
+		return w.Flush()
+	},
+}
+
+// queueFromState returns the running tests and the queue saved in the state file
+func queueFromState(cfg *config.Config) (control.Queue, error) {
+	stateManager := state.NewStateManager(cfg.GetStateDir(), cfg.RepoName)
+	if err := stateManager.LoadState(); err != nil {
+		return control.Queue{}, fmt.Errorf("failed to load state: %w", err)
+	}
+
+	queue := control.Queue{Queued: stateManager.GetQueuedJobs()}
+	for _, test := range stateManager.GetRunningTests() {
+		queue.Running = append(queue.Running, control.Run{
+			ID:        cfg.GetRunID(test.Branch, test.Commit),
+			Branch:    test.Branch,
+			Commit:    test.Commit,
+			StartTime: test.StartTime,
+		})
+	}
+	return queue, nil
+}
+
+func init() {
+	RootCmd.AddCommand(queueCmd)
+}
+
+// formatResources renders the resources required by a job
+func formatResources(resources []string) string {
+	if len(resources) == 0 {
+		return "-"
+	}
+	return strings.Join(resources, ",")
+}
+
+// formatTrigger renders the trigger of a job, with the attempt number of retries
+func formatTrigger(job runner.QueuedJob) string {
+	if job.Attempt > 1 {
+		return fmt.Sprintf("%s #%d", job.Trigger, job.Attempt)
+	}
+	return job.Trigger
+}
@@ -2,17 +2,22 @@ package cli
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
 
 	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport"
 	"github.com/spf13/cobra"
 
 	"github.com/k8s-school/home-ci/internal/config"
+	"github.com/k8s-school/home-ci/internal/control"
+	"github.com/k8s-school/home-ci/internal/gitrepo"
 	"github.com/k8s-school/home-ci/internal/logging"
 	"github.com/k8s-school/home-ci/internal/runner"
+	"github.com/k8s-school/home-ci/internal/state"
 	"github.com/k8s-school/home-ci/internal/utils"
 )
 
@@ -29,6 +34,9 @@ var runCmd = &cobra.Command{
 This command allows you to run tests on-demand for any branch in your repository.
 If no commit is specified, tests will run against the latest commit of the branch.
 
+When the daemon of the repository is running, the run is queued in its scheduler,
+like with home-ci trigger. Otherwise it runs in this process.
+
 Results and logs will be stored in the configured log directory under:
 <log-dir>/<repo-name>/tests/
 
@@ -60,12 +68,27 @@ Examples:
 			return fmt.Errorf("failed to load config from '%s': %w", configPath, err)
 		}
 
+		// With a running daemon, the run goes through its scheduler
+		queued, err := control.NewClient(cfg.GetControlSocket()).Trigger(runBranch, runCommit)
+		switch {
+		case err == nil:
+			fmt.Printf("Queued run %s in the daemon (branch %s, commit %s), follow it with 'home-ci logs -f %s'\n", queued.ID, queued.Branch, utils.ShortCommit(queued.Commit), queued.ID)
+			return nil
+		case !errors.Is(err, control.ErrDaemonNotRunning):
+			return fmt.Errorf("failed to queue the run in the daemon: %w", err)
+		}
+
 		// Determine if commit was explicitly specified
 		commitExplicitlySpecified := runCommit != ""
 
 		// If no commit specified, get the latest commit from the branch
 		if runCommit == "" {
-			commit, err := getLatestCommitFromBranch(cfg.Repository, runBranch)
+			auth, err := gitrepo.AuthForConfig(cfg, configPath)
+			if err != nil {
+				return fmt.Errorf("failed to configure git authentication: %w", err)
+			}
+
+			commit, err := getLatestCommitFromBranch(cfg.Repository, runBranch, auth)
 			if err != nil {
 				return fmt.Errorf("failed to get latest commit for branch %s: %w", runBranch, err)
 			}
@@ -73,9 +96,17 @@ Examples:
 			fmt.Printf("Using latest commit from branch %s: %s\n", runBranch, utils.ShortCommit(runCommit))
 		}
 
+		// The workspace of a run is keyed by branch and commit, it must not be in use by the daemon
+		if err := checkNotRunningInDaemon(&cfg, runBranch, runCommit); err != nil {
+			return err
+		}
+
 		// Create test runner without state manager for manual execution
 		ctx := context.Background()
-		testRunner := runner.NewTestRunner(cfg, configPath, cfg.WorkDir, ctx, nil)
+		testRunner, err := runner.NewTestRunner(cfg, configPath, cfg.WorkDir, ctx, nil)
+		if err != nil {
+			return err
+		}
 
 		// Execute test directly
 		// Handle short commits safely
@@ -97,8 +128,31 @@ Examples:
 	},
 }
 
+// checkNotRunningInDaemon returns an error when the daemon is running or has queued the
+// same commit of the branch, from its state file when its control socket does not answer
+func checkNotRunningInDaemon(cfg *config.Config, branch, commit string) error {
+	stateManager := state.NewStateManager(cfg.GetStateDir(), cfg.RepoName)
+	if err := stateManager.LoadState(); err != nil {
+		slog.Debug("Failed to load the state of the daemon", "error", err)
+		return nil
+	}
+
+	runID := cfg.GetRunID(branch, commit)
+	for _, test := range stateManager.GetRunningTests() {
+		if cfg.GetRunID(test.Branch, test.Commit) == runID {
+			return fmt.Errorf("run %s is running in the daemon, use 'home-ci rerun %s' to queue a new run once it is over", runID, runID)
+		}
+	}
+	for _, job := range stateManager.GetQueuedJobs() {
+		if cfg.GetRunID(job.Branch, job.Commit) == runID {
+			return fmt.Errorf("run %s is queued in the daemon", runID)
+		}
+	}
+	return nil
+}
+
 // getLatestCommitFromBranch retrieves the latest commit hash from a specific branch
-func getLatestCommitFromBranch(repoURL, branch string) (string, error) {
+func getLatestCommitFromBranch(repoURL, branch string, auth transport.AuthMethod) (string, error) {
 	slog.Debug("Fetching latest commit from branch", "repo", repoURL, "branch", branch)
 
 	// Create a temporary directory for the repository
@@ -111,6 +165,7 @@ func getLatestCommitFromBranch(repoURL, branch string) (string, error) {
 	// Clone the repository with only the specific branch
 	repo, err := git.PlainClone(tempDir, false, &git.CloneOptions{
 		URL:           repoURL,
+		Auth:          auth,
 		ReferenceName: plumbing.NewBranchReferenceName(branch),
 		SingleBranch:  true,
 		Depth:         1, // Only get the latest commit
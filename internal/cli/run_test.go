@@ -8,6 +8,8 @@ import (
 	"time"
 
 	"github.com/k8s-school/home-ci/internal/config"
+	"github.com/k8s-school/home-ci/internal/runner"
+	"github.com/k8s-school/home-ci/internal/state"
 )
 
 func TestRunCommand(t *testing.T) {
@@ -26,10 +28,7 @@ test_script: "/bin/echo"
 options: "Integration test successful"
 test_timeout: 5s
 max_concurrent_runs: 1
-state_dir: "` + filepath.Join(tempDir, "state") + `"
-cache_dir: "` + filepath.Join(tempDir, "cache") + `"
-workspace_dir: "` + filepath.Join(tempDir, "workspaces") + `"
-log_dir: "` + filepath.Join(tempDir, "logs") + `"
+work_dir: "` + tempDir + `"
 cleanup:
   after_e2e: false
   script: ""
@@ -57,7 +56,7 @@ github_actions_dispatch:
 		t.Skip("Not in a git repository, skipping getLatestCommitFromBranch test")
 	}
 
-	commitHash, err := getLatestCommitFromBranch(".", "main")
+	commitHash, err := getLatestCommitFromBranch(".", "main", nil)
 	if err != nil {
 		t.Logf("Failed to get latest commit (expected in test env): %v", err)
 	} else {
@@ -107,8 +106,7 @@ func TestManualTestExecution(t *testing.T) {
 		TestScript:   "/bin/echo",
 		Options:      "test",
 		TestTimeout:  5 * time.Second,
-		WorkspaceDir: filepath.Join(tempDir, "workspaces"),
-		LogDir:       filepath.Join(tempDir, "logs"),
+		WorkDir:      tempDir,
 	}
 
 	// Create a mock test execution to verify naming
@@ -157,4 +155,29 @@ func TestAbsoluteVsRelativeScriptPath(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+func TestCheckNotRunningInDaemon(t *testing.T) {
+	cfg := &config.Config{RepoName: "test-repo", WorkDir: t.TempDir()}
+	if err := os.MkdirAll(cfg.GetStateDir(), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkNotRunningInDaemon(cfg, "main", "0123456789abcdef"); err != nil {
+		t.Fatalf("checkNotRunningInDaemon() without daemon state failed: %v", err)
+	}
+
+	// A commit queued in the daemon would share the workspace of the manual run
+	stateManager := state.NewStateManager(cfg.GetStateDir(), cfg.RepoName)
+	stateManager.SetQueuedJobs([]runner.QueuedJob{{Branch: "main", Commit: "0123456789abcdef"}})
+	stateManager.AddRunningTest(runner.RunningTest{Branch: "feature/login", Commit: "fedcba9876543210"})
+	if err := stateManager.SaveState(); err != nil {
+		t.Fatal(err)
+	}
+	for _, run := range [][2]string{{"main", "0123456789abcdef"}, {"feature/login", "fedcba9876543210"}} {
+		if err := checkNotRunningInDaemon(cfg, run[0], run[1]); err == nil {
+			t.Errorf("checkNotRunningInDaemon(%s, %s) should fail", run[0], run[1])
+		}
+	}
+	if err := checkNotRunningInDaemon(cfg, "main", "fedcba9876543210"); err != nil {
+		t.Errorf("checkNotRunningInDaemon() of another commit failed: %v", err)
+	}
+}
@@ -0,0 +1,30 @@
+package cli
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/k8s-school/home-ci/internal/sandbox"
+)
+
+var sandboxOptions sandbox.Options
+
+// sandboxCmd is started by the runner in a new mount namespace to run a sandboxed test command
+var sandboxCmd = &cobra.Command{
+	Use:    sandbox.Command + " [flags] -- command [args...]",
+	Short:  "Run a test command in a private mount namespace (internal)",
+	Hidden: true,
+	Args:   cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		return sandbox.Exec(sandboxOptions, args)
+	},
+}
+
+func init() {
+	sandboxCmd.Flags().SetInterspersed(false)
+	sandboxCmd.Flags().IntVar(&sandboxOptions.UID, "uid", 0, "User running the command")
+	sandboxCmd.Flags().IntVar(&sandboxOptions.GID, "gid", 0, "Group running the command")
+	sandboxCmd.Flags().StringArrayVar(&sandboxOptions.ReadOnly, "read-only", nil, "Directory mounted read-only")
+	sandboxCmd.Flags().StringArrayVar(&sandboxOptions.Writable, "writable", nil, "Directory below a read-only one that stays writable")
+	RootCmd.AddCommand(sandboxCmd)
+}
@@ -0,0 +1,244 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/k8s-school/home-ci/internal/config"
+	"github.com/k8s-school/home-ci/internal/control"
+	"github.com/k8s-school/home-ci/internal/logging"
+	"github.com/k8s-school/home-ci/internal/runner"
+	"github.com/k8s-school/home-ci/internal/state"
+	"github.com/k8s-school/home-ci/internal/utils"
+)
+
+var statusOutput string
+
+// repoStatus is the health of the daemon of a repository and of its branches
+type repoStatus struct {
+	Repository    string          `json:"repository"`
+	RepoName      string          `json:"repo_name"`
+	DaemonRunning bool            `json:"daemon_running"`
+	DaemonPID     int             `json:"daemon_pid,omitempty"`
+	LastPoll      *time.Time      `json:"last_poll,omitempty"`
+	PollError     string          `json:"poll_error,omitempty"`
+	QueueLength   int             `json:"queue_length"`
+	Running       []runningStatus `json:"running"`
+	Branches      []branchStatus  `json:"branches"`
+}
+
+// runningStatus is a running test with its elapsed time and timeout
+type runningStatus struct {
+	ID             string    `json:"id"`
+	Branch         string    `json:"branch"`
+	Commit         string    `json:"commit"`
+	StartTime      time.Time `json:"start_time"`
+	ElapsedSeconds int64     `json:"elapsed_seconds"`
+	TimeoutSeconds int64     `json:"timeout_seconds,omitempty"`
+	Stale          bool      `json:"stale,omitempty"` // Left in the state file by a daemon that is down
+}
+
+// branchStatus is the latest commit of a branch and the result of its last finished run
+type branchStatus struct {
+	Branch        string     `json:"branch"`
+	LatestCommit  string     `json:"latest_commit"`
+	TestedCommit  string     `json:"tested_commit,omitempty"`
+	Result        string     `json:"result,omitempty"`
+	FailureReason string     `json:"failure_reason,omitempty"`
+	Attempts      int        `json:"attempts,omitempty"`
+	FinishedAt    *time.Time `json:"finished_at,omitempty"`
+	AgeSeconds    int64      `json:"age_seconds,omitempty"`
+}
+
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the health of the daemon and of the tested branches",
+	Long: `Show the time and the error of the last check of the remote branches, the
+queue length, the running tests with their elapsed time and timeout, and for each
+branch its latest commit and the result of its last finished run.
+
+The status is read from the state file saved by the daemon, the daemon is
+reported as running when it answers on its control socket or holds its lock
+file in the state directory. When it is down, the tests it left running in the
+state file are marked as stale.
+
+Examples:
+  home-ci status -c /etc/home-ci/config.yaml
+  home-ci status --output json -c /etc/home-ci/config.yaml`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		logging.InitLogging(verbose)
+
+		if statusOutput != "table" && statusOutput != "json" {
+			return fmt.Errorf("invalid output format %q, expected table or json", statusOutput)
+		}
+
+		cfg, err := config.Load(configPath)
+		if err != nil {
+			return fmt.Errorf("failed to load config from '%s': %w", configPath, err)
+		}
+
+		stateManager := state.NewStateManager(cfg.GetStateDir(), cfg.RepoName)
+		if err := stateManager.LoadState(); err != nil {
+			return fmt.Errorf("failed to load state: %w", err)
+		}
+		// The daemon keeps running without its control socket when it cannot create it
+		daemonPID, daemonRunning := stateManager.DaemonPID()
+		if _, err := control.NewClient(cfg.GetControlSocket()).Queue(); err == nil {
+			daemonRunning = true
+		}
+
+		status := buildStatus(&cfg, stateManager, daemonRunning, time.Now())
+		status.DaemonPID = daemonPID
+		if statusOutput == "json" {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(status)
+		}
+		return printStatus(os.Stdout, status, time.Now())
+	},
+}
+
+// buildStatus assembles the status of a repository from its state
+func buildStatus(cfg *config.Config, stateManager *state.StateManager, daemonRunning bool, now time.Time) repoStatus {
+	status := repoStatus{
+		Repository:    cfg.Repository,
+		RepoName:      cfg.RepoName,
+		DaemonRunning: daemonRunning,
+		QueueLength:   len(stateManager.GetQueuedJobs()),
+		Running:       []runningStatus{},
+		Branches:      []branchStatus{},
+	}
+
+	lastPoll, pollError := stateManager.GetPollStatus()
+	if !lastPoll.IsZero() {
+		status.LastPoll = &lastPoll
+	}
+	status.PollError = pollError
+
+	for _, test := range stateManager.GetRunningTests() {
+		status.Running = append(status.Running, runningStatus{
+			ID:             cfg.GetRunID(test.Branch, test.Commit),
+			Branch:         test.Branch,
+			Commit:         test.Commit,
+			StartTime:      test.StartTime,
+			ElapsedSeconds: int64(now.Sub(test.StartTime).Seconds()),
+			TimeoutSeconds: int64(test.Timeout.Seconds()),
+			Stale:          !daemonRunning,
+		})
+	}
+
+	for branch, branchState := range stateManager.GetBranchStates() {
+		branchStatus := branchStatus{Branch: branch, LatestCommit: branchState.LatestCommit}
+		if last := branchState.LastResult; last != nil {
+			branchStatus.TestedCommit = last.Commit
+			branchStatus.Result = resultName(*last)
+			branchStatus.FailureReason = last.FailureReason
+			branchStatus.Attempts = last.Attempts
+			branchStatus.FinishedAt = &last.EndTime
+			branchStatus.AgeSeconds = int64(now.Sub(last.EndTime).Seconds())
+		}
+		status.Branches = append(status.Branches, branchStatus)
+	}
+	sort.Slice(status.Branches, func(i, j int) bool {
+		return status.Branches[i].Branch < status.Branches[j].Branch
+	})
+
+	return status
+}
+
+// printStatus renders the status as tables
+func printStatus(out io.Writer, status repoStatus, now time.Time) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+
+	daemon := "not running"
+	if status.DaemonRunning {
+		daemon = "running"
+		if status.DaemonPID > 0 {
+			daemon += fmt.Sprintf(" (pid %d)", status.DaemonPID)
+		}
+	}
+	lastPoll := "never"
+	if status.LastPoll != nil {
+		lastPoll = fmt.Sprintf("%s (%s ago)", status.LastPoll.Format("2006-01-02 15:04:05"), formatAge(now.Sub(*status.LastPoll)))
+	}
+	fmt.Fprintf(w, "Repository:\t%s (%s)\n", status.RepoName, status.Repository)
+	fmt.Fprintf(w, "Daemon:\t%s\n", daemon)
+	fmt.Fprintf(w, "Last poll:\t%s\n", lastPoll)
+	if status.PollError != "" {
+		fmt.Fprintf(w, "Poll error:\t%s\n", status.PollError)
+	}
+	fmt.Fprintf(w, "Queued jobs:\t%d\n", status.QueueLength)
+	fmt.Fprintf(w, "\n")
+
+	fmt.Fprintf(w, "RUNNING\tBRANCH\tCOMMIT\tELAPSED\tTIMEOUT\n")
+	for _, run := range status.Running {
+		timeout := "-"
+		if run.TimeoutSeconds > 0 {
+			timeout = (time.Duration(run.TimeoutSeconds) * time.Second).String()
+		}
+		if run.Stale {
+			timeout += " (stale)"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", run.ID, run.Branch, utils.ShortCommit(run.Commit), time.Duration(run.ElapsedSeconds)*time.Second, timeout)
+	}
+	fmt.Fprintf(w, "\n")
+
+	fmt.Fprintf(w, "BRANCH\tLATEST\tTESTED\tRESULT\tATTEMPTS\tAGE\n")
+	for _, branch := range status.Branches {
+		tested, result, attempts, age := "-", "-", "-", "-"
+		if branch.TestedCommit != "" {
+			tested = utils.ShortCommit(branch.TestedCommit)
+			result = branch.Result
+			if branch.FailureReason != "" {
+				result += " (" + branch.FailureReason + ")"
+			}
+			if branch.Attempts > 0 {
+				attempts = strconv.Itoa(branch.Attempts)
+			}
+			age = formatAge(time.Duration(branch.AgeSeconds) * time.Second)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", branch.Branch, utils.ShortCommit(branch.LatestCommit), tested, result, attempts, age)
+	}
+
+	return w.Flush()
+}
+
+// resultName returns passed, flaky or failed
+func resultName(result runner.BranchResult) string {
+	switch {
+	case result.Success && result.Flaky:
+		return "flaky"
+	case result.Success:
+		return "passed"
+	default:
+		return "failed"
+	}
+}
+
+// formatAge renders a duration in its largest whole unit, from seconds to days
+func formatAge(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 48*time.Hour:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	default:
+		return fmt.Sprintf("%dd", int(d.Hours()/24))
+	}
+}
+
+func init() {
+	statusCmd.Flags().StringVarP(&statusOutput, "output", "o", "table", "Output format: table or json")
+	RootCmd.AddCommand(statusCmd)
+}
@@ -0,0 +1,92 @@
+package cli
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/k8s-school/home-ci/internal/config"
+	"github.com/k8s-school/home-ci/internal/runner"
+	"github.com/k8s-school/home-ci/internal/state"
+)
+
+func TestStatus(t *testing.T) {
+	cfg := config.Config{Repository: "https://example.com/repo.git", RepoName: "repo", WorkDir: t.TempDir()}
+	now := time.Date(2026, 1, 2, 12, 0, 0, 0, time.UTC)
+
+	// The daemon saves its state, the status command loads it
+	saved := state.NewStateManager(cfg.GetStateDir(), cfg.RepoName)
+	saved.SetPollStatus(now.Add(-2*time.Minute), errors.New("failed to get branches: timeout"))
+	saved.UpdateBranchState("main", "0123456789abcdef")
+	saved.RecordBranchResult("main", runner.BranchResult{Commit: "0123456789abcdef", Success: true, Flaky: true, Attempts: 2, EndTime: now.Add(-3 * time.Hour)})
+	saved.UpdateBranchState("feature/x", "aaaaaaaabbbbbbbb")
+	saved.RecordBranchResult("feature/x", runner.BranchResult{Commit: "cccccccc", FailureReason: runner.FailureReasonTimeout, Attempts: 1, EndTime: now.Add(-72 * time.Hour)})
+	saved.UpdateBranchState("fix", "dddddddd")
+	saved.AddRunningTest(runner.RunningTest{Branch: "feature/x", Commit: "aaaaaaaabbbbbbbb", StartTime: now.Add(-90 * time.Second), Timeout: time.Hour})
+	saved.SetQueuedJobs([]runner.QueuedJob{{Branch: "fix", Commit: "dddddddd"}})
+	if err := saved.SaveState(); err != nil {
+		t.Fatal(err)
+	}
+
+	stateManager := state.NewStateManager(cfg.GetStateDir(), cfg.RepoName)
+	if err := stateManager.LoadState(); err != nil {
+		t.Fatal(err)
+	}
+	status := buildStatus(&cfg, stateManager, false, now)
+
+	if status.QueueLength != 1 || len(status.Running) != 1 || len(status.Branches) != 3 {
+		t.Fatalf("unexpected status %+v", status)
+	}
+	if run := status.Running[0]; run.ID != "feature_x_aaaaaaaa" || run.ElapsedSeconds != 90 || run.TimeoutSeconds != 3600 || !run.Stale {
+		t.Errorf("unexpected running test %+v", run)
+	}
+
+	var out strings.Builder
+	if err := printStatus(&out, status, now); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		"Daemon:       not running",
+		"(2m ago)",
+		"Poll error:   failed to get branches: timeout",
+		"feature_x_aaaaaaaa  feature/x  aaaaaaaa  1m30s    1h0m0s (stale)",
+		"feature/x  aaaaaaaa  cccccccc  failed (timeout)  1         3d",
+		"fix        dddddddd  -         -                 -         -",
+		"main       01234567  01234567  flaky             2         3h",
+	} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("status misses %q:\n%s", expected, out.String())
+		}
+	}
+
+	// The tests of a running daemon are not stale
+	if status := buildStatus(&cfg, stateManager, true, now); status.Running[0].Stale {
+		t.Errorf("running test of a running daemon marked as stale %+v", status.Running[0])
+	}
+}
+
+func TestStatusDaemonLock(t *testing.T) {
+	stateManager := state.NewStateManager(t.TempDir(), "repo")
+	if _, running := stateManager.DaemonPID(); running {
+		t.Fatal("daemon reported as running without its lock")
+	}
+
+	lock, err := stateManager.LockDaemon()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pid, running := stateManager.DaemonPID(); !running || pid != os.Getpid() {
+		t.Errorf("expected the daemon running with pid %d, got %d %v", os.Getpid(), pid, running)
+	}
+	if _, err := stateManager.LockDaemon(); !errors.Is(err, state.ErrDaemonRunning) {
+		t.Errorf("expected ErrDaemonRunning for a second daemon, got %v", err)
+	}
+
+	// The lock is left behind by a daemon that is down
+	lock.Close()
+	if _, running := stateManager.DaemonPID(); running {
+		t.Error("daemon reported as running after releasing its lock")
+	}
+}
@@ -0,0 +1,87 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/k8s-school/home-ci/internal/config"
+	"github.com/k8s-school/home-ci/internal/gitrepo"
+	"github.com/k8s-school/home-ci/internal/logging"
+)
+
+// validateTimeout bounds the connection to the repository
+const validateTimeout = 30 * time.Second
+
+var validateOffline bool
+
+var validateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Check a configuration file",
+	Long: `Check a configuration file without starting the daemon: unknown keys and
+invalid values, credential files missing or readable by other users, durations
+that do not fit together, the repository of the GitHub dispatch, and that the
+repository is reachable with the configured credentials.
+
+Errors make the command fail, warnings are only reported.
+
+Examples:
+  home-ci validate -c /etc/home-ci/config.yaml
+  home-ci validate --offline -c config.yaml`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		logging.InitLogging(verbose)
+
+		cfg, err := config.Load(configPath)
+		if err != nil {
+			return err
+		}
+
+		issues := cfg.Lint(configPath)
+		if !validateOffline {
+			if err := checkRepository(&cfg); err != nil {
+				issues = append(issues, config.Issue{Message: err.Error()})
+			}
+		}
+
+		errors := 0
+		for _, issue := range issues {
+			fmt.Println(issue)
+			if !issue.Warning {
+				errors++
+			}
+		}
+		if errors > 0 {
+			return fmt.Errorf("%s has %d error(s)", configPath, errors)
+		}
+		fmt.Printf("%s is valid\n", configPath)
+		return nil
+	},
+}
+
+// checkRepository lists the branches of the repository with the configured credentials
+func checkRepository(cfg *config.Config) error {
+	auth, err := gitrepo.AuthForConfig(*cfg, configPath)
+	if err != nil {
+		return fmt.Errorf("git.auth: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), validateTimeout)
+	defer cancel()
+	branches, err := gitrepo.NewMirror(cfg.Repository, cfg.GetCacheDir(), auth).ListBranches(ctx)
+	if err != nil {
+		return fmt.Errorf("repository: %w", err)
+	}
+	if len(branches) == 0 {
+		return fmt.Errorf("repository: %s has no branch", cfg.Repository)
+	}
+	return nil
+}
+
+func init() {
+	validateCmd.Flags().BoolVar(&validateOffline, "offline", false, "Do not connect to the repository")
+	RootCmd.AddCommand(validateCmd)
+}
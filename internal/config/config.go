@@ -2,13 +2,16 @@ package config
 
 import (
 	"fmt"
+	"net"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
-
-	"gopkg.in/yaml.v3"
 )
 
 type GitHubActionsDispatch struct {
@@ -17,9 +20,9 @@ type GitHubActionsDispatch struct {
 	GitHubTokenFile string `yaml:"github_token_file"`
 	DispatchType    string `yaml:"dispatch_type"`
 	HasResultFile   bool   `yaml:"has_result_file"`
-	MaxPayloadSize  int    `yaml:"max_payload_size"`  // Max total payload size in bytes (default: 45KB)
-	MaxLogLines     int    `yaml:"max_log_lines"`     // Max lines to keep from end of log files (default: 1000)
-	MaxFileBytes    int    `yaml:"max_file_bytes"`    // Max bytes per file before truncation (default: 20KB)
+	MaxPayloadSize  int    `yaml:"max_payload_size"` // Max total payload size in bytes (default: 45KB)
+	MaxLogLines     int    `yaml:"max_log_lines"`    // Max lines to keep from end of log files (default: 1000)
+	MaxFileBytes    int    `yaml:"max_file_bytes"`   // Max bytes per file before truncation (default: 20KB)
 }
 
 type Cleanup struct {
@@ -27,6 +30,420 @@ type Cleanup struct {
 	Script   string `yaml:"script"`
 }
 
+// Step is one command of the test pipeline
+type Step struct {
+	Name            string            `yaml:"name"`
+	Command         string            `yaml:"command"`           // Run with /bin/sh -c in the project directory
+	Timeout         time.Duration     `yaml:"timeout"`           // Defaults to test_timeout
+	Env             map[string]string `yaml:"env"`               // Added to the environment of the command
+	ContinueOnError bool              `yaml:"continue_on_error"` // A failure of the step does not fail the run
+	AlwaysRun       bool              `yaml:"always_run"`        // Run even if a previous step failed, e.g. collect or cleanup
+}
+
+// Artifacts configures the files kept after a run, in a store independent from the workspaces
+type Artifacts struct {
+	Paths     []string      `yaml:"paths"`     // Glob patterns relative to the project directory, '**' matches any number of directories
+	KeepLogs  bool          `yaml:"keep_logs"` // Also store run.log, run.log.jsonl, run.json and e2e-report.yaml
+	Retention time.Duration `yaml:"retention"` // Age after which stored artifacts are deleted, 0 keeps them forever
+}
+
+// TestReports lists the test report files parsed after a run
+type TestReports struct {
+	JUnit []string `yaml:"junit"` // Glob patterns of JUnit XML files, relative to the project directory
+	TAP   []string `yaml:"tap"`   // Glob patterns of TAP files, relative to the project directory
+}
+
+// Policies reconciling the exit code of the test with the status of e2e-report.yaml
+const (
+	ReportPolicyExitCode = "exit_code" // Only the exit code decides, the report is informative
+	ReportPolicyBoth     = "both"      // The run passes if the exit code is 0 and the report, when present, passed
+	ReportPolicyReport   = "report"    // The report status decides, a missing or invalid report fails the run
+)
+
+// Report configures how e2e-report.yaml is used to decide the result of a run
+type Report struct {
+	Policy   string `yaml:"policy"`   // exit_code, both or report
+	Required bool   `yaml:"required"` // A missing report fails the run, whatever the policy
+}
+
+// GitAuth holds the credentials used for every access to the monitored repository
+type GitAuth struct {
+	Username             string `yaml:"username"`                // HTTP basic username, or SSH user when not set in the URL
+	PasswordFile         string `yaml:"password_file"`           // File containing the HTTP password or token
+	SSHKeyFile           string `yaml:"ssh_key_file"`            // Private key used for SSH URLs
+	SSHKeyPassphraseFile string `yaml:"ssh_key_passphrase_file"` // Optional file containing the private key passphrase
+	SSHAgent             bool   `yaml:"ssh_agent"`               // Use the SSH agent from SSH_AUTH_SOCK
+	KnownHostsFile       string `yaml:"known_hosts_file"`        // known_hosts file used to verify SSH host keys (default: ~/.ssh/known_hosts)
+}
+
+type Git struct {
+	Auth GitAuth `yaml:"auth"`
+}
+
+// Submodule checkout modes for workspaces
+const (
+	SubmodulesNone      = "none"
+	SubmodulesTop       = "top"
+	SubmodulesRecursive = "recursive"
+)
+
+// Workspace controls how the project tree is populated before running tests
+type Workspace struct {
+	Submodules string `yaml:"submodules"` // none, top or recursive
+	LFS        bool   `yaml:"lfs"`        // Fetch Git LFS objects with the git-lfs command
+}
+
+// PriorityRule assigns a priority to the branches matching a glob pattern
+type PriorityRule struct {
+	Branch   string `yaml:"branch"` // Glob pattern, e.g. "main" or "release/*", empty matches all branches
+	Priority int    `yaml:"priority"`
+}
+
+// Scheduling configures the order in which queued jobs are run
+type Scheduling struct {
+	Rules           []PriorityRule `yaml:"rules"`            // First matching rule gives the branch priority
+	DefaultPriority int            `yaml:"default_priority"` // Priority of branches matching no rule
+	ManualPriority  int            `yaml:"manual_priority"`  // Added to manually triggered jobs
+	RetryPriority   int            `yaml:"retry_priority"`   // Added to retried jobs
+	AgingInterval   time.Duration  `yaml:"aging_interval"`   // A waiting job gains one priority point per interval (0 disables aging)
+	SupersedeQueued bool           `yaml:"supersede_queued"` // A new commit of a branch replaces its older commit still waiting in the queue
+}
+
+// ResourceRequirement declares the named resources needed by the jobs of matching branches
+type ResourceRequirement struct {
+	Branch    string   `yaml:"branch"`    // Glob pattern, empty matches all branches
+	Resources []string `yaml:"resources"` // Each job takes one slot of every listed resource
+}
+
+// Resources configures named resources shared between jobs, repositories and home-ci daemons
+type Resources struct {
+	LockDir      string                `yaml:"lock_dir"`     // Directory of the lock files, shared by all daemons of the host
+	Capacities   map[string]int        `yaml:"capacities"`   // Number of jobs that can hold each resource at the same time
+	Requirements []ResourceRequirement `yaml:"requirements"` // All matching requirements are combined
+}
+
+// RequiredFor returns the resources needed by a job on the given branch
+func (r Resources) RequiredFor(branch string) []string {
+	var required []string
+	seen := make(map[string]bool)
+	for _, requirement := range r.Requirements {
+		if !MatchBranch(requirement.Branch, branch) {
+			continue
+		}
+		for _, name := range requirement.Resources {
+			if !seen[name] {
+				seen[name] = true
+				required = append(required, name)
+			}
+		}
+	}
+	sort.Strings(required)
+	return required
+}
+
+// MatchBranch reports whether a branch matches a glob pattern, an empty pattern matches all branches.
+// As in path.Match, '*' does not match '/'.
+func MatchBranch(pattern, branch string) bool {
+	if pattern == "" {
+		return true
+	}
+	matched, _ := path.Match(pattern, branch)
+	return matched
+}
+
+// Conditions that trigger an automatic retry
+const (
+	RetryOnFailure    = "failure"     // The test script exited with an error
+	RetryOnTimeout    = "timeout"     // The test script was killed after test_timeout
+	RetryOnSetupError = "setup_error" // The workspace could not be prepared
+	RetryOnOOM        = "oom"         // A command was killed by the memory limit of the run
+)
+
+// Retry configures the automatic retry of failed runs
+type Retry struct {
+	MaxAttempts int      `yaml:"max_attempts"` // Total number of attempts per commit, 1 disables retries
+	On          []string `yaml:"on"`           // Failure reasons that trigger a retry
+}
+
+// ShouldRetry reports whether a run that failed for the given reason gets another attempt
+func (r Retry) ShouldRetry(reason string, attempt int) bool {
+	if attempt >= r.MaxAttempts {
+		return false
+	}
+	for _, on := range r.On {
+		if on == reason {
+			return true
+		}
+	}
+	return false
+}
+
+// Limits configures the resource limits of each run, enforced by placing its
+// commands in a dedicated cgroup v2. Zero values are unlimited.
+type Limits struct {
+	Memory    string  `yaml:"memory"`     // Memory limit, with an optional K, M, G or T suffix, e.g. "4G"
+	CPUs      float64 `yaml:"cpus"`       // CPU quota in number of CPUs, e.g. 1.5
+	Pids      int     `yaml:"pids"`       // Maximum number of processes and threads
+	CgroupDir string  `yaml:"cgroup_dir"` // Delegated cgroup v2 directory, defaults to the cgroup of the daemon
+}
+
+// Enabled reports whether at least one limit is set
+func (l Limits) Enabled() bool {
+	return l.Memory != "" || l.CPUs > 0 || l.Pids > 0
+}
+
+// MemoryBytes returns the memory limit in bytes, 0 when unlimited
+func (l Limits) MemoryBytes() int64 {
+	size, _ := ParseSize(l.Memory)
+	return size
+}
+
+// ParseSize parses a size in bytes, with an optional K, M, G or T suffix (powers of 1024)
+func ParseSize(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+
+	number, multiplier := s, int64(1)
+	units := map[byte]int64{'K': 1 << 10, 'M': 1 << 20, 'G': 1 << 30, 'T': 1 << 40}
+	if m, ok := units[strings.ToUpper(s[len(s)-1:])[0]]; ok {
+		number, multiplier = s[:len(s)-1], m
+	}
+
+	value, err := strconv.ParseInt(number, 10, 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("invalid size '%s'", s)
+	}
+	return value * multiplier, nil
+}
+
+// DefaultSandboxEnv are the variables of the daemon environment passed to sandboxed commands by default
+var DefaultSandboxEnv = []string{"PATH", "LANG", "LC_*", "TZ", "TERM"}
+
+// Sandbox runs the test commands as an unprivileged user, with a temporary HOME and
+// a minimal environment. The daemon must run as root.
+type Sandbox struct {
+	Enabled        bool     `yaml:"enabled"`
+	UID            int      `yaml:"uid"`             // User running the commands
+	GID            int      `yaml:"gid"`             // Group running the commands
+	EnvAllow       []string `yaml:"env_allow"`       // Variables of the daemon environment passed to the commands, a trailing * matches a prefix
+	MountNamespace bool     `yaml:"mount_namespace"` // Private mount namespace with a read-only work directory
+}
+
+// Executor types
+const (
+	ExecutorHost = "host" // Commands run on the daemon host
+	ExecutorOCI  = "oci"  // Commands run in a container started with podman or docker
+	ExecutorSSH  = "ssh"  // Commands run on a worker host reached with ssh
+)
+
+// Container engines supported by the oci executor
+const (
+	EnginePodman = "podman"
+	EngineDocker = "docker"
+)
+
+// Image pull policies of the oci executor
+const (
+	PullMissing = "missing"
+	PullAlways  = "always"
+	PullNever   = "never"
+)
+
+// ExecutorSettings select where the test commands of a run are executed
+type ExecutorSettings struct {
+	Type    string   `yaml:"type"`    // host (default) or oci
+	Image   string   `yaml:"image"`   // Image running the commands, required by the oci executor
+	Engine  string   `yaml:"engine"`  // podman (default) or docker
+	Pull    string   `yaml:"pull"`    // Image pull policy: missing (default), always or never
+	Options []string `yaml:"options"` // Extra arguments of the container run command, e.g. --network=host
+	Labels  []string `yaml:"labels"`  // Labels the worker of the ssh executor must all have
+}
+
+// merge returns the settings with the fields set in override replaced
+func (s ExecutorSettings) merge(override ExecutorSettings) ExecutorSettings {
+	if override.Type != "" {
+		s.Type = override.Type
+	}
+	if override.Image != "" {
+		s.Image = override.Image
+	}
+	if override.Engine != "" {
+		s.Engine = override.Engine
+	}
+	if override.Pull != "" {
+		s.Pull = override.Pull
+	}
+	if override.Options != nil {
+		s.Options = override.Options
+	}
+	if override.Labels != nil {
+		s.Labels = override.Labels
+	}
+	return s
+}
+
+// validate checks the settings of an executor
+func (s ExecutorSettings) validate() error {
+	switch s.Type {
+	case ExecutorHost, ExecutorSSH:
+	case ExecutorOCI:
+		if s.Image == "" {
+			return fmt.Errorf("the %s executor requires an image", ExecutorOCI)
+		}
+	default:
+		return fmt.Errorf("invalid type '%s', expected %s, %s or %s", s.Type, ExecutorHost, ExecutorOCI, ExecutorSSH)
+	}
+	switch s.Engine {
+	case EnginePodman, EngineDocker:
+	default:
+		return fmt.Errorf("invalid engine '%s', expected %s or %s", s.Engine, EnginePodman, EngineDocker)
+	}
+	switch s.Pull {
+	case PullMissing, PullAlways, PullNever:
+	default:
+		return fmt.Errorf("invalid pull policy '%s', expected %s, %s or %s", s.Pull, PullMissing, PullAlways, PullNever)
+	}
+	return nil
+}
+
+// ExecutorRule overrides the executor settings of the branches matching a pattern
+type ExecutorRule struct {
+	Branch           string `yaml:"branch"` // Glob pattern, empty matches all branches
+	ExecutorSettings `yaml:",inline"`
+}
+
+// Executor configures where the test commands run, by default on the host
+type Executor struct {
+	ExecutorSettings `yaml:",inline"`
+	Rules            []ExecutorRule `yaml:"rules"` // The first matching rule applies, its unset fields keep the defaults above
+}
+
+// For returns the executor settings of a branch
+func (e Executor) For(branch string) ExecutorSettings {
+	for _, rule := range e.Rules {
+		if MatchBranch(rule.Branch, branch) {
+			return e.ExecutorSettings.merge(rule.ExecutorSettings)
+		}
+	}
+	return e.ExecutorSettings
+}
+
+// DefaultWorkerWorkDir is the remote directory holding the workspaces of a worker by default
+const DefaultWorkerWorkDir = "/tmp/home-ci"
+
+// WorkerResourcePrefix prefixes the worker names in the resource locks, a worker
+// slot is a resource slot so that capacities are shared by the daemons of the host
+const WorkerResourcePrefix = "worker."
+
+// Worker is a host running the commands of the ssh executor
+type Worker struct {
+	Name           string   `yaml:"name"`
+	Host           string   `yaml:"host"`             // SSH destination, e.g. ci@lab.example.org
+	Port           int      `yaml:"port"`             // SSH port, 22 by default
+	IdentityFile   string   `yaml:"identity_file"`    // Private key, relative to the config file
+	KnownHostsFile string   `yaml:"known_hosts_file"` // Host keys of the worker, relative to the config file, the user known hosts by default
+	WorkDir        string   `yaml:"work_dir"`         // Absolute remote directory holding the workspaces
+	Labels         []string `yaml:"labels"`
+	Capacity       int      `yaml:"capacity"` // Number of runs at the same time, 1 by default
+}
+
+// HasLabels reports whether the worker has all the labels
+func (w Worker) HasLabels(labels []string) bool {
+	return HasLabels(w.Labels, labels)
+}
+
+// HasLabels reports whether own contains all the required labels
+func HasLabels(own, required []string) bool {
+	for _, label := range required {
+		found := false
+		for _, have := range own {
+			if have == label {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// Logs configures the output of the test commands besides run.log
+type Logs struct {
+	Console  bool   `yaml:"console"`  // Copy the output to the daemon stdout and stderr, each line prefixed with the run ID
+	MaxSize  string `yaml:"max_size"` // Cap of run.log per attempt, e.g. "100M", its head and tail are kept; unlimited when empty
+	Compress bool   `yaml:"compress"` // Gzip run.log and run.log.jsonl once the run is over
+}
+
+// MaxBytes returns the cap of run.log in bytes, 0 when unlimited
+func (l Logs) MaxBytes() int64 {
+	size, _ := ParseSize(l.MaxSize)
+	return size
+}
+
+// API serves the logs of the runs over HTTP
+type API struct {
+	Listen    string `yaml:"listen"`     // Address of the API, e.g. "127.0.0.1:8481", disabled when empty
+	TokenFile string `yaml:"token_file"` // File holding the token of the clients, relative to the config file
+}
+
+// Control is the local socket used by the CLI to control the daemon
+type Control struct {
+	Socket string `yaml:"socket"` // Path of the Unix socket, defaults to <work_dir>/state/<repo_name>.sock
+}
+
+// DefaultLeaseTTL is how long a job leased to an agent is kept without heartbeat by default
+const DefaultLeaseTTL = time.Minute
+
+// Coordinator serves the queued jobs to agents over HTTP, instead of running them on the daemon host
+type Coordinator struct {
+	Listen    string        `yaml:"listen"`     // Address of the job API, e.g. ":8480", the coordinator is disabled when empty
+	TokenFile string        `yaml:"token_file"` // File holding the token of the agents, relative to the config file
+	LeaseTTL  time.Duration `yaml:"lease_ttl"`  // A lease without heartbeat for this long expires and its job is queued again
+	Rules     []AgentRule   `yaml:"rules"`      // The first matching rule sets the labels required from the agents
+}
+
+// AgentRule sets the labels an agent must have to run the jobs of the branches matching a pattern
+type AgentRule struct {
+	Branch string   `yaml:"branch"` // Glob pattern, empty matches all branches
+	Labels []string `yaml:"labels"`
+}
+
+// LabelsFor returns the labels an agent must have to run the jobs of a branch
+func (c Coordinator) LabelsFor(branch string) []string {
+	for _, rule := range c.Rules {
+		if MatchBranch(rule.Branch, branch) {
+			return rule.Labels
+		}
+	}
+	return nil
+}
+
+// Agent runs the jobs leased from a coordinator
+type Agent struct {
+	Coordinator string   `yaml:"coordinator"` // URL of the coordinator, e.g. http://ci.example.org:8480
+	Name        string   `yaml:"name"`        // Name reported to the coordinator, the host name by default
+	Labels      []string `yaml:"labels"`
+	TokenFile   string   `yaml:"token_file"` // File holding the token of the coordinator, relative to the config file
+}
+
+// workerNamePattern matches the valid worker names, used in lock file names
+var workerNamePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// Secret is a value injected in the environment of the test commands and
+// masked in their output. It is read from a file or from the daemon environment.
+type Secret struct {
+	Name string `yaml:"name"` // Environment variable set for the test commands
+	File string `yaml:"file"` // File holding the value, relative to the config file
+	Env  string `yaml:"env"`  // Variable of the daemon environment holding the value
+}
+
+// envNamePattern matches the valid environment variable names
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
 type Config struct {
 	// Repository configuration
 	Repository string `yaml:"repository"` // Git repository URL or path
@@ -35,14 +452,36 @@ type Config struct {
 	// Directory structure
 	WorkDir string `yaml:"work_dir"` // Base working directory - all paths calculated from this
 
+	// Git access configuration
+	Git Git `yaml:"git"`
+
 	// Test configuration
 	CheckInterval         time.Duration         `yaml:"check_interval"`
 	TestScript            string                `yaml:"test_script"`
+	Steps                 []Step                `yaml:"steps"` // Replaces test_script and options when set
 	MaxConcurrentRuns     int                   `yaml:"max_concurrent_runs"`
 	Options               string                `yaml:"options"`
 	RecentCommitsWithin   time.Duration         `yaml:"recent_commits_within"`
 	TestTimeout           time.Duration         `yaml:"test_timeout"`
 	KeepTime              time.Duration         `yaml:"keep_time"`
+	Workspace             Workspace             `yaml:"workspace"`
+	Scheduling            Scheduling            `yaml:"scheduling"`
+	Resources             Resources             `yaml:"resources"`
+	Retry                 Retry                 `yaml:"retry"`
+	Secrets               []Secret              `yaml:"secrets"`
+	Limits                Limits                `yaml:"limits"`
+	Sandbox               Sandbox               `yaml:"sandbox"`
+	Executor              Executor              `yaml:"executor"`
+	Workers               []Worker              `yaml:"workers"`
+	Logs                  Logs                  `yaml:"logs"`
+	API                   API                   `yaml:"api"`
+	Control               Control               `yaml:"control"`
+	Coordinator           Coordinator           `yaml:"coordinator"`
+	Agent                 Agent                 `yaml:"agent"`
+	RepoConfig            RepoConfig            `yaml:"repo_config"`
+	Artifacts             Artifacts             `yaml:"artifacts"`
+	TestReports           TestReports           `yaml:"test_reports"`
+	Report                Report                `yaml:"report"`
 	Cleanup               Cleanup               `yaml:"cleanup"`
 	GitHubActionsDispatch GitHubActionsDispatch `yaml:"github_actions_dispatch"`
 }
@@ -65,19 +504,45 @@ func Load(path string) (Config, error) {
 		RecentCommitsWithin: 240 * time.Hour,  // 10 days
 		TestTimeout:         30 * time.Minute, // 30 minutes default timeout
 		KeepTime:            0,                // By default, delete repositories immediately after tests
+		Workspace: Workspace{
+			Submodules: SubmodulesNone,
+			LFS:        false,
+		},
+		Scheduling: Scheduling{
+			DefaultPriority: 0,
+			ManualPriority:  50,
+			RetryPriority:   10,
+			AgingInterval:   time.Minute,
+		},
+		Resources: Resources{
+			LockDir: filepath.Join(os.TempDir(), "home-ci-locks"),
+		},
+		Retry: Retry{
+			MaxAttempts: 1,
+			On:          []string{RetryOnFailure, RetryOnTimeout, RetryOnSetupError},
+		},
+		RepoConfig: RepoConfig{
+			File: DefaultRepoConfigFile,
+		},
+		Artifacts: Artifacts{
+			Retention: 7 * 24 * time.Hour,
+		},
+		Report: Report{
+			Policy: ReportPolicyBoth,
+		},
 		Cleanup: Cleanup{
 			AfterE2E: true,
 			Script:   "",
 		},
 		GitHubActionsDispatch: GitHubActionsDispatch{
-			Enabled:        false,
-			GitHubRepo:     "",
+			Enabled:         false,
+			GitHubRepo:      "",
 			GitHubTokenFile: "",
-			DispatchType:   "",
-			HasResultFile:  false,
-			MaxPayloadSize: 45 * 1024, // 45KB default
-			MaxLogLines:    1000,      // Keep last 1000 lines
-			MaxFileBytes:   20 * 1024, // 20KB max per file
+			DispatchType:    "",
+			HasResultFile:   false,
+			MaxPayloadSize:  45 * 1024, // 45KB default
+			MaxLogLines:     1000,      // Keep last 1000 lines
+			MaxFileBytes:    20 * 1024, // 20KB max per file
 		},
 	}
 
@@ -90,7 +555,7 @@ func Load(path string) (Config, error) {
 		return config, fmt.Errorf("cannot read configuration file '%s': %w", path, err)
 	}
 
-	if err := yaml.Unmarshal(data, &config); err != nil {
+	if err := decodeStrict(data, &config); err != nil {
 		return config, fmt.Errorf("cannot parse configuration file '%s': %w", path, err)
 	}
 
@@ -118,6 +583,239 @@ func (c *Config) Normalize() error {
 		c.GitHubActionsDispatch.GitHubRepo = extractGitHubRepoFormat(c.Repository)
 	}
 
+	// Validate workspace options
+	switch c.Workspace.Submodules {
+	case "":
+		c.Workspace.Submodules = SubmodulesNone
+	case SubmodulesNone, SubmodulesTop, SubmodulesRecursive:
+	default:
+		return fmt.Errorf("invalid workspace.submodules '%s', expected %s, %s or %s",
+			c.Workspace.Submodules, SubmodulesNone, SubmodulesTop, SubmodulesRecursive)
+	}
+
+	// Validate scheduling rules
+	for _, rule := range c.Scheduling.Rules {
+		if _, err := path.Match(rule.Branch, ""); err != nil {
+			return fmt.Errorf("invalid branch pattern '%s' in scheduling rules: %w", rule.Branch, err)
+		}
+	}
+
+	// Validate resource requirements
+	for name, capacity := range c.Resources.Capacities {
+		if capacity < 1 {
+			return fmt.Errorf("invalid capacity %d for resource '%s', must be at least 1", capacity, name)
+		}
+	}
+	for _, requirement := range c.Resources.Requirements {
+		if _, err := path.Match(requirement.Branch, ""); err != nil {
+			return fmt.Errorf("invalid branch pattern '%s' in resource requirements: %w", requirement.Branch, err)
+		}
+		for _, name := range requirement.Resources {
+			if _, ok := c.Resources.Capacities[name]; !ok {
+				return fmt.Errorf("resource '%s' required for branch pattern '%s' has no capacity", name, requirement.Branch)
+			}
+		}
+	}
+
+	// Validate pipeline steps
+	stepNames := make(map[string]bool)
+	for i, step := range c.Steps {
+		if step.Name == "" {
+			return fmt.Errorf("step %d has no name", i+1)
+		}
+		if stepNames[step.Name] {
+			return fmt.Errorf("duplicate step name '%s'", step.Name)
+		}
+		stepNames[step.Name] = true
+		if strings.TrimSpace(step.Command) == "" {
+			return fmt.Errorf("step '%s' has no command", step.Name)
+		}
+		if step.Timeout < 0 {
+			return fmt.Errorf("step '%s' has a negative timeout", step.Name)
+		}
+	}
+
+	// Validate repository configuration whitelist
+	if c.RepoConfig.File == "" {
+		c.RepoConfig.File = DefaultRepoConfigFile
+	}
+	if err := c.RepoConfig.validate(); err != nil {
+		return err
+	}
+
+	// Validate artifact and test report patterns
+	patterns := append(append(append([]string{}, c.Artifacts.Paths...), c.TestReports.JUnit...), c.TestReports.TAP...)
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid file pattern '%s': %w", pattern, err)
+		}
+		if path.IsAbs(pattern) || strings.HasPrefix(pattern, "../") {
+			return fmt.Errorf("file pattern '%s' must be relative to the project directory", pattern)
+		}
+	}
+	if c.Artifacts.Retention < 0 {
+		return fmt.Errorf("invalid artifacts.retention %s", c.Artifacts.Retention)
+	}
+
+	// Validate report policy
+	switch c.Report.Policy {
+	case "":
+		c.Report.Policy = ReportPolicyBoth
+	case ReportPolicyExitCode, ReportPolicyBoth, ReportPolicyReport:
+	default:
+		return fmt.Errorf("invalid report.policy '%s', expected %s, %s or %s",
+			c.Report.Policy, ReportPolicyExitCode, ReportPolicyBoth, ReportPolicyReport)
+	}
+
+	// Validate retry options
+	if c.Retry.MaxAttempts == 0 {
+		c.Retry.MaxAttempts = 1
+	}
+	if c.Retry.MaxAttempts < 0 {
+		return fmt.Errorf("invalid retry.max_attempts %d, must be at least 1", c.Retry.MaxAttempts)
+	}
+	for _, on := range c.Retry.On {
+		switch on {
+		case RetryOnFailure, RetryOnTimeout, RetryOnSetupError, RetryOnOOM:
+		default:
+			return fmt.Errorf("invalid retry.on value '%s', expected %s, %s, %s or %s",
+				on, RetryOnFailure, RetryOnTimeout, RetryOnSetupError, RetryOnOOM)
+		}
+	}
+
+	// Validate secrets
+	secretNames := make(map[string]bool)
+	for i, secret := range c.Secrets {
+		if !envNamePattern.MatchString(secret.Name) {
+			return fmt.Errorf("invalid name '%s' for secret %d, expected an environment variable name", secret.Name, i+1)
+		}
+		if strings.HasPrefix(secret.Name, "HOME_CI_") {
+			return fmt.Errorf("secret name '%s' uses the reserved HOME_CI_ prefix", secret.Name)
+		}
+		if secretNames[secret.Name] {
+			return fmt.Errorf("duplicate secret name '%s'", secret.Name)
+		}
+		secretNames[secret.Name] = true
+		if (secret.File == "") == (secret.Env == "") {
+			return fmt.Errorf("secret '%s' must set exactly one of file or env", secret.Name)
+		}
+	}
+
+	// Validate resource limits
+	if _, err := ParseSize(c.Limits.Memory); err != nil {
+		return fmt.Errorf("invalid limits.memory: %w", err)
+	}
+	if c.Limits.CPUs < 0 || c.Limits.Pids < 0 {
+		return fmt.Errorf("invalid limits, cpus and pids must not be negative")
+	}
+
+	// Validate log cap
+	if _, err := ParseSize(c.Logs.MaxSize); err != nil {
+		return fmt.Errorf("invalid logs.max_size: %w", err)
+	}
+
+	// Validate sandbox
+	if c.Sandbox.Enabled {
+		if c.Sandbox.UID <= 0 || c.Sandbox.GID <= 0 {
+			return fmt.Errorf("sandbox.uid and sandbox.gid must be set to an unprivileged user and group")
+		}
+		if c.Sandbox.EnvAllow == nil {
+			c.Sandbox.EnvAllow = DefaultSandboxEnv
+		}
+		for _, name := range c.Sandbox.EnvAllow {
+			if !envNamePattern.MatchString(strings.TrimSuffix(name, "*")) {
+				return fmt.Errorf("invalid sandbox.env_allow entry '%s'", name)
+			}
+		}
+	}
+
+	// Validate executor
+	if c.Executor.Type == "" {
+		c.Executor.Type = ExecutorHost
+	}
+	if c.Executor.Engine == "" {
+		c.Executor.Engine = EnginePodman
+	}
+	if c.Executor.Pull == "" {
+		c.Executor.Pull = PullMissing
+	}
+	if err := c.Executor.ExecutorSettings.validate(); err != nil {
+		return fmt.Errorf("invalid executor: %w", err)
+	}
+	for _, rule := range c.Executor.Rules {
+		if _, err := path.Match(rule.Branch, ""); err != nil {
+			return fmt.Errorf("invalid branch pattern '%s' in executor rules: %w", rule.Branch, err)
+		}
+		if err := c.Executor.ExecutorSettings.merge(rule.ExecutorSettings).validate(); err != nil {
+			return fmt.Errorf("invalid executor for branch pattern '%s': %w", rule.Branch, err)
+		}
+	}
+
+	// Validate workers
+	workerNames := make(map[string]bool)
+	for i := range c.Workers {
+		worker := &c.Workers[i]
+		if !workerNamePattern.MatchString(worker.Name) {
+			return fmt.Errorf("invalid name '%s' for worker %d", worker.Name, i+1)
+		}
+		if workerNames[worker.Name] {
+			return fmt.Errorf("duplicate worker name '%s'", worker.Name)
+		}
+		workerNames[worker.Name] = true
+		if worker.Host == "" {
+			return fmt.Errorf("worker '%s' has no host", worker.Name)
+		}
+		if worker.Port == 0 {
+			worker.Port = 22
+		}
+		if worker.Capacity == 0 {
+			worker.Capacity = 1
+		}
+		if worker.Capacity < 0 {
+			return fmt.Errorf("invalid capacity %d for worker '%s'", worker.Capacity, worker.Name)
+		}
+		if worker.WorkDir == "" {
+			worker.WorkDir = DefaultWorkerWorkDir
+		}
+		if !path.IsAbs(worker.WorkDir) {
+			return fmt.Errorf("work_dir of worker '%s' must be an absolute path", worker.Name)
+		}
+	}
+	for name := range c.Resources.Capacities {
+		if strings.HasPrefix(name, WorkerResourcePrefix) {
+			return fmt.Errorf("resource name '%s' uses the reserved %s prefix", name, WorkerResourcePrefix)
+		}
+	}
+	executors := []ExecutorSettings{c.Executor.ExecutorSettings}
+	for _, rule := range c.Executor.Rules {
+		executors = append(executors, c.Executor.ExecutorSettings.merge(rule.ExecutorSettings))
+	}
+	for _, executor := range executors {
+		if executor.Type == ExecutorSSH && len(c.WorkersFor(executor.Labels)) == 0 {
+			return fmt.Errorf("no worker has the labels %v required by the %s executor", executor.Labels, ExecutorSSH)
+		}
+	}
+
+	if c.API.Listen != "" && c.API.TokenFile == "" && !IsLoopback(c.API.Listen) {
+		return fmt.Errorf("api.token_file is required when api.listen is not a loopback address")
+	}
+
+	// Validate coordinator
+	if c.Coordinator.LeaseTTL == 0 {
+		c.Coordinator.LeaseTTL = DefaultLeaseTTL
+	}
+	if c.Coordinator.LeaseTTL < 0 {
+		return fmt.Errorf("invalid coordinator.lease_ttl %s", c.Coordinator.LeaseTTL)
+	}
+	if c.Coordinator.Listen != "" && c.Coordinator.TokenFile == "" && !IsLoopback(c.Coordinator.Listen) {
+		return fmt.Errorf("coordinator.token_file is required when coordinator.listen is not a loopback address")
+	}
+	for _, rule := range c.Coordinator.Rules {
+		if _, err := path.Match(rule.Branch, ""); err != nil {
+			return fmt.Errorf("invalid branch pattern '%s' in coordinator rules: %w", rule.Branch, err)
+		}
+	}
+
 	// Validate work directory
 	if !isDirWritable(c.WorkDir) {
 		return fmt.Errorf("work directory '%s' is not accessible or writable", c.WorkDir)
@@ -126,16 +824,55 @@ func (c *Config) Normalize() error {
 	return nil
 }
 
+// WorkersFor returns the workers having all the labels
+func (c *Config) WorkersFor(labels []string) []Worker {
+	var workers []Worker
+	for _, worker := range c.Workers {
+		if worker.HasLabels(labels) {
+			workers = append(workers, worker)
+		}
+	}
+	return workers
+}
+
 // GetCacheDir returns the cache directory path
 func (c *Config) GetCacheDir() string {
 	return filepath.Join(c.WorkDir, "cache")
 }
 
+// GetArtifactsDir returns the artifact store of the repository
+func (c *Config) GetArtifactsDir() string {
+	return filepath.Join(c.WorkDir, "artifacts", c.RepoName)
+}
+
+// GetRunsDir returns the directory containing the workspaces of the repository
+func (c *Config) GetRunsDir() string {
+	return filepath.Join(c.WorkDir, c.RepoName)
+}
+
+// GetRunID returns the identifier of the run of a commit, also used as directory name
+func (c *Config) GetRunID(branch, commit string) string {
+	return c.createRunID(branch, commit)
+}
+
 // GetStateDir returns the state directory path
 func (c *Config) GetStateDir() string {
 	return filepath.Join(c.WorkDir, "state")
 }
 
+// GetControlSocket returns the path of the control socket of the daemon
+func (c *Config) GetControlSocket() string {
+	if c.Control.Socket != "" {
+		return c.Control.Socket
+	}
+	return filepath.Join(c.GetStateDir(), c.RepoName+".sock")
+}
+
+// GetHistoryFile returns the path of the history of the finished runs of the repository
+func (c *Config) GetHistoryFile() string {
+	return filepath.Join(c.GetStateDir(), c.RepoName+".history.jsonl")
+}
+
 // GetWorkspaceDir returns the workspace directory for a specific run
 func (c *Config) GetWorkspaceDir(branch, commit string) string {
 	runID := c.createRunID(branch, commit)
@@ -148,12 +885,31 @@ func (c *Config) GetLogsDir(branch, commit string) string {
 	return filepath.Join(c.WorkDir, c.RepoName, runID, "logs")
 }
 
+// GetRunArtifactsDir returns the directory where the test commands of a run write their artifacts
+func (c *Config) GetRunArtifactsDir(branch, commit string) string {
+	runID := c.createRunID(branch, commit)
+	return filepath.Join(c.WorkDir, c.RepoName, runID, "artifacts")
+}
+
 // GetProjectDir returns the project source directory for a specific run
 func (c *Config) GetProjectDir(branch, commit string) string {
 	runID := c.createRunID(branch, commit)
 	return filepath.Join(c.WorkDir, c.RepoName, runID, "src", c.RepoName)
 }
 
+// IsLoopback reports whether a listen address only accepts connections from the local host
+func IsLoopback(address string) bool {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
 // createRunID creates a run identifier from branch and commit
 func (c *Config) createRunID(branch, commit string) string {
 	// Clean branch name (remove slashes, etc.)
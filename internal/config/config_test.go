@@ -1,7 +1,12 @@
 package config
 
 import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestExtractGitHubRepoFormat(t *testing.T) {
@@ -109,4 +114,344 @@ func TestConfigNormalizeGitHubRepoDefault(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+
+func TestResourcesRequiredFor(t *testing.T) {
+	resources := Resources{
+		Capacities: map[string]int{"kind-cluster": 1, "node": 3},
+		Requirements: []ResourceRequirement{
+			{Branch: "", Resources: []string{"node"}},
+			{Branch: "main", Resources: []string{"kind-cluster", "node"}},
+			{Branch: "release/*", Resources: []string{"kind-cluster"}},
+		},
+	}
+
+	tests := []struct {
+		branch   string
+		expected []string
+	}{
+		{"main", []string{"kind-cluster", "node"}},
+		{"release/1.0", []string{"kind-cluster", "node"}},
+		{"feature/x", []string{"node"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.branch, func(t *testing.T) {
+			got := resources.RequiredFor(tt.branch)
+			if strings.Join(got, ",") != strings.Join(tt.expected, ",") {
+				t.Errorf("RequiredFor(%q) = %v, want %v", tt.branch, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestNormalizeSecrets(t *testing.T) {
+	tests := []struct {
+		name    string
+		secrets []Secret
+		valid   bool
+	}{
+		{"file and env secrets", []Secret{{Name: "TOKEN", File: "token"}, {Name: "API_KEY", Env: "CI_API_KEY"}}, true},
+		{"invalid name", []Secret{{Name: "MY-TOKEN", File: "token"}}, false},
+		{"reserved prefix", []Secret{{Name: "HOME_CI_TOKEN", File: "token"}}, false},
+		{"duplicate name", []Secret{{Name: "TOKEN", File: "a"}, {Name: "TOKEN", Env: "B"}}, false},
+		{"no source", []Secret{{Name: "TOKEN"}}, false},
+		{"both sources", []Secret{{Name: "TOKEN", File: "token", Env: "TOKEN"}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := Config{
+				Repository: "https://gitlab.com/user/repo.git",
+				RepoName:   "test-repo",
+				WorkDir:    t.TempDir(),
+				Secrets:    tt.secrets,
+			}
+			err := config.Normalize()
+			if tt.valid && err != nil {
+				t.Errorf("Normalize() failed: %v", err)
+			}
+			if !tt.valid && err == nil {
+				t.Error("expected Normalize() to fail")
+			}
+		})
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := map[string]int64{
+		"":     0,
+		"1024": 1024,
+		"512M": 512 << 20,
+		"4G":   4 << 30,
+		"2k":   2048,
+		" 1T ": 1 << 40,
+	}
+	for input, want := range tests {
+		got, err := ParseSize(input)
+		if err != nil || got != want {
+			t.Errorf("ParseSize(%q) = %d, %v, want %d", input, got, err, want)
+		}
+	}
+
+	for _, input := range []string{"G", "4GB", "-1", "1.5G"} {
+		if _, err := ParseSize(input); err == nil {
+			t.Errorf("ParseSize(%q) should fail", input)
+		}
+	}
+}
+
+func TestNormalizeSandbox(t *testing.T) {
+	tests := []struct {
+		name    string
+		sandbox Sandbox
+		valid   bool
+	}{
+		{"disabled", Sandbox{}, true},
+		{"unprivileged user", Sandbox{Enabled: true, UID: 1001, GID: 1001}, true},
+		{"root user", Sandbox{Enabled: true, UID: 0, GID: 1001}, false},
+		{"missing group", Sandbox{Enabled: true, UID: 1001}, false},
+		{"invalid env_allow", Sandbox{Enabled: true, UID: 1001, GID: 1001, EnvAllow: []string{"MY-VAR"}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := Config{
+				Repository: "https://gitlab.com/user/repo.git",
+				RepoName:   "test-repo",
+				WorkDir:    t.TempDir(),
+				Sandbox:    tt.sandbox,
+			}
+			err := config.Normalize()
+			if tt.valid && err != nil {
+				t.Errorf("Normalize() failed: %v", err)
+			}
+			if !tt.valid && err == nil {
+				t.Error("expected Normalize() to fail")
+			}
+			if tt.valid && config.Sandbox.Enabled && len(config.Sandbox.EnvAllow) == 0 {
+				t.Error("expected the default env_allow")
+			}
+		})
+	}
+}
+
+func TestExecutorFor(t *testing.T) {
+	config := Config{
+		Repository: "https://gitlab.com/user/repo.git",
+		RepoName:   "test-repo",
+		WorkDir:    t.TempDir(),
+		Executor: Executor{
+			ExecutorSettings: ExecutorSettings{Image: "golang:1.23"},
+			Rules: []ExecutorRule{
+				{Branch: "release/*", ExecutorSettings: ExecutorSettings{Type: ExecutorOCI}},
+				{Branch: "legacy", ExecutorSettings: ExecutorSettings{Type: ExecutorOCI, Image: "golang:1.20", Engine: EngineDocker}},
+			},
+		},
+	}
+	if err := config.Normalize(); err != nil {
+		t.Fatalf("Normalize() failed: %v", err)
+	}
+
+	tests := []struct {
+		branch string
+		want   ExecutorSettings
+	}{
+		{"main", ExecutorSettings{Type: ExecutorHost, Image: "golang:1.23", Engine: EnginePodman, Pull: PullMissing}},
+		{"release/v1", ExecutorSettings{Type: ExecutorOCI, Image: "golang:1.23", Engine: EnginePodman, Pull: PullMissing}},
+		{"legacy", ExecutorSettings{Type: ExecutorOCI, Image: "golang:1.20", Engine: EngineDocker, Pull: PullMissing}},
+	}
+	for _, tt := range tests {
+		if got := config.Executor.For(tt.branch); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("For(%q) = %+v, want %+v", tt.branch, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeExecutor(t *testing.T) {
+	tests := []struct {
+		name     string
+		executor Executor
+		valid    bool
+	}{
+		{"default", Executor{}, true},
+		{"oci", Executor{ExecutorSettings: ExecutorSettings{Type: ExecutorOCI, Image: "alpine"}}, true},
+		{"oci without image", Executor{ExecutorSettings: ExecutorSettings{Type: ExecutorOCI}}, false},
+		{"unknown type", Executor{ExecutorSettings: ExecutorSettings{Type: "vm"}}, false},
+		{"unknown engine", Executor{ExecutorSettings: ExecutorSettings{Engine: "lxc"}}, false},
+		{"unknown pull policy", Executor{ExecutorSettings: ExecutorSettings{Pull: "sometimes"}}, false},
+		{"rule without image", Executor{Rules: []ExecutorRule{{Branch: "main", ExecutorSettings: ExecutorSettings{Type: ExecutorOCI}}}}, false},
+		{"invalid rule pattern", Executor{Rules: []ExecutorRule{{Branch: "[", ExecutorSettings: ExecutorSettings{Type: ExecutorHost}}}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := Config{
+				Repository: "https://gitlab.com/user/repo.git",
+				RepoName:   "test-repo",
+				WorkDir:    t.TempDir(),
+				Executor:   tt.executor,
+			}
+			err := config.Normalize()
+			if tt.valid && err != nil {
+				t.Errorf("Normalize() failed: %v", err)
+			}
+			if !tt.valid && err == nil {
+				t.Error("expected Normalize() to fail")
+			}
+		})
+	}
+}
+
+func TestNormalizeWorkers(t *testing.T) {
+	ssh := Executor{ExecutorSettings: ExecutorSettings{Type: ExecutorSSH, Labels: []string{"large"}}}
+	lab := Worker{Name: "lab", Host: "ci@lab", Labels: []string{"large"}}
+
+	tests := []struct {
+		name     string
+		executor Executor
+		workers  []Worker
+		valid    bool
+	}{
+		{"ssh executor", ssh, []Worker{lab}, true},
+		{"no worker with the labels", ssh, []Worker{{Name: "small", Host: "ci@small"}}, false},
+		{"missing host", Executor{}, []Worker{{Name: "lab"}}, false},
+		{"invalid name", Executor{}, []Worker{{Name: "lab/1", Host: "ci@lab"}}, false},
+		{"duplicate name", Executor{}, []Worker{lab, lab}, false},
+		{"relative work_dir", Executor{}, []Worker{{Name: "lab", Host: "ci@lab", WorkDir: "ci"}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := Config{
+				Repository: "https://gitlab.com/user/repo.git",
+				RepoName:   "test-repo",
+				WorkDir:    t.TempDir(),
+				Executor:   tt.executor,
+				Workers:    tt.workers,
+			}
+			err := config.Normalize()
+			if tt.valid && err != nil {
+				t.Errorf("Normalize() failed: %v", err)
+			}
+			if !tt.valid && err == nil {
+				t.Error("expected Normalize() to fail")
+			}
+			if tt.valid {
+				worker := config.Workers[0]
+				if worker.Port != 22 || worker.Capacity != 1 || worker.WorkDir != DefaultWorkerWorkDir {
+					t.Errorf("unexpected worker defaults: %+v", worker)
+				}
+			}
+		})
+	}
+}
+
+func TestNormalizeCoordinator(t *testing.T) {
+	config := Config{
+		Repository: "https://gitlab.com/user/repo.git",
+		RepoName:   "test-repo",
+		WorkDir:    t.TempDir(),
+		Coordinator: Coordinator{
+			Listen:    ":8480",
+			TokenFile: "coordinator.token",
+			Rules: []AgentRule{
+				{Branch: "release/*", Labels: []string{"linux", "gpu"}},
+				{Branch: "", Labels: []string{"linux"}},
+			},
+		},
+	}
+	if err := config.Normalize(); err != nil {
+		t.Fatalf("Normalize() failed: %v", err)
+	}
+	if config.Coordinator.LeaseTTL != DefaultLeaseTTL {
+		t.Errorf("LeaseTTL = %s, want %s", config.Coordinator.LeaseTTL, DefaultLeaseTTL)
+	}
+	if got := config.Coordinator.LabelsFor("release/v1"); !reflect.DeepEqual(got, []string{"linux", "gpu"}) {
+		t.Errorf("LabelsFor(release/v1) = %v", got)
+	}
+	if got := config.Coordinator.LabelsFor("main"); !reflect.DeepEqual(got, []string{"linux"}) {
+		t.Errorf("LabelsFor(main) = %v", got)
+	}
+	if !HasLabels([]string{"gpu", "linux", "arm64"}, []string{"linux", "gpu"}) || HasLabels([]string{"linux"}, []string{"linux", "gpu"}) {
+		t.Error("HasLabels() must require all the labels")
+	}
+
+	// Without token, the job API only listens on the local host
+	config.Coordinator.TokenFile = ""
+	if err := config.Normalize(); err == nil {
+		t.Error("expected an error for a coordinator listening on all interfaces without token")
+	}
+	for _, listen := range []string{"127.0.0.1:8480", "localhost:8480", "[::1]:8480"} {
+		config.Coordinator.Listen = listen
+		if err := config.Normalize(); err != nil {
+			t.Errorf("Normalize() with listen %s failed: %v", listen, err)
+		}
+	}
+
+	// Without token, the API only listens on the local host
+	config.API.Listen = ":8481"
+	if err := config.Normalize(); err == nil {
+		t.Error("expected an error for an API listening on all interfaces without token")
+	}
+	config.API.TokenFile = "api.token"
+	if err := config.Normalize(); err != nil {
+		t.Errorf("Normalize() with an API token failed: %v", err)
+	}
+
+	config.Coordinator.Rules = []AgentRule{{Branch: "["}}
+	if err := config.Normalize(); err == nil {
+		t.Error("expected Normalize() to reject an invalid branch pattern")
+	}
+	config.Coordinator.Rules = nil
+	config.Coordinator.LeaseTTL = -time.Second
+	if err := config.Normalize(); err == nil {
+		t.Error("expected Normalize() to reject a negative lease_ttl")
+	}
+}
+
+func TestNormalizeLogs(t *testing.T) {
+	config := Config{
+		Repository: "https://gitlab.com/user/repo.git",
+		RepoName:   "test-repo",
+		WorkDir:    t.TempDir(),
+		Logs:       Logs{MaxSize: "100M"},
+	}
+	if err := config.Normalize(); err != nil {
+		t.Fatalf("Normalize() failed: %v", err)
+	}
+	if got := config.Logs.MaxBytes(); got != 100<<20 {
+		t.Errorf("MaxBytes() = %d, want %d", got, 100<<20)
+	}
+
+	config.Logs.MaxSize = "lots"
+	if err := config.Normalize(); err == nil {
+		t.Error("expected Normalize() to reject an invalid logs.max_size")
+	}
+}
+
+func TestLoadUnknownKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := "repository: https://github.com/owner/repo.git\ncache_dir: /var/cache\ngithub_actions_dispatch:\n  enabeld: true\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := Load(path)
+	if err == nil {
+		t.Fatal("expected Load() to reject unknown keys")
+	}
+	for _, expected := range []string{"line 2: unknown key 'cache_dir', the mirror is kept in <work_dir>/cache", "line 4: unknown key 'enabeld'"} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("Load() error %q misses %q", err, expected)
+		}
+	}
+
+	// An empty document keeps the defaults
+	config := Config{TestTimeout: time.Hour}
+	if err := decodeStrict(nil, &config); err != nil {
+		t.Fatalf("decodeStrict() of an empty document failed: %v", err)
+	}
+	if config.TestTimeout != time.Hour {
+		t.Errorf("decodeStrict() of an empty document changed test_timeout to %s", config.TestTimeout)
+	}
+}
@@ -0,0 +1,52 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// removedKeys explains the top-level keys of older configurations that are not settings anymore
+var removedKeys = map[string]string{
+	"cache_dir":       "the mirror is kept in <work_dir>/cache",
+	"state_dir":       "the state is kept in <work_dir>/state",
+	"workspace_dir":   "workspaces are created in <work_dir>/<repo_name>",
+	"log_dir":         "run logs are written in the workspace of each run",
+	"fetch_remote":    "the mirror is fetched at each check",
+	"has_result_file": "set github_actions_dispatch.has_result_file instead",
+}
+
+// unknownFieldPattern matches the errors of yaml.v3 for keys matching no field
+var unknownFieldPattern = regexp.MustCompile(`^line (\d+): field (\S+) not found in type (\S+)$`)
+
+// decodeStrict decodes a YAML document into out, rejecting the keys that match no setting
+func decodeStrict(data []byte, out any) error {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	err := decoder.Decode(out)
+	if errors.Is(err, io.EOF) {
+		// An empty document keeps the defaults
+		return nil
+	}
+
+	var typeErr *yaml.TypeError
+	if !errors.As(err, &typeErr) {
+		return err
+	}
+	messages := make([]string, len(typeErr.Errors))
+	for i, message := range typeErr.Errors {
+		if match := unknownFieldPattern.FindStringSubmatch(message); match != nil {
+			message = fmt.Sprintf("line %s: unknown key '%s'", match[1], match[2])
+			if hint, ok := removedKeys[match[2]]; ok && match[3] == "config.Config" {
+				message += ", " + hint
+			}
+		}
+		messages[i] = message
+	}
+	return errors.New(strings.Join(messages, "; "))
+}
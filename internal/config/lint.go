@@ -0,0 +1,124 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Issue is a problem of a configuration found by Lint
+type Issue struct {
+	Warning bool // The daemon can run, but probably not as intended
+	Message string
+}
+
+// String renders the issue with its level
+func (i Issue) String() string {
+	if i.Warning {
+		return "warning: " + i.Message
+	}
+	return "error: " + i.Message
+}
+
+// credentialFile is a file holding a secret, named by its setting
+type credentialFile struct {
+	setting string
+	path    string
+}
+
+// gitHubRepoPattern matches the owner/name of a GitHub repository
+var gitHubRepoPattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+/[A-Za-z0-9_.-]+$`)
+
+// Lint checks what Normalize does not: the credential files and their permissions, the
+// consistency of the durations, and the repository of the GitHub dispatch. Relative
+// files are resolved from the directory of configPath.
+func (c *Config) Lint(configPath string) []Issue {
+	var issues []Issue
+	errorf := func(format string, args ...any) {
+		issues = append(issues, Issue{Message: fmt.Sprintf(format, args...)})
+	}
+	warnf := func(format string, args ...any) {
+		issues = append(issues, Issue{Warning: true, Message: fmt.Sprintf(format, args...)})
+	}
+
+	// Credential files
+	files := []credentialFile{
+		{"git.auth.password_file", c.Git.Auth.PasswordFile},
+		{"git.auth.ssh_key_file", c.Git.Auth.SSHKeyFile},
+		{"git.auth.ssh_key_passphrase_file", c.Git.Auth.SSHKeyPassphraseFile},
+		{"coordinator.token_file", c.Coordinator.TokenFile},
+		{"agent.token_file", c.Agent.TokenFile},
+		{"api.token_file", c.API.TokenFile},
+	}
+	if c.GitHubActionsDispatch.Enabled {
+		if c.GitHubActionsDispatch.GitHubTokenFile == "" {
+			errorf("github_actions_dispatch.github_token_file is required when the dispatch is enabled")
+		}
+		files = append(files, credentialFile{"github_actions_dispatch.github_token_file", c.GitHubActionsDispatch.GitHubTokenFile})
+	}
+	for _, secret := range c.Secrets {
+		files = append(files, credentialFile{fmt.Sprintf("secret %s", secret.Name), secret.File})
+	}
+	configDir := filepath.Dir(configPath)
+	for _, file := range files {
+		if file.path == "" {
+			continue
+		}
+		info, err := os.Stat(resolveConfigPath(file.path, configDir))
+		switch {
+		case err != nil:
+			errorf("%s: %v", file.setting, err)
+		case !info.Mode().IsRegular():
+			errorf("%s: %s is not a regular file", file.setting, file.path)
+		case info.Mode().Perm()&0007 != 0:
+			errorf("%s: %s is accessible to all users (mode %04o), restrict it with chmod 600", file.setting, file.path, info.Mode().Perm())
+		case info.Mode().Perm()&0070 != 0:
+			warnf("%s: %s is accessible to its group (mode %04o)", file.setting, file.path, info.Mode().Perm())
+		}
+	}
+
+	// Durations
+	if c.TestTimeout <= 0 {
+		errorf("test_timeout must be positive, got %s", c.TestTimeout)
+	}
+	if c.CheckInterval <= 0 {
+		errorf("check_interval must be positive, got %s", c.CheckInterval)
+	}
+	if c.KeepTime > 0 && c.KeepTime < c.TestTimeout {
+		warnf("keep_time (%s) is shorter than test_timeout (%s), the workspace of a long run may be removed while it runs", c.KeepTime, c.TestTimeout)
+	}
+	if c.RecentCommitsWithin > 0 && c.RecentCommitsWithin < c.CheckInterval {
+		warnf("recent_commits_within (%s) is shorter than check_interval (%s), commits may be too old when they are checked", c.RecentCommitsWithin, c.CheckInterval)
+	}
+
+	if c.Sandbox.Enabled && !c.Sandbox.MountNamespace {
+		warnf("sandbox without mount_namespace: the runs of all the branches share the sandbox user and can write to each other's workspace")
+	}
+
+	// GitHub dispatch
+	if c.GitHubActionsDispatch.Enabled {
+		switch repo := c.GitHubActionsDispatch.GitHubRepo; {
+		case repo == "":
+			errorf("github_actions_dispatch.github_repo is not set and cannot be derived from repository")
+		case !gitHubRepoPattern.MatchString(repo):
+			errorf("github_actions_dispatch.github_repo '%s' is not in the owner/repo format", repo)
+		}
+	}
+
+	return issues
+}
+
+// resolveConfigPath resolves a path relative to the configuration directory, and ~/ to the home directory
+func resolveConfigPath(path, configDir string) string {
+	if strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, path[2:])
+		}
+	}
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(configDir, path)
+}
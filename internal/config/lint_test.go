@@ -0,0 +1,74 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLint(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.yaml")
+	writeFile := func(name string, mode os.FileMode) {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte("secret"), mode); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chmod(path, mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile("private", 0600)
+	writeFile("group", 0640)
+	writeFile("public", 0644)
+
+	valid := func() Config {
+		return Config{
+			Repository:    "https://github.com/owner/repo.git",
+			TestTimeout:   time.Hour,
+			CheckInterval: 5 * time.Minute,
+			KeepTime:      2 * time.Hour,
+			GitHubActionsDispatch: GitHubActionsDispatch{
+				Enabled:         true,
+				GitHubRepo:      "owner/repo",
+				GitHubTokenFile: "private",
+			},
+		}
+	}
+
+	tests := []struct {
+		name   string
+		modify func(*Config)
+		want   string
+	}{
+		{"valid", func(*Config) {}, ""},
+		{"token readable by all", func(c *Config) { c.GitHubActionsDispatch.GitHubTokenFile = "public" }, "error: github_actions_dispatch.github_token_file: public is accessible to all users"},
+		{"token readable by group", func(c *Config) { c.GitHubActionsDispatch.GitHubTokenFile = "group" }, "warning: github_actions_dispatch.github_token_file: group is accessible to its group"},
+		{"missing token", func(c *Config) { c.GitHubActionsDispatch.GitHubTokenFile = "missing" }, "error: github_actions_dispatch.github_token_file:"},
+		{"no token", func(c *Config) { c.GitHubActionsDispatch.GitHubTokenFile = "" }, "error: github_actions_dispatch.github_token_file is required"},
+		{"missing ssh key", func(c *Config) { c.Git.Auth.SSHKeyFile = filepath.Join(dir, "id_ed25519") }, "error: git.auth.ssh_key_file:"},
+		{"keep time shorter than timeout", func(c *Config) { c.KeepTime = 30 * time.Minute }, "warning: keep_time (30m0s) is shorter than test_timeout (1h0m0s)"},
+		{"no timeout", func(c *Config) { c.TestTimeout = 0 }, "error: test_timeout must be positive"},
+		{"sandbox without mount namespace", func(c *Config) { c.Sandbox = Sandbox{Enabled: true, UID: 1001, GID: 1001} }, "warning: sandbox without mount_namespace"},
+		{"sandbox with mount namespace", func(c *Config) { c.Sandbox = Sandbox{Enabled: true, UID: 1001, GID: 1001, MountNamespace: true} }, ""},
+		{"invalid github repo", func(c *Config) { c.GitHubActionsDispatch.GitHubRepo = "github.com/owner/repo" }, "error: github_actions_dispatch.github_repo 'github.com/owner/repo' is not in the owner/repo format"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := valid()
+			tt.modify(&config)
+			issues := config.Lint(configPath)
+			if tt.want == "" {
+				if len(issues) != 0 {
+					t.Errorf("Lint() = %v, want no issue", issues)
+				}
+				return
+			}
+			if len(issues) != 1 || !strings.HasPrefix(issues[0].String(), tt.want) {
+				t.Errorf("Lint() = %v, want %q", issues, tt.want)
+			}
+		})
+	}
+}
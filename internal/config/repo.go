@@ -0,0 +1,137 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultRepoConfigFile is the pipeline file read from the root of the tested project
+const DefaultRepoConfigFile = ".home-ci.yaml"
+
+// RepoOverridableKeys are the settings a repository file can set, they only apply to the current run
+var RepoOverridableKeys = []string{
+	"test_script",
+	"options",
+	"test_timeout",
+	"steps",
+	"cleanup",
+	"artifacts",
+	"test_reports",
+	"executor",
+}
+
+// RepoConfig controls which settings the tested repository can override with its own file
+type RepoConfig struct {
+	File        string   `yaml:"file"`         // Path relative to the project directory
+	AllowedKeys []string `yaml:"allowed_keys"` // Settings the repository may override, none by default
+}
+
+// validate checks that only overridable keys are allowed
+func (r RepoConfig) validate() error {
+	for _, key := range r.AllowedKeys {
+		if !isRepoOverridable(key) {
+			return fmt.Errorf("repo_config.allowed_keys: '%s' cannot be overridden by the repository, expected one of %s",
+				key, strings.Join(RepoOverridableKeys, ", "))
+		}
+	}
+	return nil
+}
+
+// isRepoOverridable reports whether a key can be set from the repository file
+func isRepoOverridable(key string) bool {
+	for _, overridable := range RepoOverridableKeys {
+		if key == overridable {
+			return true
+		}
+	}
+	return false
+}
+
+// ApplyRepoOverrides returns a copy of the configuration with the allowed settings of a repository file.
+// It also returns the keys that were applied and the keys that were ignored because they are not allowed.
+func (c Config) ApplyRepoOverrides(data []byte) (Config, []string, []string, error) {
+	var document map[string]yaml.Node
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return c, nil, nil, fmt.Errorf("cannot parse repository configuration: %w", err)
+	}
+
+	allowed := make(map[string]bool)
+	for _, key := range c.RepoConfig.AllowedKeys {
+		allowed[key] = true
+	}
+
+	kept := make(map[string]yaml.Node)
+	var applied, ignored []string
+	for key, node := range document {
+		if allowed[key] {
+			kept[key] = node
+			applied = append(applied, key)
+		} else {
+			ignored = append(ignored, key)
+		}
+	}
+	// The options of the container run command could give the container the privileges of the engine
+	if node, ok := kept["executor"]; ok && dropExecutorOptions(&node) {
+		kept["executor"] = node
+		ignored = append(ignored, "executor.options")
+	}
+	sort.Strings(applied)
+	sort.Strings(ignored)
+
+	if len(kept) == 0 {
+		return c, applied, ignored, nil
+	}
+
+	// Decode the allowed keys on top of a copy, slices and maps are replaced, not merged
+	merged := c
+	filtered, err := yaml.Marshal(kept)
+	if err != nil {
+		return c, nil, nil, fmt.Errorf("cannot encode repository overrides: %w", err)
+	}
+	if err := decodeStrict(filtered, &merged); err != nil {
+		return c, nil, nil, fmt.Errorf("cannot apply repository configuration: %w", err)
+	}
+
+	if err := merged.Normalize(); err != nil {
+		return c, nil, nil, fmt.Errorf("invalid repository configuration: %w", err)
+	}
+
+	return merged, applied, ignored, nil
+}
+
+// dropExecutorOptions removes the options of an executor section and of its rules,
+// it reports whether there were any
+func dropExecutorOptions(node *yaml.Node) bool {
+	dropped := removeKey(node, "options")
+	if node.Kind != yaml.MappingNode {
+		return dropped
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value != "rules" || node.Content[i+1].Kind != yaml.SequenceNode {
+			continue
+		}
+		for _, rule := range node.Content[i+1].Content {
+			if removeKey(rule, "options") {
+				dropped = true
+			}
+		}
+	}
+	return dropped
+}
+
+// removeKey removes key from a mapping node, it reports whether it was present
+func removeKey(node *yaml.Node, key string) bool {
+	if node.Kind != yaml.MappingNode {
+		return false
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			node.Content = append(node.Content[:i], node.Content[i+2:]...)
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,111 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestApplyRepoOverrides(t *testing.T) {
+	base := Config{
+		Repository:  "https://gitlab.com/user/repo.git",
+		RepoName:    "repo",
+		WorkDir:     t.TempDir(),
+		TestScript:  "e2e/run.sh",
+		Options:     "-c",
+		TestTimeout: 30 * time.Minute,
+		RepoConfig:  RepoConfig{AllowedKeys: []string{"options", "steps", "test_timeout"}},
+	}
+
+	data := []byte(`
+options: "-c -i ztf"
+test_timeout: 45m
+test_script: "/bin/rm"
+steps:
+  - name: test
+    command: make e2e
+`)
+
+	merged, applied, ignored, err := base.ApplyRepoOverrides(data)
+	if err != nil {
+		t.Fatalf("ApplyRepoOverrides() failed: %v", err)
+	}
+
+	if merged.Options != "-c -i ztf" || merged.TestTimeout != 45*time.Minute {
+		t.Errorf("allowed keys not applied: options=%q test_timeout=%s", merged.Options, merged.TestTimeout)
+	}
+	if len(merged.Steps) != 1 || merged.Steps[0].Command != "make e2e" {
+		t.Errorf("steps not applied: %+v", merged.Steps)
+	}
+	if merged.TestScript != "e2e/run.sh" {
+		t.Errorf("test_script is not allowed and must not change, got %q", merged.TestScript)
+	}
+	if len(applied) != 3 || len(ignored) != 1 || ignored[0] != "test_script" {
+		t.Errorf("unexpected applied=%v ignored=%v", applied, ignored)
+	}
+
+	// The daemon configuration is left untouched
+	if base.Options != "-c" || len(base.Steps) != 0 {
+		t.Error("ApplyRepoOverrides() modified the base configuration")
+	}
+}
+
+func TestApplyRepoOverridesExecutorOptions(t *testing.T) {
+	base := Config{
+		Repository: "https://gitlab.com/user/repo.git",
+		RepoName:   "repo",
+		WorkDir:    t.TempDir(),
+		Executor: Executor{ExecutorSettings: ExecutorSettings{
+			Type: ExecutorOCI, Image: "golang:1.22", Options: []string{"--network=host"}}},
+		RepoConfig: RepoConfig{AllowedKeys: []string{"executor"}},
+	}
+
+	data := []byte(`
+executor:
+  image: golang:1.23
+  options: ["--privileged"]
+  rules:
+    - branch: "release/*"
+      image: golang:1.21
+      options: ["--volume=/:/host"]
+`)
+	merged, _, ignored, err := base.ApplyRepoOverrides(data)
+	if err != nil {
+		t.Fatalf("ApplyRepoOverrides() failed: %v", err)
+	}
+	if merged.Executor.Image != "golang:1.23" || merged.Executor.For("release/1.0").Image != "golang:1.21" {
+		t.Errorf("executor image not applied: %+v", merged.Executor)
+	}
+	for _, branch := range []string{"main", "release/1.0"} {
+		if options := merged.Executor.For(branch).Options; len(options) != 1 || options[0] != "--network=host" {
+			t.Errorf("options of %s = %v, want the options of the daemon", branch, options)
+		}
+	}
+	if len(ignored) != 1 || ignored[0] != "executor.options" {
+		t.Errorf("ignored = %v, want executor.options", ignored)
+	}
+}
+
+func TestApplyRepoOverridesInvalid(t *testing.T) {
+	base := Config{
+		Repository: "https://gitlab.com/user/repo.git",
+		RepoName:   "repo",
+		WorkDir:    t.TempDir(),
+		RepoConfig: RepoConfig{AllowedKeys: []string{"steps"}},
+	}
+
+	if _, _, _, err := base.ApplyRepoOverrides([]byte("steps:\n  - name: test\n")); err == nil {
+		t.Error("expected an error for a step without command")
+	}
+}
+
+func TestRepoConfigAllowedKeysValidation(t *testing.T) {
+	c := Config{
+		Repository: "https://gitlab.com/user/repo.git",
+		RepoName:   "repo",
+		WorkDir:    t.TempDir(),
+		RepoConfig: RepoConfig{AllowedKeys: []string{"github_actions_dispatch"}},
+	}
+	if err := c.Normalize(); err == nil {
+		t.Error("expected github_actions_dispatch to be rejected from allowed_keys")
+	}
+}
@@ -0,0 +1,110 @@
+package control
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ErrDaemonNotRunning is returned when no daemon listens on the control socket
+var ErrDaemonNotRunning = errors.New("daemon is not running")
+
+// clientTimeout bounds the requests to the daemon, which answer without waiting for the runs
+const clientTimeout = 30 * time.Second
+
+// Client sends the control commands to a daemon
+type Client struct {
+	socket string
+	http   *http.Client
+}
+
+// NewClient creates a client of the daemon listening on the socket at path
+func NewClient(path string) *Client {
+	return &Client{
+		socket: path,
+		http: &http.Client{
+			Timeout: clientTimeout,
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var dialer net.Dialer
+					return dialer.DialContext(ctx, "unix", path)
+				},
+			},
+		},
+	}
+}
+
+// Queue returns the running tests and the queue of the daemon
+func (c *Client) Queue() (Queue, error) {
+	var queue Queue
+	err := c.do(http.MethodGet, "/v1/queue", nil, &queue)
+	return queue, err
+}
+
+// Trigger queues a run of a branch, at its latest commit when commit is empty
+func (c *Client) Trigger(branch, commit string) (Queued, error) {
+	var queued Queued
+	err := c.do(http.MethodPost, "/v1/trigger", TriggerRequest{Branch: branch, Commit: commit}, &queued)
+	return queued, err
+}
+
+// Cancel removes the queued jobs of a run and stops it
+func (c *Client) Cancel(runID string) (Cancelled, error) {
+	var cancelled Cancelled
+	err := c.do(http.MethodPost, "/v1/runs/"+url.PathEscape(runID)+"/cancel", nil, &cancelled)
+	return cancelled, err
+}
+
+// Rerun queues a new run of the commit of a previous run
+func (c *Client) Rerun(runID string) (Queued, error) {
+	var queued Queued
+	err := c.do(http.MethodPost, "/v1/runs/"+url.PathEscape(runID)+"/rerun", nil, &queued)
+	return queued, err
+}
+
+// do sends a request to the daemon and decodes its JSON response into out
+func (c *Client) do(method, path string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, "http://home-ci"+path, body)
+	if err != nil {
+		return err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		var opErr *net.OpError
+		if errors.As(err, &opErr) && opErr.Op == "dial" {
+			return fmt.Errorf("%w: no daemon listening on %s", ErrDaemonNotRunning, c.socket)
+		}
+		return fmt.Errorf("request to the daemon failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return errors.New(strings.TrimSpace(string(message)))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("invalid response from the daemon: %w", err)
+	}
+	return nil
+}
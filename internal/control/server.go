@@ -0,0 +1,216 @@
+// Package control serves the commands of the CLI to a running daemon over a local
+// Unix socket: trigger, cancel and rerun runs, and list the queue. The jobs go
+// through the scheduler of the daemon, like the ones queued by the monitor.
+package control
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/k8s-school/home-ci/internal/config"
+	"github.com/k8s-school/home-ci/internal/runner"
+)
+
+// socketPerm allows the group of the daemon to use the socket
+const socketPerm = 0660
+
+// ErrDaemonRunning is returned by Listen when another daemon listens on the socket
+var ErrDaemonRunning = errors.New("another daemon is listening on the control socket")
+
+// Run is a running test
+type Run struct {
+	ID        string    `json:"id"`
+	Branch    string    `json:"branch"`
+	Commit    string    `json:"commit"`
+	StartTime time.Time `json:"start_time"`
+}
+
+// Queue lists the running tests and the jobs waiting in the queue
+type Queue struct {
+	Running []Run              `json:"running"`
+	Queued  []runner.QueuedJob `json:"queued"`
+}
+
+// TriggerRequest asks for a run of a branch, at its latest commit when Commit is empty
+type TriggerRequest struct {
+	Branch string `json:"branch"`
+	Commit string `json:"commit,omitempty"`
+}
+
+// Queued is a run queued by trigger or rerun
+type Queued struct {
+	ID     string `json:"id"`
+	Branch string `json:"branch"`
+	Commit string `json:"commit"`
+}
+
+// Cancelled is the outcome of a cancel request
+type Cancelled struct {
+	Dequeued int  `json:"dequeued"` // Jobs of the run removed from the queue
+	Stopped  bool `json:"stopped"`  // The running run was stopped
+}
+
+// ResolveFunc returns the full hash of commit on branch, the latest commit of the
+// branch when commit is empty
+type ResolveFunc func(branch, commit string) (string, error)
+
+// Server serves the control commands of a daemon
+type Server struct {
+	config       *config.Config
+	runner       *runner.TestRunner
+	stateManager runner.StateManager
+	resolve      ResolveFunc
+}
+
+// NewServer creates the control server of the daemon running tr
+func NewServer(cfg *config.Config, tr *runner.TestRunner, stateManager runner.StateManager, resolve ResolveFunc) *Server {
+	return &Server{config: cfg, runner: tr, stateManager: stateManager, resolve: resolve}
+}
+
+// Handler returns the HTTP handler of the control commands
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/queue", s.handleQueue)
+	mux.HandleFunc("POST /v1/trigger", s.handleTrigger)
+	mux.HandleFunc("POST /v1/runs/{run}/cancel", s.handleCancel)
+	mux.HandleFunc("POST /v1/runs/{run}/rerun", s.handleRerun)
+	return mux
+}
+
+// Listen creates the socket at path. A socket left by a daemon that is not running
+// anymore is replaced, the socket of a running daemon is not.
+func Listen(path string) (net.Listener, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create control socket directory: %w", err)
+	}
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("%w %s", ErrDaemonRunning, path)
+		}
+		os.Remove(path)
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", path, err)
+	}
+	if err := os.Chmod(path, socketPerm); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to set control socket permissions: %w", err)
+	}
+	return listener, nil
+}
+
+// Serve runs the control server on listener until ctx is done, the socket is then removed
+func (s *Server) Serve(ctx context.Context, listener net.Listener) error {
+	server := &http.Server{
+		Handler:     s.Handler(),
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+
+	slog.Debug("Control socket listening", "socket", listener.Addr().String())
+	if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("control server failed: %w", err)
+	}
+	return nil
+}
+
+// handleQueue lists the running tests and the queue
+func (s *Server) handleQueue(w http.ResponseWriter, r *http.Request) {
+	queue := Queue{Running: []Run{}, Queued: s.runner.GetQueuedJobs()}
+	for _, test := range s.stateManager.GetRunningTests() {
+		queue.Running = append(queue.Running, Run{
+			ID:        s.config.GetRunID(test.Branch, test.Commit),
+			Branch:    test.Branch,
+			Commit:    test.Commit,
+			StartTime: test.StartTime,
+		})
+	}
+	writeJSON(w, queue)
+}
+
+// handleTrigger queues a run of a branch
+func (s *Server) handleTrigger(w http.ResponseWriter, r *http.Request) {
+	var req TriggerRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("invalid trigger request: %v", err), http.StatusBadRequest)
+		return
+	}
+	if req.Branch == "" {
+		http.Error(w, "branch is required", http.StatusBadRequest)
+		return
+	}
+
+	commit, err := s.resolve(req.Branch, req.Commit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	s.queue(w, req.Branch, commit)
+}
+
+// handleCancel removes the queued jobs of a run and stops it
+func (s *Server) handleCancel(w http.ResponseWriter, r *http.Request) {
+	dequeued, stopped, err := s.runner.CancelRun(r.PathValue("run"))
+	if err != nil {
+		http.Error(w, err.Error(), statusFor(err))
+		return
+	}
+	writeJSON(w, Cancelled{Dequeued: dequeued, Stopped: stopped})
+}
+
+// handleRerun queues a new run of the commit of a previous run
+func (s *Server) handleRerun(w http.ResponseWriter, r *http.Request) {
+	branch, commit, err := s.runner.FindRun(r.PathValue("run"))
+	if err != nil {
+		http.Error(w, err.Error(), statusFor(err))
+		return
+	}
+	s.queue(w, branch, commit)
+}
+
+// queue queues a manual run and sends it
+func (s *Server) queue(w http.ResponseWriter, branch, commit string) {
+	if _, err := s.runner.QueueManualRun(branch, commit); err != nil {
+		http.Error(w, err.Error(), statusFor(err))
+		return
+	}
+	slog.Info("Run queued from the CLI", "branch", branch, "commit", commit)
+	writeJSON(w, Queued{ID: s.config.GetRunID(branch, commit), Branch: branch, Commit: commit})
+}
+
+// statusFor returns the HTTP status of an error of the runner
+func statusFor(err error) int {
+	switch {
+	case errors.Is(err, runner.ErrRunNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, runner.ErrRunLeased), errors.Is(err, runner.ErrRunQueued):
+		return http.StatusConflict
+	default:
+		return http.StatusServiceUnavailable
+	}
+}
+
+// writeJSON sends value as the JSON body of the response
+func writeJSON(w http.ResponseWriter, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		slog.Error("Failed to send response", "error", err)
+	}
+}
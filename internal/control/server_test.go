@@ -0,0 +1,138 @@
+package control
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/k8s-school/home-ci/internal/config"
+	"github.com/k8s-school/home-ci/internal/runner"
+	"github.com/k8s-school/home-ci/internal/state"
+)
+
+// socketPath returns a socket path short enough for the limit of Unix sockets
+func socketPath(t *testing.T) string {
+	t.Helper()
+	dir, err := os.MkdirTemp("", "home-ci")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return filepath.Join(dir, "control.sock")
+}
+
+// startServer serves the control commands of a runner that does not start its jobs
+func startServer(t *testing.T) (*Client, *runner.TestRunner) {
+	t.Helper()
+
+	cfg := config.Config{RepoName: "test-repo", WorkDir: t.TempDir(), MaxConcurrentRuns: 1}
+	stateManager := state.NewStateManager(cfg.GetStateDir(), cfg.RepoName)
+	tr, err := runner.NewTestRunner(cfg, "", "", context.Background(), stateManager)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resolve := func(branch, commit string) (string, error) {
+		if branch != "main" {
+			return "", fmt.Errorf("branch %s not found", branch)
+		}
+		if commit == "" {
+			return "0123456789abcdef", nil
+		}
+		return commit, nil
+	}
+
+	path := socketPath(t)
+	listener, err := Listen(path)
+	if err != nil {
+		t.Fatalf("Listen() failed: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		NewServer(&cfg, tr, stateManager, resolve).Serve(ctx, listener)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return NewClient(path), tr
+}
+
+func TestControlCommands(t *testing.T) {
+	client, tr := startServer(t)
+
+	queued, err := client.Trigger("main", "")
+	if err != nil {
+		t.Fatalf("Trigger() failed: %v", err)
+	}
+	if queued.ID != "main_01234567" || queued.Commit != "0123456789abcdef" {
+		t.Errorf("Trigger() = %+v", queued)
+	}
+	if _, err := client.Trigger("main", ""); err == nil {
+		t.Error("Trigger() of a queued commit must fail")
+	}
+	if _, err := client.Trigger("unknown", ""); err == nil {
+		t.Error("Trigger() of an unknown branch must fail")
+	}
+
+	queue, err := client.Queue()
+	if err != nil {
+		t.Fatalf("Queue() failed: %v", err)
+	}
+	if len(queue.Queued) != 1 || queue.Queued[0].Trigger != runner.TriggerManual {
+		t.Errorf("Queue() = %+v, want the triggered job", queue)
+	}
+
+	// The commit of the queued run is found, and not queued twice
+	if _, err := client.Rerun("main_01234567"); err == nil {
+		t.Error("Rerun() of a queued run must fail")
+	}
+
+	cancelled, err := client.Cancel("main_01234567")
+	if err != nil || cancelled.Dequeued != 1 || cancelled.Stopped {
+		t.Errorf("Cancel() = %+v, %v", cancelled, err)
+	}
+	if n := len(tr.GetQueuedJobs()); n != 0 {
+		t.Errorf("expected an empty queue, got %d jobs", n)
+	}
+	if _, err := client.Cancel("main_01234567"); err == nil {
+		t.Error("Cancel() of an unknown run must fail")
+	}
+}
+
+func TestListenSocketInUse(t *testing.T) {
+	client, _ := startServer(t)
+
+	// The socket of a running daemon is not replaced
+	if _, err := Listen(client.socket); !errors.Is(err, ErrDaemonRunning) {
+		t.Fatalf("Listen() on the socket of a running daemon = %v, want ErrDaemonRunning", err)
+	}
+}
+
+func TestListenStaleSocket(t *testing.T) {
+	path := socketPath(t)
+	listener, err := Listen(path)
+	if err != nil {
+		t.Fatalf("Listen() failed: %v", err)
+	}
+	// Keep the socket file, as a daemon that was killed does
+	listener.(interface{ SetUnlinkOnClose(bool) }).SetUnlinkOnClose(false)
+	listener.Close()
+
+	listener, err = Listen(path)
+	if err != nil {
+		t.Fatalf("Listen() on a stale socket failed: %v", err)
+	}
+	listener.Close()
+}
+
+func TestClientDaemonNotRunning(t *testing.T) {
+	client := NewClient(socketPath(t))
+	if _, err := client.Queue(); !errors.Is(err, ErrDaemonNotRunning) {
+		t.Errorf("Queue() = %v, want %v", err, ErrDaemonNotRunning)
+	}
+}
@@ -0,0 +1,208 @@
+package coordinator
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/k8s-school/home-ci/internal/runner"
+	"github.com/k8s-school/home-ci/internal/utils"
+)
+
+// retryInterval is how long an agent waits after failing to reach the coordinator
+const retryInterval = 10 * time.Second
+
+// ErrLeaseLost is returned when the coordinator no longer knows a lease
+var ErrLeaseLost = errors.New("lease lost")
+
+// Agent runs the jobs leased from a coordinator with a local test runner
+type Agent struct {
+	url    string
+	name   string
+	labels []string
+	token  string
+	runner *runner.TestRunner
+	client *http.Client
+}
+
+// NewAgent creates an agent leasing jobs from the coordinator at url
+func NewAgent(url, name string, labels []string, token string, tr *runner.TestRunner) *Agent {
+	return &Agent{
+		url:    strings.TrimSuffix(url, "/"),
+		name:   name,
+		labels: labels,
+		token:  token,
+		runner: tr,
+		client: &http.Client{},
+	}
+}
+
+// Run leases and runs jobs, up to slots at the same time, until ctx is done.
+// The running jobs are completed before it returns.
+func (a *Agent) Run(ctx context.Context, slots int) {
+	slog.Info("Agent started", "coordinator", a.url, "name", a.name, "labels", a.labels, "slots", slots)
+
+	var wg sync.WaitGroup
+	for i := 0; i < slots; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				if _, err := a.RunOnce(ctx); err != nil && ctx.Err() == nil {
+					slog.Error("Failed to run a leased job", "coordinator", a.url, "error", err)
+					select {
+					case <-ctx.Done():
+					case <-time.After(retryInterval):
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// RunOnce waits for a job and runs it. It reports whether a job was run.
+func (a *Agent) RunOnce(ctx context.Context) (bool, error) {
+	lease, err := a.lease(ctx)
+	if err != nil || lease == nil {
+		return false, err
+	}
+	job := lease.Job
+	slog.Info("Running leased job", "branch", job.Branch, "commit", utils.ShortCommit(job.Commit), "attempt", job.Attempt, "lease", lease.ID)
+
+	// The heartbeats stop with the job, not with ctx, so that the job keeps its lease
+	heartbeatCtx, stopHeartbeats := context.WithCancel(context.Background())
+	go a.heartbeat(heartbeatCtx, lease)
+
+	// The files are archived before the workspace is removed, and sent with the result
+	files, err := os.CreateTemp("", "home-ci-files-*.tar")
+	if err != nil {
+		stopHeartbeats()
+		return true, fmt.Errorf("failed to create the archive of the files: %w", err)
+	}
+	defer os.Remove(files.Name())
+	defer files.Close()
+
+	result := a.runner.RunLeasedJob(runner.TestJob{
+		Branch:         job.Branch,
+		Commit:         job.Commit,
+		Trigger:        job.Trigger,
+		Attempt:        job.Attempt,
+		PreviousCommit: job.PreviousCommit,
+	}, &leaseLog{agent: a, id: lease.ID}, files)
+	stopHeartbeats()
+
+	if info, err := files.Stat(); err == nil && info.Size() > 0 {
+		if _, err := files.Seek(0, io.SeekStart); err == nil {
+			err = a.send(context.Background(), lease.ID+"/files", files, nil)
+		}
+		if err != nil {
+			slog.Error("Failed to send the files of the job", "lease", lease.ID, "error", err)
+		}
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return true, fmt.Errorf("failed to marshal result: %w", err)
+	}
+	if err := a.post(context.Background(), lease.ID+"/result", data, nil); err != nil {
+		return true, fmt.Errorf("failed to report the result of %s at %s: %w", job.Branch, utils.ShortCommit(job.Commit), err)
+	}
+	slog.Info("Leased job completed", "branch", job.Branch, "commit", utils.ShortCommit(job.Commit), "success", result.Success)
+	return true, nil
+}
+
+// lease waits for a job, it returns nil when the coordinator has none
+func (a *Agent) lease(ctx context.Context) (*Lease, error) {
+	data, err := json.Marshal(LeaseRequest{Agent: a.name, Labels: a.labels})
+	if err != nil {
+		return nil, err
+	}
+	var lease Lease
+	if err := a.post(ctx, "", data, &lease); err != nil {
+		return nil, err
+	}
+	if lease.ID == "" {
+		return nil, nil
+	}
+	return &lease, nil
+}
+
+// heartbeat renews the lease until ctx is done
+func (a *Agent) heartbeat(ctx context.Context, lease *Lease) {
+	ticker := time.NewTicker(lease.TTL / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := a.post(ctx, lease.ID+"/heartbeat", nil, nil); err != nil && ctx.Err() == nil {
+				slog.Error("Failed to renew lease", "lease", lease.ID, "error", err)
+			}
+		}
+	}
+}
+
+// post sends a request to the leases API, path being relative to it. The JSON
+// response is decoded into response when there is one.
+func (a *Agent) post(ctx context.Context, path string, body []byte, response any) error {
+	return a.send(ctx, path, bytes.NewReader(body), response)
+}
+
+// send is post with a streamed body
+func (a *Agent) send(ctx context.Context, path string, body io.Reader, response any) error {
+	url := a.url + leasesPath
+	if path != "" {
+		url += "/" + path
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, body)
+	if err != nil {
+		return err
+	}
+	if a.token != "" {
+		req.Header.Set("Authorization", "Bearer "+a.token)
+	}
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusGone:
+		return ErrLeaseLost
+	case resp.StatusCode >= 300:
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(message)))
+	case resp.StatusCode == http.StatusNoContent || response == nil:
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(response)
+}
+
+// leaseLog sends the log of a leased job to the coordinator
+type leaseLog struct {
+	agent  *Agent
+	id     string
+	failed bool // The first failure is logged, the job keeps running
+}
+
+// Write implements io.Writer
+func (l *leaseLog) Write(p []byte) (int, error) {
+	if err := l.agent.post(context.Background(), l.id+"/log", p, nil); err != nil && !l.failed {
+		l.failed = true
+		slog.Error("Failed to send the log to the coordinator", "lease", l.id, "error", err)
+	}
+	return len(p), nil
+}
@@ -0,0 +1,224 @@
+package coordinator
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/k8s-school/home-ci/internal/config"
+	"github.com/k8s-school/home-ci/internal/runner"
+	"github.com/k8s-school/home-ci/internal/state"
+)
+
+// defaultScript is a test script printing a message
+const defaultScript = "#!/bin/sh\necho hello from the agent\n"
+
+// gitRepo creates a repository with the given test script
+func gitRepo(t *testing.T, script string) (string, string) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git command not available")
+	}
+
+	dir := t.TempDir()
+	git := func(args ...string) string {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=Test User", "GIT_AUTHOR_EMAIL=test@example.com",
+			"GIT_COMMITTER_NAME=Test User", "GIT_COMMITTER_EMAIL=test@example.com")
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %s failed: %v\n%s", strings.Join(args, " "), err, output)
+		}
+		return strings.TrimSpace(string(output))
+	}
+
+	git("init", "-q", "-b", "main")
+	if err := os.WriteFile(filepath.Join(dir, "test.sh"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	git("add", "test.sh")
+	git("commit", "-q", "-m", "Add test script")
+	return dir, git("rev-parse", "HEAD")
+}
+
+// newConfig returns a normalized configuration testing repo
+func newConfig(t *testing.T, repo string) config.Config {
+	t.Helper()
+	cfg := config.Config{
+		Repository:  repo,
+		RepoName:    "test-repo",
+		WorkDir:     t.TempDir(),
+		TestScript:  "test.sh",
+		TestTimeout: time.Minute,
+		KeepTime:    time.Hour,
+	}
+	if err := cfg.Normalize(); err != nil {
+		t.Fatalf("Normalize() failed: %v", err)
+	}
+	return cfg
+}
+
+// newCoordinator creates a coordinator runner and its API
+func newCoordinator(t *testing.T, cfg config.Config, ttl time.Duration, token string) (*runner.TestRunner, *Server, *httptest.Server) {
+	t.Helper()
+	stateManager := state.NewStateManager(cfg.GetStateDir(), cfg.RepoName)
+	tr, err := runner.NewTestRunner(cfg, "", cfg.WorkDir, context.Background(), stateManager)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(tr.Close)
+
+	server := NewServer(tr, ttl, token)
+	server.pollTimeout = 100 * time.Millisecond
+	httpServer := httptest.NewServer(server.Handler())
+	t.Cleanup(httpServer.Close)
+	return tr, server, httpServer
+}
+
+func TestAgentRunsLeasedJob(t *testing.T) {
+	repo, commit := gitRepo(t, defaultScript)
+	cfg := newConfig(t, repo)
+	coordinator, _, httpServer := newCoordinator(t, cfg, time.Minute, "s3cr3t")
+
+	agentCfg := newConfig(t, repo)
+	agentCfg.KeepTime = 0
+	agentRunner, err := runner.NewTestRunner(agentCfg, "", agentCfg.WorkDir, context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	agent := NewAgent(httpServer.URL, "agent-1", []string{"linux"}, "s3cr3t", agentRunner)
+
+	// No job yet
+	if ran, err := agent.RunOnce(context.Background()); ran || err != nil {
+		t.Fatalf("RunOnce() on an empty queue = %v, %v", ran, err)
+	}
+
+	coordinator.QueueTestJob(runner.TestJob{Branch: "main", Commit: commit})
+	if ran, err := agent.RunOnce(context.Background()); !ran || err != nil {
+		t.Fatalf("RunOnce() = %v, %v", ran, err)
+	}
+
+	// The coordinator recorded the result and the log of the agent
+	logsDir := cfg.GetLogsDir("main", commit)
+	data, err := os.ReadFile(filepath.Join(logsDir, "run.json"))
+	if err != nil {
+		t.Fatalf("result not recorded by the coordinator: %v", err)
+	}
+	var result runner.TestResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		t.Fatal(err)
+	}
+	if !result.Success || len(result.Attempts) != 1 || result.Commit != commit {
+		t.Errorf("unexpected result %+v", result)
+	}
+	log, _ := os.ReadFile(filepath.Join(logsDir, "run.log"))
+	for _, want := range []string{"Agent: agent-1", "hello from the agent", "Result reported by agent-1"} {
+		if !strings.Contains(string(log), want) {
+			t.Errorf("coordinator run.log misses %q:\n%s", want, log)
+		}
+	}
+
+	// The agent removed its workspace
+	if _, err := os.Stat(agentCfg.GetWorkspaceDir("main", commit)); !os.IsNotExist(err) {
+		t.Errorf("expected the agent workspace to be removed, got %v", err)
+	}
+}
+
+func TestAgentSendsFiles(t *testing.T) {
+	repo, commit := gitRepo(t, `#!/bin/sh
+printf 'status: failed\n' > "$HOME_CI_RESULT_FILE"
+echo data > "$HOME_CI_ARTIFACTS_DIR/data.txt"
+mkdir -p out && echo '<testsuite name="e2e"><testcase name="TestDeploy"/></testsuite>' > out/junit.xml
+`)
+	cfg := newConfig(t, repo)
+	cfg.TestReports.JUnit = []string{"out/*.xml"}
+	coordinator, _, httpServer := newCoordinator(t, cfg, time.Minute, "")
+
+	agentCfg := newConfig(t, repo)
+	agentCfg.KeepTime = 0
+	agentCfg.TestReports.JUnit = []string{"out/*.xml"}
+	agentRunner, err := runner.NewTestRunner(agentCfg, "", agentCfg.WorkDir, context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	agent := NewAgent(httpServer.URL, "agent-1", nil, "", agentRunner)
+
+	coordinator.QueueTestJob(runner.TestJob{Branch: "main", Commit: commit})
+	if ran, err := agent.RunOnce(context.Background()); !ran || err != nil {
+		t.Fatalf("RunOnce() = %v, %v", ran, err)
+	}
+
+	// The report policy of the coordinator fails the run on the report of the agent
+	logsDir := cfg.GetLogsDir("main", commit)
+	data, err := os.ReadFile(filepath.Join(logsDir, "run.json"))
+	if err != nil {
+		t.Fatalf("result not recorded by the coordinator: %v", err)
+	}
+	var result runner.TestResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Success || result.Report == nil || result.Report.Status != "failed" {
+		t.Errorf("expected a failure from the report, got %+v", result)
+	}
+	if _, err := os.Stat(filepath.Join(logsDir, "e2e-report.yaml")); err != nil {
+		t.Errorf("report not stored by the coordinator: %v", err)
+	}
+	if result.Tests == nil || result.Tests.Total != 1 {
+		t.Errorf("expected the test case of the JUnit report, got %+v", result.Tests)
+	}
+
+	// The artifacts are in the store of the coordinator
+	runID := cfg.GetRunID("main", commit)
+	if len(result.Artifacts) != 1 || result.Artifacts[0].Path != "data.txt" {
+		t.Errorf("artifacts = %+v", result.Artifacts)
+	}
+	if _, err := os.Stat(filepath.Join(cfg.GetArtifactsDir(), runID, "data.txt")); err != nil {
+		t.Errorf("artifact not stored by the coordinator: %v", err)
+	}
+}
+
+func TestAgentToken(t *testing.T) {
+	repo, _ := gitRepo(t, defaultScript)
+	cfg := newConfig(t, repo)
+	_, _, httpServer := newCoordinator(t, cfg, time.Minute, "s3cr3t")
+
+	agent := NewAgent(httpServer.URL, "agent-1", nil, "wrong", nil)
+	if _, err := agent.RunOnce(context.Background()); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("expected the coordinator to reject the token, got %v", err)
+	}
+}
+
+func TestLeaseExpires(t *testing.T) {
+	repo, commit := gitRepo(t, defaultScript)
+	cfg := newConfig(t, repo)
+	coordinator, server, httpServer := newCoordinator(t, cfg, 10*time.Millisecond, "")
+	coordinator.QueueTestJob(runner.TestJob{Branch: "main", Commit: commit})
+
+	agent := NewAgent(httpServer.URL, "agent-1", nil, "", nil)
+	lease, err := agent.lease(context.Background())
+	if err != nil || lease == nil {
+		t.Fatalf("lease() = %v, %v", lease, err)
+	}
+	if jobs := coordinator.GetQueuedJobs(); len(jobs) != 0 {
+		t.Fatalf("expected the leased job to leave the queue, got %+v", jobs)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	server.ExpireLeases()
+
+	if err := agent.post(context.Background(), lease.ID+"/heartbeat", nil, nil); err != ErrLeaseLost {
+		t.Errorf("heartbeat of an expired lease = %v, want %v", err, ErrLeaseLost)
+	}
+	if jobs := coordinator.GetQueuedJobs(); len(jobs) != 1 || jobs[0].Commit != commit {
+		t.Errorf("expected the job to be queued again, got %+v", jobs)
+	}
+}
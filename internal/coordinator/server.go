@@ -0,0 +1,313 @@
+// Package coordinator serves the queued jobs of a daemon to agents over HTTP.
+//
+// Agents long-poll for a job matching their labels and lease it. While the job
+// runs, the agent sends heartbeats and its log, then its files and the TestResult.
+// A lease without heartbeat for the lease TTL expires and its job is queued again.
+package coordinator
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/k8s-school/home-ci/internal/runner"
+)
+
+// API paths
+const (
+	leasesPath = "/api/v1/leases"
+)
+
+// DefaultPollTimeout is how long a lease request waits for a job
+const DefaultPollTimeout = 30 * time.Second
+
+// maxResultBytes bounds the size of a reported result
+const maxResultBytes = 16 << 20
+
+// maxFilesBytes bounds the size of the archive of the files of a job
+const maxFilesBytes = 1 << 30
+
+// Job is a leased job, as sent to the agent
+type Job struct {
+	Branch         string `json:"branch"`
+	Commit         string `json:"commit"`
+	Trigger        string `json:"trigger"`
+	Attempt        int    `json:"attempt"`
+	PreviousCommit string `json:"previous_commit,omitempty"`
+}
+
+// LeaseRequest asks for a job an agent can run
+type LeaseRequest struct {
+	Agent  string   `json:"agent"`
+	Labels []string `json:"labels,omitempty"`
+}
+
+// Lease is a job leased to an agent, it must send a heartbeat within TTL
+type Lease struct {
+	ID  string        `json:"id"`
+	Job Job           `json:"job"`
+	TTL time.Duration `json:"ttl"`
+}
+
+// lease is the coordinator side of a lease
+type lease struct {
+	run     *runner.LeasedRun
+	expires time.Time
+}
+
+// Server is the job API of the coordinator
+type Server struct {
+	runner      *runner.TestRunner
+	token       string        // Token required from the agents, none when empty
+	ttl         time.Duration // Lease duration without heartbeat
+	pollTimeout time.Duration // Wait of a lease request for a job
+
+	mu     sync.Mutex
+	leases map[string]*lease
+}
+
+// NewServer creates the job API serving the queue of tr
+func NewServer(tr *runner.TestRunner, ttl time.Duration, token string) *Server {
+	return &Server{
+		runner:      tr,
+		token:       token,
+		ttl:         ttl,
+		pollTimeout: DefaultPollTimeout,
+		leases:      make(map[string]*lease),
+	}
+}
+
+// Handler returns the HTTP handler of the API
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST "+leasesPath, s.handleLease)
+	mux.HandleFunc("POST "+leasesPath+"/{id}/heartbeat", s.handleHeartbeat)
+	mux.HandleFunc("POST "+leasesPath+"/{id}/log", s.handleLog)
+	mux.HandleFunc("POST "+leasesPath+"/{id}/files", s.handleFiles)
+	mux.HandleFunc("POST "+leasesPath+"/{id}/result", s.handleResult)
+	return s.authenticate(mux)
+}
+
+// Serve runs the API on listener and expires the leases, until ctx is done
+func (s *Server) Serve(ctx context.Context, listener net.Listener) error {
+	server := &http.Server{
+		Handler:     s.Handler(),
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+	go s.expireLeases(ctx)
+
+	slog.Info("Coordinator listening", "address", listener.Addr().String(), "lease_ttl", s.ttl)
+	if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("coordinator failed: %w", err)
+	}
+	return nil
+}
+
+// expireLeases checks the leases a few times per TTL
+func (s *Server) expireLeases(ctx context.Context) {
+	ticker := time.NewTicker(s.ttl / 4)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.ExpireLeases()
+		}
+	}
+}
+
+// ExpireLeases ends the leases whose agent did not send a heartbeat in time
+func (s *Server) ExpireLeases() {
+	now := time.Now()
+	var expired []*lease
+	s.mu.Lock()
+	for id, l := range s.leases {
+		if now.After(l.expires) {
+			expired = append(expired, l)
+			delete(s.leases, id)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, l := range expired {
+		l.run.Expire()
+	}
+}
+
+// authenticate rejects the requests without the token of the agents
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.token != "" {
+			token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+				http.Error(w, "invalid token", http.StatusUnauthorized)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handleLease waits for a job matching the labels of the agent and leases it
+func (s *Server) handleLease(w http.ResponseWriter, r *http.Request) {
+	var req LeaseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("invalid lease request: %v", err), http.StatusBadRequest)
+		return
+	}
+	if req.Agent == "" {
+		http.Error(w, "agent name is required", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), s.pollTimeout)
+	defer cancel()
+	run, ok := s.runner.LeaseJob(ctx, req.Agent, req.Labels)
+	if !ok {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	id := newLeaseID()
+	s.mu.Lock()
+	s.leases[id] = &lease{run: run, expires: time.Now().Add(s.ttl)}
+	s.mu.Unlock()
+
+	job := run.Job()
+	writeJSON(w, Lease{
+		ID:  id,
+		TTL: s.ttl,
+		Job: Job{Branch: job.Branch, Commit: job.Commit, Trigger: job.Trigger, Attempt: job.Attempt, PreviousCommit: job.PreviousCommit},
+	})
+}
+
+// handleHeartbeat extends a lease
+func (s *Server) handleHeartbeat(w http.ResponseWriter, r *http.Request) {
+	if s.renew(r.PathValue("id")) == nil {
+		http.Error(w, "lease not found or expired", http.StatusGone)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleLog appends output of the agent to the log of the run, it extends the lease
+func (s *Server) handleLog(w http.ResponseWriter, r *http.Request) {
+	l := s.renew(r.PathValue("id"))
+	if l == nil {
+		http.Error(w, "lease not found or expired", http.StatusGone)
+		return
+	}
+	if _, err := io.Copy(l.run, r.Body); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, runner.ErrLeaseEnded) {
+			status = http.StatusGone
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleFiles stores the report, test reports and artifacts of the job, it extends the lease
+func (s *Server) handleFiles(w http.ResponseWriter, r *http.Request) {
+	l := s.renew(r.PathValue("id"))
+	if l == nil {
+		http.Error(w, "lease not found or expired", http.StatusGone)
+		return
+	}
+	if err := l.run.AddFiles(io.LimitReader(r.Body, maxFilesBytes)); err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, runner.ErrLeaseEnded) {
+			status = http.StatusGone
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleResult records the result of a leased job and ends the lease
+func (s *Server) handleResult(w http.ResponseWriter, r *http.Request) {
+	var result runner.TestResult
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxResultBytes)).Decode(&result); err != nil {
+		http.Error(w, fmt.Sprintf("invalid result: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	id := r.PathValue("id")
+	s.mu.Lock()
+	l := s.leases[id]
+	delete(s.leases, id)
+	s.mu.Unlock()
+	if l == nil {
+		http.Error(w, "lease not found or expired", http.StatusGone)
+		return
+	}
+
+	if err := l.run.Complete(result); err != nil {
+		http.Error(w, err.Error(), http.StatusGone)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// renew extends the lease with the given ID, it returns nil when there is none
+func (s *Server) renew(id string) *lease {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	l := s.leases[id]
+	if l != nil {
+		l.expires = time.Now().Add(s.ttl)
+	}
+	return l
+}
+
+// newLeaseID returns a random lease identifier
+func newLeaseID() string {
+	id := make([]byte, 16)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// writeJSON sends value as the JSON body of the response
+func writeJSON(w http.ResponseWriter, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		slog.Error("Failed to send response", "error", err)
+	}
+}
+
+// ReadToken returns the token stored in a file, relative paths are resolved
+// against the directory of the config file
+func ReadToken(file, configPath string) (string, error) {
+	if !filepath.IsAbs(file) && configPath != "" {
+		file = filepath.Join(filepath.Dir(configPath), file)
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("failed to read token file: %w", err)
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", fmt.Errorf("token file %s is empty", file)
+	}
+	return token, nil
+}
@@ -0,0 +1,110 @@
+package e2ereport
+
+import (
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FileName is the name of the report written by the test scripts to HOME_CI_RESULT_FILE
+const FileName = "e2e-report.yaml"
+
+// SchemaVersion is the version of the report schema understood by home-ci
+const SchemaVersion = 1
+
+// Report statuses
+const (
+	StatusPassed  = "passed"
+	StatusFailed  = "failed"
+	StatusSkipped = "skipped" // Only valid for checks
+)
+
+// Report is the content of e2e-report.yaml.
+// Unknown keys are ignored, so that scripts can add their own information.
+type Report struct {
+	Version  int                    `yaml:"version" json:"version,omitempty"`   // Schema version, defaults to 1
+	Status   string                 `yaml:"status" json:"status"`               // Overall status: passed or failed, required
+	Name     string                 `yaml:"name" json:"name,omitempty"`         // Name of the test suite
+	Summary  string                 `yaml:"summary" json:"summary,omitempty"`   // Human readable summary
+	Duration string                 `yaml:"duration" json:"duration,omitempty"` // Free-form duration reported by the script
+	Checks   []Check                `yaml:"checks" json:"checks,omitempty"`
+	Metrics  map[string]interface{} `yaml:"metrics" json:"metrics,omitempty"` // Free-form values, e.g. timings or resource usage
+}
+
+// Check is the result of one check of the report
+type Check struct {
+	Name     string `yaml:"name" json:"name"`                   // Required
+	Status   string `yaml:"status" json:"status"`               // passed, failed or skipped, required
+	Duration string `yaml:"duration" json:"duration,omitempty"` // Free-form duration
+	Message  string `yaml:"message" json:"message,omitempty"`
+}
+
+// Load reads and validates a report file
+func Load(path string) (*Report, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// Parse decodes and validates a report
+func Parse(data []byte) (*Report, error) {
+	var report Report
+	if err := yaml.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("invalid report YAML: %w", err)
+	}
+	if err := report.Validate(); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// Validate checks the report against the schema
+func (r *Report) Validate() error {
+	if r.Version == 0 {
+		r.Version = SchemaVersion
+	}
+	if r.Version != SchemaVersion {
+		return fmt.Errorf("unsupported report version %d, expected %d", r.Version, SchemaVersion)
+	}
+
+	switch r.Status {
+	case StatusPassed, StatusFailed:
+	case "":
+		return fmt.Errorf("report status is required")
+	default:
+		return fmt.Errorf("invalid report status '%s', expected %s or %s", r.Status, StatusPassed, StatusFailed)
+	}
+
+	for i, check := range r.Checks {
+		if check.Name == "" {
+			return fmt.Errorf("check %d has no name", i+1)
+		}
+		switch check.Status {
+		case StatusPassed, StatusFailed, StatusSkipped:
+		default:
+			return fmt.Errorf("invalid status '%s' for check '%s', expected %s, %s or %s",
+				check.Status, check.Name, StatusPassed, StatusFailed, StatusSkipped)
+		}
+	}
+
+	return nil
+}
+
+// Passed reports whether the report says the run succeeded
+func (r *Report) Passed() bool {
+	return r.Status == StatusPassed
+}
+
+// FailedChecks returns the names of the failed checks
+func (r *Report) FailedChecks() []string {
+	var failed []string
+	for _, check := range r.Checks {
+		if check.Status == StatusFailed {
+			failed = append(failed, check.Name)
+		}
+	}
+	return failed
+}
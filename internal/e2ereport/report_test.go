@@ -0,0 +1,56 @@
+package e2ereport
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	report, err := Parse([]byte(`
+status: failed
+name: fink-broker e2e
+checks:
+  - name: deploy
+    status: passed
+    duration: 2m
+  - name: alerts received
+    status: failed
+    message: 0 alerts after 5m
+  - name: gpu
+    status: skipped
+metrics:
+  alerts: 0
+  pods:
+    started: 12
+custom_field: ignored
+`))
+	if err != nil {
+		t.Fatalf("Parse() failed: %v", err)
+	}
+
+	if report.Version != SchemaVersion || report.Passed() {
+		t.Errorf("unexpected report %+v", report)
+	}
+	if failed := report.FailedChecks(); len(failed) != 1 || failed[0] != "alerts received" {
+		t.Errorf("FailedChecks() = %v", failed)
+	}
+	if report.Metrics["alerts"] != 0 {
+		t.Errorf("metrics not decoded: %v", report.Metrics)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := map[string]string{
+		"missing status":  "name: e2e\n",
+		"unknown status":  "status: ok\n",
+		"check no name":   "status: passed\nchecks:\n  - status: passed\n",
+		"check bad state": "status: passed\nchecks:\n  - name: a\n    status: done\n",
+		"bad version":     "version: 2\nstatus: passed\n",
+		"not yaml":        "status: [passed\n",
+	}
+
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Parse([]byte(content)); err == nil {
+				t.Errorf("expected Parse() to fail for %q", content)
+			}
+		})
+	}
+}
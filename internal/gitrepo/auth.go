@@ -0,0 +1,216 @@
+package gitrepo
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
+
+	"github.com/k8s-school/home-ci/internal/config"
+)
+
+const defaultSSHUser = "git"
+
+// AuthForConfig builds the authentication method of the configured repository.
+// configPath is the path of the configuration file, used to resolve relative credential files.
+func AuthForConfig(cfg config.Config, configPath string) (transport.AuthMethod, error) {
+	configDir := ""
+	if configPath != "" {
+		configDir = filepath.Dir(configPath)
+	}
+	return NewAuth(cfg.Git.Auth, cfg.Repository, configDir)
+}
+
+// NewAuth builds the go-git authentication method for repoURL from the git.auth configuration.
+// Relative file paths are resolved against configDir.
+// It returns nil when no credentials apply, which lets go-git use its defaults.
+func NewAuth(auth config.GitAuth, repoURL, configDir string) (transport.AuthMethod, error) {
+	if !IsRemoteURL(repoURL) {
+		return nil, nil
+	}
+
+	endpoint, err := transport.NewEndpoint(repoURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid repository URL %s: %w", repoURL, err)
+	}
+
+	switch endpoint.Protocol {
+	case "http", "https":
+		return newHTTPAuth(auth, configDir)
+	case "ssh":
+		user := endpoint.User
+		if user == "" {
+			user = auth.Username
+		}
+		if user == "" {
+			user = defaultSSHUser
+		}
+		return newSSHAuth(auth, user, configDir)
+	default:
+		return nil, nil
+	}
+}
+
+// CommandEnvForConfig returns the environment passing the credentials of the configured
+// repository to git commands run in a workspace, such as git lfs.
+func CommandEnvForConfig(cfg config.Config, configPath string) ([]string, error) {
+	configDir := ""
+	if configPath != "" {
+		configDir = filepath.Dir(configPath)
+	}
+	return CommandEnv(cfg.Git.Auth, cfg.Repository, configDir)
+}
+
+// CommandEnv returns the environment variables giving the git command line the same
+// credentials as NewAuth. The HTTP password is sent as a header scoped to the repository
+// host, and an SSH key with GIT_SSH_COMMAND; a key with a passphrase must be loaded in the
+// SSH agent, whose socket is inherited. Nothing is on the command line, so the secrets do
+// not show up in the process list.
+func CommandEnv(auth config.GitAuth, repoURL, configDir string) ([]string, error) {
+	if !IsRemoteURL(repoURL) {
+		return nil, nil
+	}
+
+	endpoint, err := transport.NewEndpoint(repoURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid repository URL %s: %w", repoURL, err)
+	}
+
+	switch endpoint.Protocol {
+	case "http", "https":
+		if auth.PasswordFile == "" {
+			return nil, nil
+		}
+		password, err := readSecretFile(auth.PasswordFile, configDir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read git password file: %w", err)
+		}
+		username := auth.Username
+		if username == "" {
+			username = defaultSSHUser
+		}
+		host := endpoint.Host
+		if endpoint.Port != 0 {
+			host = fmt.Sprintf("%s:%d", host, endpoint.Port)
+		}
+		header := "Authorization: Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password))
+		return []string{
+			"GIT_CONFIG_COUNT=1",
+			fmt.Sprintf("GIT_CONFIG_KEY_0=http.%s://%s/.extraHeader", endpoint.Protocol, host),
+			"GIT_CONFIG_VALUE_0=" + header,
+		}, nil
+	case "ssh":
+		if auth.SSHKeyFile == "" && auth.KnownHostsFile == "" {
+			return nil, nil
+		}
+		command := "ssh -o BatchMode=yes"
+		if auth.SSHKeyFile != "" {
+			command += " -o IdentitiesOnly=yes -i " + shellQuote(resolvePath(auth.SSHKeyFile, configDir))
+		}
+		if auth.KnownHostsFile != "" {
+			command += " -o StrictHostKeyChecking=yes -o UserKnownHostsFile=" + shellQuote(resolvePath(auth.KnownHostsFile, configDir))
+		}
+		return []string{"GIT_SSH_COMMAND=" + command}, nil
+	default:
+		return nil, nil
+	}
+}
+
+// shellQuote quotes s for GIT_SSH_COMMAND, which is run by the shell
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// newHTTPAuth returns HTTP basic authentication when a password or token file is configured
+func newHTTPAuth(auth config.GitAuth, configDir string) (transport.AuthMethod, error) {
+	if auth.PasswordFile == "" {
+		return nil, nil
+	}
+
+	password, err := readSecretFile(auth.PasswordFile, configDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read git password file: %w", err)
+	}
+
+	// Hosting services ignore the username when a token is used, but it must not be empty
+	username := auth.Username
+	if username == "" {
+		username = defaultSSHUser
+	}
+
+	return &http.BasicAuth{Username: username, Password: password}, nil
+}
+
+// newSSHAuth returns key file or agent authentication, with optional known_hosts verification
+func newSSHAuth(auth config.GitAuth, user, configDir string) (transport.AuthMethod, error) {
+	var hostKeyCallback ssh.HostKeyCallbackHelper
+	if auth.KnownHostsFile != "" {
+		callback, err := ssh.NewKnownHostsCallback(resolvePath(auth.KnownHostsFile, configDir))
+		if err != nil {
+			return nil, fmt.Errorf("failed to load known_hosts file: %w", err)
+		}
+		hostKeyCallback.HostKeyCallback = callback
+	}
+
+	switch {
+	case auth.SSHKeyFile != "":
+		passphrase := ""
+		if auth.SSHKeyPassphraseFile != "" {
+			var err error
+			passphrase, err = readSecretFile(auth.SSHKeyPassphraseFile, configDir)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read SSH key passphrase file: %w", err)
+			}
+		}
+
+		keys, err := ssh.NewPublicKeysFromFile(user, resolvePath(auth.SSHKeyFile, configDir), passphrase)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load SSH key: %w", err)
+		}
+		keys.HostKeyCallbackHelper = hostKeyCallback
+		return keys, nil
+
+	case auth.SSHAgent:
+		agent, err := ssh.NewSSHAgentAuth(user)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to SSH agent: %w", err)
+		}
+		agent.HostKeyCallbackHelper = hostKeyCallback
+		return agent, nil
+
+	default:
+		return nil, nil
+	}
+}
+
+// readSecretFile returns the trimmed content of a file holding a single secret
+func readSecretFile(path, configDir string) (string, error) {
+	data, err := os.ReadFile(resolvePath(path, configDir))
+	if err != nil {
+		return "", err
+	}
+
+	secret := strings.TrimSpace(string(data))
+	if secret == "" {
+		return "", fmt.Errorf("file %s is empty", path)
+	}
+	return secret, nil
+}
+
+// resolvePath resolves a relative path against the configuration directory
+func resolvePath(path, configDir string) string {
+	if strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, path[2:])
+		}
+	}
+	if filepath.IsAbs(path) || configDir == "" {
+		return path
+	}
+	return filepath.Join(configDir, path)
+}
@@ -0,0 +1,149 @@
+package gitrepo
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	gossh "golang.org/x/crypto/ssh"
+
+	"github.com/k8s-school/home-ci/internal/config"
+)
+
+func TestIsRemoteURL(t *testing.T) {
+	tests := []struct {
+		url      string
+		expected bool
+	}{
+		{"https://github.com/k8s-school/ktbx.git", true},
+		{"http://localhost:8080/repo.git", true},
+		{"ssh://git@github.com/k8s-school/ktbx.git", true},
+		{"git@github.com:k8s-school/ktbx.git", true},
+		{"github.com:k8s-school/ktbx.git", true},
+		{"/path/to/repo", false},
+		{"relative/repo", false},
+		{".", false},
+		{"file:///path/to/repo", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			assert.Equal(t, tt.expected, IsRemoteURL(tt.url))
+		})
+	}
+}
+
+func TestNewAuthHTTP(t *testing.T) {
+	configDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(configDir, "token"), []byte("s3cr3t\n"), 0600))
+
+	t.Run("TokenFileRelativeToConfig", func(t *testing.T) {
+		auth, err := NewAuth(config.GitAuth{PasswordFile: "token"}, "https://github.com/org/repo.git", configDir)
+		require.NoError(t, err)
+
+		basic, ok := auth.(*http.BasicAuth)
+		require.True(t, ok, "Expected HTTP basic auth, got %T", auth)
+		assert.Equal(t, "git", basic.Username)
+		assert.Equal(t, "s3cr3t", basic.Password)
+	})
+
+	t.Run("ExplicitUsername", func(t *testing.T) {
+		auth, err := NewAuth(config.GitAuth{Username: "ci", PasswordFile: filepath.Join(configDir, "token")}, "https://example.com/repo.git", "")
+		require.NoError(t, err)
+		assert.Equal(t, "ci", auth.(*http.BasicAuth).Username)
+	})
+
+	t.Run("MissingTokenFile", func(t *testing.T) {
+		_, err := NewAuth(config.GitAuth{PasswordFile: "missing"}, "https://example.com/repo.git", configDir)
+		assert.Error(t, err)
+	})
+
+	t.Run("NoCredentials", func(t *testing.T) {
+		auth, err := NewAuth(config.GitAuth{}, "https://example.com/repo.git", configDir)
+		require.NoError(t, err)
+		assert.Nil(t, auth)
+	})
+
+	t.Run("LocalRepositoryIgnoresCredentials", func(t *testing.T) {
+		auth, err := NewAuth(config.GitAuth{PasswordFile: "token"}, "/path/to/repo", configDir)
+		require.NoError(t, err)
+		assert.Nil(t, auth)
+	})
+}
+
+func TestNewAuthSSHKey(t *testing.T) {
+	configDir := t.TempDir()
+
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	block, err := gossh.MarshalPrivateKey(privateKey, "")
+	require.NoError(t, err)
+	keyFile := filepath.Join(configDir, "id_ed25519")
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(block), 0600))
+
+	knownHosts := filepath.Join(configDir, "known_hosts")
+	require.NoError(t, os.WriteFile(knownHosts, []byte(""), 0644))
+
+	auth, err := NewAuth(config.GitAuth{SSHKeyFile: "id_ed25519", KnownHostsFile: "known_hosts"}, "deploy@github.com:org/repo.git", configDir)
+	require.NoError(t, err)
+
+	keys, ok := auth.(*ssh.PublicKeys)
+	require.True(t, ok, "Expected SSH public keys auth, got %T", auth)
+	assert.Equal(t, "deploy", keys.User, "User from the URL should be used")
+	assert.NotNil(t, keys.HostKeyCallback, "known_hosts file should be used for host key verification")
+
+	t.Run("DefaultUser", func(t *testing.T) {
+		auth, err := NewAuth(config.GitAuth{SSHKeyFile: keyFile}, "ssh://github.com/org/repo.git", "")
+		require.NoError(t, err)
+		assert.Equal(t, "git", auth.(*ssh.PublicKeys).User)
+	})
+
+	t.Run("InvalidKey", func(t *testing.T) {
+		_, err := NewAuth(config.GitAuth{SSHKeyFile: "known_hosts"}, "git@github.com:org/repo.git", configDir)
+		assert.Error(t, err)
+	})
+}
+
+func TestCommandEnv(t *testing.T) {
+	configDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(configDir, "token"), []byte("s3cr3t\n"), 0600))
+
+	t.Run("HTTPHeaderScopedToHost", func(t *testing.T) {
+		env, err := CommandEnv(config.GitAuth{Username: "ci", PasswordFile: "token"}, "https://git.example.com:8443/org/repo.git", configDir)
+		require.NoError(t, err)
+		assert.Equal(t, []string{
+			"GIT_CONFIG_COUNT=1",
+			"GIT_CONFIG_KEY_0=http.https://git.example.com:8443/.extraHeader",
+			"GIT_CONFIG_VALUE_0=Authorization: Basic Y2k6czNjcjN0",
+		}, env)
+	})
+
+	t.Run("SSHKey", func(t *testing.T) {
+		env, err := CommandEnv(config.GitAuth{SSHKeyFile: "id_ed25519", KnownHostsFile: "/etc/ssh/known hosts"}, "git@github.com:org/repo.git", configDir)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"GIT_SSH_COMMAND=ssh -o BatchMode=yes -o IdentitiesOnly=yes -i '" + filepath.Join(configDir, "id_ed25519") +
+			"' -o StrictHostKeyChecking=yes -o UserKnownHostsFile='/etc/ssh/known hosts'"}, env)
+	})
+
+	t.Run("NoCredentials", func(t *testing.T) {
+		env, err := CommandEnv(config.GitAuth{}, "https://github.com/org/repo.git", configDir)
+		require.NoError(t, err)
+		assert.Empty(t, env)
+
+		env, err = CommandEnv(config.GitAuth{PasswordFile: "token"}, "/path/to/repo", configDir)
+		require.NoError(t, err)
+		assert.Empty(t, env)
+	})
+
+	t.Run("MissingPasswordFile", func(t *testing.T) {
+		_, err := CommandEnv(config.GitAuth{PasswordFile: "missing"}, "https://github.com/org/repo.git", configDir)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,311 @@
+package gitrepo
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/cache"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/storage/filesystem"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+// scpLikeURL matches the scp-like syntax of SSH URLs, such as git@github.com:org/repo.git
+var scpLikeURL = regexp.MustCompile(`^(?:[^@/]+@)?[^@/:]+:[^/]`)
+
+// mirrorLocks serializes updates of a given mirror directory within the process
+var mirrorLocks sync.Map
+
+// Mirror is a persistent bare mirror of an upstream repository.
+// It is updated incrementally and used as the object store of every workspace.
+type Mirror struct {
+	url  string
+	path string
+	auth transport.AuthMethod
+	mu   *sync.Mutex
+}
+
+// IsRemoteURL reports whether repoPath designates a remote repository rather than a local path.
+// HTTP(S), SSH and git URLs are remote, as well as scp-like SSH addresses (git@host:org/repo.git).
+func IsRemoteURL(repoPath string) bool {
+	for _, scheme := range []string{"http://", "https://", "ssh://", "git://", "git+ssh://"} {
+		if strings.HasPrefix(repoPath, scheme) {
+			return true
+		}
+	}
+	return scpLikeURL.MatchString(repoPath)
+}
+
+// MirrorPath returns the mirror directory used for a repository inside cacheDir
+func MirrorPath(cacheDir, repoURL string) string {
+	name := strings.ReplaceAll(strings.ReplaceAll(normalizeURL(repoURL), "/", "_"), ":", "_")
+	return filepath.Join(cacheDir, name+".git")
+}
+
+// normalizeURL makes local repository paths absolute so that the mirror does not depend on the current directory
+func normalizeURL(repoURL string) string {
+	if IsRemoteURL(repoURL) || strings.Contains(repoURL, "://") || filepath.IsAbs(repoURL) {
+		return repoURL
+	}
+	if abs, err := filepath.Abs(repoURL); err == nil {
+		return abs
+	}
+	return repoURL
+}
+
+// NewMirror returns the mirror of repoURL stored in cacheDir, it is created lazily by Update.
+// auth is used for every upstream access and may be nil.
+func NewMirror(repoURL, cacheDir string, auth transport.AuthMethod) *Mirror {
+	path := MirrorPath(cacheDir, repoURL)
+	mu, _ := mirrorLocks.LoadOrStore(path, &sync.Mutex{})
+	return &Mirror{
+		url:  normalizeURL(repoURL),
+		path: path,
+		auth: auth,
+		mu:   mu.(*sync.Mutex),
+	}
+}
+
+// URL returns the upstream URL of the mirror
+func (m *Mirror) URL() string {
+	return m.url
+}
+
+// Auth returns the authentication method used for upstream accesses, possibly nil
+func (m *Mirror) Auth() transport.AuthMethod {
+	return m.auth
+}
+
+// Path returns the directory of the bare mirror
+func (m *Mirror) Path() string {
+	return m.path
+}
+
+// Exists reports whether the mirror has already been created
+func (m *Mirror) Exists() bool {
+	_, err := os.Stat(filepath.Join(m.path, "HEAD"))
+	return err == nil
+}
+
+// Open opens the existing mirror
+func (m *Mirror) Open() (*git.Repository, error) {
+	repo, err := git.PlainOpen(m.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open mirror %s: %w", m.path, err)
+	}
+	return repo, nil
+}
+
+// Update creates the mirror with a full clone if needed, otherwise fetches all branches incrementally.
+// When the fetch of an existing mirror fails, the mirror is returned along with the error.
+func (m *Mirror) Update(ctx context.Context) (*git.Repository, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.Exists() {
+		repo, err := m.Open()
+		if err == nil {
+			return repo, m.fetch(ctx, repo)
+		}
+		// If opening fails, remove the corrupted mirror and recreate it
+		slog.Debug("Failed to open mirror, recreating", "mirror", m.path, "error", err)
+		os.RemoveAll(m.path)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(m.path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	slog.Debug("Creating repository mirror", "repository", m.url, "mirror", m.path)
+	repo, err := git.PlainCloneContext(ctx, m.path, true, &git.CloneOptions{
+		URL:    m.url,
+		Auth:   m.auth,
+		Mirror: true,
+	})
+	if err != nil {
+		os.RemoveAll(m.path)
+		return nil, fmt.Errorf("failed to create mirror of %s: %w", m.url, err)
+	}
+
+	return repo, nil
+}
+
+// fetch updates all branches of the mirror, removing the ones deleted upstream
+func (m *Mirror) fetch(ctx context.Context, repo *git.Repository) error {
+	err := repo.FetchContext(ctx, &git.FetchOptions{
+		RemoteName: "origin",
+		Auth:       m.auth,
+		RefSpecs:   []config.RefSpec{"+refs/heads/*:refs/heads/*"},
+		Prune:      true,
+		Force:      true,
+	})
+	if err != nil && err != git.NoErrAlreadyUpToDate {
+		return fmt.Errorf("failed to fetch mirror updates: %w", err)
+	}
+	return nil
+}
+
+// ListBranches returns the branches of the upstream repository, without updating the mirror
+func (m *Mirror) ListBranches(ctx context.Context) ([]string, error) {
+	remote := git.NewRemote(memory.NewStorage(), &config.RemoteConfig{Name: "origin", URLs: []string{m.url}})
+	refs, err := remote.ListContext(ctx, &git.ListOptions{Auth: m.auth})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list branches of %s: %w", m.url, err)
+	}
+
+	var branches []string
+	for _, ref := range refs {
+		if ref.Name().IsBranch() {
+			branches = append(branches, ref.Name().Short())
+		}
+	}
+	return branches, nil
+}
+
+// HasCommit reports whether the mirror already contains the given commit
+func (m *Mirror) HasCommit(commit string) bool {
+	if !plumbing.IsHash(commit) {
+		return false
+	}
+	repo, err := m.Open()
+	if err != nil {
+		return false
+	}
+	_, err = repo.CommitObject(plumbing.NewHash(commit))
+	return err == nil
+}
+
+// CreateWorkspace creates a working copy of branch in dir.
+// The workspace borrows its objects from the mirror through git alternates, so no object is copied.
+func (m *Mirror) CreateWorkspace(dir, branch string) (*git.Repository, error) {
+	mirrorRepo, err := m.Open()
+	if err != nil {
+		return nil, err
+	}
+
+	ref, err := mirrorRepo.Reference(plumbing.NewBranchReferenceName(branch), true)
+	if err != nil {
+		return nil, fmt.Errorf("branch %s not found in mirror: %w", branch, err)
+	}
+
+	if _, err := git.PlainInit(dir, false); err != nil {
+		return nil, fmt.Errorf("failed to initialize workspace %s: %w", dir, err)
+	}
+
+	alternatesFile := filepath.Join(dir, git.GitDirName, "objects", "info", "alternates")
+	if err := os.MkdirAll(filepath.Dir(alternatesFile), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create objects info directory: %w", err)
+	}
+	mirrorObjects, err := filepath.Abs(filepath.Join(m.path, "objects"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve mirror objects path: %w", err)
+	}
+	if err := os.WriteFile(alternatesFile, []byte(mirrorObjects+"\n"), 0644); err != nil {
+		return nil, fmt.Errorf("failed to write alternates file: %w", err)
+	}
+
+	repo, err := OpenWorkspace(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	// Point origin to upstream, so that scripts see the real repository and can fetch from it
+	if _, err := repo.CreateRemote(&config.RemoteConfig{
+		Name:  "origin",
+		URLs:  []string{m.url},
+		Fetch: []config.RefSpec{"+refs/heads/*:refs/remotes/origin/*"},
+	}); err != nil {
+		return nil, fmt.Errorf("failed to create origin remote: %w", err)
+	}
+
+	remoteRef := plumbing.NewHashReference(plumbing.NewRemoteReferenceName("origin", branch), ref.Hash())
+	if err := repo.Storer.SetReference(remoteRef); err != nil {
+		return nil, fmt.Errorf("failed to create remote-tracking branch: %w", err)
+	}
+
+	workTree, err := repo.Worktree()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get working tree: %w", err)
+	}
+	if err := workTree.Checkout(&git.CheckoutOptions{
+		Hash:   ref.Hash(),
+		Branch: plumbing.NewBranchReferenceName(branch),
+		Create: true,
+	}); err != nil {
+		return nil, fmt.Errorf("failed to checkout branch %s: %w", branch, err)
+	}
+
+	if err := repo.CreateBranch(&config.Branch{
+		Name:   branch,
+		Remote: "origin",
+		Merge:  plumbing.NewBranchReferenceName(branch),
+	}); err != nil {
+		return nil, fmt.Errorf("failed to configure branch %s: %w", branch, err)
+	}
+
+	return repo, nil
+}
+
+// Alternates returns the object directories a workspace created by CreateWorkspace borrows
+// its objects from, none when it has its own objects
+func Alternates(dir string) ([]string, error) {
+	data, err := os.ReadFile(filepath.Join(dir, git.GitDirName, "objects", "info", "alternates"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read alternates file: %w", err)
+	}
+	var dirs []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+			dirs = append(dirs, line)
+		}
+	}
+	return dirs, nil
+}
+
+// Dissociate copies the objects a workspace borrows from the mirror into the workspace
+// and removes its alternates, so that it can be used without the mirror. It requires
+// the git command.
+func Dissociate(ctx context.Context, dir string) error {
+	alternates, err := Alternates(dir)
+	if err != nil || len(alternates) == 0 {
+		return err
+	}
+	cmd := exec.CommandContext(ctx, "git", "repack", "-a", "-d", "-q")
+	cmd.Dir = dir
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("git repack failed: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+	if err := os.Remove(filepath.Join(dir, git.GitDirName, "objects", "info", "alternates")); err != nil {
+		return fmt.Errorf("failed to remove alternates file: %w", err)
+	}
+	return nil
+}
+
+// OpenWorkspace opens a workspace created by CreateWorkspace, resolving objects through its alternates
+func OpenWorkspace(dir string) (*git.Repository, error) {
+	storage := filesystem.NewStorageWithOptions(
+		osfs.New(filepath.Join(dir, git.GitDirName)),
+		cache.NewObjectLRUDefault(),
+		filesystem.Options{AlternatesFS: osfs.New("/", osfs.WithBoundOS())},
+	)
+
+	repo, err := git.Open(storage, osfs.New(dir))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open workspace %s: %w", dir, err)
+	}
+	return repo, nil
+}
@@ -0,0 +1,142 @@
+package gitrepo
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// commitFile writes a file in the repository worktree and commits it
+func commitFile(t *testing.T, repo *git.Repository, dir, name, content string) plumbing.Hash {
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+
+	worktree, err := repo.Worktree()
+	require.NoError(t, err)
+	_, err = worktree.Add(name)
+	require.NoError(t, err)
+
+	hash, err := worktree.Commit("Add "+name, &git.CommitOptions{
+		Author: &object.Signature{Name: "Test User", Email: "test@example.com", When: time.Now()},
+	})
+	require.NoError(t, err)
+	return hash
+}
+
+func TestMirrorPath(t *testing.T) {
+	assert.Equal(t, "/cache/https___github.com_k8s-school_ktbx.git.git",
+		MirrorPath("/cache", "https://github.com/k8s-school/ktbx.git"))
+	assert.True(t, strings.HasSuffix(MirrorPath("/cache", "relative/repo"), "_relative_repo.git"),
+		"Local paths should be made absolute")
+}
+
+func TestMirrorUpdateAndWorkspace(t *testing.T) {
+	tempDir := t.TempDir()
+
+	upstreamDir := filepath.Join(tempDir, "upstream")
+	upstream, err := git.PlainInit(upstreamDir, false)
+	require.NoError(t, err)
+	first := commitFile(t, upstream, upstreamDir, "README.md", "# Test\n")
+
+	mirror := NewMirror(upstreamDir, filepath.Join(tempDir, "cache"), nil)
+	assert.False(t, mirror.Exists())
+
+	_, err = mirror.Update(context.Background())
+	require.NoError(t, err)
+	assert.True(t, mirror.Exists())
+	assert.True(t, mirror.HasCommit(first.String()))
+
+	// New upstream commits are fetched incrementally
+	second := commitFile(t, upstream, upstreamDir, "main.go", "package main\n")
+	assert.False(t, mirror.HasCommit(second.String()))
+	_, err = mirror.Update(context.Background())
+	require.NoError(t, err)
+	assert.True(t, mirror.HasCommit(second.String()))
+
+	t.Run("WorkspaceSharesMirrorObjects", func(t *testing.T) {
+		head, err := upstream.Head()
+		require.NoError(t, err)
+		branch := head.Name().Short()
+
+		workspaceDir := filepath.Join(tempDir, "workspace")
+		repo, err := mirror.CreateWorkspace(workspaceDir, branch)
+		require.NoError(t, err)
+
+		wsHead, err := repo.Head()
+		require.NoError(t, err)
+		assert.Equal(t, second, wsHead.Hash())
+		assert.Equal(t, branch, wsHead.Name().Short())
+		assert.FileExists(t, filepath.Join(workspaceDir, "main.go"))
+
+		alternates, err := os.ReadFile(filepath.Join(workspaceDir, ".git", "objects", "info", "alternates"))
+		require.NoError(t, err)
+		assert.Contains(t, string(alternates), filepath.Join(mirror.Path(), "objects"))
+
+		packs, err := filepath.Glob(filepath.Join(workspaceDir, ".git", "objects", "pack", "*.pack"))
+		require.NoError(t, err)
+		assert.Empty(t, packs, "Workspace should not copy mirror objects")
+
+		remote, err := repo.Remote("origin")
+		require.NoError(t, err)
+		assert.Equal(t, []string{mirror.URL()}, remote.Config().URLs)
+
+		// Older commits are reachable through the alternates
+		_, err = repo.CommitObject(first)
+		assert.NoError(t, err)
+	})
+
+	t.Run("Dissociate", func(t *testing.T) {
+		if _, err := exec.LookPath("git"); err != nil {
+			t.Skip("git command not available")
+		}
+		head, err := upstream.Head()
+		require.NoError(t, err)
+
+		workspaceDir := filepath.Join(tempDir, "dissociated")
+		_, err = mirror.CreateWorkspace(workspaceDir, head.Name().Short())
+		require.NoError(t, err)
+		require.NoError(t, Dissociate(context.Background(), workspaceDir))
+
+		alternates, err := Alternates(workspaceDir)
+		require.NoError(t, err)
+		assert.Empty(t, alternates)
+
+		// The whole history is in the workspace
+		repo, err := git.PlainOpen(workspaceDir)
+		require.NoError(t, err)
+		_, err = repo.CommitObject(first)
+		assert.NoError(t, err)
+	})
+
+	t.Run("UnknownBranch", func(t *testing.T) {
+		_, err := mirror.CreateWorkspace(filepath.Join(tempDir, "unknown"), "does-not-exist")
+		assert.Error(t, err)
+	})
+}
+
+func TestMirrorListBranches(t *testing.T) {
+	tempDir := t.TempDir()
+
+	upstreamDir := filepath.Join(tempDir, "upstream")
+	upstream, err := git.PlainInit(upstreamDir, false)
+	require.NoError(t, err)
+	commitFile(t, upstream, upstreamDir, "README.md", "# Test\n")
+
+	mirror := NewMirror(upstreamDir, filepath.Join(tempDir, "cache"), nil)
+	branches, err := mirror.ListBranches(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"master"}, branches)
+	assert.False(t, mirror.Exists(), "Listing branches must not create the mirror")
+
+	_, err = NewMirror(filepath.Join(tempDir, "missing"), filepath.Join(tempDir, "cache"), nil).ListBranches(context.Background())
+	assert.Error(t, err)
+}
@@ -0,0 +1,125 @@
+package history
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/k8s-school/home-ci/internal/utils"
+)
+
+// timeFormat is the format of the times in the Markdown export
+const timeFormat = "2006-01-02 15:04:05"
+
+// Failure returns the failure reason of a failed run with the step where it failed
+func (e Entry) Failure() string {
+	switch {
+	case e.Result != ResultFailed:
+		return ""
+	case e.FailedStep != "":
+		return fmt.Sprintf("%s at step %s", e.FailureReason, e.FailedStep)
+	default:
+		return e.FailureReason
+	}
+}
+
+// Failure returns the failure reason of a failed attempt with the step where it failed
+func (a Attempt) Failure() string {
+	switch {
+	case a.Success:
+		return ""
+	case a.FailedStep != "":
+		return fmt.Sprintf("%s at step %s", a.FailureReason, a.FailedStep)
+	default:
+		return a.FailureReason
+	}
+}
+
+// Summary counts the runs by result
+type Summary struct {
+	Runs   int `json:"runs"`
+	Passed int `json:"passed"`
+	Flaky  int `json:"flaky"`
+	Failed int `json:"failed"`
+}
+
+// Summarize counts the entries by result
+func Summarize(entries []Entry) Summary {
+	summary := Summary{Runs: len(entries)}
+	for _, entry := range entries {
+		switch entry.Result {
+		case ResultPassed:
+			summary.Passed++
+		case ResultFlaky:
+			summary.Flaky++
+		default:
+			summary.Failed++
+		}
+	}
+	return summary
+}
+
+// String renders the summary as a sentence
+func (s Summary) String() string {
+	return fmt.Sprintf("%d runs: %d passed, %d flaky, %d failed", s.Runs, s.Passed, s.Flaky, s.Failed)
+}
+
+// WriteJSON writes the entries as a JSON array
+func WriteJSON(w io.Writer, entries []Entry) error {
+	if entries == nil {
+		entries = []Entry{}
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(entries)
+}
+
+// WriteCSV writes the entries as CSV with a header line, durations in seconds
+func WriteCSV(w io.Writer, entries []Entry) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"run_id", "branch", "commit", "start_time", "end_time", "duration_seconds", "result", "attempts", "failure_reason", "failed_step", "error_message"})
+	for _, entry := range entries {
+		writer.Write([]string{
+			entry.RunID,
+			entry.Branch,
+			entry.Commit,
+			entry.StartTime.Format(time.RFC3339),
+			entry.EndTime.Format(time.RFC3339),
+			strconv.FormatInt(int64(entry.Duration.Seconds()), 10),
+			entry.Result,
+			strconv.Itoa(entry.Attempts),
+			entry.FailureReason,
+			entry.FailedStep,
+			entry.ErrorMessage,
+		})
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// WriteMarkdown writes the entries as a Markdown table followed by their summary
+func WriteMarkdown(w io.Writer, entries []Entry) error {
+	fmt.Fprintf(w, "| Started | Branch | Commit | Result | Duration | Attempts | Failure |\n")
+	fmt.Fprintf(w, "|---|---|---|---|---|---|---|\n")
+	for _, entry := range entries {
+		fmt.Fprintf(w, "| %s | %s | `%s` | %s | %s | %d | %s |\n",
+			entry.StartTime.Format(timeFormat),
+			markdownEscape(entry.Branch),
+			utils.ShortCommit(entry.Commit),
+			entry.Result,
+			entry.Duration.Round(time.Second),
+			entry.Attempts,
+			markdownEscape(entry.Failure()))
+	}
+	_, err := fmt.Fprintf(w, "\n%s\n", Summarize(entries))
+	return err
+}
+
+// markdownEscape escapes the characters breaking a Markdown table cell
+func markdownEscape(s string) string {
+	return strings.ReplaceAll(s, "|", `\|`)
+}
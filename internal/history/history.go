@@ -0,0 +1,178 @@
+// Package history keeps the finished runs of a repository in a JSON lines file,
+// one entry per run once all its attempts are over, and filters and exports them.
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/k8s-school/home-ci/internal/config"
+)
+
+// Results of a run, as filtered by Filter.Result
+const (
+	ResultPassed = "passed"
+	ResultFlaky  = "flaky"
+	ResultFailed = "failed"
+)
+
+// Entry is a finished run
+type Entry struct {
+	RunID          string        `json:"run_id"`
+	Branch         string        `json:"branch"`
+	Commit         string        `json:"commit"`
+	StartTime      time.Time     `json:"start_time"`
+	EndTime        time.Time     `json:"end_time"`
+	Duration       time.Duration `json:"duration"`
+	Result         string        `json:"result"`
+	Attempts       int           `json:"attempts"`
+	FailureReason  string        `json:"failure_reason,omitempty"`
+	FailedStep     string        `json:"failed_step,omitempty"`
+	ErrorMessage   string        `json:"error_message,omitempty"`
+	AttemptResults []Attempt     `json:"attempt_results,omitempty"` // Every attempt, for runs that were retried
+	Tests          []Test        `json:"tests,omitempty"`           // Test cases of the JUnit and TAP reports of the last attempt
+}
+
+// Test is the outcome of a test case of a run
+type Test struct {
+	Suite   string `json:"suite,omitempty"`
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"` // Failure message, truncated
+}
+
+// Attempt is one of the attempts of a run
+type Attempt struct {
+	Attempt       int           `json:"attempt"`
+	StartTime     time.Time     `json:"start_time"`
+	Duration      time.Duration `json:"duration"`
+	Success       bool          `json:"success"`
+	FailureReason string        `json:"failure_reason,omitempty"`
+	FailedStep    string        `json:"failed_step,omitempty"`
+}
+
+// key identifies a run, the same commit can be run several times
+func (e Entry) key() string {
+	return e.RunID + "@" + e.StartTime.UTC().Format(time.RFC3339Nano)
+}
+
+// appendMu serializes the appends of the runs finishing together
+var appendMu sync.Mutex
+
+// Append adds an entry at the end of the history file at path
+func Append(path string, entry Entry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal history entry: %w", err)
+	}
+
+	appendMu.Lock()
+	defer appendMu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create history directory: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open history file: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write history file: %w", err)
+	}
+	return nil
+}
+
+// Load reads the history file at path. A missing file is an empty history, and
+// invalid lines, such as one cut by a crash, are skipped.
+func Load(path string) ([]Entry, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history file: %w", err)
+	}
+	defer file.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			slog.Debug("Skipping invalid history line", "file", path, "line", line, "error", err)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read history file: %w", err)
+	}
+	return entries, nil
+}
+
+// Merge adds to entries the ones of others they do not contain, and sorts them most recent first
+func Merge(entries []Entry, others ...Entry) []Entry {
+	seen := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		seen[entry.key()] = true
+	}
+	for _, other := range others {
+		if !seen[other.key()] {
+			seen[other.key()] = true
+			entries = append(entries, other)
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].StartTime.After(entries[j].StartTime)
+	})
+	return entries
+}
+
+// Filter selects entries, empty fields select all of them
+type Filter struct {
+	Branch string    // Branch name or glob pattern, see config.MatchBranch
+	Result string    // passed, flaky or failed
+	Commit string    // Prefix of the commit hash
+	Since  time.Time // Runs started at or after Since
+	Until  time.Time // Runs started before Until
+	Limit  int       // Most recent entries kept, 0 keeps all of them
+}
+
+// Apply returns the entries selected by the filter, keeping their order
+func (f Filter) Apply(entries []Entry) []Entry {
+	var selected []Entry
+	for _, entry := range entries {
+		if f.Limit > 0 && len(selected) == f.Limit {
+			break
+		}
+		if !config.MatchBranch(f.Branch, entry.Branch) {
+			continue
+		}
+		if f.Result != "" && entry.Result != f.Result {
+			continue
+		}
+		if !strings.HasPrefix(entry.Commit, f.Commit) {
+			continue
+		}
+		if !f.Since.IsZero() && entry.StartTime.Before(f.Since) {
+			continue
+		}
+		if !f.Until.IsZero() && !entry.StartTime.Before(f.Until) {
+			continue
+		}
+		selected = append(selected, entry)
+	}
+	return selected
+}
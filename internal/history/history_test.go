@@ -0,0 +1,119 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testEntries returns runs of several branches, most recent first
+func testEntries(start time.Time) []Entry {
+	return []Entry{
+		{RunID: "main_33333333", Branch: "main", Commit: "3333333333333333", StartTime: start.Add(2 * time.Hour), Duration: 90 * time.Second, Result: ResultPassed, Attempts: 1},
+		{RunID: "release_1.0_22222222", Branch: "release/1.0", Commit: "2222222222222222", StartTime: start.Add(time.Hour), Duration: time.Minute, Result: ResultFailed, Attempts: 2, FailureReason: "timeout", FailedStep: "e2e|kind"},
+		{RunID: "main_11111111", Branch: "main", Commit: "1111111111111111", StartTime: start, Duration: 2 * time.Minute, Result: ResultFlaky, Attempts: 2},
+	}
+}
+
+func TestAppendLoadMerge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "repo.history.jsonl")
+	start := time.Date(2026, 1, 5, 10, 0, 0, 0, time.UTC)
+	entries := testEntries(start)
+
+	if loaded, err := Load(path); err != nil || loaded != nil {
+		t.Fatalf("Load() of a missing file = %v, %v", loaded, err)
+	}
+	for i := len(entries) - 1; i >= 0; i-- {
+		if err := Append(path, entries[i]); err != nil {
+			t.Fatalf("Append() failed: %v", err)
+		}
+	}
+	// A line cut by a crash is skipped
+	file, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	file.WriteString(`{"run_id": "cut`)
+	file.Close()
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if len(loaded) != 3 {
+		t.Fatalf("Load() returned %d entries, want 3", len(loaded))
+	}
+
+	// A run found both in the history and in a run.json is listed once
+	manual := Entry{RunID: "fix_44444444", Branch: "fix", StartTime: start.Add(3 * time.Hour), Result: ResultPassed}
+	merged := Merge(loaded, entries[0], manual)
+	if len(merged) != 4 || merged[0].RunID != "fix_44444444" || merged[3].RunID != "main_11111111" {
+		t.Errorf("Merge() = %+v", merged)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	start := time.Date(2026, 1, 5, 10, 0, 0, 0, time.UTC)
+	entries := testEntries(start)
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   []string
+	}{
+		{"all", Filter{}, []string{"main_33333333", "release_1.0_22222222", "main_11111111"}},
+		{"branch", Filter{Branch: "main"}, []string{"main_33333333", "main_11111111"}},
+		{"branch pattern", Filter{Branch: "release/*"}, []string{"release_1.0_22222222"}},
+		{"result", Filter{Result: ResultFailed}, []string{"release_1.0_22222222"}},
+		{"commit", Filter{Commit: "1111"}, []string{"main_11111111"}},
+		{"since", Filter{Since: start.Add(time.Hour)}, []string{"main_33333333", "release_1.0_22222222"}},
+		{"until", Filter{Until: start.Add(time.Hour)}, []string{"main_11111111"}},
+		{"limit", Filter{Branch: "main", Limit: 1}, []string{"main_33333333"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, entry := range tt.filter.Apply(entries) {
+				got = append(got, entry.RunID)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Apply() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExport(t *testing.T) {
+	start := time.Date(2026, 1, 5, 10, 0, 0, 0, time.UTC)
+	entries := testEntries(start)
+
+	var csv strings.Builder
+	if err := WriteCSV(&csv, entries); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(csv.String()), "\n")
+	if len(lines) != 4 || !strings.HasPrefix(lines[0], "run_id,branch,commit,") {
+		t.Fatalf("unexpected CSV:\n%s", csv.String())
+	}
+	if expected := "release_1.0_22222222,release/1.0,2222222222222222,2026-01-05T11:00:00Z,0001-01-01T00:00:00Z,60,failed,2,timeout,e2e|kind,"; lines[2] != expected {
+		t.Errorf("CSV line = %q, want %q", lines[2], expected)
+	}
+
+	var markdown strings.Builder
+	if err := WriteMarkdown(&markdown, entries); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		"| 2026-01-05 11:00:00 | release/1.0 | `22222222` | failed | 1m0s | 2 | timeout at step e2e\\|kind |",
+		"| 2026-01-05 10:00:00 | main | `11111111` | flaky | 2m0s | 2 |  |",
+		"3 runs: 1 passed, 1 flaky, 1 failed",
+	} {
+		if !strings.Contains(markdown.String(), expected) {
+			t.Errorf("Markdown misses %q:\n%s", expected, markdown.String())
+		}
+	}
+
+	var json strings.Builder
+	if err := WriteJSON(&json, nil); err != nil || strings.TrimSpace(json.String()) != "[]" {
+		t.Errorf("WriteJSON() of no entries = %q, %v", json.String(), err)
+	}
+}
@@ -0,0 +1,214 @@
+// Package logstream follows the log of the test runs while they run.
+package logstream
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/k8s-school/home-ci/internal/artifacts"
+	"github.com/k8s-school/home-ci/internal/config"
+	"github.com/k8s-school/home-ci/internal/runner"
+)
+
+// FollowInterval is how often a followed log is checked for new lines
+const FollowInterval = 500 * time.Millisecond
+
+// Line is a line of a run log, stamped when it was written
+type Line struct {
+	Time   time.Time `json:"time"`
+	Offset int64     `json:"offset"` // Offset of the next line in the log file, to resume from
+	Text   string    `json:"text"`
+}
+
+// RunLogPath returns the run.log of a run, from its workspace or from the
+// artifact store once the workspace is removed. The path of a compressed log
+// ends with .gz.
+func RunLogPath(cfg *config.Config, runID string) (string, error) {
+	if runID == "" || runID == "." || runID == ".." || strings.ContainsAny(runID, `/\`) {
+		return "", fmt.Errorf("invalid run ID '%s'", runID)
+	}
+
+	path := filepath.Join(cfg.GetRunsDir(), runID, "logs", "run.log")
+	for _, name := range []string{"run.log", "run.log.gz"} {
+		if _, err := os.Stat(filepath.Join(filepath.Dir(path), name)); err == nil {
+			return filepath.Join(filepath.Dir(path), name), nil
+		}
+	}
+	store := artifacts.NewStore(cfg.GetArtifactsDir())
+	for _, name := range []string{"logs/run.log", "logs/run.log.gz"} {
+		if stored, err := store.Path(runID, name); err == nil {
+			return stored, nil
+		}
+	}
+	return path, nil
+}
+
+// IsRunning reports whether the run with the given ID is among the running tests
+func IsRunning(cfg *config.Config, tests []runner.RunningTest, runID string) bool {
+	for _, test := range tests {
+		if cfg.GetRunID(test.Branch, test.Commit) == runID {
+			return true
+		}
+	}
+	return false
+}
+
+// Follow sends the lines of the log file at path, starting at offset. While running
+// reports that the run is not over, it waits for new lines, and the missing log of a
+// run that did not start writing it yet. The last line is sent once the run is over,
+// even when it is not terminated. A nil running reads the log once.
+func Follow(ctx context.Context, path string, offset int64, running func() bool, send func(Line) error) error {
+	isRunning := func() bool { return running != nil && running() }
+
+	var file *os.File
+	for {
+		var err error
+		if file, err = os.Open(path); err == nil {
+			break
+		}
+		if !errors.Is(err, os.ErrNotExist) || !isRunning() {
+			return fmt.Errorf("failed to open log: %w", err)
+		}
+		if err := wait(ctx); err != nil {
+			return err
+		}
+	}
+	defer file.Close()
+	times := openWriteTimes(path)
+	defer times.Close()
+
+	// A compressed log is complete, the offset is in its uncompressed content
+	var reader *bufio.Reader
+	if strings.HasSuffix(path, ".gz") {
+		zr, err := gzip.NewReader(file)
+		if err != nil {
+			return fmt.Errorf("failed to read log: %w", err)
+		}
+		if _, err := io.CopyN(io.Discard, zr, offset); err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("failed to read log: %w", err)
+		}
+		reader = bufio.NewReader(zr)
+		running = nil
+	} else {
+		if _, err := file.Seek(offset, io.SeekStart); err != nil {
+			return fmt.Errorf("failed to seek log: %w", err)
+		}
+		reader = bufio.NewReader(file)
+	}
+	var pending string
+	over := false
+	for {
+		chunk, err := reader.ReadString('\n')
+		pending += chunk
+		offset += int64(len(chunk))
+		if err == nil {
+			if err := send(Line{Time: times.At(offset), Offset: offset, Text: strings.TrimSuffix(pending, "\n")}); err != nil {
+				return err
+			}
+			pending = ""
+			continue
+		}
+		if !errors.Is(err, io.EOF) {
+			return fmt.Errorf("failed to read log: %w", err)
+		}
+
+		// The end of the log, once more after the run is over for the lines written meanwhile
+		if over {
+			if pending != "" {
+				return send(Line{Time: times.At(offset), Offset: offset, Text: pending})
+			}
+			return nil
+		}
+		if !isRunning() {
+			over = true
+			continue
+		}
+		if err := wait(ctx); err != nil {
+			return err
+		}
+	}
+}
+
+// writeTimes reads the time the lines of a log were written from its structured log
+type writeTimes struct {
+	file    *os.File
+	reader  *bufio.Reader
+	pending string          // Start of an entry not terminated yet
+	entry   runner.LogEntry // Last entry read
+	read    bool            // An entry was read
+}
+
+// openWriteTimes opens the structured log next to the log at path, compressed
+// when the log is. Without it, the lines are stamped when they are read.
+func openWriteTimes(path string) *writeTimes {
+	times := &writeTimes{}
+	name := runner.StructuredLogFile
+	if strings.HasSuffix(path, ".gz") {
+		name += ".gz"
+	}
+	file, err := os.Open(filepath.Join(filepath.Dir(path), name))
+	if err != nil {
+		return times
+	}
+	times.file = file
+	if strings.HasSuffix(name, ".gz") {
+		zr, err := gzip.NewReader(file)
+		if err != nil {
+			return times
+		}
+		times.reader = bufio.NewReader(zr)
+	} else {
+		times.reader = bufio.NewReader(file)
+	}
+	return times
+}
+
+// At returns the time the line ending at offset in the log was written: the time
+// of the first entry of the structured log written with it or after it. The
+// entries are written right after the log, a line whose entry is not written yet
+// or that has no structured log is stamped with the current time.
+// The offsets must increase from one call to the next.
+func (t *writeTimes) At(offset int64) time.Time {
+	for t.reader != nil && (!t.read || t.entry.End < offset) {
+		chunk, err := t.reader.ReadString('\n')
+		t.pending += chunk
+		if err != nil {
+			return time.Now()
+		}
+		var entry runner.LogEntry
+		if json.Unmarshal([]byte(t.pending), &entry) == nil {
+			t.entry, t.read = entry, true
+		}
+		t.pending = ""
+	}
+	if !t.read || t.entry.End < offset {
+		return time.Now()
+	}
+	return t.entry.Time
+}
+
+// Close closes the structured log
+func (t *writeTimes) Close() {
+	if t.file != nil {
+		t.file.Close()
+	}
+}
+
+// wait sleeps for FollowInterval, or until ctx is done
+func wait(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(FollowInterval):
+		return nil
+	}
+}
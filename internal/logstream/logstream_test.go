@@ -0,0 +1,179 @@
+package logstream
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/k8s-school/home-ci/internal/config"
+	"github.com/k8s-school/home-ci/internal/runner"
+)
+
+func TestFollow(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run.log")
+	var running atomic.Bool
+	running.Store(true)
+
+	// The log is created, then written while the run is followed
+	go func() {
+		time.Sleep(FollowInterval / 2)
+		file, err := os.Create(path)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		file.WriteString("first\nsec")
+		time.Sleep(FollowInterval)
+		file.WriteString("ond\nlast without newline")
+		file.Close()
+		running.Store(false)
+	}()
+
+	var lines []Line
+	err := Follow(context.Background(), path, 0, running.Load, func(line Line) error {
+		lines = append(lines, line)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Follow() failed: %v", err)
+	}
+
+	want := []string{"first", "second", "last without newline"}
+	if len(lines) != len(want) {
+		t.Fatalf("got %d lines %+v, want %v", len(lines), lines, want)
+	}
+	for i, line := range lines {
+		if line.Text != want[i] {
+			t.Errorf("line %d = %q, want %q", i, line.Text, want[i])
+		}
+	}
+	if lines[1].Offset != int64(len("first\nsecond\n")) {
+		t.Errorf("offset after the second line = %d", lines[1].Offset)
+	}
+
+	// Resuming from an offset skips the lines already read
+	offset := lines[1].Offset
+	lines = nil
+	Follow(context.Background(), path, offset, nil, func(line Line) error {
+		lines = append(lines, line)
+		return nil
+	})
+	if len(lines) != 1 || lines[0].Text != "last without newline" {
+		t.Errorf("unexpected lines after resuming: %+v", lines)
+	}
+}
+
+func TestFollowCompressed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run.log.gz")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := gzip.NewWriter(file)
+	zw.Write([]byte("first\nsecond\nlast"))
+	zw.Close()
+	file.Close()
+
+	// A compressed log is read once, even when the run is reported running
+	var lines []Line
+	err = Follow(context.Background(), path, int64(len("first\n")), func() bool { return true }, func(line Line) error {
+		lines = append(lines, line)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Follow() failed: %v", err)
+	}
+	if len(lines) != 2 || lines[0].Text != "second" || lines[1].Text != "last" || lines[1].Offset != int64(len("first\nsecond\nlast")) {
+		t.Errorf("unexpected lines: %+v", lines)
+	}
+}
+
+func TestFollowWriteTimes(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "run.log")
+	if err := os.WriteFile(path, []byte("first\nsecond\nthird\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The second and third lines are ended by the same write
+	written := time.Date(2024, 1, 15, 10, 5, 0, 0, time.UTC)
+	var entries []byte
+	for i, entry := range []runner.LogEntry{
+		{Time: written, Text: "first", End: 6},
+		{Time: written.Add(time.Second), Text: "second", End: 19},
+		{Time: written.Add(time.Second), Text: "third", End: 19},
+	} {
+		entry.Stream = runner.StreamStdout
+		data, err := json.Marshal(entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries = append(append(entries, data...), '\n')
+		if i == 0 {
+			entries = append(entries, "not an entry\n"...)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, runner.StructuredLogFile), entries, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var times []time.Time
+	err := Follow(context.Background(), path, 0, nil, func(line Line) error {
+		times = append(times, line.Time)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Follow() failed: %v", err)
+	}
+	want := []time.Time{written, written.Add(time.Second), written.Add(time.Second)}
+	if len(times) != len(want) {
+		t.Fatalf("got %d lines, want %d", len(times), len(want))
+	}
+	for i := range want {
+		if !times[i].Equal(want[i]) {
+			t.Errorf("line %d written at %s, want %s", i, times[i], want[i])
+		}
+	}
+
+	// Resuming from an offset skips the entries of the lines already read
+	times = nil
+	Follow(context.Background(), path, 6, nil, func(line Line) error {
+		times = append(times, line.Time)
+		return nil
+	})
+	if len(times) != 2 || !times[0].Equal(written.Add(time.Second)) {
+		t.Errorf("unexpected times after resuming: %v", times)
+	}
+}
+
+func TestFollowMissingLog(t *testing.T) {
+	err := Follow(context.Background(), filepath.Join(t.TempDir(), "run.log"), 0, nil, func(Line) error { return nil })
+	if err == nil {
+		t.Error("expected an error for the log of a run that is not running")
+	}
+}
+
+func TestRunLogPath(t *testing.T) {
+	cfg := &config.Config{RepoName: "test-repo", WorkDir: t.TempDir()}
+
+	for _, runID := range []string{"", "..", "main/../../etc"} {
+		if _, err := RunLogPath(cfg, runID); err == nil {
+			t.Errorf("RunLogPath(%q) should fail", runID)
+		}
+	}
+
+	path, err := RunLogPath(cfg, "main_01234567")
+	if err != nil || path != filepath.Join(cfg.GetLogsDir("main", "0123456789abcdef"), "run.log") {
+		t.Errorf("RunLogPath() = %q, %v", path, err)
+	}
+
+	tests := []runner.RunningTest{{Branch: "feature/login", Commit: "0123456789abcdef"}}
+	if !IsRunning(cfg, tests, "feature_login_01234567") || IsRunning(cfg, tests, "main_01234567") {
+		t.Error("IsRunning() must match the run ID of the running tests")
+	}
+}
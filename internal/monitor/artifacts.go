@@ -0,0 +1,47 @@
+package monitor
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/k8s-school/home-ci/internal/artifacts"
+)
+
+// startArtifactRetention periodically removes the artifacts older than artifacts.retention
+func (m *Monitor) startArtifactRetention() {
+	retention := m.config.Artifacts.Retention
+	store := artifacts.NewStore(m.config.GetArtifactsDir())
+
+	interval := defaultCleanupInterval
+	if retention < 2*time.Hour {
+		interval = retention / 2
+	}
+	if interval < minCleanupInterval {
+		interval = minCleanupInterval
+	}
+
+	prune := func() {
+		removed, err := store.Prune(retention)
+		if err != nil {
+			slog.Error("Failed to prune artifacts", "dir", store.Dir(), "error", err)
+		}
+		if removed > 0 {
+			slog.Info("Removed expired artifacts", "runs", removed, "retention", retention)
+		}
+	}
+
+	slog.Debug("Starting artifact retention routine", "interval", interval, "retention", retention)
+	prune()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-ticker.C:
+			prune()
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package monitor
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
 	"os"
@@ -9,41 +10,36 @@ import (
 	"time"
 
 	"github.com/go-git/go-git/v5"
-	"github.com/go-git/go-git/v5/config"
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+
+	"github.com/k8s-school/home-ci/internal/gitrepo"
 )
 
 type GitRepository struct {
 	repo        *git.Repository
 	repoPath    string
 	isRemoteURL bool
-	cacheDir    string // Directory for local cache of remote repos
+	cacheDir    string          // Directory holding the mirror of the repository
+	mirror      *gitrepo.Mirror // Persistent bare mirror shared with test workspaces
+	ctx         context.Context // Interrupts the fetches of the mirror when the monitor stops
 }
 
-func NewGitRepository(repoPath string, cacheBaseDir string) (*GitRepository, error) {
-	isRemoteURL := strings.HasPrefix(repoPath, "http://") || strings.HasPrefix(repoPath, "https://")
-
-	if isRemoteURL {
-		// For remote URLs, clean up any existing cache on startup
-		gr := &GitRepository{
-			repo:        nil,
-			repoPath:    repoPath,
-			isRemoteURL: true,
-			cacheDir:    "", // Will be set in cleanupCache based on cacheBaseDir
-		}
-		gr.cleanupCache(cacheBaseDir)
-		return gr, nil
-	}
-
-	// For local paths, also use cache-based approach for consistency
+// NewGitRepository creates the interface of the monitored repository, ctx interrupts its fetches
+func NewGitRepository(ctx context.Context, repoPath string, cacheBaseDir string, auth transport.AuthMethod) (*GitRepository, error) {
+	// Both remote URLs and local paths are monitored through a persistent bare mirror,
+	// which is kept across restarts and updated incrementally
+	mirror := gitrepo.NewMirror(repoPath, cacheBaseDir, auth)
 	gr := &GitRepository{
-		repo:        nil, // Will use cached repo instead
+		repo:        nil, // Will use the mirror instead
 		repoPath:    repoPath,
-		isRemoteURL: false,
-		cacheDir:    "", // Will be set in cleanupCache based on cacheBaseDir
+		isRemoteURL: gitrepo.IsRemoteURL(repoPath),
+		cacheDir:    mirror.Path(),
+		mirror:      mirror,
+		ctx:         ctx,
 	}
-	gr.cleanupCache(cacheBaseDir)
+	gr.cleanupLegacyCache(cacheBaseDir)
 	return gr, nil
 }
 
@@ -54,7 +50,7 @@ func (gr *GitRepository) GetPath() string {
 
 func (gr *GitRepository) GetBranches(recentCommitsWithin time.Duration) ([]string, error) {
 	// Use the unified getRemoteBranchesWithRecentCommits method for all cases
-	// This works for both remote URLs and local repositories using git.PlainClone
+	// This works for both remote URLs and local repositories through the mirror
 	return gr.getRemoteBranchesWithRecentCommits(recentCommitsWithin)
 }
 
@@ -63,21 +59,13 @@ func (gr *GitRepository) getRemoteBranchesWithRecentCommits(recentCommitsWithin
 	cutoffTime := time.Now().Add(-recentCommitsWithin)
 	slog.Debug("Filtering branches by commit recency", "repository", gr.repoPath, "cutoff_time", cutoffTime.Format("2006-01-02 15:04:05"), "recent_commits_within", recentCommitsWithin)
 
-	// Use cached repository approach for both remote URLs and local repositories
-	// git.PlainClone works with both remote URLs and local paths
-	repo, err := gr.ensureCachedRepo()
+	// Fetch latest updates into the mirror, creating it on first use
+	repo, err := gr.updateMirror()
 	if err != nil {
-		return nil, fmt.Errorf("failed to ensure cached repository: %w", err)
+		return nil, fmt.Errorf("failed to update repository mirror: %w", err)
 	}
 
-	// Fetch latest updates
-	err = gr.fetchRemoteUpdates(repo)
-	if err != nil {
-		slog.Debug("Failed to fetch remote updates", "error", err)
-		// Continue with existing cache if fetch fails
-	}
-
-	// Check all remote branches for recent commits
+	// Check all mirrored branches for recent commits
 	var branchesWithRecentCommits []string
 
 	refs, err := repo.References()
@@ -86,29 +74,12 @@ func (gr *GitRepository) getRemoteBranchesWithRecentCommits(recentCommitsWithin
 	}
 
 	err = refs.ForEach(func(ref *plumbing.Reference) error {
-		var branchName string
-
-		// For remote URLs: look for remote-tracking branches (refs/remotes/origin/*)
-		// For local repos: look for local branches (refs/heads/*)
-		if gr.isRemoteURL {
-			// Only process remote branch references
-			if !ref.Name().IsRemote() || !strings.HasPrefix(ref.Name().String(), "refs/remotes/origin/") {
-				return nil
-			}
-			// Skip the HEAD reference
-			if ref.Name().String() == "refs/remotes/origin/HEAD" {
-				return nil
-			}
-			// Extract branch name
-			branchName = strings.TrimPrefix(ref.Name().String(), "refs/remotes/origin/")
-		} else {
-			// For local repositories, process local branches
-			if !ref.Name().IsBranch() {
-				return nil
-			}
-			// Extract branch name
-			branchName = ref.Name().Short()
+		// The mirror stores upstream branches as local branches (refs/heads/*)
+		// for both remote URLs and local repositories
+		if !ref.Name().IsBranch() {
+			return nil
 		}
+		branchName := ref.Name().Short()
 
 		// Check commit timestamp
 		hasRecentCommit, err := gr.checkCachedBranchTimestamp(repo, ref, branchName, cutoffTime)
@@ -132,77 +103,33 @@ func (gr *GitRepository) getRemoteBranchesWithRecentCommits(recentCommitsWithin
 	return branchesWithRecentCommits, nil
 }
 
-// cleanupCache removes any existing cache directory for this repository
-func (gr *GitRepository) cleanupCache(cacheBaseDir string) {
-	if gr.cacheDir == "" {
-		// Create cache directory path based on repository URL
-		repoName := strings.ReplaceAll(strings.ReplaceAll(gr.repoPath, "/", "_"), ":", "_")
-		gr.cacheDir = filepath.Join(cacheBaseDir, repoName)
-	}
+// cleanupLegacyCache removes the shallow working-copy cache used before mirrors were introduced
+func (gr *GitRepository) cleanupLegacyCache(cacheBaseDir string) {
+	repoName := strings.ReplaceAll(strings.ReplaceAll(gr.repoPath, "/", "_"), ":", "_")
+	legacyDir := filepath.Join(cacheBaseDir, repoName)
 
-	if _, err := os.Stat(gr.cacheDir); err == nil {
-		slog.Debug("Cleaning up existing cache on startup", "cache_dir", gr.cacheDir)
-		if err := os.RemoveAll(gr.cacheDir); err != nil {
-			slog.Debug("Failed to remove cache directory", "cache_dir", gr.cacheDir, "error", err)
+	if _, err := os.Stat(filepath.Join(legacyDir, ".git")); err == nil {
+		slog.Debug("Removing legacy repository cache", "cache_dir", legacyDir)
+		if err := os.RemoveAll(legacyDir); err != nil {
+			slog.Debug("Failed to remove legacy cache directory", "cache_dir", legacyDir, "error", err)
 		}
 	}
 }
 
-// ensureCachedRepo creates or opens a cached repository for remote URL
-func (gr *GitRepository) ensureCachedRepo() (*git.Repository, error) {
-	if gr.cacheDir == "" {
-		return nil, fmt.Errorf("cache directory not set - this should not happen")
-	}
-
-	// Check if cached repository already exists
-	if _, err := os.Stat(filepath.Join(gr.cacheDir, ".git")); err == nil {
-		// Open existing cached repository
-		repo, err := git.PlainOpen(gr.cacheDir)
-		if err != nil {
-			// If opening fails, remove the corrupted cache and recreate
-			slog.Debug("Failed to open cached repository, recreating", "cache_dir", gr.cacheDir, "error", err)
-			os.RemoveAll(gr.cacheDir)
-		} else {
-			return repo, nil
-		}
-	}
-
-	// Create cache directory
-	if err := os.MkdirAll(gr.cacheDir, 0755); err != nil {
-		return nil, fmt.Errorf("failed to create cache directory: %w", err)
-	}
-
-	// Clone repository with shallow depth for monitoring efficiency
-	slog.Debug("Creating cached repository", "repository", gr.repoPath, "cache_dir", gr.cacheDir)
-	repo, err := git.PlainClone(gr.cacheDir, false, &git.CloneOptions{
-		URL:   gr.repoPath,
-		Depth: 1, // Shallow clone for efficient monitoring
-	})
-	if err != nil {
-		return nil, fmt.Errorf("failed to clone repository to cache: %w", err)
-	}
-
-	return repo, nil
-}
-
-// fetchRemoteUpdates fetches latest updates for the cached repository
-func (gr *GitRepository) fetchRemoteUpdates(repo *git.Repository) error {
-	remote, err := repo.Remote("origin")
-	if err != nil {
-		return fmt.Errorf("failed to get origin remote: %w", err)
+// updateMirror fetches latest updates into the mirror.
+// If the fetch fails but a previous mirror exists, monitoring continues with it.
+func (gr *GitRepository) updateMirror() (*git.Repository, error) {
+	repo, err := gr.mirror.Update(gr.ctx)
+	if err == nil {
+		return repo, nil
 	}
 
-	// Fetch all branches with shallow depth
-	err = remote.Fetch(&git.FetchOptions{
-		RefSpecs: []config.RefSpec{"refs/heads/*:refs/remotes/origin/*"},
-		Depth:    1,    // Only get the latest commit for each branch
-		Force:    true, // Force update in case of shallow history conflicts
-	})
-	if err != nil && err != git.NoErrAlreadyUpToDate {
-		return fmt.Errorf("failed to fetch remote updates: %w", err)
+	if repo != nil {
+		slog.Debug("Failed to fetch remote updates, using existing mirror", "mirror", gr.cacheDir, "error", err)
+		return repo, nil
 	}
 
-	return nil
+	return nil, err
 }
 
 // checkCachedBranchTimestamp checks if a branch in the cached repository has recent commits
@@ -227,22 +154,19 @@ func (gr *GitRepository) checkCachedBranchTimestamp(repo *git.Repository, ref *p
 }
 
 func (gr *GitRepository) GetLatestCommitForBranch(branchName string, recentCommitsWithin time.Duration) (*object.Commit, error) {
-	// Use cached repository approach for all cases
-	repo, err := gr.ensureCachedRepo()
-	if err != nil {
-		return nil, fmt.Errorf("failed to ensure cached repository: %w", err)
-	}
-
-	var refName string
-	if gr.isRemoteURL {
-		// For remote URLs, use remote tracking branches
-		refName = fmt.Sprintf("refs/remotes/origin/%s", branchName)
+	// Read the branch from the mirror, which is updated by GetBranches
+	var repo *git.Repository
+	var err error
+	if gr.mirror.Exists() {
+		repo, err = gr.mirror.Open()
 	} else {
-		// For local repositories, use local branches
-		refName = fmt.Sprintf("refs/heads/%s", branchName)
+		repo, err = gr.updateMirror()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open repository mirror: %w", err)
 	}
 
-	ref, err := repo.Reference(plumbing.ReferenceName(refName), true)
+	ref, err := repo.Reference(plumbing.NewBranchReferenceName(branchName), true)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get reference for branch %s: %w", branchName, err)
 	}
@@ -255,3 +179,27 @@ func (gr *GitRepository) GetLatestCommitForBranch(branchName string, recentCommi
 
 	return commit, nil
 }
+
+// ResolveCommit returns the full hash of a commit of a branch, given in full or short
+// form, or the latest commit of the branch when commit is empty. The mirror is
+// updated first, so that commits pushed since the last check are found.
+func (gr *GitRepository) ResolveCommit(branchName, commit string) (string, error) {
+	repo, err := gr.updateMirror()
+	if err != nil {
+		return "", fmt.Errorf("failed to update repository mirror: %w", err)
+	}
+
+	ref, err := repo.Reference(plumbing.NewBranchReferenceName(branchName), true)
+	if err != nil {
+		return "", fmt.Errorf("branch %s not found: %w", branchName, err)
+	}
+	if commit == "" {
+		return ref.Hash().String(), nil
+	}
+
+	hash, err := repo.ResolveRevision(plumbing.Revision(commit))
+	if err != nil {
+		return "", fmt.Errorf("commit %s not found: %w", commit, err)
+	}
+	return hash.String(), nil
+}
@@ -2,15 +2,22 @@ package monitor
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"net"
 	"os"
 	"path/filepath"
 	"time"
 
+	"github.com/k8s-school/home-ci/internal/api"
 	"github.com/k8s-school/home-ci/internal/config"
+	"github.com/k8s-school/home-ci/internal/control"
+	"github.com/k8s-school/home-ci/internal/coordinator"
+	"github.com/k8s-school/home-ci/internal/gitrepo"
 	"github.com/k8s-school/home-ci/internal/runner"
 	"github.com/k8s-school/home-ci/internal/state"
+	"github.com/k8s-school/home-ci/internal/utils"
 )
 
 const (
@@ -33,7 +40,10 @@ type Monitor struct {
 	gitRepo      *GitRepository
 	stateManager runner.StateManager
 	testRunner   *runner.TestRunner
+	coordinator  *coordinator.Server // Serves the jobs to agents instead of the test runner, nil when disabled
+	apiToken     string              // Token of the clients of the API, none required when empty
 	cleanupMgr   *CleanupManager
+	daemonLock   *os.File // Lock held while the daemon runs, nil when it could not be taken
 	ctx          context.Context
 	cancel       context.CancelFunc
 }
@@ -55,24 +65,50 @@ func NewCleanupManager(keepTime time.Duration, workspaceDir string, ctx context.
 }
 
 func NewMonitor(cfg config.Config, configPath string) (*Monitor, error) {
-	// Create git repository interface for both local and remote repositories
-	gitRepo, err := NewGitRepository(cfg.Repository, cfg.GetCacheDir())
+	auth, err := gitrepo.AuthForConfig(cfg, configPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to initialize git repository interface for '%s': %w\n\nPlease check your configuration:\n1. Ensure repository points to a valid git repository\n2. Example: repository: \"/path/to/your/repo\" or \"https://github.com/user/repo.git\"", cfg.Repository, err)
+		return nil, fmt.Errorf("failed to configure git authentication: %w", err)
 	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 
+	// Create git repository interface for both local and remote repositories
+	gitRepo, err := NewGitRepository(ctx, cfg.Repository, cfg.GetCacheDir(), auth)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to initialize git repository interface for '%s': %w\n\nPlease check your configuration:\n1. Ensure repository points to a valid git repository\n2. Example: repository: \"/path/to/your/repo\" or \"https://github.com/user/repo.git\"", cfg.Repository, err)
+	}
+
 	stateManager := state.NewStateManager(cfg.GetStateDir(), cfg.RepoName)
 
+	// The lock tells home-ci status that the daemon runs, even when it has no control socket
+	daemonLock, err := stateManager.LockDaemon()
+	if errors.Is(err, state.ErrDaemonRunning) {
+		cancel()
+		return nil, err
+	}
+	if err != nil {
+		slog.Warn("Failed to take the daemon lock, home-ci status only detects the daemon from its control socket", "error", err)
+	}
+	fail := func(err error) (*Monitor, error) {
+		cancel()
+		if daemonLock != nil {
+			daemonLock.Close()
+		}
+		return nil, err
+	}
+
 	// Load existing state
 	if err := stateManager.LoadState(); err != nil {
-		cancel() // Clean up context on error
-		return nil, fmt.Errorf("failed to load state: %w", err)
+		return fail(fmt.Errorf("failed to load state: %w", err))
 	}
 
-	testRunner := runner.NewTestRunner(cfg, configPath, cfg.WorkDir, ctx, stateManager)
-	cleanupMgr := NewCleanupManager(cfg.KeepTime, cfg.WorkDir, ctx)
+	testRunner, err := runner.NewTestRunner(cfg, configPath, cfg.WorkDir, ctx, stateManager)
+	if err != nil {
+		return fail(err)
+	}
+	// Only the workspaces expire, the mirror and the state are kept in the same work directory
+	cleanupMgr := NewCleanupManager(cfg.KeepTime, cfg.GetRunsDir(), ctx)
 
 	m := &Monitor{
 		config:       cfg,
@@ -80,6 +116,7 @@ func NewMonitor(cfg config.Config, configPath string) (*Monitor, error) {
 		stateManager: stateManager,
 		testRunner:   testRunner,
 		cleanupMgr:   cleanupMgr,
+		daemonLock:   daemonLock,
 		ctx:          ctx,
 		cancel:       cancel,
 	}
@@ -88,6 +125,21 @@ func NewMonitor(cfg config.Config, configPath string) (*Monitor, error) {
 		slog.Debug("Failed to load previous state", "error", err)
 	}
 
+	if cfg.Coordinator.Listen != "" {
+		token := ""
+		if cfg.Coordinator.TokenFile != "" {
+			if token, err = coordinator.ReadToken(cfg.Coordinator.TokenFile, configPath); err != nil {
+				return fail(fmt.Errorf("failed to load coordinator token: %w", err))
+			}
+		}
+		m.coordinator = coordinator.NewServer(testRunner, cfg.Coordinator.LeaseTTL, token)
+	}
+	if cfg.API.Listen != "" && cfg.API.TokenFile != "" {
+		if m.apiToken, err = coordinator.ReadToken(cfg.API.TokenFile, configPath); err != nil {
+			return fail(fmt.Errorf("failed to load API token: %w", err))
+		}
+	}
+
 	return m, nil
 }
 
@@ -95,14 +147,63 @@ func (m *Monitor) Start() error {
 	slog.Debug("Starting Git CI Monitor")
 	slog.Debug("Configuration", "repository", m.config.Repository, "check_interval", m.config.CheckInterval, "max_concurrent_runs", m.config.MaxConcurrentRuns, "recent_commits_within", m.config.RecentCommitsWithin, "options", m.config.Options)
 
-	// Start test runner goroutine
-	go m.testRunner.Start()
+	// Requeue jobs that were waiting when the previous instance stopped
+	m.restoreQueuedJobs()
+
+	// Serve the commands of the CLI. Without the socket, the daemon still runs the
+	// tests, unless another daemon serves the same repository.
+	listener, err := control.Listen(m.config.GetControlSocket())
+	switch {
+	case errors.Is(err, control.ErrDaemonRunning):
+		return fmt.Errorf("failed to start control socket: %w", err)
+	case err != nil:
+		slog.Error("Control socket disabled, the CLI cannot control the daemon", "error", err)
+	default:
+		go func() {
+			if err := control.NewServer(&m.config, m.testRunner, m.stateManager, m.gitRepo.ResolveCommit).Serve(m.ctx, listener); err != nil {
+				slog.Error("Control socket stopped", "error", err)
+			}
+		}()
+	}
+
+	// Start test runner goroutine, or serve the jobs to the agents
+	if m.coordinator != nil {
+		listener, err := net.Listen("tcp", m.config.Coordinator.Listen)
+		if err != nil {
+			return fmt.Errorf("failed to start coordinator: %w", err)
+		}
+		go func() {
+			if err := m.coordinator.Serve(m.ctx, listener); err != nil {
+				slog.Error("Coordinator stopped", "error", err)
+			}
+		}()
+	} else {
+		go m.testRunner.Start()
+	}
+
+	// Serve the logs of the runs
+	if m.config.API.Listen != "" {
+		listener, err := net.Listen("tcp", m.config.API.Listen)
+		if err != nil {
+			return fmt.Errorf("failed to start API: %w", err)
+		}
+		go func() {
+			if err := api.NewServer(&m.config, m.stateManager, m.apiToken).Serve(m.ctx, listener); err != nil {
+				slog.Error("API stopped", "error", err)
+			}
+		}()
+	}
 
 	// Start cleanup routine if KeepTime is configured
 	if m.config.KeepTime > 0 {
 		go m.cleanupMgr.startCleanupRoutine()
 	}
 
+	// Artifacts have their own retention, independent from the workspaces
+	if m.config.Artifacts.Retention > 0 {
+		go m.startArtifactRetention()
+	}
+
 	// Start monitoring loop
 	ticker := time.NewTicker(m.config.CheckInterval)
 	defer ticker.Stop()
@@ -125,12 +226,28 @@ func (m *Monitor) Start() error {
 	}
 }
 
+// restoreQueuedJobs requeues the jobs saved in the state, their commits are already marked as processed
+func (m *Monitor) restoreQueuedJobs() {
+	queued := m.stateManager.GetQueuedJobs()
+	m.stateManager.SetQueuedJobs(nil)
+
+	for _, job := range queued {
+		restored := runner.TestJob{Branch: job.Branch, Commit: job.Commit, Trigger: job.Trigger, QueuedAt: job.QueuedAt, Attempt: job.Attempt, PreviousCommit: job.PreviousCommit}
+		if m.testRunner.QueueTestJob(restored) {
+			slog.Debug("Restored queued job", "branch", job.Branch, "commit", utils.ShortCommit(job.Commit), "trigger", job.Trigger)
+		}
+	}
+}
+
 func (m *Monitor) Stop() {
 	m.cancel()
 	m.testRunner.Close()
 	if err := m.stateManager.SaveState(); err != nil {
 		slog.Debug("Error saving state", "error", err)
 	}
+	if m.daemonLock != nil {
+		m.daemonLock.Close()
+	}
 }
 
 func (m *Monitor) checkForUpdates() error {
@@ -138,9 +255,15 @@ func (m *Monitor) checkForUpdates() error {
 
 	branches, err := m.gitRepo.GetBranches(m.config.RecentCommitsWithin)
 	if err != nil {
-		return fmt.Errorf("failed to get branches: %w", err)
+		err = fmt.Errorf("failed to get branches: %w", err)
+		m.stateManager.SetPollStatus(time.Now(), err)
+		if saveErr := m.stateManager.SaveState(); saveErr != nil {
+			slog.Debug("Error saving state", "error", saveErr)
+		}
+		return err
 	}
 
+	m.stateManager.SetPollStatus(time.Now(), nil)
 	m.processBranches(branches)
 	return m.stateManager.SaveState()
 }
@@ -179,6 +302,9 @@ func (m *Monitor) processBranchWithDateFilter(branchName string) error {
 
 	// Queue the test job
 	job := runner.TestJob{Branch: branchName, Commit: commitHash}
+	if state != nil {
+		job.PreviousCommit = state.LatestCommit
+	}
 	if m.testRunner.QueueTestJob(job) {
 		// Update state after queuing
 		m.stateManager.UpdateBranchState(branchName, commitHash)
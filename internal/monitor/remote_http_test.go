@@ -1,6 +1,7 @@
 package monitor
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -34,7 +35,7 @@ func TestGitRepository_HTTPSRemoteAccess(t *testing.T) {
 	repoURL := fmt.Sprintf("%s/test-repo.git", server.URL)
 
 	t.Run("RemoteRepositoryDetection", func(t *testing.T) {
-		gitRepo, err := NewGitRepository(repoURL, "/tmp")
+		gitRepo, err := NewGitRepository(context.Background(), repoURL, "/tmp", nil)
 		require.NoError(t, err)
 
 		assert.True(t, gitRepo.isRemoteURL, "Should detect HTTPS URL as remote")
@@ -43,7 +44,7 @@ func TestGitRepository_HTTPSRemoteAccess(t *testing.T) {
 	})
 
 	t.Run("GetBranchesFromRemote", func(t *testing.T) {
-		gitRepo, err := NewGitRepository(repoURL, "/tmp")
+		gitRepo, err := NewGitRepository(context.Background(), repoURL, "/tmp", nil)
 		require.NoError(t, err)
 
 		recentCommitsWithin := 24 * time.Hour
@@ -63,7 +64,7 @@ func TestGitRepository_HTTPSRemoteAccess(t *testing.T) {
 	})
 
 	t.Run("GetLatestCommitForRemoteBranch", func(t *testing.T) {
-		gitRepo, err := NewGitRepository(repoURL, "/tmp")
+		gitRepo, err := NewGitRepository(context.Background(), repoURL, "/tmp", nil)
 		require.NoError(t, err)
 
 		recentCommitsWithin := 24 * time.Hour
@@ -78,7 +79,7 @@ func TestGitRepository_HTTPSRemoteAccess(t *testing.T) {
 	t.Run("NetworkErrorHandling", func(t *testing.T) {
 		// Test with unreachable server
 		unreachableURL := "http://localhost:99999/nonexistent-repo.git"
-		gitRepo, err := NewGitRepository(unreachableURL, "/tmp")
+		gitRepo, err := NewGitRepository(context.Background(), unreachableURL, "/tmp", nil)
 		require.NoError(t, err) // Creation should succeed
 
 		_, err = gitRepo.GetBranches(24 * time.Hour)
@@ -91,7 +92,7 @@ func TestGitRepository_HTTPSNetworkErrors(t *testing.T) {
 	t.Run("TimeoutHandling", func(t *testing.T) {
 		// Test with an unreachable port on localhost (should fail quickly)
 		timeoutURL := "http://localhost:99999/timeout-repo.git"
-		gitRepo, err := NewGitRepository(timeoutURL, "/tmp")
+		gitRepo, err := NewGitRepository(context.Background(), timeoutURL, "/tmp", nil)
 		require.NoError(t, err)
 
 		// This should fail due to connection refused
@@ -106,7 +107,7 @@ func TestGitRepository_HTTPSNetworkErrors(t *testing.T) {
 
 	t.Run("InvalidURLFormat", func(t *testing.T) {
 		invalidURL := "http://not-a-valid-url-format"
-		gitRepo, err := NewGitRepository(invalidURL, "/tmp")
+		gitRepo, err := NewGitRepository(context.Background(), invalidURL, "/tmp", nil)
 		require.NoError(t, err) // Creation should succeed
 
 		_, err = gitRepo.GetBranches(24 * time.Hour)
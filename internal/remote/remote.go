@@ -0,0 +1,126 @@
+package remote
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// Client runs commands on a remote host with the ssh command, so that the user
+// ssh configuration, agent and known hosts apply
+type Client struct {
+	Host           string // SSH destination, e.g. ci@lab.example.org
+	Port           int    // 0 keeps the port of the ssh configuration
+	IdentityFile   string // Private key, the ssh defaults when empty
+	KnownHostsFile string // Host keys, the user known hosts when empty
+}
+
+// Args returns the arguments of the ssh command running command on the host
+func (c *Client) Args(command string) []string {
+	// BatchMode fails instead of prompting for a password or a host key,
+	// the keepalives detect a worker that went away
+	args := []string{"-o", "BatchMode=yes", "-o", "ConnectTimeout=30", "-o", "ServerAliveInterval=30"}
+	if c.Port != 0 {
+		args = append(args, "-p", strconv.Itoa(c.Port))
+	}
+	if c.IdentityFile != "" {
+		args = append(args, "-i", c.IdentityFile)
+	}
+	if c.KnownHostsFile != "" {
+		args = append(args, "-o", "UserKnownHostsFile="+c.KnownHostsFile, "-o", "StrictHostKeyChecking=yes")
+	}
+	return append(args, c.Host, command)
+}
+
+// Command returns the ssh command running a shell command on the host
+func (c *Client) Command(ctx context.Context, command string) *exec.Cmd {
+	return exec.CommandContext(ctx, "ssh", c.Args(command)...)
+}
+
+// Run runs a shell command on the host and returns its output
+func (c *Client) Run(ctx context.Context, command string, stdin io.Reader) ([]byte, error) {
+	cmd := c.Command(ctx, command)
+	cmd.Stdin = stdin
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return out, fmt.Errorf("ssh %s: %w: %s", c.Host, err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// Upload replaces remoteDir with the named files and directories of localDir
+func (c *Client) Upload(ctx context.Context, localDir string, names []string, remoteDir string) error {
+	reader, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(WriteTar(writer, localDir, names))
+	}()
+
+	command := fmt.Sprintf("rm -rf %[1]s && mkdir -p %[1]s && tar -C %[1]s -xf -", Quote(remoteDir))
+	_, err := c.Run(ctx, command, reader)
+	reader.Close()
+	if err != nil {
+		return fmt.Errorf("failed to upload %s to %s:%s: %w", localDir, c.Host, remoteDir, err)
+	}
+	return nil
+}
+
+// Download copies the named files of remoteDir to localDir
+func (c *Client) Download(ctx context.Context, remoteDir string, names []string, localDir string) error {
+	if len(names) == 0 {
+		return nil
+	}
+
+	// The names go through stdin, so that long lists fit
+	cmd := c.Command(ctx, fmt.Sprintf("cd %s && tar -cf - -T -", Quote(remoteDir)))
+	cmd.Stdin = strings.NewReader(strings.Join(names, "\n") + "\n")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start ssh: %w", err)
+	}
+
+	extractErr := ExtractTar(stdout, localDir)
+	io.Copy(io.Discard, stdout)
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("failed to download from %s:%s: %w: %s", c.Host, remoteDir, err, strings.TrimSpace(stderr.String()))
+	}
+	return extractErr
+}
+
+// ListFiles returns the regular files below the named directories of remoteDir,
+// as slash-separated paths relative to remoteDir. Missing directories are ignored.
+func (c *Client) ListFiles(ctx context.Context, remoteDir string, dirs ...string) ([]string, error) {
+	var quoted []string
+	for _, dir := range dirs {
+		quoted = append(quoted, Quote(dir))
+	}
+	command := fmt.Sprintf("cd %s && for d in %s; do [ -d \"$d\" ] && find \"$d\" -type f; done; true",
+		Quote(remoteDir), strings.Join(quoted, " "))
+	out, err := c.Run(ctx, command, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, line := range strings.Split(string(out), "\n") {
+		if line = strings.TrimPrefix(line, "./"); line != "" {
+			files = append(files, line)
+		}
+	}
+	return files, nil
+}
+
+// Quote quotes s for a POSIX shell
+func Quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
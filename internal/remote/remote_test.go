@@ -0,0 +1,130 @@
+package remote
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestQuote(t *testing.T) {
+	for _, value := range []string{"plain", "with space", "it's", `"$HOME" $(id) ; rm -rf /`, ""} {
+		out, err := exec.Command("sh", "-c", "printf %s "+Quote(value)).Output()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(out) != value {
+			t.Errorf("Quote(%q) is read back as %q", value, out)
+		}
+	}
+}
+
+func TestClientArgs(t *testing.T) {
+	client := &Client{Host: "ci@lab", Port: 2222, IdentityFile: "/keys/id", KnownHostsFile: "/keys/known_hosts"}
+	args := client.Args("true")
+	want := []string{"-o", "BatchMode=yes", "-o", "ConnectTimeout=30", "-o", "ServerAliveInterval=30",
+		"-p", "2222", "-i", "/keys/id",
+		"-o", "UserKnownHostsFile=/keys/known_hosts", "-o", "StrictHostKeyChecking=yes",
+		"ci@lab", "true"}
+	if len(args) != len(want) {
+		t.Fatalf("Args() = %q, want %q", args, want)
+	}
+	for i := range want {
+		if args[i] != want[i] {
+			t.Fatalf("Args() = %q, want %q", args, want)
+		}
+	}
+}
+
+func TestTarRoundTrip(t *testing.T) {
+	src := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(src, "src", "repo", "e2e"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "src", "repo", "e2e", "run.sh"), []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("e2e/run.sh", filepath.Join(src, "src", "repo", "run.sh")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "skipped.txt"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var archive bytes.Buffer
+	if err := WriteTar(&archive, src, []string{"src"}); err != nil {
+		t.Fatalf("WriteTar() failed: %v", err)
+	}
+	dst := t.TempDir()
+	if err := ExtractTar(&archive, dst); err != nil {
+		t.Fatalf("ExtractTar() failed: %v", err)
+	}
+
+	info, err := os.Stat(filepath.Join(dst, "src", "repo", "e2e", "run.sh"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0755 {
+		t.Errorf("mode = %v, want 0755", info.Mode().Perm())
+	}
+	if link, err := os.Readlink(filepath.Join(dst, "src", "repo", "run.sh")); err != nil || link != "e2e/run.sh" {
+		t.Errorf("symlink = %q, %v", link, err)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "skipped.txt")); err == nil {
+		t.Error("a file not listed was archived")
+	}
+}
+
+func TestWriteTarFiles(t *testing.T) {
+	src := t.TempDir()
+	if err := os.WriteFile(filepath.Join(src, "e2e-report.yaml"), []byte("status: passed\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var archive bytes.Buffer
+	if err := WriteTarFiles(&archive, map[string]string{"report/e2e-report.yaml": filepath.Join(src, "e2e-report.yaml")}); err != nil {
+		t.Fatalf("WriteTarFiles() failed: %v", err)
+	}
+	dst := t.TempDir()
+	if err := ExtractTar(&archive, dst); err != nil {
+		t.Fatalf("ExtractTar() failed: %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(dst, "report", "e2e-report.yaml")); err != nil || string(data) != "status: passed\n" {
+		t.Errorf("extracted file = %q, %v", data, err)
+	}
+
+	if err := WriteTarFiles(&archive, map[string]string{"missing": filepath.Join(src, "missing")}); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
+
+func TestExtractTarOutside(t *testing.T) {
+	tests := map[string][]tar.Header{
+		"parent directory": {
+			{Name: "../escaped", Typeflag: tar.TypeReg, Mode: 0644},
+		},
+		"through a symlink": {
+			{Name: "link", Typeflag: tar.TypeSymlink, Linkname: os.TempDir()},
+			{Name: "link/escaped", Typeflag: tar.TypeReg, Mode: 0644},
+		},
+	}
+
+	for name, headers := range tests {
+		t.Run(name, func(t *testing.T) {
+			var archive bytes.Buffer
+			tw := tar.NewWriter(&archive)
+			for _, header := range headers {
+				if err := tw.WriteHeader(&header); err != nil {
+					t.Fatal(err)
+				}
+			}
+			tw.Close()
+
+			if err := ExtractTar(&archive, t.TempDir()); err == nil {
+				t.Error("expected ExtractTar() to reject the entry")
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package runner
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/k8s-school/home-ci/internal/artifacts"
+	"github.com/k8s-school/home-ci/internal/utils"
+)
+
+// runLogFiles are the files of the logs directory kept with artifacts.keep_logs
+var runLogFiles = []string{"run.log", "run.log.gz", StructuredLogFile, StructuredLogFile + ".gz", "run.json", "e2e-report.yaml"}
+
+// artifactStore returns the artifact store of the repository
+func (te *TestExecution) artifactStore() *artifacts.Store {
+	return artifacts.NewStore(te.cfg().GetArtifactsDir())
+}
+
+// collectArtifacts copies the files matching artifacts.paths from the project directory,
+// and the files written to HOME_CI_ARTIFACTS_DIR, to the artifact store
+func (te *TestExecution) collectArtifacts() {
+	cfg := te.cfg()
+	var scriptFiles []string
+	if te.artifactsDir != "" {
+		scriptFiles, _ = artifacts.FindFiles(te.artifactsDir, []string{"**"})
+	}
+	if len(cfg.Artifacts.Paths) == 0 && len(scriptFiles) == 0 {
+		return
+	}
+
+	runID := cfg.GetRunID(te.branch, te.commit)
+	store := te.artifactStore()
+
+	fmt.Fprintf(te.logFile, "\n=== Collecting Artifacts ===\n")
+	fmt.Fprintf(te.logFile, "Patterns: %v\n", cfg.Artifacts.Paths)
+	fmt.Fprintf(te.logFile, "Destination: %s\n", store.RunDir(runID))
+
+	var files []artifacts.File
+	var err error
+	if len(cfg.Artifacts.Paths) > 0 {
+		files, err = store.Collect(runID, te.projectDir, cfg.Artifacts.Paths)
+	}
+	if err == nil && len(scriptFiles) > 0 {
+		var written []artifacts.File
+		written, err = store.Collect(runID, te.artifactsDir, []string{"**"})
+		files = append(files, written...)
+	}
+	for _, file := range files {
+		fmt.Fprintf(te.logFile, "  %s (%d bytes)\n", file.Path, file.Size)
+	}
+	if err != nil {
+		fmt.Fprintf(te.logFile, "Failed to collect artifacts: %v\n", err)
+		slog.Error("Failed to collect artifacts", "branch", te.branch, "commit", utils.ShortCommit(te.commit), "error", err)
+	}
+	fmt.Fprintf(te.logFile, "Collected %d file(s)\n", len(files))
+	fmt.Fprintf(te.logFile, "============================\n")
+
+	te.testResult.Artifacts = files
+}
+
+// storeRunLogs copies the log files of the run to the artifact store, so that they outlive the workspace
+func (te *TestExecution) storeRunLogs() {
+	cfg := te.cfg()
+	if !cfg.Artifacts.KeepLogs {
+		return
+	}
+
+	runID := cfg.GetRunID(te.branch, te.commit)
+	logsDir := filepath.Dir(te.logFilePath)
+	store := te.artifactStore()
+
+	for _, name := range runLogFiles {
+		src := filepath.Join(logsDir, name)
+		if _, err := os.Stat(src); err != nil {
+			continue
+		}
+		if _, err := store.AddFile(runID, src, filepath.Join("logs", name)); err != nil {
+			slog.Error("Failed to store run log", "file", src, "error", err)
+		}
+	}
+}
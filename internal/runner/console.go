@@ -0,0 +1,79 @@
+package runner
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// consoleOutput copies the output of the commands to a stream of the daemon.
+// With a prefix, the lines are written whole so that concurrent runs do not
+// interleave within a line.
+type consoleOutput struct {
+	mu      sync.Mutex
+	w       io.Writer
+	prefix  string
+	pending []byte  // Start of a line not terminated yet
+	log     *runLog // The copy stops once the log is over logs.max_size
+}
+
+// consoleOutput returns the copy of the command output to w, nil when the output is
+// only written to run.log. Manual runs print it as is, the daemon and the agents
+// prefix each line with the run ID when logs.console is set.
+func (te *TestExecution) consoleOutput(w io.Writer) *consoleOutput {
+	if te.manualRun() {
+		return &consoleOutput{w: w, log: te.logFile}
+	}
+	if !te.cfg().Logs.Console {
+		return nil
+	}
+	return &consoleOutput{w: w, prefix: "[" + te.cfg().GetRunID(te.branch, te.commit) + "] ", log: te.logFile}
+}
+
+// Write implements io.Writer
+func (c *consoleOutput) Write(p []byte) (int, error) {
+	if c.log.Dropping() {
+		return len(p), nil
+	}
+	if c.prefix == "" {
+		return c.w.Write(p)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	data := append(c.pending, p...)
+	for {
+		end := bytes.IndexByte(data, '\n')
+		if end < 0 {
+			break
+		}
+		if _, err := c.w.Write(append([]byte(c.prefix), data[:end+1]...)); err != nil {
+			return 0, err
+		}
+		data = data[end+1:]
+	}
+	c.pending = append([]byte(nil), data...)
+	return len(p), nil
+}
+
+// Flush writes the last line when it is not terminated
+func (c *consoleOutput) Flush() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.pending) > 0 {
+		c.w.Write(append(append([]byte(c.prefix), c.pending...), '\n'))
+		c.pending = nil
+	}
+}
+
+// withConsole returns a writer copying to a stream of run.log and to console, if any.
+// The log is written first, so that the console copy stops as soon as the log reaches its cap.
+func (te *TestExecution) withConsole(console *consoleOutput, stream string) io.Writer {
+	if console == nil {
+		return te.logFile.Stream(stream)
+	}
+	return io.MultiWriter(te.logFile.Stream(stream), console)
+}
@@ -0,0 +1,44 @@
+package runner
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/k8s-school/home-ci/internal/config"
+)
+
+func TestConsoleOutput(t *testing.T) {
+	cfg := config.Config{RepoName: "test-repo", WorkDir: t.TempDir()}
+	execution := &TestExecution{
+		runner: &TestRunner{config: cfg, stateManager: &MockStateManager{}},
+		branch: "feature/login",
+		commit: "0123456789abcdef",
+	}
+
+	// The daemon does not copy the output by default
+	if execution.consoleOutput(&bytes.Buffer{}) != nil {
+		t.Fatal("the output must only go to run.log without logs.console")
+	}
+
+	// With logs.console, each line is prefixed with the run ID
+	execution.runner.config.Logs.Console = true
+	var out bytes.Buffer
+	console := execution.consoleOutput(&out)
+	console.Write([]byte("first line\nsecond "))
+	console.Write([]byte("line\nunterminated"))
+	console.Flush()
+	want := "[feature_login_01234567] first line\n[feature_login_01234567] second line\n[feature_login_01234567] unterminated\n"
+	if out.String() != want {
+		t.Errorf("console output = %q, want %q", out.String(), want)
+	}
+
+	// Manual runs print the output as is
+	execution.runner.stateManager = nil
+	out.Reset()
+	console = execution.consoleOutput(&out)
+	console.Write([]byte("progress 50%\r"))
+	console.Flush()
+	if out.String() != "progress 50%\r" {
+		t.Errorf("manual run output = %q", out.String())
+	}
+}
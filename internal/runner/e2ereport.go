@@ -0,0 +1,99 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/k8s-school/home-ci/internal/config"
+	"github.com/k8s-school/home-ci/internal/e2ereport"
+	"github.com/k8s-school/home-ci/internal/utils"
+)
+
+// removeReport removes the e2e-report.yaml of a previous attempt, which must not decide
+// the result of this one
+func (te *TestExecution) removeReport() error {
+	path := filepath.Join(te.cfg().GetLogsDir(te.branch, te.commit), e2ereport.FileName)
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove %s of previous attempt: %w", e2ereport.FileName, err)
+	}
+	return nil
+}
+
+// reconcileReport parses e2e-report.yaml and combines its status with the exit code of the test,
+// according to report.policy
+func (te *TestExecution) reconcileReport() {
+	cfg := te.cfg()
+	result := te.testResult
+	path := filepath.Join(cfg.GetLogsDir(te.branch, te.commit), e2ereport.FileName)
+
+	report, err := e2ereport.Load(path)
+	missing := errors.Is(err, fs.ErrNotExist)
+	if err != nil && !missing {
+		result.ReportError = err.Error()
+	}
+	result.Report = report
+
+	exitCodeSuccess := result.Success
+	reportStatus := "missing"
+	switch {
+	case report != nil:
+		reportStatus = report.Status
+	case !missing:
+		reportStatus = "invalid"
+	}
+
+	// Decide the result of the run
+	var failure string
+	switch {
+	case missing && cfg.Report.Required:
+		failure = fmt.Sprintf("required %s was not written", e2ereport.FileName)
+	case cfg.Report.Policy == config.ReportPolicyExitCode:
+	case err != nil && !missing:
+		failure = fmt.Sprintf("invalid %s: %v", e2ereport.FileName, err)
+	case missing && cfg.Report.Policy == config.ReportPolicyReport:
+		failure = fmt.Sprintf("%s was not written", e2ereport.FileName)
+	case report != nil && !report.Passed():
+		failure = fmt.Sprintf("%s status is %s", e2ereport.FileName, report.Status)
+	case report != nil && cfg.Report.Policy == config.ReportPolicyReport && !result.Success && !result.TimedOut && result.FailureReason != FailureReasonOOM:
+		// The report decides, even if the script exited with an error
+		result.Success = true
+		result.ErrorMessage = ""
+		result.FailedStep = ""
+	}
+
+	if failure != "" && result.Success {
+		result.Success = false
+		result.ErrorMessage = failure
+	}
+
+	fmt.Fprintf(te.logFile, "\n=== Test Report ===\n")
+	fmt.Fprintf(te.logFile, "Report: %s (%s)\n", e2ereport.FileName, reportStatus)
+	if result.ReportError != "" {
+		fmt.Fprintf(te.logFile, "Report error: %s\n", result.ReportError)
+	}
+	if report != nil {
+		for _, check := range report.Checks {
+			fmt.Fprintf(te.logFile, "  %-8s %s\n", check.Status, check.Name)
+		}
+	}
+	fmt.Fprintf(te.logFile, "Policy: %s\n", cfg.Report.Policy)
+	fmt.Fprintf(te.logFile, "Exit code success: %t\n", exitCodeSuccess)
+	fmt.Fprintf(te.logFile, "Result: %s\n", map[bool]string{true: "success", false: "failure"}[result.Success])
+	if failure != "" {
+		fmt.Fprintf(te.logFile, "Reason: %s\n", failure)
+	}
+	fmt.Fprintf(te.logFile, "===================\n")
+
+	if exitCodeSuccess != result.Success {
+		slog.Info("Test result changed by the report",
+			"branch", te.branch,
+			"commit", utils.ShortCommit(te.commit),
+			"policy", cfg.Report.Policy,
+			"report_status", reportStatus,
+			"success", result.Success)
+	}
+}
@@ -0,0 +1,106 @@
+package runner
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/k8s-school/home-ci/internal/config"
+	"github.com/k8s-school/home-ci/internal/e2ereport"
+)
+
+func TestReconcileReport(t *testing.T) {
+	tests := []struct {
+		name        string
+		policy      string
+		required    bool
+		report      string // Empty for no report
+		exitSuccess bool
+		want        bool
+	}{
+		{"exit 0 and report passed", config.ReportPolicyBoth, false, "status: passed\n", true, true},
+		{"exit 0 but report failed", config.ReportPolicyBoth, false, "status: failed\n", true, false},
+		{"exit 0 without report", config.ReportPolicyBoth, false, "", true, true},
+		{"exit 0 with invalid report", config.ReportPolicyBoth, false, "status: great\n", true, false},
+		{"exit 0 without required report", config.ReportPolicyBoth, true, "", true, false},
+		{"exit code policy ignores report", config.ReportPolicyExitCode, false, "status: failed\n", true, true},
+		{"report policy overrides exit code", config.ReportPolicyReport, false, "status: passed\n", false, true},
+		{"report policy without report", config.ReportPolicyReport, false, "", true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Config{
+				RepoName: "test-repo",
+				WorkDir:  t.TempDir(),
+				Report:   config.Report{Policy: tt.policy, Required: tt.required},
+			}
+			logsDir := cfg.GetLogsDir("main", "0123456789abcdef")
+			if err := os.MkdirAll(logsDir, 0755); err != nil {
+				t.Fatal(err)
+			}
+			if tt.report != "" {
+				if err := os.WriteFile(filepath.Join(logsDir, e2ereport.FileName), []byte(tt.report), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			logFile, err := os.Create(filepath.Join(logsDir, "run.log"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer logFile.Close()
+
+			execution := &TestExecution{
+				runner:     &TestRunner{config: cfg},
+				branch:     "main",
+				commit:     "0123456789abcdef",
+				logFile:    newRunLog(logFile, nil),
+				testResult: &TestResult{Success: tt.exitSuccess},
+			}
+			execution.reconcileReport()
+
+			if execution.testResult.Success != tt.want {
+				t.Errorf("Success = %v, want %v (error: %q)", execution.testResult.Success, tt.want, execution.testResult.ErrorMessage)
+			}
+			if !tt.want && execution.testResult.ErrorMessage == "" {
+				t.Error("expected an error message explaining the failure")
+			}
+		})
+	}
+}
+
+func TestReportOfPreviousAttemptIsRemoved(t *testing.T) {
+	cfg := config.Config{RepoName: "test-repo", WorkDir: t.TempDir(), Report: config.Report{Policy: config.ReportPolicyReport}}
+	logsDir := cfg.GetLogsDir("main", "0123456789abcdef")
+	if err := os.MkdirAll(logsDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(logsDir, e2ereport.FileName), []byte("status: passed\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	logFile, err := os.Create(filepath.Join(logsDir, "run.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logFile.Close()
+
+	// The next attempt fails without writing a report, the passed report of the first one is ignored
+	execution := &TestExecution{
+		runner:     &TestRunner{config: cfg},
+		branch:     "main",
+		commit:     "0123456789abcdef",
+		logFile:    newRunLog(logFile, nil),
+		testResult: &TestResult{},
+	}
+	if err := execution.removeReport(); err != nil {
+		t.Fatalf("removeReport() failed: %v", err)
+	}
+	if err := execution.removeReport(); err != nil {
+		t.Errorf("removeReport() without report failed: %v", err)
+	}
+	execution.reconcileReport()
+	if execution.testResult.Success || execution.testResult.Report != nil {
+		t.Errorf("result = %+v, want a failure without report", execution.testResult)
+	}
+}
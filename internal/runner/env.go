@@ -0,0 +1,52 @@
+package runner
+
+import (
+	"strconv"
+
+	"github.com/k8s-school/home-ci/internal/utils"
+)
+
+// EnvVersion is the version of the HOME_CI_* environment contract, exported as
+// HOME_CI_ENV_VERSION. It is incremented when a variable is removed or changes
+// meaning, adding a variable keeps the version.
+const EnvVersion = 1
+
+// Variables of the HOME_CI_* environment contract, all of them are set for every
+// test command, possibly to an empty value
+const (
+	EnvVersionVar     = "HOME_CI_ENV_VERSION"
+	EnvBranch         = "HOME_CI_BRANCH"          // Branch under test, also set on a detached HEAD
+	EnvCommit         = "HOME_CI_COMMIT"          // Full hash of the commit under test
+	EnvCommitShort    = "HOME_CI_COMMIT_SHORT"    // First 8 characters of the commit hash
+	EnvRunID          = "HOME_CI_RUN_ID"          // Identifier of the run, shared by its attempts
+	EnvAttempt        = "HOME_CI_ATTEMPT"         // Attempt number of the run, starts at 1
+	EnvWorkspace      = "HOME_CI_WORKSPACE"       // Checkout of the commit, the working directory of the commands
+	EnvArtifactsDir   = "HOME_CI_ARTIFACTS_DIR"   // Files written there are copied to the artifact store
+	EnvPreviousCommit = "HOME_CI_PREVIOUS_COMMIT" // Commit tested before on the branch, empty when unknown
+	EnvTrigger        = "HOME_CI_TRIGGER"         // Why the run was queued: commit, manual or retry
+	EnvResultFile     = "HOME_CI_RESULT_FILE"     // Path of the e2e-report.yaml report
+)
+
+// contractEnv returns the HOME_CI_* variables of the run as KEY=value entries
+func (te *TestExecution) contractEnv() []string {
+	cfg := te.cfg()
+	vars := [][2]string{
+		{EnvVersionVar, strconv.Itoa(EnvVersion)},
+		{EnvBranch, te.branch},
+		{EnvCommit, te.commit},
+		{EnvCommitShort, utils.ShortCommit(te.commit)},
+		{EnvRunID, cfg.GetRunID(te.branch, te.commit)},
+		{EnvAttempt, strconv.Itoa(te.attempt)},
+		{EnvWorkspace, te.projectDir},
+		{EnvArtifactsDir, te.artifactsDir},
+		{EnvPreviousCommit, te.previousCommit},
+		{EnvTrigger, te.trigger},
+		{EnvResultFile, te.resultFile()},
+	}
+
+	env := make([]string, 0, len(vars))
+	for _, v := range vars {
+		env = append(env, v[0]+"="+v[1])
+	}
+	return env
+}
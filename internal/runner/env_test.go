@@ -0,0 +1,117 @@
+package runner
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/k8s-school/home-ci/internal/config"
+)
+
+// contractV1 are the variables of version 1 of the HOME_CI_* contract. Removing
+// one, or changing its meaning, requires incrementing EnvVersion.
+var contractV1 = []string{
+	EnvVersionVar,
+	EnvBranch,
+	EnvCommit,
+	EnvCommitShort,
+	EnvRunID,
+	EnvAttempt,
+	EnvWorkspace,
+	EnvArtifactsDir,
+	EnvPreviousCommit,
+	EnvTrigger,
+	EnvResultFile,
+}
+
+func TestContractEnv(t *testing.T) {
+	cfg := config.Config{
+		RepoName:    "test-repo",
+		WorkDir:     t.TempDir(),
+		TestTimeout: time.Minute,
+		Steps:       []config.Step{{Name: "env", Command: "env > env.txt"}},
+	}
+
+	projectDir := t.TempDir()
+	logFile, err := os.Create(filepath.Join(t.TempDir(), "run.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logFile.Close()
+
+	execution := &TestExecution{
+		runner:         &TestRunner{config: cfg},
+		branch:         "feature/login",
+		commit:         "0123456789abcdef0123456789abcdef01234567",
+		projectDir:     projectDir,
+		artifactsDir:   "/tmp/artifacts",
+		previousCommit: "fedcba9876543210fedcba9876543210fedcba98",
+		trigger:        TriggerRetry,
+		attempt:        2,
+		logFile:        newRunLog(logFile, nil),
+		testResult:     &TestResult{},
+	}
+	if err := execution.executeTest(); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(projectDir, "env.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := make(map[string]string)
+	for _, line := range strings.Split(string(content), "\n") {
+		if key, value, ok := strings.Cut(line, "="); ok && strings.HasPrefix(key, "HOME_CI_") {
+			env[key] = value
+		}
+	}
+
+	expected := map[string]string{
+		EnvVersionVar:     "1",
+		EnvBranch:         "feature/login",
+		EnvCommit:         "0123456789abcdef0123456789abcdef01234567",
+		EnvCommitShort:    "01234567",
+		EnvRunID:          "feature_login_01234567",
+		EnvAttempt:        "2",
+		EnvWorkspace:      projectDir,
+		EnvArtifactsDir:   "/tmp/artifacts",
+		EnvPreviousCommit: "fedcba9876543210fedcba9876543210fedcba98",
+		EnvTrigger:        TriggerRetry,
+		EnvResultFile:     filepath.Join(cfg.GetLogsDir("feature/login", "0123456789abcdef"), "e2e-report.yaml"),
+	}
+	for key, want := range expected {
+		if got, ok := env[key]; !ok || got != want {
+			t.Errorf("%s = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestContractEnvVersion(t *testing.T) {
+	if EnvVersion != 1 {
+		t.Fatalf("EnvVersion changed to %d, add the variables of the new version to this test", EnvVersion)
+	}
+
+	execution := &TestExecution{
+		runner:     &TestRunner{config: config.Config{RepoName: "test-repo"}},
+		branch:     "main",
+		commit:     "0123456789abcdef",
+		trigger:    TriggerCommit,
+		attempt:    1,
+		testResult: &TestResult{},
+	}
+
+	// Every variable of the contract is set, even when empty, and none is dropped
+	env := execution.contractEnv()
+	keys := make(map[string]bool)
+	for _, entry := range env {
+		key, _, _ := strings.Cut(entry, "=")
+		keys[key] = true
+	}
+	for _, name := range contractV1 {
+		if !keys[name] {
+			t.Errorf("%s is missing from the environment of version %d", name, EnvVersion)
+		}
+	}
+}
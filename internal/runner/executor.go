@@ -0,0 +1,147 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/k8s-school/home-ci/internal/config"
+)
+
+// Executor runs the commands of a test run. The runner builds each command with
+// the executor, runs it and then calls Finish, so that the output, timeouts and
+// exit codes are handled the same way whatever the executor.
+type Executor interface {
+	// Command returns the command running spec, killed when ctx is done
+	Command(ctx context.Context, spec CommandSpec) (*exec.Cmd, error)
+	// Finish is called once the command exited. It reports whether the command was
+	// killed by the memory limit of the run, and releases what Command allocated.
+	Finish(cmd *exec.Cmd) bool
+}
+
+// CommandSpec describes a command of a run
+type CommandSpec struct {
+	Args []string // Program and its arguments
+	Dir  string   // Working directory
+	Env  []string // KEY=value entries of the run: HOME_CI_* contract, secrets and step variables
+}
+
+// newExecutor returns the executor configured for the branch of the run
+func (te *TestExecution) newExecutor() (Executor, error) {
+	settings := te.cfg().Executor.For(te.branch)
+	switch settings.Type {
+	case config.ExecutorOCI:
+		return &ociExecutor{te: te, settings: settings}, nil
+	case config.ExecutorSSH:
+		// The worker is leased by the scheduler from the daemon configuration,
+		// before the repository configuration is known
+		if te.worker == nil {
+			return nil, fmt.Errorf("no worker leased for the %s executor, it must be selected by the daemon configuration", config.ExecutorSSH)
+		}
+		if !te.worker.HasLabels(settings.Labels) {
+			return nil, fmt.Errorf("worker %s does not have the labels %v", te.worker.Name, settings.Labels)
+		}
+		return newSSHExecutor(te, *te.worker), nil
+	}
+	return &hostExecutor{te: te}, nil
+}
+
+// setupExecutor selects the executor of the attempt, once the repository
+// configuration is loaded since it may override it
+func (te *TestExecution) setupExecutor() error {
+	executor, err := te.newExecutor()
+	if err != nil {
+		return err
+	}
+	te.executor = executor
+
+	switch e := executor.(type) {
+	case *ociExecutor:
+		return e.setup()
+	case *sshExecutor:
+		return e.setup()
+	}
+	return nil
+}
+
+// downloadResults copies back the files written by the commands when they did
+// not run on the local workspace
+func (te *TestExecution) downloadResults() {
+	if e, ok := te.executor.(*sshExecutor); ok {
+		e.download()
+	}
+}
+
+// cleanupExecutor releases what the executor allocated for the attempt
+func (te *TestExecution) cleanupExecutor() {
+	if e, ok := te.executor.(*sshExecutor); ok {
+		e.removeWorkspace()
+	}
+}
+
+// runEnv returns the variables of the run passed to the test commands: the
+// HOME_CI_* contract and the secrets
+func (te *TestExecution) runEnv() []string {
+	return append(te.contractEnv(), te.secretEnv...)
+}
+
+// runCommand runs a command of the run with its executor, its output going to the
+// console and run.log. It reports whether the command was killed by the memory limit.
+func (te *TestExecution) runCommand(ctx context.Context, spec CommandSpec) (bool, error) {
+	if te.executor == nil {
+		executor, err := te.newExecutor()
+		if err != nil {
+			return false, err
+		}
+		te.executor = executor
+	}
+
+	cmd, err := te.executor.Command(ctx, spec)
+	if err != nil {
+		return false, err
+	}
+	flushOutput := te.setCommandOutput(cmd)
+	err = cmd.Run()
+	flushOutput()
+	return te.executor.Finish(cmd), err
+}
+
+// hostExecutor runs the commands on the daemon host, in the sandbox and the cgroup of the run if any
+type hostExecutor struct {
+	te       *TestExecution
+	oomKills int // OOM kills of the cgroup when the last command started
+}
+
+// Command implements Executor
+func (e *hostExecutor) Command(ctx context.Context, spec CommandSpec) (*exec.Cmd, error) {
+	te := e.te
+	cmd := exec.CommandContext(ctx, spec.Args[0], spec.Args[1:]...)
+	cmd.Dir = spec.Dir
+	cmd.Env = os.Environ()
+	if te.cfg().Sandbox.Enabled {
+		cmd.Env = te.sandboxEnv()
+	}
+	cmd.Env = append(cmd.Env, spec.Env...)
+	killProcessGroupOnCancel(cmd)
+
+	if err := te.sandboxCommand(cmd); err != nil {
+		return nil, err
+	}
+	if te.cgroup != nil {
+		if err := te.cgroup.Attach(cmd); err != nil {
+			return nil, err
+		}
+		e.oomKills, _ = te.cgroup.OOMKills()
+	}
+	return cmd, nil
+}
+
+// Finish implements Executor
+func (e *hostExecutor) Finish(cmd *exec.Cmd) bool {
+	if e.te.cgroup == nil {
+		return false
+	}
+	after, _ := e.te.cgroup.OOMKills()
+	return after > e.oomKills
+}
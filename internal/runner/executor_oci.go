@@ -0,0 +1,128 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/k8s-school/home-ci/internal/config"
+	"github.com/k8s-school/home-ci/internal/gitrepo"
+)
+
+// containerStopDelay is how long the runner waits for the engine client once
+// the container of a timed out command has been killed
+const containerStopDelay = 10 * time.Second
+
+// invalidContainerChars matches the characters not allowed in a container name
+var invalidContainerChars = regexp.MustCompile(`[^a-zA-Z0-9_.-]`)
+
+// ociExecutor runs each command in a new container. The workspace of the run is
+// mounted at the same path, so that the HOME_CI_* paths are valid in the container,
+// and the objects of the mirror it borrows through git alternates are mounted read-only.
+type ociExecutor struct {
+	te         *TestExecution
+	settings   config.ExecutorSettings
+	alternates []string // Object directories of the mirror used by the workspace
+	count      int      // Commands started, numbers the containers of the attempt
+	container  string   // Container of the last command
+}
+
+// setup checks the container engine
+func (e *ociExecutor) setup() error {
+	te := e.te
+	if _, err := exec.LookPath(e.settings.Engine); err != nil {
+		return fmt.Errorf("container engine %s not found: %w", e.settings.Engine, err)
+	}
+	alternates, err := gitrepo.Alternates(te.projectDir)
+	if err != nil {
+		return err
+	}
+	e.alternates = alternates
+
+	fmt.Fprintf(te.logFile, "=== Executor ===\n")
+	fmt.Fprintf(te.logFile, "Type: %s\n", e.settings.Type)
+	fmt.Fprintf(te.logFile, "Engine: %s\n", e.settings.Engine)
+	fmt.Fprintf(te.logFile, "Image: %s\n", e.settings.Image)
+	fmt.Fprintf(te.logFile, "Pull: %s\n", e.settings.Pull)
+	fmt.Fprintf(te.logFile, "Mount: %s\n", te.workspaceDir)
+	for _, dir := range e.alternates {
+		fmt.Fprintf(te.logFile, "Mount: %s (read-only)\n", dir)
+	}
+	fmt.Fprintf(te.logFile, "================\n\n")
+	return nil
+}
+
+// containerName returns the name of the next container of the attempt
+func (e *ociExecutor) containerName() string {
+	e.count++
+	runID := e.te.cfg().GetRunID(e.te.branch, e.te.commit)
+	return fmt.Sprintf("home-ci-%s-%d-%d", invalidContainerChars.ReplaceAllString(runID, "_"), e.te.attempt, e.count)
+}
+
+// runArgs returns the arguments of the engine run command for spec
+func (e *ociExecutor) runArgs(name string, spec CommandSpec) []string {
+	te := e.te
+	args := []string{"run", "--name", name, "--init",
+		"--pull=" + e.settings.Pull,
+		"--volume", te.workspaceDir + ":" + te.workspaceDir,
+		"--workdir", spec.Dir}
+	for _, dir := range e.alternates {
+		args = append(args, "--volume", dir+":"+dir+":ro")
+	}
+
+	// Only the names are given, the engine reads the values from its environment,
+	// so that the secrets do not show in the process list
+	for _, entry := range spec.Env {
+		key, _, _ := strings.Cut(entry, "=")
+		args = append(args, "--env", key)
+	}
+
+	limits := te.cfg().Limits
+	if memory := limits.MemoryBytes(); memory > 0 {
+		args = append(args, "--memory", strconv.FormatInt(memory, 10), "--memory-swap", strconv.FormatInt(memory, 10))
+	}
+	if limits.CPUs > 0 {
+		args = append(args, "--cpus", strconv.FormatFloat(limits.CPUs, 'f', -1, 64))
+	}
+	if limits.Pids > 0 {
+		args = append(args, "--pids-limit", strconv.Itoa(limits.Pids))
+	}
+	if sb := te.cfg().Sandbox; sb.Enabled {
+		args = append(args, "--user", fmt.Sprintf("%d:%d", sb.UID, sb.GID))
+	}
+
+	args = append(args, e.settings.Options...)
+	args = append(args, e.settings.Image)
+	return append(args, spec.Args...)
+}
+
+// Command implements Executor. The container is kept after it exits so that
+// Finish can inspect it, and killed by the engine when ctx is done.
+func (e *ociExecutor) Command(ctx context.Context, spec CommandSpec) (*exec.Cmd, error) {
+	name := e.containerName()
+	e.container = name
+	cmd := exec.CommandContext(ctx, e.settings.Engine, e.runArgs(name, spec)...)
+	cmd.Dir = spec.Dir
+	cmd.Env = append(os.Environ(), spec.Env...)
+	cmd.Cancel = func() error {
+		exec.Command(e.settings.Engine, "kill", name).Run()
+		return cmd.Process.Kill()
+	}
+	cmd.WaitDelay = containerStopDelay
+	return cmd, nil
+}
+
+// Finish implements Executor
+func (e *ociExecutor) Finish(cmd *exec.Cmd) bool {
+	name := e.container
+	out, _ := exec.Command(e.settings.Engine, "inspect", "--format", "{{.State.OOMKilled}}", name).Output()
+	if err := exec.Command(e.settings.Engine, "rm", "--force", name).Run(); err != nil {
+		fmt.Fprintf(e.te.logFile, "Failed to remove container %s: %v\n", name, err)
+	}
+	return strings.TrimSpace(string(out)) == "true"
+}
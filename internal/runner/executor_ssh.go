@@ -0,0 +1,224 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/k8s-school/home-ci/internal/artifacts"
+	"github.com/k8s-school/home-ci/internal/config"
+	"github.com/k8s-school/home-ci/internal/gitrepo"
+	"github.com/k8s-school/home-ci/internal/remote"
+	"github.com/k8s-school/home-ci/internal/utils"
+)
+
+// remoteStopDelay is how long the runner waits for ssh once the remote command
+// of a timed out command has been killed
+const remoteStopDelay = 10 * time.Second
+
+// remoteKillTimeout bounds the ssh connection killing a timed out remote command
+const remoteKillTimeout = 30 * time.Second
+
+// remoteCleanupTimeout bounds the removal of the workspace from the worker
+const remoteCleanupTimeout = 5 * time.Minute
+
+// sshExecutor runs the commands on a worker. The workspace is copied to the
+// worker before the first command, the report, the artifacts and the test
+// reports are copied back after the test.
+type sshExecutor struct {
+	te     *TestExecution
+	worker config.Worker
+	client *remote.Client
+	count  int // Commands started, numbers their PID files
+}
+
+// newSSHExecutor returns the executor running the commands on worker
+func newSSHExecutor(te *TestExecution, worker config.Worker) *sshExecutor {
+	configDir := ""
+	if te.runner.configPath != "" {
+		configDir = filepath.Dir(te.runner.configPath)
+	}
+	resolve := func(file string) string {
+		if file == "" || filepath.IsAbs(file) {
+			return file
+		}
+		return filepath.Join(configDir, file)
+	}
+
+	return &sshExecutor{
+		te:     te,
+		worker: worker,
+		client: &remote.Client{
+			Host:           worker.Host,
+			Port:           worker.Port,
+			IdentityFile:   resolve(worker.IdentityFile),
+			KnownHostsFile: resolve(worker.KnownHostsFile),
+		},
+	}
+}
+
+// remoteDir returns the workspace of the run on the worker
+func (e *sshExecutor) remoteDir() string {
+	cfg := e.te.cfg()
+	return path.Join(e.worker.WorkDir, cfg.RepoName, cfg.GetRunID(e.te.branch, e.te.commit))
+}
+
+// remotePath maps a path of the local workspace to the worker, other values are kept
+func (e *sshExecutor) remotePath(value string) string {
+	if !filepath.IsAbs(value) {
+		return value
+	}
+	rel, err := filepath.Rel(e.te.workspaceDir, value)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return value
+	}
+	return path.Join(e.remoteDir(), filepath.ToSlash(rel))
+}
+
+// relPath returns a path of the local workspace relative to the workspace
+func (e *sshExecutor) relPath(local string) string {
+	rel, _ := filepath.Rel(e.te.workspaceDir, local)
+	return filepath.ToSlash(rel)
+}
+
+// setup copies the project tree to the worker
+func (e *sshExecutor) setup() error {
+	te := e.te
+	if te.cfg().Sandbox.Enabled {
+		return fmt.Errorf("the sandbox is not supported by the %s executor", config.ExecutorSSH)
+	}
+
+	fmt.Fprintf(te.logFile, "=== Executor ===\n")
+	fmt.Fprintf(te.logFile, "Type: %s\n", config.ExecutorSSH)
+	fmt.Fprintf(te.logFile, "Worker: %s (%s)\n", e.worker.Name, e.worker.Host)
+	fmt.Fprintf(te.logFile, "Remote workspace: %s\n", e.remoteDir())
+
+	start := time.Now()
+	ctx := te.context()
+	// The workspace borrows its objects from the mirror, which is not on the worker
+	if err := gitrepo.Dissociate(ctx, te.projectDir); err != nil {
+		return fmt.Errorf("failed to copy the mirror objects into the workspace: %w", err)
+	}
+	projectTop := strings.SplitN(e.relPath(te.projectDir), "/", 2)[0]
+	if err := e.client.Upload(ctx, te.workspaceDir, []string{projectTop}, e.remoteDir()); err != nil {
+		return err
+	}
+	dirs := []string{
+		remote.Quote(e.remotePath(te.artifactsDir)),
+		remote.Quote(path.Dir(e.remotePath(te.resultFile()))),
+	}
+	if _, err := e.client.Run(ctx, "mkdir -p "+strings.Join(dirs, " "), nil); err != nil {
+		return fmt.Errorf("failed to prepare the workspace on worker %s: %w", e.worker.Name, err)
+	}
+
+	fmt.Fprintf(te.logFile, "Upload: %s\n", time.Since(start).Round(time.Millisecond))
+	fmt.Fprintf(te.logFile, "================\n\n")
+	return nil
+}
+
+// Command implements Executor. The variables are sent through stdin, so that the
+// secrets do not show in the process list of the worker. The PID of the remote
+// command is recorded, since killing ssh does not stop it.
+func (e *sshExecutor) Command(ctx context.Context, spec CommandSpec) (*exec.Cmd, error) {
+	e.count++
+	pidFile := path.Join(e.remoteDir(), fmt.Sprintf(".home-ci-command-%d.pid", e.count))
+
+	var env strings.Builder
+	for _, entry := range spec.Env {
+		key, value, _ := strings.Cut(entry, "=")
+		fmt.Fprintf(&env, "%s=%s\n", key, remote.Quote(e.remotePath(value)))
+	}
+	args := make([]string, len(spec.Args))
+	for i, arg := range spec.Args {
+		args[i] = remote.Quote(e.remotePath(arg))
+	}
+	// The command runs in its own session when setsid is available, so that killing
+	// its process group also kills its children
+	command := fmt.Sprintf(`cd %s && set -a && . /dev/stdin && set +a || exit 125; `+
+		`session=; command -v setsid >/dev/null && session="setsid -w"; `+
+		`exec $session sh -c 'echo $$ > "$0" && exec "$@"' %s %s`,
+		remote.Quote(e.remotePath(spec.Dir)), remote.Quote(pidFile), strings.Join(args, " "))
+
+	cmd := e.client.Command(ctx, command)
+	cmd.Stdin = strings.NewReader(env.String())
+	cmd.Cancel = func() error {
+		killCtx, cancel := context.WithTimeout(context.Background(), remoteKillTimeout)
+		defer cancel()
+		kill := fmt.Sprintf("pid=$(cat %s) && { kill -s KILL -- -$pid 2>/dev/null || kill -s KILL $pid; }", remote.Quote(pidFile))
+		if _, err := e.client.Run(killCtx, kill, nil); err != nil {
+			slog.Error("Failed to kill the remote command", "worker", e.worker.Name, "error", err)
+		}
+		return cmd.Process.Kill()
+	}
+	cmd.WaitDelay = remoteStopDelay
+	return cmd, nil
+}
+
+// Finish implements Executor. The commands do not run in a cgroup of the worker,
+// the OOM kills there cannot be told from other kills and are not reported.
+func (e *sshExecutor) Finish(cmd *exec.Cmd) bool {
+	return false
+}
+
+// download copies back the files written on the worker that the runner reads
+// after the test: the report, HOME_CI_ARTIFACTS_DIR, and the files of the
+// project matching the artifact and test report patterns
+func (e *sshExecutor) download() {
+	te := e.te
+	cfg := te.cfg()
+	ctx := te.context()
+
+	resultFile := e.relPath(te.resultFile())
+	artifactsDir := e.relPath(te.artifactsDir)
+	projectDir := e.relPath(te.projectDir)
+	patterns := append(append(append([]string{}, cfg.Artifacts.Paths...), cfg.TestReports.JUnit...), cfg.TestReports.TAP...)
+
+	dirs := []string{path.Dir(resultFile), artifactsDir}
+	if len(patterns) > 0 {
+		dirs = append(dirs, projectDir)
+	}
+	files, err := e.client.ListFiles(ctx, e.remoteDir(), dirs...)
+	if err == nil {
+		var names []string
+		for _, file := range files {
+			if file == resultFile || strings.HasPrefix(file, artifactsDir+"/") {
+				names = append(names, file)
+				continue
+			}
+			if rel, ok := strings.CutPrefix(file, projectDir+"/"); ok && matchesAny(patterns, rel) {
+				names = append(names, file)
+			}
+		}
+		err = e.client.Download(ctx, e.remoteDir(), names, te.workspaceDir)
+		fmt.Fprintf(te.logFile, "\nDownloaded %d file(s) from worker %s\n", len(names), e.worker.Name)
+	}
+	if err != nil {
+		fmt.Fprintf(te.logFile, "\nFailed to download the results from worker %s: %v\n", e.worker.Name, err)
+		slog.Error("Failed to download the results from the worker",
+			"branch", te.branch, "commit", utils.ShortCommit(te.commit), "worker", e.worker.Name, "error", err)
+	}
+}
+
+// removeWorkspace removes the workspace of the run from the worker, also after a cancel
+func (e *sshExecutor) removeWorkspace() {
+	ctx, cancel := context.WithTimeout(context.Background(), remoteCleanupTimeout)
+	defer cancel()
+	if _, err := e.client.Run(ctx, "rm -rf "+remote.Quote(e.remoteDir()), nil); err != nil {
+		slog.Error("Failed to remove the workspace from the worker", "worker", e.worker.Name, "error", err)
+	}
+}
+
+// matchesAny reports whether a slash-separated path matches one of the artifact patterns
+func matchesAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if artifacts.Match(pattern, name) {
+			return true
+		}
+	}
+	return false
+}
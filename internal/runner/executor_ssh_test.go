@@ -0,0 +1,207 @@
+package runner
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/k8s-school/home-ci/internal/config"
+	"github.com/k8s-school/home-ci/internal/e2ereport"
+)
+
+// testWorker returns a worker reached over ssh. HOME_CI_TEST_SSH_HOST selects a
+// real sshd, e.g. user@localhost, sharing the filesystem of the test. Otherwise a
+// fake ssh command runs the remote commands locally.
+func testWorker(t *testing.T) config.Worker {
+	worker := config.Worker{Name: "lab", Labels: []string{"large"}, Capacity: 1, WorkDir: t.TempDir()}
+	if host := os.Getenv("HOME_CI_TEST_SSH_HOST"); host != "" {
+		worker.Host = host
+		return worker
+	}
+
+	dir := t.TempDir()
+	script := `#!/bin/sh
+# Skip the options and the destination, then run the command locally
+while [ $# -gt 1 ]; do
+	case "$1" in
+	-o|-p|-i) shift 2 ;;
+	-*) shift ;;
+	*) shift; break ;;
+	esac
+done
+exec sh -c "$1"
+`
+	if err := os.WriteFile(filepath.Join(dir, "ssh"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	worker.Host = "ci@lab"
+	return worker
+}
+
+// newSSHExecution returns an execution with a workspace laid out as by setupRepository
+func newSSHExecution(t *testing.T, worker config.Worker, steps []config.Step) *TestExecution {
+	cfg := config.Config{
+		RepoName:    "test-repo",
+		WorkDir:     t.TempDir(),
+		TestTimeout: time.Minute,
+		Steps:       steps,
+		Executor:    config.Executor{ExecutorSettings: config.ExecutorSettings{Type: config.ExecutorSSH, Labels: []string{"large"}}},
+		Workers:     []config.Worker{worker},
+		TestReports: config.TestReports{JUnit: []string{"out/*.xml"}},
+	}
+	branch, commit := "feature/login", "0123456789abcdef0123456789abcdef01234567"
+
+	execution := &TestExecution{
+		runner:       &TestRunner{config: cfg},
+		branch:       branch,
+		commit:       commit,
+		workspaceDir: cfg.GetWorkspaceDir(branch, commit),
+		projectDir:   cfg.GetProjectDir(branch, commit),
+		artifactsDir: cfg.GetRunArtifactsDir(branch, commit),
+		attempt:      1,
+		testResult:   &TestResult{},
+		secretEnv:    []string{"API_TOKEN=it's secret"},
+	}
+	execution.worker = &execution.runner.config.Workers[0]
+	for _, dir := range []string{execution.projectDir, execution.artifactsDir, cfg.GetLogsDir(branch, commit)} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(execution.projectDir, "Makefile"), []byte("test:\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	logFile, err := os.Create(filepath.Join(cfg.GetLogsDir(branch, commit), "run.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { logFile.Close() })
+	execution.logFile = newRunLog(logFile, nil)
+	return execution
+}
+
+func TestSSHExecutor(t *testing.T) {
+	worker := testWorker(t)
+	execution := newSSHExecution(t, worker, []config.Step{{
+		Name: "test",
+		Command: `echo "workspace=$HOME_CI_WORKSPACE pwd=$(pwd) token=$API_TOKEN" && ls Makefile &&
+			echo passed > "$HOME_CI_RESULT_FILE" && echo data > "$HOME_CI_ARTIFACTS_DIR/data.txt" &&
+			mkdir -p out && echo '<testsuite/>' > out/junit.xml && echo ignored > out/other.txt`,
+	}})
+
+	if err := execution.setupExecutor(); err != nil {
+		t.Fatalf("setupExecutor() failed: %v", err)
+	}
+	executor, ok := execution.executor.(*sshExecutor)
+	if !ok {
+		t.Fatalf("expected the ssh executor, got %T", execution.executor)
+	}
+	remoteProject := executor.remotePath(execution.projectDir)
+
+	// The limits do not apply on the worker, no cgroup of the daemon is needed
+	execution.runner.config.Limits = config.Limits{Memory: "1G", CgroupDir: filepath.Join(t.TempDir(), "missing")}
+	if err := execution.setupLimits(); err != nil || execution.cgroup != nil {
+		t.Errorf("setupLimits() = %v, cgroup %v, want no cgroup", err, execution.cgroup)
+	}
+	execution.runner.config.Limits = config.Limits{}
+
+	if !strings.HasPrefix(remoteProject, worker.WorkDir+"/test-repo/") {
+		t.Fatalf("remote project directory %s is not below %s", remoteProject, worker.WorkDir)
+	}
+
+	if err := execution.executeTest(); err != nil {
+		t.Fatalf("executeTest() failed: %v", err)
+	}
+	execution.downloadResults()
+
+	logData, err := os.ReadFile(execution.logFile.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "workspace=" + remoteProject + " pwd=" + remoteProject + " token=it's secret"
+	if !strings.Contains(string(logData), want) {
+		t.Errorf("run.log does not contain %q:\n%s", want, logData)
+	}
+
+	logsDir := execution.cfg().GetLogsDir(execution.branch, execution.commit)
+	for _, file := range []string{
+		filepath.Join(logsDir, e2ereport.FileName),
+		filepath.Join(execution.artifactsDir, "data.txt"),
+		filepath.Join(execution.projectDir, "out", "junit.xml"),
+	} {
+		if _, err := os.Stat(file); err != nil {
+			t.Errorf("expected %s to be downloaded: %v", file, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(execution.projectDir, "out", "other.txt")); err == nil {
+		t.Error("a file matching no pattern was downloaded")
+	}
+
+	execution.cleanupExecutor()
+	if _, err := os.Stat(executor.remoteDir()); !os.IsNotExist(err) {
+		t.Errorf("remote workspace %s not removed: %v", executor.remoteDir(), err)
+	}
+}
+
+func TestSSHExecutorTimeout(t *testing.T) {
+	execution := newSSHExecution(t, testWorker(t), []config.Step{
+		{Name: "hang", Command: "sleep 30", Timeout: time.Second},
+	})
+	if err := execution.setupExecutor(); err != nil {
+		t.Fatalf("setupExecutor() failed: %v", err)
+	}
+
+	start := time.Now()
+	if err := execution.executeTest(); err == nil {
+		t.Fatal("expected the step to time out")
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("remote command killed after %s", elapsed)
+	}
+	if execution.testResult.Steps[0].Status != StepTimeout {
+		t.Errorf("status = %s, want %s", execution.testResult.Steps[0].Status, StepTimeout)
+	}
+}
+
+func TestSSHExecutorWithoutWorker(t *testing.T) {
+	execution := newSSHExecution(t, testWorker(t), nil)
+	execution.worker = nil
+	if err := execution.setupExecutor(); err == nil {
+		t.Error("expected setupExecutor() to fail without a leased worker")
+	}
+}
+
+func TestTryAcquireWorker(t *testing.T) {
+	cfg := config.Config{
+		Executor: config.Executor{ExecutorSettings: config.ExecutorSettings{Type: config.ExecutorSSH, Labels: []string{"large"}}},
+		Workers: []config.Worker{
+			{Name: "small", Labels: []string{"small"}, Capacity: 4},
+			{Name: "lab", Labels: []string{"large", "gpu"}, Capacity: 1},
+			{Name: "lab2", Labels: []string{"large"}, Capacity: 1},
+		},
+	}
+	tr := &TestRunner{config: cfg, resources: NewResourceLocks(t.TempDir(), resourceCapacities(cfg))}
+
+	first, lease1, ok := tr.tryAcquireWorker("main")
+	if !ok || first != "lab" {
+		t.Fatalf("first job got worker %q, %t", first, ok)
+	}
+	second, lease2, ok := tr.tryAcquireWorker("main")
+	if !ok || second != "lab2" {
+		t.Fatalf("second job got worker %q, %t", second, ok)
+	}
+	if name, _, ok := tr.tryAcquireWorker("main"); ok {
+		t.Fatalf("third job got worker %q beyond the capacities", name)
+	}
+
+	lease1.Release()
+	if name, lease, ok := tr.tryAcquireWorker("main"); !ok || name != "lab" {
+		t.Errorf("expected the released worker, got %q, %t", name, ok)
+	} else {
+		lease.Release()
+	}
+	lease2.Release()
+}
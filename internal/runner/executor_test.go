@@ -0,0 +1,225 @@
+package runner
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/k8s-school/home-ci/internal/config"
+	"github.com/k8s-school/home-ci/internal/gitrepo"
+)
+
+// fakeEngine installs a podman command recording its arguments, one call per line.
+// It reports the containers as killed by the OOM killer.
+func fakeEngine(t *testing.T) string {
+	dir := t.TempDir()
+	calls := filepath.Join(dir, "calls")
+	script := "#!/bin/sh\necho \"$@\" >> " + calls + "\n" +
+		"if [ \"$1\" = inspect ]; then echo true; fi\n" +
+		"if [ \"$1\" = run ]; then echo \"token=$API_TOKEN\"; exit 137; fi\n"
+	if err := os.WriteFile(filepath.Join(dir, config.EnginePodman), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return calls
+}
+
+func TestOCIExecutor(t *testing.T) {
+	calls := fakeEngine(t)
+
+	cfg := config.Config{
+		RepoName:    "test-repo",
+		WorkDir:     t.TempDir(),
+		TestTimeout: time.Minute,
+		Limits:      config.Limits{Memory: "1G", CPUs: 1.5},
+		Executor: config.Executor{
+			ExecutorSettings: config.ExecutorSettings{Type: config.ExecutorHost, Engine: config.EnginePodman, Pull: config.PullMissing},
+			Rules: []config.ExecutorRule{
+				{Branch: "feature/*", ExecutorSettings: config.ExecutorSettings{
+					Type: config.ExecutorOCI, Image: "golang:1.23", Options: []string{"--network=host"}}},
+			},
+		},
+	}
+
+	workspaceDir := t.TempDir()
+	mirrorObjects := filepath.Join(t.TempDir(), "mirror.git", "objects")
+	alternates := filepath.Join(workspaceDir, ".git", "objects", "info", "alternates")
+	if err := os.MkdirAll(filepath.Dir(alternates), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(alternates, []byte(mirrorObjects+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	logFile, err := os.Create(filepath.Join(t.TempDir(), "run.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logFile.Close()
+
+	execution := &TestExecution{
+		runner:       &TestRunner{config: cfg},
+		branch:       "feature/login",
+		commit:       "0123456789abcdef0123456789abcdef01234567",
+		workspaceDir: workspaceDir,
+		projectDir:   workspaceDir,
+		attempt:      1,
+		logFile:      newRunLog(logFile, nil),
+		testResult:   &TestResult{},
+		secretEnv:    []string{"API_TOKEN=s3cr3t"},
+	}
+	if err := execution.setupExecutor(); err != nil {
+		t.Fatalf("setupExecutor() failed: %v", err)
+	}
+	if _, ok := execution.executor.(*ociExecutor); !ok {
+		t.Fatalf("expected the oci executor for branch %s, got %T", execution.branch, execution.executor)
+	}
+
+	// The limits are given to the engine, the commands do not run in a cgroup of the daemon
+	if err := execution.setupLimits(); err != nil || execution.cgroup != nil {
+		t.Errorf("setupLimits() = %v, cgroup %v, want no cgroup", err, execution.cgroup)
+	}
+
+	oomKilled, err := execution.runCommand(context.Background(), CommandSpec{
+		Args: []string{"make", "test"},
+		Dir:  workspaceDir,
+		Env:  execution.runEnv(),
+	})
+	if err == nil {
+		t.Error("expected the exit code of the container")
+	}
+	if !oomKilled {
+		t.Error("expected the OOM kill reported by the engine")
+	}
+
+	data, err := os.ReadFile(calls)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected run, inspect and rm calls, got %q", lines)
+	}
+	name := "home-ci-feature_login_01234567-1-1"
+	run := lines[0]
+	for _, want := range []string{
+		"run --name " + name + " --init --pull=missing",
+		"--volume " + workspaceDir + ":" + workspaceDir,
+		"--volume " + mirrorObjects + ":" + mirrorObjects + ":ro",
+		"--workdir " + workspaceDir,
+		"--env " + EnvCommit,
+		"--env API_TOKEN",
+		"--memory 1073741824 --memory-swap 1073741824",
+		"--cpus 1.5",
+		"--network=host golang:1.23 make test",
+	} {
+		if !strings.Contains(run, want) {
+			t.Errorf("run call %q does not contain %q", run, want)
+		}
+	}
+	if strings.Contains(run, "s3cr3t") {
+		t.Errorf("secret value passed as an argument: %q", run)
+	}
+	if lines[1] != "inspect --format {{.State.OOMKilled}} "+name || lines[2] != "rm --force "+name {
+		t.Errorf("unexpected calls after run: %q", lines[1:])
+	}
+
+	logData, err := os.ReadFile(logFile.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(logData), "token=s3cr3t") {
+		t.Errorf("expected the secret in the environment of the engine, got %q", logData)
+	}
+}
+
+// TestOCIExecutorGitLog runs git in a real container, on a workspace borrowing its objects
+// from the mirror. The image is set by HOME_CI_TEST_GIT_IMAGE.
+func TestOCIExecutorGitLog(t *testing.T) {
+	engine := ""
+	for _, name := range []string{config.EnginePodman, config.EngineDocker} {
+		if _, err := exec.LookPath(name); err == nil {
+			engine = name
+			break
+		}
+	}
+	if engine == "" {
+		t.Skip("no container engine available")
+	}
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git command not available")
+	}
+	image := os.Getenv("HOME_CI_TEST_GIT_IMAGE")
+	if image == "" {
+		image = "docker.io/alpine/git:latest"
+	}
+
+	tempDir := t.TempDir()
+	repoDir := filepath.Join(tempDir, "project")
+	runGit(t, tempDir, "init", "-q", "-b", "main", repoDir)
+	runGit(t, repoDir, "commit", "-q", "--allow-empty", "-m", "Initial commit")
+	commit := runGit(t, repoDir, "rev-parse", "HEAD")
+
+	cfg := config.Config{
+		Repository:  repoDir,
+		RepoName:    "project",
+		TestTimeout: time.Minute,
+		WorkDir:     filepath.Join(tempDir, "work"),
+		Executor: config.Executor{ExecutorSettings: config.ExecutorSettings{
+			Type: config.ExecutorOCI, Engine: engine, Image: image, Pull: config.PullMissing,
+			Options: []string{"--entrypoint="}}},
+	}
+	mirror := gitrepo.NewMirror(cfg.Repository, cfg.GetCacheDir(), nil)
+	if _, err := mirror.Update(context.Background()); err != nil {
+		t.Fatalf("Update() failed: %v", err)
+	}
+	logFile, err := os.Create(filepath.Join(tempDir, "run.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logFile.Close()
+
+	execution := &TestExecution{
+		runner:       &TestRunner{config: cfg, mirror: mirror},
+		branch:       "main",
+		commit:       commit,
+		workspaceDir: cfg.GetWorkspaceDir("main", commit),
+		projectDir:   cfg.GetProjectDir("main", commit),
+		attempt:      1,
+		logFile:      newRunLog(logFile, nil),
+		testResult:   &TestResult{},
+	}
+	if err := execution.cloneFromMirror(); err != nil {
+		t.Fatalf("cloneFromMirror() failed: %v", err)
+	}
+	if err := execution.setupExecutor(); err != nil {
+		t.Fatalf("setupExecutor() failed: %v", err)
+	}
+	if _, err := execution.runCommand(context.Background(), CommandSpec{
+		Args: []string{"git", "-c", "safe.directory=*", "log", "-1", "--format=commit=%H"},
+		Dir:  execution.projectDir,
+	}); err != nil {
+		logData, _ := os.ReadFile(logFile.Name())
+		t.Fatalf("git log failed in the container: %v\n%s", err, logData)
+	}
+	logData, _ := os.ReadFile(logFile.Name())
+	if !strings.Contains(string(logData), "commit="+commit) {
+		t.Errorf("expected commit %s in the output of git log, got:\n%s", commit, logData)
+	}
+}
+
+func TestHostExecutorSelected(t *testing.T) {
+	execution := &TestExecution{
+		runner: &TestRunner{config: config.Config{}},
+		branch: "main",
+	}
+	if err := execution.setupExecutor(); err != nil {
+		t.Fatalf("setupExecutor() failed: %v", err)
+	}
+	if _, ok := execution.executor.(*hostExecutor); !ok {
+		t.Errorf("expected the host executor by default, got %T", execution.executor)
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !unix
+
+package runner
+
+import (
+	"errors"
+	"os"
+)
+
+// tryLockFile is not supported on this platform
+func tryLockFile(file *os.File) (bool, error) {
+	return false, errors.New("resource locks are not supported on this platform")
+}
@@ -16,12 +16,19 @@ import (
 	"time"
 
 	"gopkg.in/yaml.v3"
+
+	"github.com/k8s-school/home-ci/internal/secrets"
+	"github.com/k8s-school/home-ci/internal/testreport"
 )
 
 const (
 	githubAPIVersion  = "2022-11-28"
 	githubAcceptType  = "application/vnd.github+json"
 	githubContentType = "application/json"
+
+	// Limits of the test failures included in the payload, which is capped by GitHub
+	maxPayloadFailures       = 10
+	maxPayloadFailureMessage = 200
 )
 
 // GitHubDispatchPayload represents the payload sent to GitHub Actions
@@ -270,12 +277,14 @@ func createCompressedArtifactsArchive(files []FileToArchive) (Artifact, error) {
 	}, nil
 }
 
-// readFileForArchive reads a file with limits and returns data ready for archive
-func readFileForArchive(filePath string, maxBytes, maxLines int, fileType string) (FileToArchive, error) {
+// readFileForArchive reads a file with limits and returns data ready for archive,
+// the secret values are masked before the file is truncated
+func readFileForArchive(filePath string, maxBytes, maxLines int, fileType string, masker *secrets.Masker) (FileToArchive, error) {
 	data, err := os.ReadFile(filePath)
 	if err != nil {
 		return FileToArchive{}, err
 	}
+	data = masker.Bytes(data)
 
 	originalSize := len(data)
 	truncated := false
@@ -374,7 +383,7 @@ func truncateBase64Content(payload map[string]interface{}) map[string]interface{
 
 // createArtifactsMap creates the artifacts map for the dispatch payload using combined archive
 // Returns the artifacts map and a boolean indicating if a required file is missing
-func createArtifactsMap(branch, commit string, success bool, logFilePath, resultFilePath string, hasResultFile bool, maxFileBytes, maxLogLines int) (map[string]interface{}, bool, error) {
+func createArtifactsMap(branch, commit string, success bool, logFilePath, resultFilePath string, hasResultFile bool, maxFileBytes, maxLogLines int, masker *secrets.Masker) (map[string]interface{}, bool, error) {
 	slog.Debug("Creating artifacts map", "branch", branch, "commit", commit, "success", success, "logFile", logFilePath, "resultFile", resultFilePath, "hasResultFile", hasResultFile)
 	artifacts := make(map[string]interface{})
 	var files []FileToArchive
@@ -382,7 +391,7 @@ func createArtifactsMap(branch, commit string, success bool, logFilePath, result
 
 	// Add log file
 	if logFilePath != "" {
-		if file, err := readFileForArchive(logFilePath, maxFileBytes, maxLogLines, "log"); err == nil {
+		if file, err := readFileForArchive(logFilePath, maxFileBytes, maxLogLines, "log", masker); err == nil {
 			files = append(files, file)
 			if file.Truncated {
 				slog.Warn("Log file truncated for archive", "file", file.Name, "original_size", file.OriginalSize, "max_bytes", maxFileBytes, "max_lines", maxLogLines)
@@ -391,11 +400,25 @@ func createArtifactsMap(branch, commit string, success bool, logFilePath, result
 		} else {
 			slog.Debug("Failed to read log file for archive", "file", logFilePath, "error", err)
 		}
+
+		// Add the structured log, without the entry cut by the truncation
+		structuredLogPath := filepath.Join(filepath.Dir(logFilePath), StructuredLogFile)
+		if file, err := readFileForArchive(structuredLogPath, maxFileBytes, maxLogLines, "structured-log", masker); err == nil {
+			if file.Truncated && !bytes.HasPrefix(file.Data, []byte("{")) {
+				if end := bytes.IndexByte(file.Data, '\n'); end >= 0 {
+					file.Data = file.Data[end+1:]
+				}
+			}
+			files = append(files, file)
+			slog.Debug("Added structured log file to archive", "file", file.Name, "size", len(file.Data), "truncated", file.Truncated)
+		} else if !os.IsNotExist(err) {
+			slog.Debug("Failed to read structured log file for archive", "file", structuredLogPath, "error", err)
+		}
 	}
 
 	// Add result file
 	if resultFilePath != "" {
-		if file, err := readFileForArchive(resultFilePath, maxFileBytes, maxLogLines, "result"); err == nil {
+		if file, err := readFileForArchive(resultFilePath, maxFileBytes, maxLogLines, "result", masker); err == nil {
 			files = append(files, file)
 			if file.Truncated {
 				slog.Warn("Result file truncated for archive", "file", file.Name, "original_size", file.OriginalSize, "max_bytes", maxFileBytes, "max_lines", maxLogLines)
@@ -411,7 +434,7 @@ func createArtifactsMap(branch, commit string, success bool, logFilePath, result
 		logDir := filepath.Dir(logFilePath)
 		yamlReportFile := findYAMLReportFile(logDir)
 		if yamlReportFile != "" {
-			if file, err := readFileForArchive(yamlReportFile, maxFileBytes, maxLogLines, "e2e-report"); err == nil {
+			if file, err := readFileForArchive(yamlReportFile, maxFileBytes, maxLogLines, "e2e-report", masker); err == nil {
 				files = append(files, file)
 				if file.Truncated {
 					slog.Warn("YAML report file truncated for archive", "file", file.Name, "original_size", file.OriginalSize, "max_bytes", maxFileBytes, "max_lines", maxLogLines)
@@ -453,7 +476,7 @@ func createArtifactsMap(branch, commit string, success bool, logFilePath, result
 }
 
 // createClientPayload creates the complete client payload for the dispatch
-func createClientPayload(branch, commit string, success bool, logFilePath, resultFilePath string, hasResultFile bool, maxFileBytes, maxLogLines int) (map[string]interface{}, error) {
+func createClientPayload(branch, commit string, success bool, logFilePath, resultFilePath string, hasResultFile bool, maxFileBytes, maxLogLines int, masker *secrets.Masker) (map[string]interface{}, error) {
 	// Create artifact name with cleaned branch name and short commit
 	branchClean := strings.ReplaceAll(branch, "/", "_")
 	commitShort := commit
@@ -462,7 +485,7 @@ func createClientPayload(branch, commit string, success bool, logFilePath, resul
 	}
 	artifactName := fmt.Sprintf("log-%s-%s", branchClean, commitShort)
 
-	artifacts, missingRequiredFile, err := createArtifactsMap(branch, commit, success, logFilePath, resultFilePath, hasResultFile, maxFileBytes, maxLogLines)
+	artifacts, missingRequiredFile, err := createArtifactsMap(branch, commit, success, logFilePath, resultFilePath, hasResultFile, maxFileBytes, maxLogLines, masker)
 	if err != nil {
 		return nil, err
 	}
@@ -489,6 +512,107 @@ func createClientPayload(branch, commit string, success bool, logFilePath, resul
 	}, nil
 }
 
+// addRunSummary adds the outcome details of the run to the dispatch payload
+func addRunSummary(payload map[string]interface{}, result *TestResult) {
+	summary := map[string]interface{}{
+		"attempt": result.Attempt,
+		"flaky":   result.Flaky,
+	}
+	if result.FailureReason != "" && !result.Success {
+		summary["failure_reason"] = result.FailureReason
+	}
+	if result.FailedStep != "" {
+		summary["failed_step"] = result.FailedStep
+	}
+	if result.Report != nil {
+		summary["report_status"] = result.Report.Status
+		if failed := result.Report.FailedChecks(); len(failed) > 0 {
+			summary["failed_checks"] = failed
+		}
+	}
+	if result.ReportError != "" {
+		summary["report_error"] = result.ReportError
+	}
+	if result.Tests != nil {
+		summary["tests"] = testsSummary(result.Tests)
+	}
+	if len(result.Steps) > 0 {
+		steps := make([]map[string]interface{}, 0, len(result.Steps))
+		for _, step := range result.Steps {
+			steps = append(steps, map[string]interface{}{
+				"name":     step.Name,
+				"status":   step.Status,
+				"duration": step.Duration.Round(time.Second).String(),
+			})
+		}
+		summary["steps"] = steps
+	}
+
+	metadata, _ := payload["metadata"].(map[string]interface{})
+	for key, value := range summary {
+		payload[key] = value
+		if metadata != nil {
+			metadata[key] = value
+		}
+	}
+}
+
+// maskPayload masks the secret values in the strings of a payload value, the
+// archive content is masked when the files are read
+func maskPayload(value interface{}, masker *secrets.Masker) interface{} {
+	if masker == nil {
+		return value
+	}
+	switch v := value.(type) {
+	case string:
+		return masker.String(v)
+	case []string:
+		for i := range v {
+			v[i] = masker.String(v[i])
+		}
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = maskPayload(item, masker)
+		}
+	case []map[string]interface{}:
+		for _, item := range v {
+			maskPayload(item, masker)
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = maskPayload(v[i], masker)
+		}
+	}
+	return value
+}
+
+// testsSummary renders the test counts and the first failures for the dispatch payload
+func testsSummary(tests *testreport.Summary) map[string]interface{} {
+	failures := []map[string]interface{}{}
+	for _, failure := range tests.Failures() {
+		if len(failures) == maxPayloadFailures {
+			break
+		}
+		message := failure.Message
+		if len(message) > maxPayloadFailureMessage {
+			message = message[:maxPayloadFailureMessage] + "..."
+		}
+		failures = append(failures, map[string]interface{}{
+			"suite":   failure.Suite,
+			"name":    failure.Name,
+			"message": message,
+		})
+	}
+
+	return map[string]interface{}{
+		"total":    tests.Total,
+		"passed":   tests.Passed,
+		"failed":   tests.Failed,
+		"skipped":  tests.Skipped,
+		"failures": failures,
+	}
+}
+
 // determineEventType determines the event type based on configuration and success status
 func determineEventType(configEventType string, success bool) string {
 	if configEventType != "" {
@@ -502,8 +626,9 @@ func determineEventType(configEventType string, success bool) string {
 }
 
 // notifyGitHubActions sends a notification to GitHub Actions via repository dispatch
-func (tr *TestRunner) notifyGitHubActions(branch, commit string, success bool, logFilePath, resultFilePath string) error {
+func (tr *TestRunner) notifyGitHubActions(result *TestResult, logFilePath, resultFilePath string, masker *secrets.Masker) error {
 	config := tr.config.GitHubActionsDispatch
+	branch, commit, success := result.Branch, result.Commit, result.Success
 
 	// Parse repository owner and name
 	repoOwner, repoName, err := parseRepoString(config.GitHubRepo)
@@ -530,10 +655,12 @@ func (tr *TestRunner) notifyGitHubActions(branch, commit string, success bool, l
 	eventType := determineEventType(config.DispatchType, success)
 
 	// Create payload with size limits from config
-	clientPayload, err := createClientPayload(branch, commit, success, logFilePath, resultFilePath, config.HasResultFile, config.MaxFileBytes, config.MaxLogLines)
+	clientPayload, err := createClientPayload(branch, commit, success, logFilePath, resultFilePath, config.HasResultFile, config.MaxFileBytes, config.MaxLogLines, masker)
 	if err != nil {
 		return fmt.Errorf("failed to create client payload: %w", err)
 	}
+	addRunSummary(clientPayload, result)
+	maskPayload(clientPayload, masker)
 
 	// Log dispatch attempt with request details
 	slog.Debug("Sending GitHub Actions dispatch",
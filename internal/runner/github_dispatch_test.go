@@ -176,7 +176,7 @@ Test summary: 2/3 tests passed
 		config:     *cfg,
 		configPath: "/home/fjammes/src/github.com/k8s-school/home-ci/some-config.yaml", // Mock config path in project root
 	}
-	err = tr.notifyGitHubActions("main", "abcdef123456", false, logFilePath, resultFilePath)
+	err = tr.notifyGitHubActions(&TestResult{Branch: "main", Commit: "abcdef123456"}, logFilePath, resultFilePath, nil)
 	if err != nil {
 		t.Fatalf("Expected no error for valid dispatch with artifacts, got: %v", err)
 	}
@@ -258,7 +258,7 @@ func TestCreateClientPayload(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			payload, err := createClientPayload(tc.branch, tc.commit, tc.success, "", "", false, 20*1024, 1000)
+			payload, err := createClientPayload(tc.branch, tc.commit, tc.success, "", "", false, 20*1024, 1000, nil)
 			if err != nil {
 				t.Fatalf("Unexpected error: %v", err)
 			}
@@ -446,7 +446,7 @@ func TestReadFileForArchive(t *testing.T) {
 	}
 
 	// Test reading without truncation
-	file, err := readFileForArchive(testFile, 1000, 1000, "log")
+	file, err := readFileForArchive(testFile, 1000, 1000, "log", nil)
 	if err != nil {
 		t.Fatalf("Failed to read file: %v", err)
 	}
@@ -468,7 +468,7 @@ func TestReadFileForArchive(t *testing.T) {
 	}
 
 	// Test reading with line truncation (keep last 2 lines)
-	file, err = readFileForArchive(testFile, 1000, 2, "log")
+	file, err = readFileForArchive(testFile, 1000, 2, "log", nil)
 	if err != nil {
 		t.Fatalf("Failed to read file with line truncation: %v", err)
 	}
@@ -485,7 +485,7 @@ func TestReadFileForArchive(t *testing.T) {
 	}
 
 	// Test reading with byte truncation
-	file, err = readFileForArchive(testFile, 10, 1000, "log")
+	file, err = readFileForArchive(testFile, 10, 1000, "log", nil)
 	if err != nil {
 		t.Fatalf("Failed to read file with byte truncation: %v", err)
 	}
@@ -525,7 +525,7 @@ func TestCreateArtifactsMapCombinedArchive(t *testing.T) {
 	}
 
 	// Test with combined archive mode (now the only mode)
-	artifacts, _, err := createArtifactsMap("main", "abc123", true, logFile, resultFile, false, 1000, 100)
+	artifacts, _, err := createArtifactsMap("main", "abc123", true, logFile, resultFile, false, 1000, 100, nil)
 	if err != nil {
 		t.Fatalf("Failed to create artifacts map: %v", err)
 	}
@@ -579,7 +579,7 @@ func TestCreateArtifactsMapMissingRequiredFile(t *testing.T) {
 
 	// Test with hasResultFile=true but no e2e-report.yaml present
 	// This should NOT return an error anymore, but should set missingRequiredFile=true
-	artifacts, missingRequiredFile, err := createArtifactsMap("main", "def456", true, logFile, "", true, 1000, 100)
+	artifacts, missingRequiredFile, err := createArtifactsMap("main", "def456", true, logFile, "", true, 1000, 100, nil)
 	if err != nil {
 		t.Fatalf("Expected no error when e2e-report.yaml is missing, but got: %v", err)
 	}
@@ -634,7 +634,7 @@ func TestCreateClientPayloadWithMissingRequiredFile(t *testing.T) {
 	}
 
 	// Create client payload with hasResultFile=true but missing e2e-report.yaml
-	payload, err := createClientPayload("feature/test", "abc123def456", true, logFile, "", true, 1000, 100)
+	payload, err := createClientPayload("feature/test", "abc123def456", true, logFile, "", true, 1000, 100, nil)
 	if err != nil {
 		t.Fatalf("Expected no error when creating payload with missing e2e-report.yaml, but got: %v", err)
 	}
@@ -0,0 +1,101 @@
+package runner
+
+import (
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/k8s-school/home-ci/internal/artifacts"
+	"github.com/k8s-school/home-ci/internal/config"
+	"github.com/k8s-school/home-ci/internal/history"
+	"github.com/k8s-school/home-ci/internal/utils"
+)
+
+// historyEntry returns the history entry of a finished run, it spans all its attempts
+func historyEntry(cfg *config.Config, result TestResult) history.Entry {
+	entry := history.Entry{
+		RunID:         cfg.GetRunID(result.Branch, result.Commit),
+		Branch:        result.Branch,
+		Commit:        result.Commit,
+		StartTime:     result.StartTime,
+		EndTime:       result.EndTime,
+		Result:        history.ResultFailed,
+		Attempts:      len(result.Attempts),
+		FailureReason: result.FailureReason,
+		FailedStep:    result.FailedStep,
+		ErrorMessage:  result.ErrorMessage,
+	}
+	if len(result.Attempts) > 0 {
+		entry.StartTime = result.Attempts[0].StartTime
+	}
+	if len(result.Attempts) > 1 {
+		for _, attempt := range result.Attempts {
+			entry.AttemptResults = append(entry.AttemptResults, history.Attempt{
+				Attempt:       attempt.Attempt,
+				StartTime:     attempt.StartTime,
+				Duration:      attempt.Duration,
+				Success:       attempt.Success,
+				FailureReason: attempt.FailureReason,
+				FailedStep:    attempt.FailedStep,
+			})
+		}
+	}
+	if result.Tests != nil {
+		for _, c := range result.Tests.Cases {
+			entry.Tests = append(entry.Tests, history.Test{Suite: c.Suite, Name: c.Name, Status: c.Status, Message: c.Message})
+		}
+	}
+	entry.Duration = entry.EndTime.Sub(entry.StartTime)
+
+	switch {
+	case result.Success && result.Flaky:
+		entry.Result = history.ResultFlaky
+	case result.Success:
+		entry.Result = history.ResultPassed
+	}
+	return entry
+}
+
+// recordHistory appends the result of the run to the history of the repository
+func (te *TestExecution) recordHistory() {
+	entry := historyEntry(&te.runner.config, *te.testResult)
+	if err := history.Append(te.runner.config.GetHistoryFile(), entry); err != nil {
+		slog.Error("Failed to record run in history", "branch", te.branch, "commit", utils.ShortCommit(te.commit), "error", err)
+	}
+}
+
+// LoadHistory returns the finished runs of the repository, most recent first. They are
+// read from the history file, and from the run.json of the kept workspaces and of the
+// artifact store for the runs missing from it, such as manual runs.
+func LoadHistory(cfg *config.Config) ([]history.Entry, error) {
+	entries, err := history.Load(cfg.GetHistoryFile())
+	if err != nil {
+		return nil, err
+	}
+
+	paths, _ := filepath.Glob(filepath.Join(cfg.GetRunsDir(), "*", "logs", "run.json"))
+	store := artifacts.NewStore(cfg.GetArtifactsDir())
+	if runs, err := store.Runs(); err == nil {
+		for _, run := range runs {
+			if path, err := store.Path(run.ID, "logs/run.json"); err == nil {
+				paths = append(paths, path)
+			}
+		}
+	}
+
+	var others []history.Entry
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		var result TestResult
+		if err := json.Unmarshal(data, &result); err != nil || result.Branch == "" || result.EndTime.IsZero() {
+			slog.Debug("Skipping invalid run result", "file", path, "error", err)
+			continue
+		}
+		others = append(others, historyEntry(cfg, result))
+	}
+	return history.Merge(entries, others...), nil
+}
@@ -0,0 +1,72 @@
+package runner
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/k8s-school/home-ci/internal/config"
+	"github.com/k8s-school/home-ci/internal/history"
+	"github.com/k8s-school/home-ci/internal/testreport"
+)
+
+func TestLoadHistory(t *testing.T) {
+	cfg := config.Config{RepoName: "test-repo", WorkDir: t.TempDir()}
+	start := time.Date(2026, 1, 5, 10, 0, 0, 0, time.UTC)
+
+	// A run passing on its second attempt spans both attempts
+	retried := TestResult{
+		Branch: "main", Commit: "0123456789abcdef", StartTime: start.Add(5 * time.Minute), EndTime: start.Add(9 * time.Minute), Success: true, Flaky: true,
+		Attempts: []AttemptResult{{Attempt: 1, StartTime: start}, {Attempt: 2, StartTime: start.Add(5 * time.Minute)}},
+	}
+	entry := historyEntry(&cfg, retried)
+	if entry.RunID != "main_01234567" || entry.Result != history.ResultFlaky || entry.Attempts != 2 || entry.Duration != 9*time.Minute {
+		t.Errorf("historyEntry() = %+v", entry)
+	}
+	if len(entry.AttemptResults) != 2 || entry.AttemptResults[0].Attempt != 1 || entry.AttemptResults[1].StartTime != start.Add(5*time.Minute) {
+		t.Errorf("historyEntry() attempts = %+v", entry.AttemptResults)
+	}
+
+	// The test cases of the reports are kept with their outcome
+	reported := retried
+	reported.Tests = &testreport.Summary{}
+	reported.Tests.Add(testreport.Case{Suite: "e2e", Name: "TestDeploy", Status: testreport.StatusFailed, Message: "timeout"})
+	reported.Tests.Add(testreport.Case{Suite: "e2e", Name: "TestScale", Status: testreport.StatusPassed})
+	if tests := historyEntry(&cfg, reported).Tests; len(tests) != 2 || tests[0] != (history.Test{Suite: "e2e", Name: "TestDeploy", Status: "failed", Message: "timeout"}) {
+		t.Errorf("historyEntry() tests = %+v", tests)
+	}
+
+	if err := history.Append(cfg.GetHistoryFile(), entry); err != nil {
+		t.Fatal(err)
+	}
+
+	// A manual run is only found from the run.json of its workspace, a recorded run is not listed twice
+	writeResult := func(result TestResult) {
+		logsDir := cfg.GetLogsDir(result.Branch, result.Commit)
+		if err := os.MkdirAll(logsDir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		data, _ := json.Marshal(result)
+		if err := os.WriteFile(filepath.Join(logsDir, "run.json"), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeResult(retried)
+	writeResult(TestResult{
+		Branch: "fix", Commit: "aaaaaaaabbbbbbbb", StartTime: start.Add(time.Hour), EndTime: start.Add(time.Hour + time.Minute),
+		FailureReason: FailureReasonTest, FailedStep: "e2e",
+	})
+
+	entries, err := LoadHistory(&cfg)
+	if err != nil {
+		t.Fatalf("LoadHistory() failed: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("LoadHistory() returned %d entries, want 2: %+v", len(entries), entries)
+	}
+	if entries[0].RunID != "fix_aaaaaaaa" || entries[0].Failure() != "failure at step e2e" {
+		t.Errorf("unexpected manual run %+v", entries[0])
+	}
+}
@@ -1,6 +1,36 @@
 package runner
 
+import "time"
+
+// Job triggers, used to compute the job priority
+const (
+	TriggerCommit = "commit" // New commit detected by the monitor
+	TriggerManual = "manual" // Run requested by a user
+	TriggerRetry  = "retry"  // Automatic retry of a failed run
+)
+
 type TestJob struct {
-	Branch string
-	Commit string
+	Branch         string
+	Commit         string
+	Trigger        string    // Why the job was queued, defaults to TriggerCommit
+	Priority       int       // Base priority, computed from the scheduling rules when queued
+	QueuedAt       time.Time // Set when queued, used for aging
+	Resources      []string  // Named resources the job must hold to run, computed when queued
+	Attempt        int       // Attempt number of the run, starts at 1
+	PreviousCommit string    // Commit tested before on the branch, empty when unknown
+	Worker         string    // Worker leased for the ssh executor, set when the job is dequeued
+	seq            uint64    // Set by the queue
+}
+
+// QueuedJob is the public view of a job waiting in the queue
+type QueuedJob struct {
+	Branch         string    `json:"branch"`
+	Commit         string    `json:"commit"`
+	Trigger        string    `json:"trigger"`
+	BasePriority   int       `json:"base_priority"`
+	Priority       int       `json:"priority"` // Effective priority, including aging
+	QueuedAt       time.Time `json:"queued_at"`
+	Resources      []string  `json:"resources,omitempty"`
+	Attempt        int       `json:"attempt"`
+	PreviousCommit string    `json:"previous_commit,omitempty"`
 }
@@ -0,0 +1,268 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/k8s-school/home-ci/internal/artifacts"
+	"github.com/k8s-school/home-ci/internal/config"
+	"github.com/k8s-school/home-ci/internal/e2ereport"
+	"github.com/k8s-school/home-ci/internal/remote"
+	"github.com/k8s-school/home-ci/internal/utils"
+)
+
+// ErrLeaseEnded is returned when writing to a leased run that completed or expired
+var ErrLeaseEnded = errors.New("lease ended")
+
+// Directories of the archive of the files sent by the agent with the result of a job
+const (
+	leaseReportDir    = "report"    // e2e-report.yaml
+	leaseProjectDir   = "project"   // JUnit and TAP reports, relative to the project directory
+	leaseArtifactsDir = "artifacts" // Artifacts collected by the agent
+)
+
+// LeasedRun is a job leased to an agent. The coordinator writes the log sent by the
+// agent to run.log, and records its result like the attempt of a local run.
+type LeasedRun struct {
+	mu        sync.Mutex
+	te        *TestExecution
+	job       TestJob
+	agent     string
+	resources *ResourceLease
+	ended     bool
+}
+
+// LeaseJob removes from the queue the first job an agent with the given labels can
+// run, once its resources are acquired. It waits for such a job until ctx is done,
+// and returns false when there is none or the queue is closed.
+func (tr *TestRunner) LeaseJob(ctx context.Context, agent string, labels []string) (*LeasedRun, bool) {
+	var lease *ResourceLease
+	job, ok := tr.testQueue.PopFirstContext(ctx, func(j TestJob) bool {
+		if !config.HasLabels(labels, tr.config.Coordinator.LabelsFor(j.Branch)) {
+			return false
+		}
+		var acquired bool
+		lease, acquired = tr.tryAcquireResources(j)
+		if acquired && !tr.reserveRun(j, true) {
+			lease.Release()
+			return false
+		}
+		return acquired
+	}, func(j TestJob) {
+		lease.Release()
+		tr.releaseRun(j.Branch, j.Commit)
+	}, resourcePollInterval)
+	if !ok {
+		return nil, false
+	}
+	tr.publishQueue()
+
+	te := tr.newJobExecution(job)
+	if err := te.setupLogging(); err != nil {
+		slog.Error("Failed to lease job", "branch", job.Branch, "commit", utils.ShortCommit(job.Commit), "agent", agent, "error", err)
+		tr.releaseRun(job.Branch, job.Commit)
+		lease.Release()
+		return nil, false
+	}
+	if err := te.registerRunningTest(); err != nil {
+		slog.Error("Failed to save the running test", "branch", job.Branch, "error", err)
+	}
+	// The files of a previous attempt are replaced by the ones the agent sends
+	err := os.RemoveAll(te.projectDir)
+	if err == nil {
+		err = te.resetAttemptFiles()
+	}
+	if err != nil {
+		slog.Error("Failed to remove the files of the previous attempt", "branch", job.Branch, "error", err)
+	}
+
+	fmt.Fprintf(te.logFile, "=== Leased to Agent ===\n")
+	fmt.Fprintf(te.logFile, "Agent: %s\n", agent)
+	fmt.Fprintf(te.logFile, "Labels: %s\n", strings.Join(labels, ","))
+	fmt.Fprintf(te.logFile, "Timestamp: %s\n", time.Now().Format(time.RFC3339))
+	fmt.Fprintf(te.logFile, "=======================\n\n")
+
+	slog.Info("Job leased", "branch", job.Branch, "commit", utils.ShortCommit(job.Commit), "attempt", te.attempt, "agent", agent)
+	return &LeasedRun{te: te, job: job, agent: agent, resources: lease}, true
+}
+
+// Job returns the leased job
+func (r *LeasedRun) Job() TestJob {
+	return r.job
+}
+
+// Write appends the log sent by the agent to run.log
+func (r *LeasedRun) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.ended {
+		return 0, ErrLeaseEnded
+	}
+	return r.te.logFile.Stream(StreamAgent).Write(p)
+}
+
+// Complete records the result reported by the agent as the attempt of the run,
+// queues a retry when configured and sends the notification of the last attempt
+func (r *LeasedRun) Complete(result TestResult) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.ended {
+		return ErrLeaseEnded
+	}
+	r.ended = true
+	te := r.te
+
+	// The agent does not know the previous attempts, and the run is the leased one
+	result.Branch = te.branch
+	result.Commit = te.commit
+	result.LogFile = filepath.Base(te.logFilePath)
+	result.Attempts = te.testResult.Attempts
+	result.GitHubActionsNotified = false
+	result.GitHubActionsSuccess = false
+	result.GitHubActionsErrorMessage = ""
+	result.Artifacts = nil
+	te.testResult = &result
+
+	// The report, the test reports and the artifacts sent by the agent are handled
+	// with the settings of the coordinator
+	fmt.Fprintf(te.logFile, "\n=== Result reported by %s ===\n", r.agent)
+	te.reconcileReport()
+	te.parseTestReports()
+	te.collectArtifacts()
+	te.finishAttempt()
+	te.saveTestResultForDispatch()
+	if !te.retryQueued {
+		// Only the last attempt of a run is notified
+		te.sendGitHubNotificationIfNeeded()
+	}
+	te.cleanup()
+	r.resources.Release()
+
+	slog.Info("Leased job completed", "branch", te.branch, "commit", utils.ShortCommit(te.commit), "agent", r.agent, "success", result.Success)
+	return nil
+}
+
+// AddFiles extracts the archive of the files sent by the agent, written by
+// RunLeasedJob, to the workspace of the run. They are read when the result is reported.
+func (r *LeasedRun) AddFiles(archive io.Reader) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.ended {
+		return ErrLeaseEnded
+	}
+	te := r.te
+
+	dir := filepath.Join(te.workspaceDir, "agent-files")
+	defer os.RemoveAll(dir)
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	if err := remote.ExtractTar(archive, dir); err != nil {
+		return fmt.Errorf("failed to extract the files of the agent: %w", err)
+	}
+
+	report := filepath.Join(dir, leaseReportDir, e2ereport.FileName)
+	if info, err := os.Lstat(report); err == nil && !info.Mode().IsRegular() {
+		return fmt.Errorf("%s sent by the agent is not a regular file", e2ereport.FileName)
+	}
+	for _, move := range []struct{ src, dst string }{
+		{report, filepath.Join(te.cfg().GetLogsDir(te.branch, te.commit), e2ereport.FileName)},
+		{filepath.Join(dir, leaseProjectDir), te.projectDir},
+		{filepath.Join(dir, leaseArtifactsDir), te.artifactsDir},
+	} {
+		if _, err := os.Lstat(move.src); err != nil {
+			continue
+		}
+		if err := os.RemoveAll(move.dst); err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(move.dst), 0755); err != nil {
+			return err
+		}
+		if err := os.Rename(move.src, move.dst); err != nil {
+			return fmt.Errorf("failed to store the files of the agent: %w", err)
+		}
+	}
+	fmt.Fprintf(te.logFile, "\nFiles received from %s\n", r.agent)
+	return nil
+}
+
+// writeLeaseFiles writes to leaseFiles the files the coordinator reads after a leased
+// job: the report, the JUnit and TAP reports and the collected artifacts
+func (te *TestExecution) writeLeaseFiles() {
+	if te.leaseFiles == nil {
+		return
+	}
+	cfg := te.cfg()
+	files := make(map[string]string)
+
+	report := filepath.Join(cfg.GetLogsDir(te.branch, te.commit), e2ereport.FileName)
+	if _, err := os.Stat(report); err == nil {
+		files[path.Join(leaseReportDir, e2ereport.FileName)] = report
+	}
+	if patterns := append(append([]string{}, cfg.TestReports.JUnit...), cfg.TestReports.TAP...); len(patterns) > 0 {
+		reports, _ := artifacts.FindFiles(te.projectDir, patterns)
+		for _, rel := range reports {
+			files[path.Join(leaseProjectDir, rel)] = filepath.Join(te.projectDir, filepath.FromSlash(rel))
+		}
+	}
+	runDir := te.artifactStore().RunDir(cfg.GetRunID(te.branch, te.commit))
+	for _, file := range te.testResult.Artifacts {
+		files[path.Join(leaseArtifactsDir, file.Path)] = filepath.Join(runDir, filepath.FromSlash(file.Path))
+	}
+
+	if err := remote.WriteTarFiles(te.leaseFiles, files); err != nil {
+		fmt.Fprintf(te.logFile, "Failed to archive the files for the coordinator: %v\n", err)
+		slog.Error("Failed to archive the files for the coordinator", "branch", te.branch, "commit", utils.ShortCommit(te.commit), "error", err)
+		return
+	}
+	fmt.Fprintf(te.logFile, "\n%d file(s) sent to the coordinator\n", len(files))
+}
+
+// Expire ends a lease whose agent went away, the job is queued again with the same attempt
+func (r *LeasedRun) Expire() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.ended {
+		return
+	}
+	r.ended = true
+	te := r.te
+
+	fmt.Fprintf(te.logFile, "\n=== Lease Expired ===\n")
+	fmt.Fprintf(te.logFile, "Agent %s stopped sending heartbeats\n", r.agent)
+	slog.Warn("Lease expired", "branch", te.branch, "commit", utils.ShortCommit(te.commit), "agent", r.agent)
+
+	// A commit job queued meanwhile for the branch supersedes the expired one
+	requeue := true
+	if r.job.Trigger == TriggerCommit && te.runner.config.Scheduling.SupersedeQueued {
+		for _, queued := range te.runner.GetQueuedJobs() {
+			if queued.Branch == r.job.Branch && queued.Trigger == TriggerCommit {
+				requeue = false
+				break
+			}
+		}
+	}
+	switch {
+	case !requeue:
+		fmt.Fprintf(te.logFile, "A newer commit of the branch is queued, the job is dropped\n")
+	case te.runner.QueueTestJob(r.job):
+		fmt.Fprintf(te.logFile, "Job queued again\n")
+	default:
+		fmt.Fprintf(te.logFile, "Failed to queue the job again, queue is full\n")
+		slog.Error("Failed to requeue expired job, queue is full", "branch", te.branch, "commit", utils.ShortCommit(te.commit))
+	}
+	fmt.Fprintf(te.logFile, "=====================\n")
+
+	te.cleanup()
+	r.resources.Release()
+}
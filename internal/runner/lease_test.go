@@ -0,0 +1,125 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/k8s-school/home-ci/internal/config"
+)
+
+// newCoordinatorRunner creates a runner serving its queue to agents
+func newCoordinatorRunner(t *testing.T) *TestRunner {
+	t.Helper()
+
+	cfg := config.Config{
+		RepoName: "test-repo",
+		WorkDir:  t.TempDir(),
+		KeepTime: time.Hour,
+		Retry:    config.Retry{MaxAttempts: 2, On: []string{config.RetryOnFailure}},
+		Coordinator: config.Coordinator{
+			Rules: []config.AgentRule{{Branch: "gpu/*", Labels: []string{"gpu"}}},
+		},
+	}
+	return &TestRunner{
+		config:       cfg,
+		testQueue:    NewJobQueue(cfg.Scheduling, cfg.Resources, defaultQueueCapacity),
+		stateManager: &MockStateManager{},
+	}
+}
+
+func TestLeaseJobLabels(t *testing.T) {
+	tr := newCoordinatorRunner(t)
+	tr.QueueTestJob(TestJob{Branch: "gpu/cuda", Commit: "0123456789abcdef"})
+
+	// The agent does not have the gpu label
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, ok := tr.LeaseJob(ctx, "agent-1", []string{"linux"}); ok {
+		t.Fatal("a job requiring the gpu label must not be leased to agent-1")
+	}
+
+	run, ok := tr.LeaseJob(context.Background(), "agent-2", []string{"linux", "gpu"})
+	if !ok || run.Job().Branch != "gpu/cuda" {
+		t.Fatalf("expected gpu/cuda to be leased to agent-2, got ok=%v", ok)
+	}
+	if n := len(tr.stateManager.GetRunningTests()); n != 1 {
+		t.Errorf("expected the leased job to be running, got %d running tests", n)
+	}
+
+	run.Write([]byte("remote output\n"))
+	if err := run.Complete(TestResult{Success: true, StartTime: time.Now()}); err != nil {
+		t.Fatalf("Complete() failed: %v", err)
+	}
+	if _, err := run.Write([]byte("late\n")); err != ErrLeaseEnded {
+		t.Errorf("Write() after Complete() = %v, want %v", err, ErrLeaseEnded)
+	}
+
+	data, err := os.ReadFile(run.te.resultFilePath)
+	if err != nil {
+		t.Fatalf("result file not written: %v", err)
+	}
+	var result TestResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		t.Fatal(err)
+	}
+	if !result.Success || result.Branch != "gpu/cuda" || len(result.Attempts) != 1 {
+		t.Errorf("unexpected result %+v", result)
+	}
+	log, _ := os.ReadFile(run.te.logFilePath)
+	if !strings.Contains(string(log), "Agent: agent-2") || !strings.Contains(string(log), "remote output") {
+		t.Errorf("run.log misses the lease or the agent output:\n%s", log)
+	}
+	if n := len(tr.stateManager.GetRunningTests()); n != 0 {
+		t.Errorf("expected no running test, got %d", n)
+	}
+}
+
+func TestLeasedRunFailureIsRetried(t *testing.T) {
+	tr := newCoordinatorRunner(t)
+	tr.QueueTestJob(TestJob{Branch: "main", Commit: "0123456789abcdef"})
+
+	run, ok := tr.LeaseJob(context.Background(), "agent-1", nil)
+	if !ok {
+		t.Fatal("expected main to be leased")
+	}
+	run.Complete(TestResult{StartTime: time.Now(), ErrorMessage: "exit status 1"})
+
+	job, ok := tr.testQueue.Pop()
+	if !ok || job.Trigger != TriggerRetry || job.Attempt != 2 {
+		t.Fatalf("expected a retry of the failed leased job, got %+v", job)
+	}
+}
+
+func TestLeasedRunExpire(t *testing.T) {
+	tr := newCoordinatorRunner(t)
+	tr.QueueTestJob(TestJob{Branch: "main", Commit: "0123456789abcdef"})
+
+	run, ok := tr.LeaseJob(context.Background(), "agent-1", nil)
+	if !ok {
+		t.Fatal("expected main to be leased")
+	}
+	run.Expire()
+	if err := run.Complete(TestResult{Success: true}); err != ErrLeaseEnded {
+		t.Errorf("Complete() after Expire() = %v, want %v", err, ErrLeaseEnded)
+	}
+
+	job, ok := tr.testQueue.Pop()
+	if !ok || job.Commit != "0123456789abcdef" || job.Attempt != 1 {
+		t.Fatalf("expected the expired job to be queued again, got %+v", job)
+	}
+
+	// With supersede_queued, a newer commit of the branch supersedes the expired job
+	tr.config.Scheduling.SupersedeQueued = true
+	tr.testQueue.scheduling.SupersedeQueued = true
+	tr.QueueTestJob(job)
+	run, _ = tr.LeaseJob(context.Background(), "agent-1", nil)
+	tr.QueueTestJob(TestJob{Branch: "main", Commit: "fedcba9876543210"})
+	run.Expire()
+	if jobs := tr.GetQueuedJobs(); len(jobs) != 1 || jobs[0].Commit != "fedcba9876543210" {
+		t.Errorf("expected only the newer commit to be queued, got %+v", jobs)
+	}
+}
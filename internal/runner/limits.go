@@ -0,0 +1,104 @@
+package runner
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/k8s-school/home-ci/internal/cgroup"
+	"github.com/k8s-school/home-ci/internal/config"
+	"github.com/k8s-school/home-ci/internal/utils"
+)
+
+// cgroupLimits converts the configured limits to cgroup limits
+func cgroupLimits(limits config.Limits) cgroup.Limits {
+	return cgroup.Limits{
+		MemoryMax: limits.MemoryBytes(),
+		CPUs:      limits.CPUs,
+		PidsMax:   limits.Pids,
+	}
+}
+
+// cgroupManager returns the manager of the run cgroups, it is set up on first use
+// since it may move the daemon to a child cgroup
+func (tr *TestRunner) cgroupManager() (*cgroup.Manager, error) {
+	tr.cgroupsOnce.Do(func() {
+		tr.cgroups, tr.cgroupsErr = cgroup.NewManager(tr.config.Limits.CgroupDir, cgroupLimits(tr.config.Limits))
+		if tr.cgroupsErr == nil {
+			slog.Info("Resource limits enabled", "cgroup_dir", tr.cgroups.Dir())
+		}
+	})
+	return tr.cgroups, tr.cgroupsErr
+}
+
+// setupLimits creates the cgroup enforcing the resource limits of the attempt, once
+// its executor is known
+func (te *TestExecution) setupLimits() error {
+	limits := te.cfg().Limits
+	if !limits.Enabled() {
+		return nil
+	}
+	// The container engine enforces the limits in the cgroup of the container, and
+	// the limits do not apply to the commands run on a worker
+	switch te.executor.(type) {
+	case *ociExecutor, *sshExecutor:
+		return nil
+	}
+
+	manager, err := te.runner.cgroupManager()
+	if err != nil {
+		return fmt.Errorf("failed to set up resource limits: %w", err)
+	}
+	name := fmt.Sprintf("%s.%d", te.cfg().GetRunID(te.branch, te.commit), te.attempt)
+	group, err := manager.Create(name, cgroupLimits(limits))
+	if err != nil {
+		return fmt.Errorf("failed to set up resource limits: %w", err)
+	}
+	te.cgroup = group
+
+	fmt.Fprintf(te.logFile, "=== Resource Limits ===\n")
+	fmt.Fprintf(te.logFile, "Memory: %s\n", formatLimit(limits.Memory != "", limits.Memory))
+	fmt.Fprintf(te.logFile, "CPUs: %s\n", formatLimit(limits.CPUs > 0, limits.CPUs))
+	fmt.Fprintf(te.logFile, "Pids: %s\n", formatLimit(limits.Pids > 0, limits.Pids))
+	fmt.Fprintf(te.logFile, "Cgroup: %s\n", group.Path())
+	fmt.Fprintf(te.logFile, "=======================\n\n")
+	return nil
+}
+
+// formatLimit renders a limit for run.log
+func formatLimit(set bool, value interface{}) string {
+	if !set {
+		return "unlimited"
+	}
+	return fmt.Sprint(value)
+}
+
+// oomMessage describes a command killed by the memory limit
+func (te *TestExecution) oomMessage() string {
+	return fmt.Sprintf("killed by the OOM killer (memory limit %s)", te.cfg().Limits.Memory)
+}
+
+// handleOOMKill records a test script killed by the memory limit of the run
+func (te *TestExecution) handleOOMKill() {
+	te.testResult.FailureReason = FailureReasonOOM
+	te.testResult.ErrorMessage = te.oomMessage()
+
+	slog.Error("Test killed by the OOM killer",
+		"branch", te.branch,
+		"commit", utils.ShortCommit(te.commit),
+		"memory_limit", te.cfg().Limits.Memory)
+
+	fmt.Fprintf(te.logFile, "\n=== OUT OF MEMORY ===\n")
+	fmt.Fprintf(te.logFile, "Test was killed after reaching the memory limit of %s\n", te.cfg().Limits.Memory)
+	fmt.Fprintf(te.logFile, "=====================\n")
+}
+
+// removeCgroup kills the processes left by the commands and removes the cgroup of the run
+func (te *TestExecution) removeCgroup() {
+	if te.cgroup == nil {
+		return
+	}
+	if err := te.cgroup.Remove(); err != nil {
+		slog.Error("Failed to remove the cgroup of the run", "error", err)
+	}
+	te.cgroup = nil
+}
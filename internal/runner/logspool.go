@@ -0,0 +1,141 @@
+package runner
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// spoolBufferSize is the size of the pieces read back from the spool of a capped log
+const spoolBufferSize = 32 * 1024
+
+// tailSpool keeps the last writes of a capped log in a temporary file, each as a
+// header line followed by its data. The dropped writes are reclaimed once they
+// take more room than the kept ones.
+type tailSpool struct {
+	file  *os.File
+	start int64 // Offset of the header of the oldest write kept
+	skip  int64 // Bytes dropped from the start of the oldest write
+	end   int64 // Size of the spool
+	bytes int64 // Bytes of data kept
+}
+
+// spoolHeader describes a write kept in the spool
+type spoolHeader struct {
+	Time   time.Time `json:"time"`
+	Stream string    `json:"stream"`
+	Step   string    `json:"step,omitempty"`
+	Size   int64     `json:"size"`
+}
+
+// newTailSpool creates the spool in dir
+func newTailSpool(dir string) (*tailSpool, error) {
+	file, err := os.CreateTemp(dir, ".run.log.tail-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create log spool: %w", err)
+	}
+	return &tailSpool{file: file}, nil
+}
+
+// append adds a write to the spool, drops the oldest data past limit bytes and
+// returns the number of bytes dropped
+func (s *tailSpool) append(t time.Time, stream, step string, data []byte, limit int64) (int64, error) {
+	header, err := json.Marshal(spoolHeader{Time: t, Stream: stream, Step: step, Size: int64(len(data))})
+	if err != nil {
+		return 0, err
+	}
+	record := append(append(header, '\n'), data...)
+	if _, err := s.file.WriteAt(record, s.end); err != nil {
+		return 0, fmt.Errorf("failed to write log spool: %w", err)
+	}
+	s.end += int64(len(record))
+	s.bytes += int64(len(data))
+	return s.trim(limit)
+}
+
+// trim drops the oldest data past limit bytes, and returns the number of bytes dropped
+func (s *tailSpool) trim(limit int64) (int64, error) {
+	var dropped int64
+	for s.bytes > limit {
+		header, data, err := s.readHeader(s.start)
+		if err != nil {
+			return dropped, err
+		}
+		excess := s.bytes - limit
+		if left := header.Size - s.skip; left <= excess {
+			excess = left
+			s.start, s.skip = data+header.Size, 0
+		} else {
+			s.skip += excess
+		}
+		s.bytes -= excess
+		dropped += excess
+	}
+	if s.start > limit {
+		return dropped, s.compact()
+	}
+	return dropped, nil
+}
+
+// compact moves the writes kept to the start of the spool
+func (s *tailSpool) compact() error {
+	kept := io.NewSectionReader(s.file, s.start, s.end-s.start)
+	if _, err := io.Copy(io.NewOffsetWriter(s.file, 0), kept); err != nil {
+		return fmt.Errorf("failed to compact log spool: %w", err)
+	}
+	s.end -= s.start
+	s.start = 0
+	if err := s.file.Truncate(s.end); err != nil {
+		return fmt.Errorf("failed to compact log spool: %w", err)
+	}
+	return nil
+}
+
+// readHeader reads the header at offset, and returns the offset of its data
+func (s *tailSpool) readHeader(offset int64) (spoolHeader, int64, error) {
+	line, err := bufio.NewReader(io.NewSectionReader(s.file, offset, s.end-offset)).ReadBytes('\n')
+	if err != nil {
+		return spoolHeader{}, 0, fmt.Errorf("failed to read log spool: %w", err)
+	}
+	var header spoolHeader
+	if err := json.Unmarshal(line, &header); err != nil {
+		return spoolHeader{}, 0, fmt.Errorf("invalid log spool header: %w", err)
+	}
+	return header, offset + int64(len(line)), nil
+}
+
+// each calls fn with the writes kept, oldest first, their data split in pieces
+func (s *tailSpool) each(fn func(header spoolHeader, data []byte)) error {
+	buf := make([]byte, spoolBufferSize)
+	skip := s.skip
+	for offset := s.start; offset < s.end; {
+		header, data, err := s.readHeader(offset)
+		if err != nil {
+			return err
+		}
+		reader := io.NewSectionReader(s.file, data+skip, header.Size-skip)
+		for {
+			n, err := reader.Read(buf)
+			if n > 0 {
+				fn(header, buf[:n])
+			}
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return fmt.Errorf("failed to read log spool: %w", err)
+			}
+		}
+		offset, skip = data+header.Size, 0
+	}
+	return nil
+}
+
+// Close removes the spool
+func (s *tailSpool) Close() error {
+	s.file.Close()
+	return os.Remove(s.file.Name())
+}
@@ -0,0 +1,47 @@
+package runner
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestTailSpool(t *testing.T) {
+	spool, err := newTailSpool(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer spool.Close()
+
+	var dropped int64
+	now := time.Now()
+	for i := 0; i < 1000; i++ {
+		n, err := spool.append(now, StreamStdout, "test", []byte(fmt.Sprintf("line-%03d\n", i)), 25)
+		if err != nil {
+			t.Fatalf("append failed: %v", err)
+		}
+		dropped += n
+	}
+	if dropped != 1000*9-25 {
+		t.Errorf("dropped %d bytes, want %d", dropped, 1000*9-25)
+	}
+
+	// The dropped writes are reclaimed
+	if spool.end > 1024 {
+		t.Errorf("spool of %d bytes for 25 bytes kept", spool.end)
+	}
+
+	var kept string
+	err = spool.each(func(header spoolHeader, data []byte) {
+		if header.Stream != StreamStdout || header.Step != "test" || !header.Time.Equal(now) {
+			t.Errorf("unexpected header %+v", header)
+		}
+		kept += string(data)
+	})
+	if err != nil {
+		t.Fatalf("each failed: %v", err)
+	}
+	if kept != "ne-997\nline-998\nline-999\n" {
+		t.Errorf("kept %q", kept)
+	}
+}
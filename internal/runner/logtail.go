@@ -0,0 +1,64 @@
+package runner
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"time"
+)
+
+// logTailInterval is how often a followed log is checked for new content
+const logTailInterval = 500 * time.Millisecond
+
+// logTail copies what is appended to a log file to a writer, until stopped
+type logTail struct {
+	file *os.File
+	w    io.Writer
+	stop chan struct{}
+	done chan struct{}
+}
+
+// startLogTail follows the log file at path from its current end
+func startLogTail(path string, w io.Writer) (*logTail, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to follow log file %s: %w", path, err)
+	}
+	if _, err := file.Seek(0, io.SeekEnd); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to follow log file %s: %w", path, err)
+	}
+
+	t := &logTail{file: file, w: w, stop: make(chan struct{}), done: make(chan struct{})}
+	go t.run()
+	return t, nil
+}
+
+// run copies the new content every logTailInterval
+func (t *logTail) run() {
+	defer close(t.done)
+	for {
+		select {
+		case <-t.stop:
+			t.copy()
+			return
+		case <-time.After(logTailInterval):
+			t.copy()
+		}
+	}
+}
+
+// copy writes the content appended since the last copy
+func (t *logTail) copy() {
+	if _, err := io.Copy(t.w, t.file); err != nil {
+		slog.Error("Failed to copy log", "file", t.file.Name(), "error", err)
+	}
+}
+
+// Stop copies the remaining content and stops following the file
+func (t *logTail) Stop() {
+	close(t.stop)
+	<-t.done
+	t.file.Close()
+}
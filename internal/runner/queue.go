@@ -0,0 +1,307 @@
+package runner
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/k8s-school/home-ci/internal/config"
+	"github.com/k8s-school/home-ci/internal/utils"
+)
+
+const defaultQueueCapacity = 100
+
+// JobQueue is a priority queue of test jobs.
+// Jobs with the highest effective priority are popped first, ties are broken by queue time.
+// The effective priority grows with the waiting time so that low-priority jobs do not starve.
+type JobQueue struct {
+	mu         sync.Mutex
+	wake       chan struct{} // Signaled when a job is pushed or the queue is closed
+	jobs       []TestJob
+	nextSeq    uint64 // Identifies the queued jobs, so that a job probed without the lock is found again
+	capacity   int
+	closed     bool
+	scheduling config.Scheduling
+	resources  config.Resources
+	now        func() time.Time
+}
+
+// NewJobQueue creates an empty job queue using the given scheduling and resource configuration
+func NewJobQueue(scheduling config.Scheduling, resources config.Resources, capacity int) *JobQueue {
+	return &JobQueue{
+		wake:       make(chan struct{}, 1),
+		capacity:   capacity,
+		scheduling: scheduling,
+		resources:  resources,
+		now:        time.Now,
+	}
+}
+
+// basePriority computes the priority of a job from the branch rules and its trigger
+func basePriority(scheduling config.Scheduling, job TestJob) int {
+	priority := scheduling.DefaultPriority
+	for _, rule := range scheduling.Rules {
+		if config.MatchBranch(rule.Branch, job.Branch) {
+			priority = rule.Priority
+			break
+		}
+	}
+
+	switch job.Trigger {
+	case TriggerManual:
+		priority += scheduling.ManualPriority
+	case TriggerRetry:
+		priority += scheduling.RetryPriority
+	}
+
+	return priority
+}
+
+// EffectivePriority adds the aging bonus to a base priority, one point per aging interval spent in the queue
+func EffectivePriority(scheduling config.Scheduling, basePriority int, queuedAt, now time.Time) int {
+	if scheduling.AgingInterval <= 0 {
+		return basePriority
+	}
+	return basePriority + int(now.Sub(queuedAt)/scheduling.AgingInterval)
+}
+
+// effectivePriority returns the current priority of a queued job
+func (q *JobQueue) effectivePriority(job TestJob, now time.Time) int {
+	return EffectivePriority(q.scheduling, job.Priority, job.QueuedAt, now)
+}
+
+// Push adds a job to the queue, it returns false if the queue is full or closed.
+// A commit already waiting is not queued twice. With scheduling.supersede_queued, a job
+// queued for a new commit replaces a waiting job of the same branch with an older commit.
+func (q *JobQueue) Push(job TestJob) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return false
+	}
+
+	if job.Trigger == "" {
+		job.Trigger = TriggerCommit
+	}
+	if job.Attempt < 1 {
+		job.Attempt = 1
+	}
+	if job.QueuedAt.IsZero() {
+		job.QueuedAt = q.now()
+	}
+	job.Priority = basePriority(q.scheduling, job)
+	job.Resources = q.resources.RequiredFor(job.Branch)
+	q.nextSeq++
+	job.seq = q.nextSeq
+
+	if job.Trigger == TriggerCommit {
+		for i, queued := range q.jobs {
+			if queued.Branch != job.Branch || queued.Trigger != TriggerCommit {
+				continue
+			}
+			if queued.Commit == job.Commit {
+				return true
+			}
+			if q.scheduling.SupersedeQueued {
+				slog.Info("Queued commit superseded by a newer commit",
+					"branch", job.Branch,
+					"commit", utils.ShortCommit(queued.Commit),
+					"new_commit", utils.ShortCommit(job.Commit))
+				// Keep the original queue time so that the branch does not lose its aging,
+				// and the previous commit since the replaced one is never tested
+				job.QueuedAt = queued.QueuedAt
+				job.PreviousCommit = queued.PreviousCommit
+				q.jobs[i] = job
+				return true
+			}
+		}
+	}
+
+	if len(q.jobs) >= q.capacity {
+		return false
+	}
+
+	q.jobs = append(q.jobs, job)
+	q.signal()
+	return true
+}
+
+// signal wakes up a consumer waiting for jobs, the caller must hold the lock
+func (q *JobQueue) signal() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Notify wakes up a consumer so that it checks the waiting jobs again
+func (q *JobQueue) Notify() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if !q.closed {
+		q.signal()
+	}
+}
+
+// Remove removes the waiting jobs accepted by match and returns their number
+func (q *JobQueue) Remove(match func(TestJob) bool) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	kept := q.jobs[:0]
+	for _, job := range q.jobs {
+		if !match(job) {
+			kept = append(kept, job)
+		}
+	}
+	removed := len(q.jobs) - len(kept)
+	q.jobs = kept
+	return removed
+}
+
+// Pop removes and returns the job with the highest effective priority.
+// It blocks until a job is available, and returns false once the queue is closed and empty.
+func (q *JobQueue) Pop() (TestJob, bool) {
+	return q.PopFirst(func(TestJob) bool { return true }, nil, 0)
+}
+
+// PopFirst removes and returns the first job, in priority order, accepted by ready.
+// It blocks until such a job exists and returns false once the queue is closed and empty.
+// Jobs are checked again every pollInterval when it is positive, for conditions that
+// change outside of the queue such as resources released by another process.
+//
+// ready is called without holding the queue lock, since it may take resources with file
+// I/O. When the accepted job was removed from the queue meanwhile, release is called to
+// give back what ready took, and the next jobs are checked.
+func (q *JobQueue) PopFirst(ready func(TestJob) bool, release func(TestJob), pollInterval time.Duration) (TestJob, bool) {
+	return q.PopFirstContext(context.Background(), ready, release, pollInterval)
+}
+
+// PopFirstContext is PopFirst giving up when ctx is done
+func (q *JobQueue) PopFirstContext(ctx context.Context, ready func(TestJob) bool, release func(TestJob), pollInterval time.Duration) (TestJob, bool) {
+	for {
+		q.mu.Lock()
+		if q.closed && len(q.jobs) == 0 {
+			q.mu.Unlock()
+			return TestJob{}, false
+		}
+
+		now := q.now()
+		candidates := make([]TestJob, len(q.jobs))
+		copy(candidates, q.jobs)
+		q.mu.Unlock()
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return q.before(candidates[i], candidates[j], now)
+		})
+
+		for _, job := range candidates {
+			if !ready(job) {
+				continue
+			}
+			if q.take(job.seq) {
+				return job, true
+			}
+			if release != nil {
+				release(job)
+			}
+		}
+
+		q.mu.Lock()
+		closed := q.closed
+		q.mu.Unlock()
+
+		if closed {
+			// The wake channel is closed, only polling can make a remaining job ready
+			if pollInterval <= 0 {
+				return TestJob{}, false
+			}
+			select {
+			case <-ctx.Done():
+				return TestJob{}, false
+			case <-time.After(pollInterval):
+			}
+			continue
+		}
+
+		var poll <-chan time.Time
+		if pollInterval > 0 {
+			poll = time.After(pollInterval)
+		}
+		select {
+		case <-ctx.Done():
+			return TestJob{}, false
+		case <-q.wake:
+		case <-poll:
+		}
+	}
+}
+
+// take removes the job with the given sequence number, it returns false if it is no longer queued
+func (q *JobQueue) take(seq uint64) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for i, job := range q.jobs {
+		if job.seq == seq {
+			q.jobs = append(q.jobs[:i], q.jobs[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// before reports whether a must run before b
+func (q *JobQueue) before(a, b TestJob, now time.Time) bool {
+	pa, pb := q.effectivePriority(a, now), q.effectivePriority(b, now)
+	if pa != pb {
+		return pa > pb
+	}
+	return a.QueuedAt.Before(b.QueuedAt)
+}
+
+// List returns the waiting jobs in the order they will run
+func (q *JobQueue) List() []QueuedJob {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := q.now()
+	jobs := make([]TestJob, len(q.jobs))
+	copy(jobs, q.jobs)
+	sort.SliceStable(jobs, func(i, j int) bool {
+		return q.before(jobs[i], jobs[j], now)
+	})
+
+	queued := make([]QueuedJob, 0, len(jobs))
+	for _, job := range jobs {
+		queued = append(queued, QueuedJob{
+			Branch:         job.Branch,
+			Commit:         job.Commit,
+			Trigger:        job.Trigger,
+			BasePriority:   job.Priority,
+			Priority:       q.effectivePriority(job, now),
+			QueuedAt:       job.QueuedAt,
+			Resources:      job.Resources,
+			Attempt:        job.Attempt,
+			PreviousCommit: job.PreviousCommit,
+		})
+	}
+	return queued
+}
+
+// Len returns the number of waiting jobs
+func (q *JobQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.jobs)
+}
+
+// Close wakes up all waiting consumers, remaining jobs can still be popped
+func (q *JobQueue) Close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.closed = true
+	close(q.wake)
+}
@@ -0,0 +1,256 @@
+package runner
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/k8s-school/home-ci/internal/config"
+)
+
+// newTestQueue creates a queue with a controllable clock
+func newTestQueue(scheduling config.Scheduling, now *time.Time) *JobQueue {
+	q := NewJobQueue(scheduling, config.Resources{}, defaultQueueCapacity)
+	q.now = func() time.Time { return *now }
+	return q
+}
+
+func TestJobQueuePriorityRules(t *testing.T) {
+	now := time.Now()
+	q := newTestQueue(config.Scheduling{
+		Rules: []config.PriorityRule{
+			{Branch: "main", Priority: 100},
+			{Branch: "release/*", Priority: 80},
+		},
+		ManualPriority: 50,
+		RetryPriority:  10,
+	}, &now)
+
+	q.Push(TestJob{Branch: "feature/a", Commit: "aaaaaaaa"})
+	now = now.Add(time.Second)
+	q.Push(TestJob{Branch: "feature/b", Commit: "bbbbbbbb", Trigger: TriggerRetry})
+	now = now.Add(time.Second)
+	q.Push(TestJob{Branch: "release/1.0", Commit: "cccccccc"})
+	now = now.Add(time.Second)
+	q.Push(TestJob{Branch: "main", Commit: "dddddddd"})
+	now = now.Add(time.Second)
+	q.Push(TestJob{Branch: "feature/c", Commit: "eeeeeeee", Trigger: TriggerManual})
+
+	expected := []string{"main", "release/1.0", "feature/c", "feature/b", "feature/a"}
+
+	listed := q.List()
+	if len(listed) != len(expected) {
+		t.Fatalf("List() returned %d jobs, want %d", len(listed), len(expected))
+	}
+	for i, branch := range expected {
+		if listed[i].Branch != branch {
+			t.Errorf("List()[%d] = %s, want %s", i, listed[i].Branch, branch)
+		}
+	}
+
+	for _, branch := range expected {
+		job, ok := q.Pop()
+		if !ok {
+			t.Fatalf("Pop() returned no job, want %s", branch)
+		}
+		if job.Branch != branch {
+			t.Errorf("Pop() = %s, want %s", job.Branch, branch)
+		}
+	}
+}
+
+func TestJobQueueFIFOForEqualPriority(t *testing.T) {
+	now := time.Now()
+	q := newTestQueue(config.Scheduling{}, &now)
+
+	for _, branch := range []string{"a", "b", "c"} {
+		q.Push(TestJob{Branch: branch, Commit: "12345678"})
+		now = now.Add(time.Second)
+	}
+
+	for _, branch := range []string{"a", "b", "c"} {
+		job, _ := q.Pop()
+		if job.Branch != branch {
+			t.Errorf("Pop() = %s, want %s", job.Branch, branch)
+		}
+	}
+}
+
+func TestJobQueueAgingPreventsStarvation(t *testing.T) {
+	now := time.Now()
+	q := newTestQueue(config.Scheduling{
+		Rules:         []config.PriorityRule{{Branch: "main", Priority: 10}},
+		AgingInterval: time.Minute,
+	}, &now)
+
+	q.Push(TestJob{Branch: "feature/old", Commit: "aaaaaaaa"})
+
+	// After 11 minutes the feature branch outranks a fresh main job
+	now = now.Add(11 * time.Minute)
+	q.Push(TestJob{Branch: "main", Commit: "bbbbbbbb"})
+
+	job, _ := q.Pop()
+	if job.Branch != "feature/old" {
+		t.Errorf("Pop() = %s, want feature/old to win through aging", job.Branch)
+	}
+}
+
+func TestJobQueueKeepsBranchCommits(t *testing.T) {
+	now := time.Now()
+	q := newTestQueue(config.Scheduling{}, &now)
+
+	q.Push(TestJob{Branch: "feature/a", Commit: "11111111"})
+	q.Push(TestJob{Branch: "feature/a", Commit: "22222222"})
+	q.Push(TestJob{Branch: "feature/a", Commit: "22222222"})
+
+	listed := q.List()
+	if len(listed) != 2 || listed[0].Commit != "11111111" || listed[1].Commit != "22222222" {
+		t.Errorf("List() = %+v, want both commits queued once", listed)
+	}
+}
+
+func TestJobQueueSupersedesBranchCommit(t *testing.T) {
+	now := time.Now()
+	q := newTestQueue(config.Scheduling{SupersedeQueued: true}, &now)
+
+	q.Push(TestJob{Branch: "feature/a", Commit: "11111111", PreviousCommit: "00000000"})
+	firstQueuedAt := now
+	now = now.Add(time.Minute)
+	q.Push(TestJob{Branch: "feature/a", Commit: "22222222", PreviousCommit: "11111111"})
+	q.Push(TestJob{Branch: "feature/a", Commit: "33333333", Trigger: TriggerManual})
+
+	listed := q.List()
+	if len(listed) != 2 {
+		t.Fatalf("List() returned %d jobs, want 2", len(listed))
+	}
+
+	for _, job := range listed {
+		if job.Trigger == TriggerCommit {
+			if job.Commit != "22222222" {
+				t.Errorf("Queued commit = %s, want the newest commit 22222222", job.Commit)
+			}
+			if !job.QueuedAt.Equal(firstQueuedAt) {
+				t.Errorf("Superseding job should keep the original queue time")
+			}
+			if job.PreviousCommit != "00000000" {
+				t.Errorf("PreviousCommit = %s, want 00000000 since 11111111 was never tested", job.PreviousCommit)
+			}
+		}
+	}
+}
+
+func TestJobQueueCapacityAndClose(t *testing.T) {
+	now := time.Now()
+	q := NewJobQueue(config.Scheduling{}, config.Resources{}, 1)
+	q.now = func() time.Time { return now }
+
+	if !q.Push(TestJob{Branch: "a", Commit: "12345678"}) {
+		t.Fatal("Push() should succeed on an empty queue")
+	}
+	if q.Push(TestJob{Branch: "b", Commit: "12345678"}) {
+		t.Error("Push() should fail when the queue is full")
+	}
+
+	done := make(chan bool)
+	q.Pop()
+	go func() {
+		_, ok := q.Pop()
+		done <- ok
+	}()
+
+	q.Close()
+	select {
+	case ok := <-done:
+		if ok {
+			t.Error("Pop() should return false once the queue is closed and empty")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Pop() did not return after Close()")
+	}
+
+	if q.Push(TestJob{Branch: "c", Commit: "12345678"}) {
+		t.Error("Push() should fail on a closed queue")
+	}
+}
+
+func TestJobQueuePopFirstSkipsBlockedJobs(t *testing.T) {
+	now := time.Now()
+	q := NewJobQueue(config.Scheduling{
+		Rules: []config.PriorityRule{{Branch: "main", Priority: 100}},
+	}, config.Resources{
+		Capacities: map[string]int{"kind-cluster": 1},
+		Requirements: []config.ResourceRequirement{
+			{Branch: "main", Resources: []string{"kind-cluster"}},
+		},
+	}, defaultQueueCapacity)
+	q.now = func() time.Time { return now }
+
+	q.Push(TestJob{Branch: "main", Commit: "aaaaaaaa"})
+	q.Push(TestJob{Branch: "feature/a", Commit: "bbbbbbbb"})
+
+	if listed := q.List(); len(listed[0].Resources) != 1 || listed[0].Resources[0] != "kind-cluster" {
+		t.Fatalf("expected main to require kind-cluster, got %v", listed[0].Resources)
+	}
+
+	// The cluster is busy: the lower priority job without requirements runs first
+	job, ok := q.PopFirst(func(j TestJob) bool { return len(j.Resources) == 0 }, nil, time.Millisecond)
+	if !ok || job.Branch != "feature/a" {
+		t.Fatalf("expected feature/a, got %q (ok=%v)", job.Branch, ok)
+	}
+
+	job, ok = q.PopFirst(func(TestJob) bool { return true }, nil, time.Millisecond)
+	if !ok || job.Branch != "main" {
+		t.Fatalf("expected main, got %q (ok=%v)", job.Branch, ok)
+	}
+}
+
+func TestJobQueuePopFirstProbesWithoutLock(t *testing.T) {
+	q := NewJobQueue(config.Scheduling{}, config.Resources{}, defaultQueueCapacity)
+	q.Push(TestJob{Branch: "main", Commit: "aaaaaaaa"})
+	q.Push(TestJob{Branch: "feature/a", Commit: "bbbbbbbb"})
+
+	// The queue stays usable while a job is probed, and a job removed meanwhile is released
+	var released []string
+	job, ok := q.PopFirst(func(j TestJob) bool {
+		if j.Branch == "main" {
+			q.Remove(func(queued TestJob) bool { return queued.Branch == "main" })
+		}
+		return q.Len() > 0
+	}, func(j TestJob) {
+		released = append(released, j.Branch)
+	}, 0)
+	if !ok || job.Branch != "feature/a" {
+		t.Fatalf("expected feature/a, got %q (ok=%v)", job.Branch, ok)
+	}
+	if len(released) != 1 || released[0] != "main" {
+		t.Errorf("released = %v, want [main]", released)
+	}
+	if q.Len() != 0 {
+		t.Errorf("expected an empty queue, got %d jobs", q.Len())
+	}
+}
+
+func TestJobQueuePopFirstContext(t *testing.T) {
+	q := NewJobQueue(config.Scheduling{}, config.Resources{}, defaultQueueCapacity)
+
+	// No job is accepted: the pop gives up with the context
+	q.Push(TestJob{Branch: "main", Commit: "aaaaaaaa"})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, ok := q.PopFirstContext(ctx, func(TestJob) bool { return false }, nil, 0); ok {
+		t.Fatal("PopFirstContext() should fail once the context is done")
+	}
+	if q.Len() != 1 {
+		t.Fatalf("the refused job must stay queued, got %d jobs", q.Len())
+	}
+
+	// A job pushed while waiting is returned
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		q.Push(TestJob{Branch: "feature/a", Commit: "bbbbbbbb"})
+	}()
+	job, ok := q.PopFirstContext(context.Background(), func(j TestJob) bool { return j.Branch == "feature/a" }, nil, 0)
+	if !ok || job.Branch != "feature/a" {
+		t.Fatalf("expected feature/a, got %q (ok=%v)", job.Branch, ok)
+	}
+}
@@ -0,0 +1,75 @@
+package runner
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/k8s-school/home-ci/internal/utils"
+)
+
+// loadRepoConfig applies the allowed settings of the pipeline file of the tested repository,
+// and logs the effective configuration of the run in run.log
+func (te *TestExecution) loadRepoConfig() error {
+	base := te.runner.config
+	path := filepath.Join(te.projectDir, base.RepoConfig.File)
+
+	fmt.Fprintf(te.logFile, "\n=== Repository Configuration ===\n")
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		fmt.Fprintf(te.logFile, "No %s found in the repository, using the daemon configuration\n", base.RepoConfig.File)
+		te.logEffectiveConfig()
+		return nil
+	}
+	if err != nil {
+		fmt.Fprintf(te.logFile, "Failed to read %s: %v\n", base.RepoConfig.File, err)
+		return fmt.Errorf("failed to read repository configuration %s: %w", base.RepoConfig.File, err)
+	}
+
+	merged, applied, ignored, err := base.ApplyRepoOverrides(data)
+	if err != nil {
+		fmt.Fprintf(te.logFile, "Invalid %s: %v\n", base.RepoConfig.File, err)
+		return fmt.Errorf("failed to load repository configuration %s: %w", base.RepoConfig.File, err)
+	}
+	te.config = &merged
+
+	fmt.Fprintf(te.logFile, "File: %s\n", base.RepoConfig.File)
+	fmt.Fprintf(te.logFile, "Applied keys: %s\n", formatKeys(applied))
+	if len(ignored) > 0 {
+		fmt.Fprintf(te.logFile, "Ignored keys (not in repo_config.allowed_keys): %s\n", formatKeys(ignored))
+		slog.Warn("Ignored settings of repository configuration",
+			"branch", te.branch,
+			"commit", utils.ShortCommit(te.commit),
+			"file", base.RepoConfig.File,
+			"keys", ignored)
+	}
+
+	te.logEffectiveConfig()
+	return nil
+}
+
+// logEffectiveConfig writes the configuration used by the run to run.log
+func (te *TestExecution) logEffectiveConfig() {
+	data, err := yaml.Marshal(te.cfg())
+	if err != nil {
+		fmt.Fprintf(te.logFile, "Failed to encode effective configuration: %v\n", err)
+		return
+	}
+
+	fmt.Fprintf(te.logFile, "--- Effective configuration ---\n")
+	te.logFile.Write(data)
+	fmt.Fprintf(te.logFile, "================================\n\n")
+}
+
+// formatKeys renders a list of configuration keys
+func formatKeys(keys []string) string {
+	if len(keys) == 0 {
+		return "none"
+	}
+	return strings.Join(keys, ", ")
+}
@@ -0,0 +1,63 @@
+package runner
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/k8s-school/home-ci/internal/config"
+)
+
+func TestLoadRepoConfig(t *testing.T) {
+	projectDir := t.TempDir()
+	logPath := filepath.Join(t.TempDir(), "run.log")
+	logFile, err := os.Create(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logFile.Close()
+
+	cfg := config.Config{
+		Repository: "https://gitlab.com/user/repo.git",
+		RepoName:   "repo",
+		WorkDir:    t.TempDir(),
+		Options:    "-c",
+		RepoConfig: config.RepoConfig{File: config.DefaultRepoConfigFile, AllowedKeys: []string{"options"}},
+	}
+	execution := &TestExecution{
+		runner:     &TestRunner{config: cfg},
+		projectDir: projectDir,
+		logFile:    newRunLog(logFile, nil),
+		testResult: &TestResult{},
+	}
+
+	// Without a repository file, the daemon configuration is used
+	if err := execution.loadRepoConfig(); err != nil {
+		t.Fatalf("loadRepoConfig() failed: %v", err)
+	}
+	if execution.cfg().Options != "-c" {
+		t.Errorf("Options = %q, want the daemon value", execution.cfg().Options)
+	}
+
+	content := "options: \"-c -m\"\ntest_script: /bin/false\n"
+	if err := os.WriteFile(filepath.Join(projectDir, config.DefaultRepoConfigFile), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := execution.loadRepoConfig(); err != nil {
+		t.Fatalf("loadRepoConfig() failed: %v", err)
+	}
+	if execution.cfg().Options != "-c -m" {
+		t.Errorf("Options = %q, want the repository value", execution.cfg().Options)
+	}
+
+	logContent, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{"Applied keys: options", "Ignored keys (not in repo_config.allowed_keys): test_script", "--- Effective configuration ---", "options: -c -m"} {
+		if !strings.Contains(string(logContent), expected) {
+			t.Errorf("run.log does not contain %q", expected)
+		}
+	}
+}
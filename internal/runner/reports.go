@@ -0,0 +1,81 @@
+package runner
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/k8s-school/home-ci/internal/artifacts"
+	"github.com/k8s-school/home-ci/internal/testreport"
+	"github.com/k8s-school/home-ci/internal/utils"
+)
+
+// parseTestReports reads the JUnit and TAP files of the project and records their test cases in the result
+func (te *TestExecution) parseTestReports() {
+	reports := te.cfg().TestReports
+	if len(reports.JUnit) == 0 && len(reports.TAP) == 0 {
+		return
+	}
+
+	fmt.Fprintf(te.logFile, "\n=== Parsing Test Reports ===\n")
+
+	summary := &testreport.Summary{}
+	te.parseReportFiles("JUnit", reports.JUnit, testreport.ParseJUnit, summary)
+	te.parseReportFiles("TAP", reports.TAP, testreport.ParseTAP, summary)
+
+	fmt.Fprintf(te.logFile, "Tests: %d total, %d passed, %d failed, %d skipped\n", summary.Total, summary.Passed, summary.Failed, summary.Skipped)
+	for _, failure := range summary.Failures() {
+		fmt.Fprintf(te.logFile, "  FAILED %s %s\n", failure.Suite, failure.Name)
+	}
+	fmt.Fprintf(te.logFile, "============================\n")
+
+	slog.Debug("Parsed test reports",
+		"branch", te.branch,
+		"commit", utils.ShortCommit(te.commit),
+		"total", summary.Total,
+		"failed", summary.Failed)
+
+	te.testResult.Tests = summary
+}
+
+// parseReportFiles parses the files matching the patterns with the given parser
+func (te *TestExecution) parseReportFiles(format string, patterns []string, parse func(io.Reader) ([]testreport.Case, error), summary *testreport.Summary) {
+	if len(patterns) == 0 {
+		return
+	}
+
+	files, err := artifacts.FindFiles(te.projectDir, patterns)
+	if err != nil {
+		fmt.Fprintf(te.logFile, "Failed to search %s reports: %v\n", format, err)
+	}
+	if len(files) == 0 {
+		fmt.Fprintf(te.logFile, "No %s report found for %v\n", format, patterns)
+		return
+	}
+
+	for _, rel := range files {
+		cases, err := parseReportFile(filepath.Join(te.projectDir, filepath.FromSlash(rel)), parse)
+		if err != nil {
+			fmt.Fprintf(te.logFile, "Failed to parse %s report %s: %v\n", format, rel, err)
+			slog.Warn("Failed to parse test report", "format", format, "file", rel, "error", err)
+			continue
+		}
+		for i := range cases {
+			cases[i].File = rel
+		}
+		summary.Add(cases...)
+		fmt.Fprintf(te.logFile, "%s report %s: %d test case(s)\n", format, rel, len(cases))
+	}
+}
+
+// parseReportFile opens and parses a single report file
+func parseReportFile(path string, parse func(io.Reader) ([]testreport.Case, error)) ([]testreport.Case, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return parse(file)
+}
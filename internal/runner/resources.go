@@ -0,0 +1,127 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// resourcePollInterval is how often waiting jobs check for resources released by other processes
+const resourcePollInterval = 5 * time.Second
+
+// ResourceLocks grants named resources to jobs.
+// Each resource has one lock file per slot, so that all home-ci daemons of the host
+// sharing the same lock directory respect the same capacities.
+type ResourceLocks struct {
+	dir        string
+	capacities map[string]int
+}
+
+// ResourceLease holds the resource slots acquired for a job
+type ResourceLease struct {
+	files []*os.File
+}
+
+// NewResourceLocks creates the resource locks stored in dir
+func NewResourceLocks(dir string, capacities map[string]int) *ResourceLocks {
+	return &ResourceLocks{dir: dir, capacities: capacities}
+}
+
+// TryAcquire takes one slot of every named resource without blocking.
+// It returns false, holding nothing, if one of the resources is fully used.
+func (rl *ResourceLocks) TryAcquire(names []string) (*ResourceLease, bool, error) {
+	lease := &ResourceLease{}
+	if len(names) == 0 {
+		return lease, true, nil
+	}
+
+	if err := os.MkdirAll(rl.dir, 0755); err != nil {
+		return nil, false, fmt.Errorf("failed to create resource lock directory: %w", err)
+	}
+
+	for _, name := range names {
+		file, err := rl.acquireSlot(name)
+		if err != nil {
+			lease.Release()
+			return nil, false, err
+		}
+		if file == nil {
+			lease.Release()
+			return nil, false, nil
+		}
+		lease.files = append(lease.files, file)
+	}
+
+	return lease, true, nil
+}
+
+// Acquire takes one slot of every named resource, waiting until they are all available
+func (rl *ResourceLocks) Acquire(ctx context.Context, names []string) (*ResourceLease, error) {
+	for {
+		lease, ok, err := rl.TryAcquire(names)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return lease, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(resourcePollInterval):
+		}
+	}
+}
+
+// acquireSlot locks the first free slot of a resource, it returns nil if all slots are taken
+func (rl *ResourceLocks) acquireSlot(name string) (*os.File, error) {
+	capacity, ok := rl.capacities[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown resource '%s'", name)
+	}
+
+	for i := 0; i < capacity; i++ {
+		path := filepath.Join(rl.dir, fmt.Sprintf("%s.%d.lock", name, i))
+		// flock only needs a read descriptor, so daemons of other users can lock the file
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_RDONLY, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open lock file for resource '%s': %w", name, err)
+		}
+
+		locked, err := tryLockFile(file)
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to lock resource '%s': %w", name, err)
+		}
+		if locked {
+			return file, nil
+		}
+		file.Close()
+	}
+
+	return nil, nil
+}
+
+// merge moves the slots held by other to the lease
+func (l *ResourceLease) merge(other *ResourceLease) {
+	if l == nil || other == nil {
+		return
+	}
+	l.files = append(l.files, other.files...)
+	other.files = nil
+}
+
+// Release frees all the resource slots held by the lease
+func (l *ResourceLease) Release() {
+	if l == nil {
+		return
+	}
+	for _, file := range l.files {
+		// Closing the file releases the lock
+		file.Close()
+	}
+	l.files = nil
+}
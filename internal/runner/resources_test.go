@@ -0,0 +1,87 @@
+package runner
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestResourceLocksCapacity(t *testing.T) {
+	dir := t.TempDir()
+	capacities := map[string]int{"kind-cluster": 1, "node": 2}
+
+	// Two lock instances on the same directory behave like two daemons
+	first := NewResourceLocks(dir, capacities)
+	second := NewResourceLocks(dir, capacities)
+
+	lease, ok, err := first.TryAcquire([]string{"kind-cluster", "node"})
+	if err != nil || !ok {
+		t.Fatalf("expected first acquisition to succeed, got ok=%v err=%v", ok, err)
+	}
+
+	if _, ok, err := second.TryAcquire([]string{"kind-cluster"}); err != nil || ok {
+		t.Fatalf("expected kind-cluster to be busy, got ok=%v err=%v", ok, err)
+	}
+
+	nodeLease, ok, err := second.TryAcquire([]string{"node"})
+	if err != nil || !ok {
+		t.Fatalf("expected second node slot to be free, got ok=%v err=%v", ok, err)
+	}
+
+	// A failed acquisition must not keep the slots it managed to take
+	if _, ok, _ := second.TryAcquire([]string{"node", "kind-cluster"}); ok {
+		t.Fatal("expected acquisition to fail while all node slots are used")
+	}
+
+	lease.Release()
+	nodeLease.Release()
+
+	lease, ok, err = second.TryAcquire([]string{"kind-cluster", "node"})
+	if err != nil || !ok {
+		t.Fatalf("expected resources to be free after release, got ok=%v err=%v", ok, err)
+	}
+	lease.Release()
+}
+
+func TestResourceLocksUnknownResource(t *testing.T) {
+	locks := NewResourceLocks(t.TempDir(), map[string]int{})
+	if _, _, err := locks.TryAcquire([]string{"gpu"}); err == nil {
+		t.Fatal("expected an error for an unknown resource")
+	}
+}
+
+func TestResourceLocksAcquireCancel(t *testing.T) {
+	locks := NewResourceLocks(t.TempDir(), map[string]int{"kind-cluster": 1})
+	lease, _, err := locks.TryAcquire([]string{"kind-cluster"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lease.Release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := locks.Acquire(ctx, []string{"kind-cluster"}); err == nil {
+		t.Fatal("expected Acquire to fail when the context is done")
+	}
+}
+
+func TestResourceLocksReadOnlyFile(t *testing.T) {
+	dir := t.TempDir()
+
+	// The lock file was created by a daemon of another user
+	path := filepath.Join(dir, "kind-cluster.0.lock")
+	if err := os.WriteFile(path, nil, 0444); err != nil {
+		t.Fatal(err)
+	}
+
+	lease, ok, err := NewResourceLocks(dir, map[string]int{"kind-cluster": 1}).TryAcquire([]string{"kind-cluster"})
+	if err != nil || !ok {
+		t.Fatalf("expected the read-only lock file to be locked, got ok=%v err=%v", ok, err)
+	}
+	defer lease.Release()
+	if _, err := lease.files[0].Write([]byte("x")); err == nil {
+		t.Error("the lock file must be opened read-only")
+	}
+}
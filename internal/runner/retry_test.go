@@ -0,0 +1,110 @@
+package runner
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/k8s-school/home-ci/internal/config"
+)
+
+// newRetryExecution creates a test execution writing its files in a temporary directory
+func newRetryExecution(t *testing.T, tr *TestRunner, attempt int) *TestExecution {
+	t.Helper()
+
+	execution := tr.newTestExecution("main", "0123456789abcdef")
+	execution.attempt = attempt
+	if err := execution.setupLogging(); err != nil {
+		t.Fatalf("setupLogging() failed: %v", err)
+	}
+	t.Cleanup(func() { execution.logFile.Close() })
+	return execution
+}
+
+func TestFinishAttemptQueuesRetry(t *testing.T) {
+	cfg := config.Config{
+		RepoName: "test-repo",
+		WorkDir:  t.TempDir(),
+		Retry:    config.Retry{MaxAttempts: 2, On: []string{config.RetryOnTimeout}},
+	}
+	tr := &TestRunner{
+		config:       cfg,
+		testQueue:    NewJobQueue(cfg.Scheduling, cfg.Resources, defaultQueueCapacity),
+		stateManager: &MockStateManager{},
+	}
+
+	// A plain failure is not configured for retries
+	execution := newRetryExecution(t, tr, 1)
+	execution.finishAttempt()
+	if execution.retryQueued || tr.testQueue.Len() != 0 {
+		t.Fatal("failure should not be retried when retry.on only contains timeout")
+	}
+	if execution.testResult.FailureReason != FailureReasonTest {
+		t.Errorf("FailureReason = %q, want %q", execution.testResult.FailureReason, FailureReasonTest)
+	}
+
+	// A timeout is retried once
+	execution = newRetryExecution(t, tr, 1)
+	execution.testResult.TimedOut = true
+	execution.finishAttempt()
+	if !execution.retryQueued {
+		t.Fatal("timeout should be retried")
+	}
+	job, ok := tr.testQueue.Pop()
+	if !ok || job.Trigger != TriggerRetry || job.Attempt != 2 {
+		t.Fatalf("unexpected retry job %+v", job)
+	}
+
+	// The last attempt is never retried
+	execution = newRetryExecution(t, tr, 2)
+	execution.testResult.TimedOut = true
+	execution.finishAttempt()
+	if execution.retryQueued {
+		t.Fatal("last attempt should not be retried")
+	}
+}
+
+func TestFlakyRunKeepsAllAttempts(t *testing.T) {
+	cfg := config.Config{
+		RepoName: "test-repo",
+		WorkDir:  t.TempDir(),
+		Retry:    config.Retry{MaxAttempts: 3, On: []string{config.RetryOnFailure}},
+	}
+	tr := &TestRunner{
+		config:       cfg,
+		testQueue:    NewJobQueue(cfg.Scheduling, cfg.Resources, defaultQueueCapacity),
+		stateManager: &MockStateManager{},
+	}
+
+	first := newRetryExecution(t, tr, 1)
+	first.testResult.ErrorMessage = "exit status 1"
+	first.finishAttempt()
+	first.saveTestResultForDispatch()
+
+	second := newRetryExecution(t, tr, 2)
+	second.testResult.Success = true
+	second.finishAttempt()
+	second.saveTestResultForDispatch()
+
+	data, err := os.ReadFile(second.resultFilePath)
+	if err != nil {
+		t.Fatalf("failed to read result file: %v", err)
+	}
+	var result TestResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		t.Fatalf("failed to parse result file: %v", err)
+	}
+
+	if !result.Flaky || result.Attempt != 2 || len(result.Attempts) != 2 {
+		t.Fatalf("expected a flaky result with 2 attempts, got flaky=%v attempt=%d attempts=%d", result.Flaky, result.Attempt, len(result.Attempts))
+	}
+	if result.Attempts[0].Success || result.Attempts[0].FailureReason != FailureReasonTest {
+		t.Errorf("unexpected first attempt %+v", result.Attempts[0])
+	}
+
+	// Both attempts write to the same log
+	if _, err := os.Stat(filepath.Join(filepath.Dir(second.resultFilePath), "run.log")); err != nil {
+		t.Errorf("expected run.log to exist: %v", err)
+	}
+}
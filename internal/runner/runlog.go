@@ -0,0 +1,383 @@
+package runner
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// StructuredLogFile is the name of the structured log written next to run.log
+const StructuredLogFile = "run.log.jsonl"
+
+// Streams of the structured log
+const (
+	StreamStdout = "stdout"  // Standard output of the commands
+	StreamStderr = "stderr"  // Standard error of the commands
+	StreamHomeCI = "home-ci" // Banners and messages of the runner
+	StreamAgent  = "agent"   // Log reported by the agent running a leased job
+)
+
+// LogEntry is a line of the structured log
+type LogEntry struct {
+	Time   time.Time `json:"time"`
+	Stream string    `json:"stream"`
+	Step   string    `json:"step,omitempty"`
+	Text   string    `json:"text"`
+	End    int64     `json:"end"` // Offset in run.log following the write that ended the line
+}
+
+// runLog writes run.log, and records each of its lines in the structured log
+// with the time it was written, its stream and the current step.
+//
+// With a cap, the first half of the cap is written as it comes. Past it, only
+// the last half is kept in a spool file, and written after a marker by flushTail.
+type runLog struct {
+	mu      sync.Mutex
+	file    *os.File
+	events  *os.File // Structured log, nil when not recorded
+	step    string
+	pending map[string][]byte // Start of a line not terminated yet, per stream
+
+	maxBytes    int64      // Cap of the log, 0 when unlimited
+	written     int64      // Bytes written to the head of the log
+	dropping    bool       // The head is full, the writes go to tail
+	tail        *tailSpool // Last writes, up to half of the cap, nil when they cannot be kept
+	tailWritten int64      // Bytes written to the tail
+	dropped     int64      // Bytes dropped between the head and the tail
+	lastByte    byte       // Last byte written to run.log
+	size        int64      // Size of run.log
+
+	// Offsets of the flushed tail, see rebase
+	headEnd    int64 // End of the head, followed by the offsets in the tail while dropping
+	droppedAt  int64 // Start of the marker of the dropped bytes
+	keptAt     int64 // Start of the kept tail
+	keptOffset int64 // Offset of the kept tail while dropping
+}
+
+// openRunLog opens run.log at path and the structured log next to it with the
+// same flags. The log is capped to maxBytes, unless 0.
+func openRunLog(path string, flags int, maxBytes int64) (*runLog, error) {
+	file, err := os.OpenFile(path, flags, 0644)
+	if err != nil {
+		return nil, err
+	}
+	events, err := os.OpenFile(filepath.Join(filepath.Dir(path), StructuredLogFile), flags, 0644)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	size, err := file.Seek(0, io.SeekEnd)
+	if err != nil {
+		file.Close()
+		events.Close()
+		return nil, err
+	}
+	log := newRunLog(file, events)
+	log.maxBytes = maxBytes
+	log.size = size
+	return log, nil
+}
+
+// newRunLog returns the run log writing to file, and to events when not nil
+func newRunLog(file, events *os.File) *runLog {
+	return &runLog{file: file, events: events, pending: make(map[string][]byte)}
+}
+
+// Write implements io.Writer, the lines are recorded in the home-ci stream
+func (l *runLog) Write(p []byte) (int, error) {
+	return l.write(StreamHomeCI, p)
+}
+
+// Stream returns a writer to the log recording its lines in the given stream
+func (l *runLog) Stream(stream string) io.Writer {
+	return streamWriter{log: l, stream: stream}
+}
+
+// streamWriter writes to a stream of the run log
+type streamWriter struct {
+	log    *runLog
+	stream string
+}
+
+// Write implements io.Writer
+func (w streamWriter) Write(p []byte) (int, error) {
+	return w.log.write(w.stream, p)
+}
+
+// write appends p to run.log, or to the tail once the head of a capped log is full
+func (l *runLog) write(stream string, p []byte) (int, error) {
+	if l == nil {
+		return 0, os.ErrInvalid
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	size := len(p)
+	if !l.dropping {
+		head := int64(len(p))
+		if l.maxBytes > 0 && l.written+head > l.maxBytes/2 {
+			head = l.maxBytes/2 - l.written
+		}
+		n, err := l.emit(now, stream, l.step, p[:head])
+		l.written += int64(n)
+		if err != nil || head == int64(len(p)) {
+			return n, err
+		}
+		l.startDropping(now)
+		p = p[head:]
+	}
+
+	// The output is dropped from now on when it cannot be kept, rather than failing the commands
+	l.tailWritten += int64(len(p))
+	if l.tail == nil {
+		l.dropped += int64(len(p))
+		return size, nil
+	}
+	dropped, err := l.tail.append(now, stream, l.step, p, l.maxBytes-l.maxBytes/2)
+	l.dropped += dropped
+	if err != nil {
+		slog.Error("Failed to keep the end of the log, it is dropped", "file", l.file.Name(), "error", err)
+		l.dropped += l.tail.bytes
+		l.closeTail()
+	}
+	return size, nil
+}
+
+// emit writes data to run.log and records its complete lines
+func (l *runLog) emit(t time.Time, stream, step string, data []byte) (int, error) {
+	n, err := l.file.Write(data)
+	l.size += int64(n)
+	if n > 0 {
+		l.lastByte = data[n-1]
+	}
+	if l.events == nil {
+		return n, err
+	}
+
+	line := append(l.pending[stream], data[:n]...)
+	for {
+		end := bytes.IndexByte(line, '\n')
+		if end < 0 {
+			break
+		}
+		l.record(t, stream, step, string(line[:end]))
+		line = line[end+1:]
+	}
+	l.pending[stream] = append([]byte(nil), line...)
+	return n, err
+}
+
+// record writes an entry to the structured log, its errors do not fail run.log
+func (l *runLog) record(t time.Time, stream, step, text string) {
+	data, err := json.Marshal(LogEntry{Time: t, Stream: stream, Step: step, Text: strings.TrimSuffix(text, "\r"), End: l.size})
+	if err != nil {
+		return
+	}
+	l.events.Write(append(data, '\n'))
+}
+
+// recordPending records the lines not terminated yet
+func (l *runLog) recordPending(t time.Time) {
+	if l.events == nil {
+		return
+	}
+	for _, stream := range []string{StreamHomeCI, StreamStdout, StreamStderr, StreamAgent} {
+		if data := l.pending[stream]; len(data) > 0 {
+			l.record(t, stream, l.step, string(data))
+		}
+	}
+	l.pending = make(map[string][]byte)
+}
+
+// marker writes a line of the runner to run.log, on a line of its own
+func (l *runLog) marker(t time.Time, format string, args ...any) {
+	l.recordPending(t)
+	text := fmt.Sprintf(format, args...)
+	if l.lastByte != 0 && l.lastByte != '\n' {
+		text = "\n" + text
+	}
+	l.emit(t, StreamHomeCI, l.step, []byte(text+"\n"))
+}
+
+// startDropping writes the marker of the start of the dropped output and
+// creates the spool of the tail next to run.log
+func (l *runLog) startDropping(t time.Time) {
+	l.dropping = true
+	l.marker(t, "=== Log over logs.max_size (%d bytes), only its end is kept from now on ===", l.maxBytes)
+	l.headEnd = l.size
+
+	tail, err := newTailSpool(filepath.Dir(l.file.Name()))
+	if err != nil {
+		slog.Error("Failed to keep the end of the log, it is dropped", "file", l.file.Name(), "error", err)
+		return
+	}
+	l.tail = tail
+}
+
+// closeTail removes the spool of the tail
+func (l *runLog) closeTail() {
+	if l.tail == nil {
+		return
+	}
+	if err := l.tail.Close(); err != nil {
+		slog.Debug("Failed to remove log spool", "error", err)
+	}
+	l.tail = nil
+}
+
+// flushTail writes the marker of the dropped bytes and the kept tail of a capped
+// log. The cap no longer applies to the next writes.
+func (l *runLog) flushTail() {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.maxBytes = 0
+	if !l.dropping {
+		return
+	}
+	l.dropping = false
+	l.droppedAt = l.size
+	l.marker(time.Now(), "=== %d bytes dropped ===", l.dropped)
+	l.keptAt = l.size
+	l.keptOffset = l.headEnd + l.dropped
+	if l.tail != nil {
+		err := l.tail.each(func(header spoolHeader, data []byte) {
+			l.emit(header.Time, header.Stream, header.Step, data)
+		})
+		if err != nil {
+			slog.Error("Failed to write the end of the log", "file", l.file.Name(), "error", err)
+		}
+		l.closeTail()
+	}
+}
+
+// rebase returns the offset in run.log of an offset returned by Offset before
+// flushTail. The offsets in the dropped output are moved to the marker of the
+// dropped bytes.
+func (l *runLog) rebase(offset int64) int64 {
+	if l == nil {
+		return offset
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	switch {
+	case l.headEnd == 0 || l.dropping || offset < l.headEnd:
+		return offset
+	case offset < l.keptOffset:
+		return l.droppedAt
+	default:
+		return l.keptAt + offset - l.keptOffset
+	}
+}
+
+// Dropping reports whether the output is over the cap, and only kept in the tail
+func (l *runLog) Dropping() bool {
+	if l == nil {
+		return false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.dropping
+}
+
+// SetStep sets the step of the lines written next, none when empty
+func (l *runLog) SetStep(name string) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.step = name
+}
+
+// Offset returns the write position in run.log. While the output is dropped, it
+// is the position the next write would have if the tail were written, and the
+// offset in run.log is given by rebase after flushTail.
+func (l *runLog) Offset() int64 {
+	if l == nil {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.dropping {
+		return l.headEnd + l.tailWritten
+	}
+	return l.size
+}
+
+// Name returns the path of run.log
+func (l *runLog) Name() string {
+	return l.file.Name()
+}
+
+// Close writes the tail of a capped log, records the lines not terminated and
+// closes the files
+func (l *runLog) Close() error {
+	l.flushTail()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.events != nil {
+		l.recordPending(time.Now())
+		l.events.Close()
+	}
+	return l.file.Close()
+}
+
+// compressLogs replaces run.log and the structured log with their gzip, once the run is over
+func (te *TestExecution) compressLogs() {
+	if te.logFile == nil {
+		return
+	}
+	structuredLogPath := filepath.Join(filepath.Dir(te.logFilePath), StructuredLogFile)
+	if err := gzipFile(structuredLogPath); err != nil {
+		slog.Error("Failed to compress structured log", "file", structuredLogPath, "error", err)
+	}
+	if err := gzipFile(te.logFilePath); err != nil {
+		slog.Error("Failed to compress run log", "file", te.logFilePath, "error", err)
+		return
+	}
+	te.logFilePath += ".gz"
+}
+
+// gzipFile compresses path to path.gz and removes it
+func gzipFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(dst)
+	if _, err := io.Copy(zw, src); err != nil {
+		dst.Close()
+		os.Remove(dst.Name())
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		dst.Close()
+		os.Remove(dst.Name())
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(dst.Name())
+		return err
+	}
+	return os.Remove(path)
+}
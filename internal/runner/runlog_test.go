@@ -0,0 +1,223 @@
+package runner
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunLog(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "run.log")
+	log, err := openRunLog(logPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0)
+	if err != nil {
+		t.Fatalf("openRunLog failed: %v", err)
+	}
+
+	fmt.Fprintf(log, "=== CI Test Run ===\n")
+	log.SetStep("test")
+	stdout := log.Stream(StreamStdout)
+	stdout.Write([]byte("compiling"))
+	log.Stream(StreamStderr).Write([]byte("warning\n"))
+	stdout.Write([]byte(" done\nlast"))
+	log.SetStep("")
+	if err := log.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	data, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "=== CI Test Run ===\ncompilingwarning\n done\nlast" {
+		t.Errorf("unexpected run.log %q", data)
+	}
+
+	file, err := os.Open(filepath.Join(dir, StructuredLogFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var entries []LogEntry
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry LogEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("invalid entry %q: %v", scanner.Text(), err)
+		}
+		if entry.Time.IsZero() {
+			t.Errorf("entry %q has no time", entry.Text)
+		}
+		entries = append(entries, entry)
+	}
+
+	want := []LogEntry{
+		{Stream: StreamHomeCI, Text: "=== CI Test Run ===", End: 20},
+		{Stream: StreamStderr, Step: "test", Text: "warning", End: 37},
+		{Stream: StreamStdout, Step: "test", Text: "compiling done", End: 47},
+		{Stream: StreamStdout, Text: "last", End: 47},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("got %d entries, want %d: %+v", len(entries), len(want), entries)
+	}
+	for i, entry := range entries {
+		if entry.Stream != want[i].Stream || entry.Step != want[i].Step || entry.Text != want[i].Text || entry.End != want[i].End {
+			t.Errorf("entry %d = %+v, want %+v", i, entry, want[i])
+		}
+	}
+}
+
+func TestRunLogCap(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "run.log")
+	log, err := openRunLog(logPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 20)
+	if err != nil {
+		t.Fatalf("openRunLog failed: %v", err)
+	}
+
+	stdout := log.Stream(StreamStdout)
+	stdout.Write([]byte("head\n"))
+	if log.Dropping() {
+		t.Fatal("log dropping before its cap")
+	}
+	headOffset := log.Offset()
+	var droppedOffset, keptOffset int64
+	for i := 0; i < 100; i++ {
+		switch i {
+		case 50:
+			droppedOffset = log.Offset()
+		case 99:
+			keptOffset = log.Offset()
+		}
+		fmt.Fprintf(stdout, "line-%02d\n", i)
+	}
+	if !log.Dropping() {
+		t.Fatal("log not dropping past its cap")
+	}
+	if spools, _ := filepath.Glob(filepath.Join(dir, ".run.log.tail-*")); len(spools) != 1 {
+		t.Errorf("the tail must be kept in a spool file, got %v", spools)
+	}
+
+	// The head and the tail are both 10 bytes
+	log.flushTail()
+	fmt.Fprintf(log, "=== Result ===\n")
+	if err := log.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	data, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "head\nline-\n" +
+		"=== Log over logs.max_size (20 bytes), only its end is kept from now on ===\n" +
+		"=== 785 bytes dropped ===\n" +
+		"8\nline-99\n" +
+		"=== Result ===\n"
+	if string(data) != want {
+		t.Errorf("run.log = %q, want %q", data, want)
+	}
+	if spools, _ := filepath.Glob(filepath.Join(dir, ".run.log.tail-*")); len(spools) != 0 {
+		t.Errorf("spool files left: %v", spools)
+	}
+
+	// The offsets taken while dropping are moved to the kept tail, or to the marker of the dropped bytes
+	if got := log.rebase(headOffset); got != 5 {
+		t.Errorf("rebase(%d) = %d, want 5", headOffset, got)
+	}
+	if got := log.rebase(droppedOffset); !strings.HasPrefix(string(data[got:]), "=== 785 bytes dropped ===") {
+		t.Errorf("rebase(%d) = %d, not at the marker of the dropped bytes", droppedOffset, got)
+	}
+	if got := log.rebase(keptOffset); !strings.HasPrefix(string(data[got:]), "line-99\n") {
+		t.Errorf("rebase(%d) = %d, not at the kept line", keptOffset, got)
+	}
+
+	events, err := os.ReadFile(filepath.Join(dir, StructuredLogFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(events), `"stream":"stdout","text":"line-99"`) || strings.Contains(string(events), "line-50") {
+		t.Errorf("unexpected structured log %s", events)
+	}
+}
+
+func TestCompressLogs(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "run.log")
+	log, err := openRunLog(logPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0)
+	if err != nil {
+		t.Fatalf("openRunLog failed: %v", err)
+	}
+	fmt.Fprintf(log, "=== CI Test Run ===\n")
+	log.Close()
+
+	execution := &TestExecution{logFilePath: logPath, logFile: log}
+	execution.compressLogs()
+	if execution.logFilePath != logPath+".gz" {
+		t.Errorf("logFilePath = %s, want %s.gz", execution.logFilePath, logPath)
+	}
+	for _, name := range []string{"run.log", StructuredLogFile} {
+		if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+			t.Errorf("%s not removed: %v", name, err)
+		}
+		file, err := os.Open(filepath.Join(dir, name+".gz"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer file.Close()
+		zr, err := gzip.NewReader(file)
+		if err != nil {
+			t.Fatalf("%s.gz: %v", name, err)
+		}
+		data, err := io.ReadAll(zr)
+		if err != nil || !strings.Contains(string(data), "=== CI Test Run ===") {
+			t.Errorf("%s.gz = %q, %v", name, data, err)
+		}
+	}
+}
+
+func TestRunLogNil(t *testing.T) {
+	var log *runLog
+	if _, err := fmt.Fprintf(log, "banner\n"); err == nil {
+		t.Error("expected an error writing to a nil run log")
+	}
+	log.SetStep("test")
+}
+
+func TestCreateArtifactsMapStructuredLog(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "run.log")
+	if err := os.WriteFile(logPath, []byte("log\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	entries := `{"time":"2024-10-15T12:00:00Z","stream":"home-ci","text":"=== CI Test Run ==="}` + "\n" +
+		`{"time":"2024-10-15T12:00:01Z","stream":"stdout","step":"test","text":"ok"}` + "\n"
+	if err := os.WriteFile(filepath.Join(dir, StructuredLogFile), []byte(entries), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The byte limit truncates the structured log
+	artifacts, _, err := createArtifactsMap("main", "abc123", true, logPath, "", false, 90, 100, nil)
+	if err != nil {
+		t.Fatalf("createArtifactsMap failed: %v", err)
+	}
+	archive := artifacts["combined-archive.tar.gz"].(Artifact)
+	found := false
+	for _, file := range archive.Files {
+		if file.Name == StructuredLogFile {
+			found = true
+			if file.Type != "structured-log" || !file.Truncated {
+				t.Errorf("unexpected archive entry %+v", file)
+			}
+		}
+	}
+	if !found {
+		t.Fatalf("%s not in archive: %+v", StructuredLogFile, archive.Files)
+	}
+}
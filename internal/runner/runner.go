@@ -7,15 +7,21 @@ import (
 	"io"
 	"log/slog"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/k8s-school/home-ci/internal/artifacts"
+	"github.com/k8s-school/home-ci/internal/cgroup"
 	"github.com/k8s-school/home-ci/internal/config"
+	"github.com/k8s-school/home-ci/internal/e2ereport"
+	"github.com/k8s-school/home-ci/internal/gitrepo"
+	"github.com/k8s-school/home-ci/internal/secrets"
+	"github.com/k8s-school/home-ci/internal/testreport"
 	"github.com/k8s-school/home-ci/internal/utils"
 )
 
@@ -24,45 +30,91 @@ type StateManager interface {
 	AddRunningTest(test RunningTest)
 	RemoveRunningTest(branch, commit string)
 	GetRunningTests() []RunningTest
+	SetQueuedJobs(jobs []QueuedJob)
+	GetQueuedJobs() []QueuedJob
 	CleanupOldRunningTests(maxAge time.Duration)
 	SaveState() error
 	// Additional methods needed by monitor
 	GetBranchState(branch string) *BranchState
 	UpdateBranchState(branch, commit string)
+	RecordBranchResult(branch string, result BranchResult)
+	SetPollStatus(at time.Time, err error)
 	LoadState() error
 }
 
 // BranchState represents the state of a branch
 type BranchState struct {
-	LatestCommit string `json:"latest_commit"`
+	LatestCommit string        `json:"latest_commit"`
+	LastResult   *BranchResult `json:"last_result,omitempty"`
+}
+
+// BranchResult is the outcome of the last finished run of a branch
+type BranchResult struct {
+	Commit        string    `json:"commit"`
+	Success       bool      `json:"success"`
+	Flaky         bool      `json:"flaky,omitempty"`
+	FailureReason string    `json:"failure_reason,omitempty"`
+	Attempts      int       `json:"attempts,omitempty"`
+	EndTime       time.Time `json:"end_time"`
 }
 
 // RunningTest represents a test that is currently running
 type RunningTest struct {
-	Branch    string    `json:"branch"`
-	Commit    string    `json:"commit"`
-	LogFile   string    `json:"log_file"`
-	StartTime time.Time `json:"start_time"`
-	PID       int       `json:"pid,omitempty"`
+	Branch    string        `json:"branch"`
+	Commit    string        `json:"commit"`
+	LogFile   string        `json:"log_file"`
+	StartTime time.Time     `json:"start_time"`
+	Timeout   time.Duration `json:"timeout,omitempty"`
+	PID       int           `json:"pid,omitempty"`
 }
 
 // TestResult represents the complete result of a test execution
 type TestResult struct {
-	Branch                    string        `json:"branch"`
-	Commit                    string        `json:"commit"`
-	LogFile                   string        `json:"log_file"`
-	StartTime                 time.Time     `json:"start_time"`
-	EndTime                   time.Time     `json:"end_time"`
-	Duration                  time.Duration `json:"duration"`
-	Success                   bool          `json:"success"`
-	TimedOut                  bool          `json:"timed_out"`
-	CleanupExecuted           bool          `json:"cleanup_executed"`
-	CleanupSuccess            bool          `json:"cleanup_success"`
-	GitHubActionsNotified     bool          `json:"github_actions_notified"`
-	GitHubActionsSuccess      bool          `json:"github_actions_success"`
-	ErrorMessage              string        `json:"error_message,omitempty"`
-	CleanupErrorMessage       string        `json:"cleanup_error_message,omitempty"`
-	GitHubActionsErrorMessage string        `json:"github_actions_error_message,omitempty"`
+	Branch                    string              `json:"branch"`
+	Commit                    string              `json:"commit"`
+	LogFile                   string              `json:"log_file"`
+	StartTime                 time.Time           `json:"start_time"`
+	EndTime                   time.Time           `json:"end_time"`
+	Duration                  time.Duration       `json:"duration"`
+	Success                   bool                `json:"success"`
+	TimedOut                  bool                `json:"timed_out"`
+	CleanupExecuted           bool                `json:"cleanup_executed"`
+	CleanupSuccess            bool                `json:"cleanup_success"`
+	GitHubActionsNotified     bool                `json:"github_actions_notified"`
+	GitHubActionsSuccess      bool                `json:"github_actions_success"`
+	ErrorMessage              string              `json:"error_message,omitempty"`
+	CleanupErrorMessage       string              `json:"cleanup_error_message,omitempty"`
+	GitHubActionsErrorMessage string              `json:"github_actions_error_message,omitempty"`
+	Attempt                   int                 `json:"attempt"`
+	FailureReason             string              `json:"failure_reason,omitempty"`
+	Flaky                     bool                `json:"flaky"`              // Passed after at least one failed attempt
+	Attempts                  []AttemptResult     `json:"attempts,omitempty"` // All attempts of the run, including this one
+	Steps                     []StepResult        `json:"steps,omitempty"`
+	FailedStep                string              `json:"failed_step,omitempty"` // First step that failed the run
+	Artifacts                 []artifacts.File    `json:"artifacts,omitempty"`   // Files copied to the artifact store
+	Tests                     *testreport.Summary `json:"tests,omitempty"`       // Test cases of the JUnit and TAP reports
+	Report                    *e2ereport.Report   `json:"report,omitempty"`      // Parsed e2e-report.yaml
+	ReportError               string              `json:"report_error,omitempty"`
+}
+
+// Failure reasons recorded in TestResult, they match the retry.on values
+const (
+	FailureReasonTest    = config.RetryOnFailure
+	FailureReasonTimeout = config.RetryOnTimeout
+	FailureReasonSetup   = config.RetryOnSetupError
+	FailureReasonOOM     = config.RetryOnOOM
+)
+
+// AttemptResult is the outcome of one attempt of a run
+type AttemptResult struct {
+	Attempt       int           `json:"attempt"`
+	StartTime     time.Time     `json:"start_time"`
+	EndTime       time.Time     `json:"end_time"`
+	Duration      time.Duration `json:"duration"`
+	Success       bool          `json:"success"`
+	FailureReason string        `json:"failure_reason,omitempty"`
+	FailedStep    string        `json:"failed_step,omitempty"`
+	ErrorMessage  string        `json:"error_message,omitempty"`
 }
 
 // TestRunner manages test execution and coordination
@@ -70,60 +122,151 @@ type TestRunner struct {
 	config       config.Config
 	configPath   string // Path to the config file for resolving relative paths
 	logDir       string
-	testQueue    chan TestJob
+	testQueue    *JobQueue
 	ctx          context.Context
-	semaphore    chan struct{} // Semaphore to limit concurrency
-	stateManager StateManager  // State manager for tracking running tests
+	semaphore    chan struct{}   // Semaphore to limit concurrency
+	stateManager StateManager    // State manager for tracking running tests
+	mirror       *gitrepo.Mirror // Local mirror used to create workspaces
+	resources    *ResourceLocks  // Named resources shared with other jobs and daemons
+
+	runsMu sync.Mutex
+	runs   map[string]*activeRun // Runs started by the scheduler, by run ID
+
+	cgroupsOnce sync.Once
+	cgroups     *cgroup.Manager // Creates the cgroups enforcing the resource limits
+	cgroupsErr  error
 }
 
 // TestExecution encapsulates a single test execution context
 type TestExecution struct {
-	runner                   *TestRunner
-	branch                   string
-	commit                   string
+	runner                    *TestRunner
+	branch                    string
+	commit                    string
 	commitExplicitlySpecified bool
-	startTime                time.Time
-	logFilePath              string
-	resultFilePath           string
-	workspaceDir             string // Root workspace directory for this test
-	projectDir               string // Project directory within workspace
-	testResult               *TestResult
-	logFile                  *os.File
+	startTime                 time.Time
+	logFilePath               string
+	resultFilePath            string
+	workspaceDir              string // Root workspace directory for this test
+	projectDir                string // Project directory within workspace
+	testResult                *TestResult
+	logFile                   *runLog
+	attempt                   int             // Attempt number of this execution, starts at 1
+	retryQueued               bool            // A new attempt of the run has been queued
+	config                    *config.Config  // Configuration of the run with the repository overrides, nil until loaded
+	secretEnv                 []string        // KEY=value entries of the secrets
+	artifactsDir              string          // Directory exported as HOME_CI_ARTIFACTS_DIR
+	previousCommit            string          // Commit tested before on the branch, empty when unknown
+	trigger                   string          // Why the run was queued
+	cgroup                    *cgroup.Group   // Enforces the resource limits, nil without limits
+	masker                    *secrets.Masker // Masks the secret values in the command output, nil without secrets
+	executor                  Executor        // Runs the test commands, selected once the repository configuration is loaded
+	worker                    *config.Worker  // Worker leased for the ssh executor, nil otherwise
+	leaseLog                  io.Writer       // Receives the log of a job leased from a coordinator, nil otherwise
+	leaseFiles                io.Writer       // Receives the files of a job leased from a coordinator, nil otherwise
+	logTail                   *logTail        // Copies run.log to leaseLog
+	ctx                       context.Context // Cancelled when a user cancels the run, nil for manual runs
+}
+
+// cfg returns the configuration of the run, the repository file overrides are
+// only included once the workspace is set up
+func (te *TestExecution) cfg() *config.Config {
+	if te.config != nil {
+		return te.config
+	}
+	return &te.runner.config
 }
 
 // NewTestRunner creates a new test runner instance
-func NewTestRunner(cfg config.Config, configPath, logDir string, ctx context.Context, stateManager StateManager) *TestRunner {
+func NewTestRunner(cfg config.Config, configPath, logDir string, ctx context.Context, stateManager StateManager) (*TestRunner, error) {
+	auth, err := gitrepo.AuthForConfig(cfg, configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure git authentication: %w", err)
+	}
+
 	return &TestRunner{
 		config:       cfg,
 		configPath:   configPath,
 		logDir:       logDir,
-		testQueue:    make(chan TestJob, 100),
+		testQueue:    NewJobQueue(cfg.Scheduling, cfg.Resources, defaultQueueCapacity),
 		ctx:          ctx,
 		semaphore:    make(chan struct{}, cfg.MaxConcurrentRuns),
 		stateManager: stateManager,
-	}
+		mirror:       gitrepo.NewMirror(cfg.Repository, cfg.GetCacheDir(), auth),
+		resources:    NewResourceLocks(cfg.Resources.LockDir, resourceCapacities(cfg)),
+	}, nil
 }
 
 // Start begins processing test jobs from the queue
 func (tr *TestRunner) Start() {
 	slog.Debug("Starting test runner", "max_concurrent_runs", tr.config.MaxConcurrentRuns)
 
-	for job := range tr.testQueue {
-		// Acquire semaphore BEFORE launching goroutine to respect concurrency limit
+	for {
+		// Acquire semaphore BEFORE popping, so that the job with the highest priority
+		// at the time a slot frees up is the one launched
 		tr.semaphore <- struct{}{}
-		go func(j TestJob) {
+
+		// Take the first job, in priority order, whose resources and worker are all available
+		var lease *ResourceLease
+		var worker string
+		job, ok := tr.testQueue.PopFirst(func(j TestJob) bool {
+			var acquired bool
+			lease, acquired = tr.tryAcquireResources(j)
+			if !acquired {
+				return false
+			}
+			var workerLease *ResourceLease
+			worker, workerLease, acquired = tr.tryAcquireWorker(j.Branch)
+			if !acquired {
+				lease.Release()
+				return false
+			}
+			lease.merge(workerLease)
+
+			// A job of a commit being tested waits for the end of the run using its workspace
+			if !tr.reserveRun(j, false) {
+				lease.Release()
+				return false
+			}
+			return true
+		}, func(j TestJob) {
+			lease.Release()
+			tr.releaseRun(j.Branch, j.Commit)
+		}, resourcePollInterval)
+		if !ok {
+			<-tr.semaphore
+			return
+		}
+		job.Worker = worker
+		tr.publishQueue()
+
+		slog.Debug("Dequeued test job", "branch", job.Branch, "commit", utils.ShortCommit(job.Commit), "trigger", job.Trigger, "priority", job.Priority, "resources", job.Resources, "worker", job.Worker, "waited", time.Since(job.QueuedAt).Round(time.Second))
+		go func(j TestJob, l *ResourceLease) {
 			defer func() { <-tr.semaphore }() // Release when done
+			defer l.Release()
 			tr.executeTestJobWithoutSemaphore(j)
-		}(job)
+		}(job, lease)
 	}
 }
 
+// tryAcquireResources takes the resources needed by a job without blocking
+func (tr *TestRunner) tryAcquireResources(job TestJob) (*ResourceLease, bool) {
+	if tr.resources == nil {
+		return nil, true
+	}
+	lease, ok, err := tr.resources.TryAcquire(job.Resources)
+	if err != nil {
+		slog.Error("Failed to acquire resources", "branch", job.Branch, "resources", job.Resources, "error", err)
+		return nil, false
+	}
+	return lease, ok
+}
+
 // executeTestJobWithoutSemaphore handles test execution without semaphore management
 // The semaphore is expected to be managed by the caller
 func (tr *TestRunner) executeTestJobWithoutSemaphore(job TestJob) {
-	slog.Debug("Starting tests", "branch", job.Branch, "commit", utils.ShortCommit(job.Commit))
+	slog.Debug("Starting tests", "branch", job.Branch, "commit", utils.ShortCommit(job.Commit), "attempt", job.Attempt)
 
-	if err := tr.runTests(job.Branch, job.Commit); err != nil {
+	if err := tr.runTests(job); err != nil {
 		slog.Debug("Tests failed", "branch", job.Branch, "error", err)
 	} else {
 		slog.Debug("Tests completed successfully", "branch", job.Branch)
@@ -132,52 +275,158 @@ func (tr *TestRunner) executeTestJobWithoutSemaphore(job TestJob) {
 
 // QueueTestJob adds a test job to the processing queue
 func (tr *TestRunner) QueueTestJob(job TestJob) bool {
-	select {
-	case tr.testQueue <- job:
-		return true
-	default:
+	if !tr.testQueue.Push(job) {
 		return false
 	}
+	tr.publishQueue()
+	return true
+}
+
+// GetQueuedJobs returns the waiting jobs in the order they will run
+func (tr *TestRunner) GetQueuedJobs() []QueuedJob {
+	return tr.testQueue.List()
+}
+
+// publishQueue stores a snapshot of the queue in the state, so that it is visible from the CLI
+func (tr *TestRunner) publishQueue() {
+	if tr.stateManager == nil {
+		return
+	}
+	tr.stateManager.SetQueuedJobs(tr.testQueue.List())
+	if err := tr.stateManager.SaveState(); err != nil {
+		slog.Debug("Failed to save queue state", "error", err)
+	}
 }
 
 // Close shuts down the test runner
 func (tr *TestRunner) Close() {
-	close(tr.testQueue)
+	tr.testQueue.Close()
 }
 
 // runTests orchestrates the execution of a single test
-func (tr *TestRunner) runTests(branch, commit string) error {
-	slog.Debug("Running tests", "branch", branch, "commit", utils.ShortCommit(commit), "timeout", tr.config.TestTimeout)
+func (tr *TestRunner) runTests(job TestJob) error {
+	_, err := tr.runJob(job, nil, nil)
+	return err
+}
+
+// RunLeasedJob runs a job leased from a coordinator, copying its log to leaseLog while
+// it runs. The files the coordinator needs after the run, the report, the test reports
+// and the artifacts, are written to leaseFiles as a tar archive. The coordinator
+// records the attempt, queues the retries and notifies.
+func (tr *TestRunner) RunLeasedJob(job TestJob, leaseLog, leaseFiles io.Writer) TestResult {
+	// The jobs of the ssh executor run on a worker of the agent
+	worker, workerLease, err := tr.acquireWorker(tr.ctx, job.Branch)
+	if err != nil {
+		return TestResult{Branch: job.Branch, Commit: job.Commit, Attempt: job.Attempt, StartTime: time.Now(), EndTime: time.Now(),
+			FailureReason: FailureReasonSetup, ErrorMessage: fmt.Sprintf("failed to acquire a worker: %v", err)}
+	}
+	defer workerLease.Release()
+	if worker != nil {
+		job.Worker = worker.Name
+	}
+
+	result, err := tr.runJob(job, leaseLog, leaseFiles)
+	if err != nil && result.ErrorMessage == "" {
+		result.Attempt = job.Attempt
+		result.FailureReason = FailureReasonSetup
+		result.ErrorMessage = err.Error()
+	}
+	return *result
+}
+
+// newJobExecution creates the execution context of a queued job
+func (tr *TestRunner) newJobExecution(job TestJob) *TestExecution {
+	execution := tr.newTestExecution(job.Branch, job.Commit)
+	if job.Attempt > 1 {
+		execution.attempt = job.Attempt
+	}
+	if job.Trigger != "" {
+		execution.trigger = job.Trigger
+	}
+	execution.previousCommit = job.PreviousCommit
+	execution.worker = tr.workerByName(job.Worker)
+	execution.ctx = tr.runContext(job.Branch, job.Commit)
+	return execution
+}
+
+// runJob runs an attempt of a job and returns its result. The log of a job leased
+// from a coordinator is copied to leaseLog, and its files written to leaseFiles.
+func (tr *TestRunner) runJob(job TestJob, leaseLog, leaseFiles io.Writer) (*TestResult, error) {
+	slog.Debug("Running tests", "branch", job.Branch, "commit", utils.ShortCommit(job.Commit), "timeout", tr.config.TestTimeout)
 
 	// Initialize test execution context
-	execution := tr.newTestExecution(branch, commit)
+	execution := tr.newJobExecution(job)
+	execution.leaseLog = leaseLog
+	execution.leaseFiles = leaseFiles
 	defer execution.cleanup()
 
 	// Setup logging and state management
 	if err := execution.setupLogging(); err != nil {
-		return err
+		return execution.testResult, err
 	}
 
 	if err := execution.registerRunningTest(); err != nil {
-		return err
+		return execution.testResult, err
 	}
 
-	// Setup repository
-	if err := execution.setupRepository(); err != nil {
-		return err
+	// Load the secrets, then setup repository and apply its pipeline file
+	err := execution.loadSecrets()
+	if err == nil {
+		err = execution.setupRepository()
+	}
+	if err == nil {
+		err = execution.loadRepoConfig()
+	}
+	if err == nil {
+		err = execution.setupSandbox()
+	}
+	if err == nil {
+		err = execution.setupExecutor()
+	}
+	if err == nil {
+		err = execution.setupLimits()
+	}
+	if err == nil && execution.cancelled() {
+		err = fmt.Errorf("run cancelled")
+	}
+	if err != nil {
+		execution.testResult.FailureReason = FailureReasonSetup
+		execution.testResult.ErrorMessage = err.Error()
+		if execution.cancelled() {
+			execution.markCancelled()
+		}
+		execution.finishAttempt()
+		execution.saveTestResultForDispatch()
+		return execution.testResult, err
 	}
 
 	// Execute the test
 	if err := execution.executeTest(); err != nil {
 		execution.testResult.ErrorMessage = err.Error()
 	}
+	if execution.cancelled() {
+		execution.markCancelled()
+	}
+	execution.downloadResults()
+	execution.importSandboxReport()
+	if execution.leaseLog == nil {
+		// The coordinator reconciles the report of a leased job, with its own report policy
+		execution.reconcileReport()
+	}
 
-	// Post-execution tasks
+	// Post-execution tasks, the cleanup script may remove the reports and artifacts
+	execution.parseTestReports()
+	execution.collectArtifacts()
+	execution.writeLeaseFiles()
 	execution.runCleanupIfNeeded()
+	execution.finishAttempt()
 	execution.saveTestResultForDispatch()
-	execution.sendGitHubNotificationIfNeeded()
+	if !execution.retryQueued && execution.leaseLog == nil {
+		// Only the last attempt of a run is notified
+		execution.sendGitHubNotificationIfNeeded()
+	}
 
-	return nil
+	return execution.testResult, nil
 }
 
 // newTestExecution creates a new test execution context
@@ -194,15 +443,18 @@ func (tr *TestRunner) newTestExecution(branch, commit string) *TestExecution {
 	resultFileName := "run.json"
 
 	return &TestExecution{
-		runner:                   tr,
-		branch:                   branch,
-		commit:                   commit,
+		runner:                    tr,
+		branch:                    branch,
+		commit:                    commit,
 		commitExplicitlySpecified: false, // Default false for non-manual runs
-		startTime:                startTime,
-		logFilePath:              filepath.Join(logsDir, logFileName),
-		resultFilePath:           filepath.Join(logsDir, resultFileName),
-		workspaceDir:             workspaceDir,
-		projectDir:               projectDir,
+		startTime:                 startTime,
+		logFilePath:               filepath.Join(logsDir, logFileName),
+		resultFilePath:            filepath.Join(logsDir, resultFileName),
+		workspaceDir:              workspaceDir,
+		projectDir:                projectDir,
+		artifactsDir:              tr.config.GetRunArtifactsDir(branch, commit),
+		attempt:                   1,
+		trigger:                   TriggerCommit,
 		testResult: &TestResult{
 			Branch:    branch,
 			Commit:    commit,
@@ -218,10 +470,28 @@ func (te *TestExecution) cleanup() {
 	te.testResult.EndTime = time.Now()
 	te.testResult.Duration = te.testResult.EndTime.Sub(te.testResult.StartTime)
 
+	// Kill the processes left by the commands
+	te.removeCgroup()
+	te.cleanupExecutor()
+
+	// The run ID is free for the next job of the commit once the workspace is handled
+	defer te.runner.releaseRun(te.branch, te.commit)
+
 	// Close log file if open
 	if te.logFile != nil {
 		te.logFile.Close()
 	}
+	if te.logTail != nil {
+		te.logTail.Stop()
+	}
+
+	// The next attempt of the run appends to the log, it is compressed after the last one
+	if te.cfg().Logs.Compress && !te.retryQueued {
+		te.compressLogs()
+	}
+
+	// Keep the logs in the artifact store before the workspace is removed
+	te.storeRunLogs()
 
 	// Remove from state if state manager is available
 	if te.runner.stateManager != nil {
@@ -230,8 +500,8 @@ func (te *TestExecution) cleanup() {
 	}
 
 	// Clean up workspace directory if immediate cleanup is needed,
-	// but skip cleanup for manual runs (no state manager) and just inform user
-	if te.runner.stateManager == nil {
+	// but skip cleanup for manual runs and just inform user
+	if te.manualRun() {
 		// Manual run - just inform user about created files
 		fmt.Printf("\n=== Manual Test Run Completed ===\n")
 		fmt.Printf("Created files (you can clean up manually):\n")
@@ -239,12 +509,17 @@ func (te *TestExecution) cleanup() {
 		fmt.Printf("- Log file: %s\n", te.logFilePath)
 		fmt.Printf("- Result file: %s\n", te.resultFilePath)
 		fmt.Printf("===================================\n")
-	} else if te.runner.config.KeepTime == 0 && te.workspaceDir != "" {
+	} else if te.cfg().KeepTime == 0 && te.workspaceDir != "" && !te.retryQueued {
 		// Automated run with immediate cleanup
 		os.RemoveAll(te.workspaceDir)
 	}
 }
 
+// manualRun reports whether the run was started by the run command, outside of the daemon and the agents
+func (te *TestExecution) manualRun() bool {
+	return te.runner.stateManager == nil && te.leaseLog == nil
+}
+
 // setupLogging creates and configures the log file
 func (te *TestExecution) setupLogging() error {
 	// Ensure logs directory structure exists (both log and result files go in same directory now)
@@ -253,12 +528,32 @@ func (te *TestExecution) setupLogging() error {
 		return fmt.Errorf("failed to create logs directory %s: %w", logsDir, err)
 	}
 
-	logFile, err := os.Create(te.logFilePath)
+	// Later attempts of a run append to the log of the previous ones, the coordinator
+	// keeps the log of the previous attempts of a leased job
+	appendLog := te.attempt > 1 && te.leaseLog == nil
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if appendLog {
+		flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	}
+	logFile, err := openRunLog(te.logFilePath, flags, te.cfg().Logs.MaxBytes())
 	if err != nil {
 		return fmt.Errorf("failed to create log file %s: %w", te.logFilePath, err)
 	}
 	te.logFile = logFile
 
+	if te.leaseLog != nil {
+		if te.logTail, err = startLogTail(te.logFilePath, te.leaseLog); err != nil {
+			return err
+		}
+	}
+
+	if appendLog {
+		te.loadPreviousAttempts()
+		fmt.Fprintf(te.logFile, "\n=== Attempt %d/%d ===\n", te.attempt, te.cfg().Retry.MaxAttempts)
+		fmt.Fprintf(te.logFile, "Timestamp: %s\n", time.Now().Format(time.RFC3339))
+		fmt.Fprintf(te.logFile, "=====================\n\n")
+	}
+
 	slog.Debug("Test output will be logged", "log_file", te.logFilePath)
 	return nil
 }
@@ -274,27 +569,54 @@ func (te *TestExecution) registerRunningTest() error {
 		Commit:    te.commit,
 		LogFile:   filepath.Base(te.logFilePath),
 		StartTime: te.startTime,
+		Timeout:   te.cfg().TestTimeout,
 	}
 
 	te.runner.stateManager.AddRunningTest(runningTest)
 	return te.runner.stateManager.SaveState()
 }
 
-// setupRepository clones and prepares the repository for testing (simplified - no cache)
+// resetAttemptFiles removes the report and the artifacts of a previous attempt: every
+// attempt starts with an empty HOME_CI_ARTIFACTS_DIR, and stores only its own artifacts
+func (te *TestExecution) resetAttemptFiles() error {
+	if err := os.RemoveAll(te.artifactsDir); err != nil {
+		return fmt.Errorf("failed to clean artifacts directory: %w", err)
+	}
+	if err := te.artifactStore().RemoveRun(te.cfg().GetRunID(te.branch, te.commit)); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(te.artifactsDir, 0755); err != nil {
+		return fmt.Errorf("failed to create artifacts directory: %w", err)
+	}
+	return te.removeReport()
+}
+
+// setupRepository creates the workspace from the local mirror and prepares it for testing
 func (te *TestExecution) setupRepository() error {
+	// A retried run starts again from a fresh project tree
+	if te.attempt > 1 {
+		if err := os.RemoveAll(te.projectDir); err != nil {
+			return fmt.Errorf("failed to remove project directory of previous attempt: %w", err)
+		}
+	}
+
 	// Create workspace directory
 	if err := os.MkdirAll(te.workspaceDir, 0755); err != nil {
 		return fmt.Errorf("failed to create workspace directory: %w", err)
 	}
 
+	if err := te.resetAttemptFiles(); err != nil {
+		return err
+	}
+
 	slog.Debug("Created workspace for test execution",
 		"workspace", te.workspaceDir,
 		"project_dir", te.projectDir,
-		"repo", te.runner.config.RepoName)
+		"repo", te.cfg().RepoName)
 
 	// Log repository setup
 	fmt.Fprintf(te.logFile, "=== Setting up Repository Workspace ===\n")
-	fmt.Fprintf(te.logFile, "Repository: %s\n", te.runner.config.Repository)
+	fmt.Fprintf(te.logFile, "Repository: %s\n", te.cfg().Repository)
 	fmt.Fprintf(te.logFile, "Workspace: %s\n", te.workspaceDir)
 	fmt.Fprintf(te.logFile, "Project Directory: %s\n", te.projectDir)
 	fmt.Fprintf(te.logFile, "Branch: %s\n", te.branch)
@@ -304,27 +626,49 @@ func (te *TestExecution) setupRepository() error {
 	return te.directCloneToWorkspace()
 }
 
-// directCloneToWorkspace clones the repository directly from upstream for full history
+// directCloneToWorkspace creates the workspace from the local mirror of the repository
 func (te *TestExecution) directCloneToWorkspace() error {
-	fmt.Fprintf(te.logFile, "Cloning directly from upstream for full history...\n")
-	return te.cloneFromOrigin()
+	fmt.Fprintf(te.logFile, "Creating workspace from local mirror %s...\n", te.runner.mirror.Path())
+	return te.cloneFromMirror()
+}
+
+// ensureCommitInMirror fetches upstream into the mirror, only when the commit is not already there
+func (te *TestExecution) ensureCommitInMirror() error {
+	if te.runner.mirror.Exists() && te.runner.mirror.HasCommit(te.commit) {
+		fmt.Fprintf(te.logFile, "Commit %s already available in mirror, skipping upstream fetch\n", utils.ShortCommit(te.commit))
+		return nil
+	}
+
+	fmt.Fprintf(te.logFile, "Updating mirror from %s...\n", te.cfg().Repository)
+	fetchStart := time.Now()
+	if _, err := te.runner.mirror.Update(te.context()); err != nil {
+		fmt.Fprintf(te.logFile, "Failed to update mirror: %v\n", err)
+		if !te.runner.mirror.Exists() {
+			return fmt.Errorf("failed to create mirror of %s: %w", te.cfg().Repository, err)
+		}
+		slog.Warn("Failed to update mirror, using existing content", "mirror", te.runner.mirror.Path(), "error", err)
+		return nil
+	}
+	fmt.Fprintf(te.logFile, "Mirror updated in %s\n", time.Since(fetchStart).Round(time.Millisecond))
+	return nil
 }
 
-// cloneFromOrigin performs git clone from remote origin using go-git API
-func (te *TestExecution) cloneFromOrigin() error {
-	fmt.Fprintf(te.logFile, "Cloning branch %s from origin using go-git API...\n", te.branch)
+// cloneFromMirror creates the project directory from the local mirror using git alternates
+func (te *TestExecution) cloneFromMirror() error {
+	fmt.Fprintf(te.logFile, "Checking out branch %s from mirror using go-git API...\n", te.branch)
 
 	// Log detailed clone operation info
-	slog.Info("Starting git clone operation",
+	slog.Info("Starting workspace creation",
 		"branch", te.branch,
 		"commit", te.commit,
 		"target_directory", te.projectDir,
-		"repository_url", te.runner.config.Repository)
+		"repository_url", te.cfg().Repository,
+		"mirror", te.runner.mirror.Path())
 
 	// Check if target directory already exists
 	if info, err := os.Stat(te.projectDir); err == nil {
 		fmt.Fprintf(te.logFile, "WARNING: Target directory already exists: %s\n", te.projectDir)
-		slog.Warn("Git clone target directory already exists",
+		slog.Warn("Workspace target directory already exists",
 			"directory", te.projectDir,
 			"is_dir", info.IsDir(),
 			"branch", te.branch,
@@ -343,32 +687,31 @@ func (te *TestExecution) cloneFromOrigin() error {
 		}
 	} else {
 		fmt.Fprintf(te.logFile, "Target directory does not exist: %s\n", te.projectDir)
-		slog.Info("Git clone target directory is free", "directory", te.projectDir)
+		slog.Info("Workspace target directory is free", "directory", te.projectDir)
 	}
 
-	// Clone from remote origin with specific branch and full history
-	repo, err := git.PlainClone(te.projectDir, false, &git.CloneOptions{
-		URL:           te.runner.config.Repository,
-		ReferenceName: plumbing.NewBranchReferenceName(te.branch),
-		SingleBranch:  true,
-		// No Depth specified = full history
-	})
+	if err := te.ensureCommitInMirror(); err != nil {
+		return err
+	}
 
+	// Create the workspace, its objects are shared with the mirror through alternates
+	repo, err := te.runner.mirror.CreateWorkspace(te.projectDir, te.branch)
 	if err != nil {
-		fmt.Fprintf(te.logFile, "Failed to clone repository from origin using go-git: %v\n", err)
-		slog.Error("Git clone operation failed",
-			"repository_url", te.runner.config.Repository,
+		fmt.Fprintf(te.logFile, "Failed to create workspace from mirror: %v\n", err)
+		slog.Error("Workspace creation failed",
+			"repository_url", te.cfg().Repository,
+			"mirror", te.runner.mirror.Path(),
 			"branch", te.branch,
 			"commit", te.commit,
 			"target_directory", te.projectDir,
 			"error", err.Error())
-		return fmt.Errorf("failed to clone repository from %s to %s: %w", te.runner.config.Repository, te.projectDir, err)
+		return fmt.Errorf("failed to create workspace from mirror %s to %s: %w", te.runner.mirror.Path(), te.projectDir, err)
 	}
 
 	// Success!
-	fmt.Fprintf(te.logFile, "Successfully cloned to: %s\n", te.projectDir)
-	slog.Info("Git clone operation successful",
-		"repository_url", te.runner.config.Repository,
+	fmt.Fprintf(te.logFile, "Successfully created workspace: %s\n", te.projectDir)
+	slog.Info("Workspace creation successful",
+		"repository_url", te.cfg().Repository,
 		"branch", te.branch,
 		"commit", te.commit,
 		"target_directory", te.projectDir)
@@ -443,6 +786,14 @@ func (te *TestExecution) cloneFromOrigin() error {
 		}
 	}
 
+	// Populate submodules and LFS objects of the checked out commit
+	if err := te.setupSubmodules(repo); err != nil {
+		return err
+	}
+	if err := te.setupLFS(); err != nil {
+		return err
+	}
+
 	// Verify we have the full history by checking commit count
 	commitIter, err := repo.Log(&git.LogOptions{})
 	if err != nil {
@@ -459,13 +810,13 @@ func (te *TestExecution) cloneFromOrigin() error {
 		fmt.Fprintf(te.logFile, "Repository has %d+ commits available for testing\n", commitCount)
 	}
 
-	fmt.Fprintf(te.logFile, "Repository cloned successfully from origin using go-git\n")
+	fmt.Fprintf(te.logFile, "Workspace created successfully from mirror using go-git\n")
 	fmt.Fprintf(te.logFile, "Branch: %s\n", te.branch)
 	fmt.Fprintf(te.logFile, "Commit: %s\n", te.commit)
 	fmt.Fprintf(te.logFile, "========================================\n\n")
 
-	slog.Debug("Repository workspace setup completed from origin using go-git",
-		"repo", te.runner.config.RepoName,
+	slog.Debug("Repository workspace setup completed from mirror using go-git",
+		"repo", te.cfg().RepoName,
 		"branch", te.branch,
 		"commit", utils.ShortCommit(te.commit),
 		"workspace", te.workspaceDir)
@@ -473,52 +824,66 @@ func (te *TestExecution) cloneFromOrigin() error {
 	return nil
 }
 
-// executeTest runs the actual test script
+// executeTest runs the pipeline steps, or the test script when no steps are configured
 func (te *TestExecution) executeTest() error {
+	if len(te.cfg().Steps) > 0 {
+		return te.executePipeline()
+	}
+	return te.executeTestScript()
+}
+
+// executeTestScript runs the test script as the single "test" step
+func (te *TestExecution) executeTestScript() error {
+	step := te.startStep("test")
+
 	// Prepare command arguments
 	args := te.parseCommandArgs()
 
 	// Create context with timeout
-	testCtx, testCancel := context.WithTimeout(context.Background(), te.runner.config.TestTimeout)
+	testCtx, testCancel := context.WithTimeout(te.context(), te.cfg().TestTimeout)
 	defer testCancel()
 
 	// Setup command
 	var scriptPath string
-	if filepath.IsAbs(te.runner.config.TestScript) {
-		scriptPath = te.runner.config.TestScript
+	if filepath.IsAbs(te.cfg().TestScript) {
+		scriptPath = te.cfg().TestScript
 	} else {
-		scriptPath = filepath.Join(te.projectDir, te.runner.config.TestScript)
+		scriptPath = filepath.Join(te.projectDir, te.cfg().TestScript)
+	}
+	spec := CommandSpec{
+		Args: append([]string{scriptPath}, args...),
+		Dir:  te.projectDir,
+		Env:  te.runEnv(),
 	}
-	cmd := exec.CommandContext(testCtx, scriptPath, args...)
-	cmd.Dir = te.projectDir
-	cmd.Stdout = io.MultiWriter(os.Stdout, te.logFile)
-	cmd.Stderr = io.MultiWriter(os.Stderr, te.logFile)
-
-	// Set up environment variables for the test script
-	logsDir := te.runner.config.GetLogsDir(te.branch, te.commit)
-	resultFile := filepath.Join(logsDir, "e2e-report.yaml")
-	cmd.Env = append(os.Environ(), fmt.Sprintf("HOME_CI_RESULT_FILE=%s", resultFile))
 
 	// Log test execution
 	te.logTestExecution(scriptPath, args)
 
 	// Execute test
 	testStartTime := time.Now()
-	err := cmd.Run()
+	oomKilled, err := te.runCommand(testCtx, spec)
 	duration := time.Since(testStartTime)
 
 	// Process test result
 	te.processTestResult(err, testCtx, duration)
+	oomKilled = oomKilled && err != nil && !te.testResult.TimedOut
+	if oomKilled {
+		te.handleOOMKill()
+	}
+	te.finishStep(step, err, te.testResult.TimedOut, oomKilled)
+	if err != nil {
+		te.testResult.FailedStep = step.Name
+	}
 
 	return err
 }
 
 // parseCommandArgs parses the configuration options into command arguments
 func (te *TestExecution) parseCommandArgs() []string {
-	if te.runner.config.Options == "" {
+	if te.cfg().Options == "" {
 		return []string{}
 	}
-	return strings.Fields(te.runner.config.Options)
+	return strings.Fields(te.cfg().Options)
 }
 
 // logTestExecution logs the test command and parameters
@@ -532,7 +897,7 @@ func (te *TestExecution) logTestExecution(scriptPath string, args []string) {
 	fmt.Fprintf(te.logFile, "Timestamp: %s\n", time.Now().Format(time.RFC3339))
 	fmt.Fprintf(te.logFile, "Command: %s\n", fullCommand)
 	fmt.Fprintf(te.logFile, "Working Directory: %s\n", te.projectDir)
-	fmt.Fprintf(te.logFile, "Timeout: %s\n", te.runner.config.TestTimeout)
+	fmt.Fprintf(te.logFile, "Timeout: %s\n", te.cfg().TestTimeout)
 	fmt.Fprintf(te.logFile, "==================\n\n")
 }
 
@@ -560,11 +925,11 @@ func (te *TestExecution) handleTestTimeout(duration time.Duration) {
 		"branch", te.branch,
 		"commit", utils.ShortCommit(te.commit),
 		"duration", duration,
-		"timeout", te.runner.config.TestTimeout)
+		"timeout", te.cfg().TestTimeout)
 
 	fmt.Fprintf(te.logFile, "\n=== TEST TIMEOUT ===\n")
 	fmt.Fprintf(te.logFile, "Test execution timed out after %s\n", duration)
-	fmt.Fprintf(te.logFile, "Timeout limit: %s\n", te.runner.config.TestTimeout)
+	fmt.Fprintf(te.logFile, "Timeout limit: %s\n", te.cfg().TestTimeout)
 	fmt.Fprintf(te.logFile, "Test was killed due to timeout\n")
 	fmt.Fprintf(te.logFile, "===================\n")
 }
@@ -582,12 +947,15 @@ func (te *TestExecution) logTestCompletion(duration time.Duration) {
 
 // runCleanupIfNeeded executes cleanup script if configured
 func (te *TestExecution) runCleanupIfNeeded() {
-	if !te.runner.config.Cleanup.AfterE2E || te.runner.config.Cleanup.Script == "" {
+	if !te.cfg().Cleanup.AfterE2E || te.cfg().Cleanup.Script == "" {
 		return
 	}
 
 	te.testResult.CleanupExecuted = true
-	if err := te.runCleanupScript(); err != nil {
+	step := te.startStep("cleanup")
+	err := te.runCleanupScript()
+	te.finishStep(step, err, false, false)
+	if err != nil {
 		te.testResult.CleanupSuccess = false
 		te.testResult.CleanupErrorMessage = err.Error()
 		te.logCleanupFailure(err)
@@ -601,25 +969,25 @@ func (te *TestExecution) runCleanupScript() error {
 	slog.Debug("Running cleanup script",
 		"branch", te.branch,
 		"commit", te.commit[:8],
-		"script", te.runner.config.Cleanup.Script)
+		"script", te.cfg().Cleanup.Script)
 
 	fmt.Fprintf(te.logFile, "\n=== Running Cleanup Script ===\n")
-	fmt.Fprintf(te.logFile, "Script: %s\n", te.runner.config.Cleanup.Script)
+	fmt.Fprintf(te.logFile, "Script: %s\n", te.cfg().Cleanup.Script)
 	fmt.Fprintf(te.logFile, "Working Directory: %s\n", te.projectDir)
 	fmt.Fprintf(te.logFile, "==============================\n\n")
 
-	scriptPath := filepath.Join(te.projectDir, te.runner.config.Cleanup.Script)
+	scriptPath := filepath.Join(te.projectDir, te.cfg().Cleanup.Script)
 
 	// Create context with timeout
-	cleanupCtx, cleanupCancel := context.WithTimeout(context.Background(), te.runner.config.TestTimeout)
+	cleanupCtx, cleanupCancel := context.WithTimeout(context.Background(), te.cfg().TestTimeout)
 	defer cleanupCancel()
 
-	cmd := exec.CommandContext(cleanupCtx, scriptPath)
-	cmd.Dir = te.projectDir
-	cmd.Stdout = io.MultiWriter(os.Stdout, te.logFile)
-	cmd.Stderr = io.MultiWriter(os.Stderr, te.logFile)
-
-	return cmd.Run()
+	_, err := te.runCommand(cleanupCtx, CommandSpec{
+		Args: []string{scriptPath},
+		Dir:  te.projectDir,
+		Env:  te.runEnv(),
+	})
+	return err
 }
 
 // logCleanupFailure logs cleanup script failures
@@ -634,6 +1002,96 @@ func (te *TestExecution) logCleanupFailure(err error) {
 	fmt.Fprintf(te.logFile, "============================\n")
 }
 
+// loadPreviousAttempts reads the attempts already recorded in the result file of the run
+func (te *TestExecution) loadPreviousAttempts() {
+	data, err := os.ReadFile(te.resultFilePath)
+	if err != nil {
+		slog.Debug("No previous attempt result found", "file", te.resultFilePath, "error", err)
+		return
+	}
+
+	var previous TestResult
+	if err := json.Unmarshal(data, &previous); err != nil {
+		slog.Error("Failed to parse previous attempt result", "file", te.resultFilePath, "error", err)
+		return
+	}
+	te.testResult.Attempts = previous.Attempts
+}
+
+// finishAttempt records the outcome of the current attempt, and queues a new attempt
+// when the failure reason is configured for retries
+func (te *TestExecution) finishAttempt() {
+	// The output of the attempt is over, the end of a capped log is written before the result
+	te.logFile.flushTail()
+	for i := range te.testResult.Steps {
+		step := &te.testResult.Steps[i]
+		step.LogStart, step.LogEnd = te.logFile.rebase(step.LogStart), te.logFile.rebase(step.LogEnd)
+	}
+
+	result := te.testResult
+	result.Attempt = te.attempt
+
+	if !result.Success && result.FailureReason == "" {
+		if result.TimedOut {
+			result.FailureReason = FailureReasonTimeout
+		} else {
+			result.FailureReason = FailureReasonTest
+		}
+	}
+
+	// The attempts of a leased job are recorded by the coordinator
+	if te.leaseLog != nil {
+		return
+	}
+
+	endTime := time.Now()
+	result.Attempts = append(result.Attempts, AttemptResult{
+		Attempt:       te.attempt,
+		StartTime:     result.StartTime,
+		EndTime:       endTime,
+		Duration:      endTime.Sub(result.StartTime),
+		Success:       result.Success,
+		FailureReason: result.FailureReason,
+		FailedStep:    result.FailedStep,
+		ErrorMessage:  result.ErrorMessage,
+	})
+
+	if result.Success {
+		for _, attempt := range result.Attempts[:len(result.Attempts)-1] {
+			if !attempt.Success {
+				result.Flaky = true
+				break
+			}
+		}
+		if result.Flaky {
+			slog.Info("Test passed after a failed attempt, marking commit as flaky",
+				"branch", te.branch, "commit", utils.ShortCommit(te.commit), "attempt", te.attempt)
+			fmt.Fprintf(te.logFile, "\n=== Flaky: passed on attempt %d ===\n", te.attempt)
+		}
+		return
+	}
+
+	// Retries go through the scheduler, manual runs are not retried
+	if te.runner.stateManager == nil || !te.cfg().Retry.ShouldRetry(result.FailureReason, te.attempt) {
+		return
+	}
+
+	retry := TestJob{Branch: te.branch, Commit: te.commit, Trigger: TriggerRetry, Attempt: te.attempt + 1, PreviousCommit: te.previousCommit}
+	if !te.runner.QueueTestJob(retry) {
+		slog.Error("Failed to queue retry, queue is full", "branch", te.branch, "commit", utils.ShortCommit(te.commit))
+		return
+	}
+	te.retryQueued = true
+
+	slog.Info("Test failed, retry queued",
+		"branch", te.branch,
+		"commit", utils.ShortCommit(te.commit),
+		"reason", result.FailureReason,
+		"next_attempt", retry.Attempt,
+		"max_attempts", te.cfg().Retry.MaxAttempts)
+	fmt.Fprintf(te.logFile, "\n=== Attempt %d failed (%s), retry queued ===\n", te.attempt, result.FailureReason)
+}
+
 // saveTestResultForDispatch saves the test result before sending GitHub dispatch
 func (te *TestExecution) saveTestResultForDispatch() {
 	// Finalize test result timing (test is complete, calculate duration before dispatch)
@@ -646,22 +1104,35 @@ func (te *TestExecution) saveTestResultForDispatch() {
 	} else {
 		slog.Debug("Test result saved for dispatch", "file", te.resultFilePath)
 	}
+
+	// The result of the branch is the one of the last attempt, saved with the state by cleanup
+	if te.runner.stateManager != nil && te.leaseLog == nil && !te.retryQueued {
+		te.recordHistory()
+		te.runner.stateManager.RecordBranchResult(te.branch, BranchResult{
+			Commit:        te.commit,
+			Success:       te.testResult.Success,
+			Flaky:         te.testResult.Flaky,
+			FailureReason: te.testResult.FailureReason,
+			Attempts:      len(te.testResult.Attempts),
+			EndTime:       te.testResult.EndTime,
+		})
+	}
 }
 
 // sendGitHubNotificationIfNeeded sends GitHub Actions notification if enabled
 func (te *TestExecution) sendGitHubNotificationIfNeeded() {
 	slog.Debug("Checking GitHub Actions dispatch",
-		"enabled", te.runner.config.GitHubActionsDispatch.Enabled,
-		"github_repo", te.runner.config.GitHubActionsDispatch.GitHubRepo,
-		"token_file", te.runner.config.GitHubActionsDispatch.GitHubTokenFile)
+		"enabled", te.cfg().GitHubActionsDispatch.Enabled,
+		"github_repo", te.cfg().GitHubActionsDispatch.GitHubRepo,
+		"token_file", te.cfg().GitHubActionsDispatch.GitHubTokenFile)
 
-	if !te.runner.config.GitHubActionsDispatch.Enabled {
+	if !te.cfg().GitHubActionsDispatch.Enabled {
 		slog.Debug("GitHub Actions dispatch is disabled")
 		return
 	}
 
 	te.testResult.GitHubActionsNotified = true
-	if err := te.runner.notifyGitHubActions(te.branch, te.commit, te.testResult.Success, te.logFilePath, te.resultFilePath); err != nil {
+	if err := te.runner.notifyGitHubActions(te.testResult, te.logFilePath, te.resultFilePath, te.masker); err != nil {
 		te.testResult.GitHubActionsSuccess = false
 		te.testResult.GitHubActionsErrorMessage = err.Error()
 		slog.Error("GitHub Actions notification failed",
@@ -673,7 +1144,6 @@ func (te *TestExecution) sendGitHubNotificationIfNeeded() {
 	}
 }
 
-
 // saveTestResult saves a test result to a JSON file
 func (tr *TestRunner) saveTestResult(result TestResult, filePath string) error {
 	data, err := json.MarshalIndent(result, "", "  ")
@@ -692,8 +1162,26 @@ func (tr *TestRunner) saveTestResult(result TestResult, filePath string) error {
 func (tr *TestRunner) RunTestsManually(branch, commit string, commitExplicitlySpecified bool) error {
 	slog.Info("Running manual test execution", "branch", branch, "commit", utils.ShortCommit(commit), "timeout", tr.config.TestTimeout)
 
+	// Wait for the named resources, which may be held by a running daemon
+	if required := tr.config.Resources.RequiredFor(branch); len(required) > 0 {
+		slog.Info("Waiting for resources", "resources", required)
+		lease, err := tr.resources.Acquire(tr.ctx, required)
+		if err != nil {
+			return fmt.Errorf("failed to acquire resources: %w", err)
+		}
+		defer lease.Release()
+	}
+
+	// Wait for a worker when the commands run over ssh
+	worker, workerLease, err := tr.acquireWorker(tr.ctx, branch)
+	if err != nil {
+		return fmt.Errorf("failed to acquire a worker: %w", err)
+	}
+	defer workerLease.Release()
+
 	// Initialize manual test execution context
 	execution := tr.newManualTestExecution(branch, commit, commitExplicitlySpecified)
+	execution.worker = worker
 	defer execution.cleanup()
 
 	// Setup logging
@@ -701,19 +1189,40 @@ func (tr *TestRunner) RunTestsManually(branch, commit string, commitExplicitlySp
 		return err
 	}
 
-	// Setup repository
+	// Load the secrets, then setup repository and apply its pipeline file
+	if err := execution.loadSecrets(); err != nil {
+		return err
+	}
 	if err := execution.setupRepository(); err != nil {
 		return err
 	}
+	if err := execution.loadRepoConfig(); err != nil {
+		return err
+	}
+	if err := execution.setupSandbox(); err != nil {
+		return err
+	}
+	if err := execution.setupExecutor(); err != nil {
+		return err
+	}
+	if err := execution.setupLimits(); err != nil {
+		return err
+	}
 
 	// Execute the test
 	if err := execution.executeTest(); err != nil {
 		execution.testResult.ErrorMessage = err.Error()
 		// Don't return immediately - we still want to run post-execution tasks like GitHub dispatch
 	}
+	execution.downloadResults()
+	execution.importSandboxReport()
+	execution.reconcileReport()
 
-	// Post-execution tasks
+	// Post-execution tasks, the cleanup script may remove the reports and artifacts
+	execution.parseTestReports()
+	execution.collectArtifacts()
 	execution.runCleanupIfNeeded()
+	execution.finishAttempt()
 	execution.saveTestResultForDispatch()
 	execution.sendGitHubNotificationIfNeeded()
 
@@ -740,15 +1249,18 @@ func (tr *TestRunner) newManualTestExecution(branch, commit string, commitExplic
 	resultFileName := "run.json"
 
 	return &TestExecution{
-		runner:                   tr,
-		branch:                   branch,
-		commit:                   commit,
+		runner:                    tr,
+		branch:                    branch,
+		commit:                    commit,
 		commitExplicitlySpecified: commitExplicitlySpecified, // Use the parameter value for manual runs
-		startTime:                startTime,
-		logFilePath:              filepath.Join(logsDir, logFileName),
-		resultFilePath:           filepath.Join(logsDir, resultFileName),
-		workspaceDir:             workspaceDir,
-		projectDir:               projectDir,
+		startTime:                 startTime,
+		logFilePath:               filepath.Join(logsDir, logFileName),
+		resultFilePath:            filepath.Join(logsDir, resultFileName),
+		workspaceDir:              workspaceDir,
+		projectDir:                projectDir,
+		artifactsDir:              tr.config.GetRunArtifactsDir(branch, commit),
+		attempt:                   1,
+		trigger:                   TriggerManual,
 		testResult: &TestResult{
 			Branch:    branch,
 			Commit:    commit,
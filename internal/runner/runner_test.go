@@ -52,6 +52,26 @@ func (m *MockStateManager) SaveState() error {
 	return nil
 }
 
+func (m *MockStateManager) SetQueuedJobs(jobs []QueuedJob) {}
+
+func (m *MockStateManager) GetQueuedJobs() []QueuedJob {
+	return nil
+}
+
+func (m *MockStateManager) GetBranchState(branch string) *BranchState {
+	return nil
+}
+
+func (m *MockStateManager) UpdateBranchState(branch, commit string) {}
+
+func (m *MockStateManager) RecordBranchResult(branch string, result BranchResult) {}
+
+func (m *MockStateManager) SetPollStatus(at time.Time, err error) {}
+
+func (m *MockStateManager) LoadState() error {
+	return nil
+}
+
 func (m *MockStateManager) GetRunningTestsCount() int {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -571,7 +591,7 @@ exit 0
 		commit:       "abc123def",
 		workspaceDir: workspaceDir,
 		projectDir:   workspaceDir,
-		logFile:      logFile,
+		logFile:      newRunLog(logFile, nil),
 		testResult:   testResult,
 	}
 
@@ -630,3 +650,16 @@ exit 0
 
 	t.Logf("✅ Test completed successfully - HOME_CI_RESULT_FILE is properly handled")
 }
+
+func TestNewTestRunnerInvalidAuth(t *testing.T) {
+	cfg := config.Config{
+		Repository:        "https://github.com/owner/repo.git",
+		RepoName:          "repo",
+		WorkDir:           t.TempDir(),
+		MaxConcurrentRuns: 1,
+		Git:               config.Git{Auth: config.GitAuth{PasswordFile: filepath.Join(t.TempDir(), "missing")}},
+	}
+	if _, err := NewTestRunner(cfg, "", cfg.WorkDir, context.Background(), nil); err == nil {
+		t.Error("expected NewTestRunner() to fail on a missing password file")
+	}
+}
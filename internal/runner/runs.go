@@ -0,0 +1,175 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/k8s-school/home-ci/internal/artifacts"
+)
+
+// FailureReasonCancelled is recorded for a run cancelled by a user, it is never retried
+const FailureReasonCancelled = "cancelled"
+
+// Errors of the run commands
+var (
+	ErrRunNotFound = errors.New("run not found")
+	ErrRunLeased   = errors.New("run is leased to an agent")
+	ErrRunQueued   = errors.New("run is already queued")
+)
+
+// activeRun is a run started by the scheduler. Its run ID is reserved until it is
+// over, so that another job of the same commit does not use its workspace meanwhile.
+type activeRun struct {
+	branch string
+	commit string
+	ctx    context.Context
+	cancel context.CancelFunc
+	leased bool // Leased to an agent, it cannot be cancelled from here
+}
+
+// reserveRun reserves the run ID of a job, it returns false when the run is active
+func (tr *TestRunner) reserveRun(job TestJob, leased bool) bool {
+	runID := tr.config.GetRunID(job.Branch, job.Commit)
+
+	tr.runsMu.Lock()
+	defer tr.runsMu.Unlock()
+	if _, ok := tr.runs[runID]; ok {
+		return false
+	}
+	if tr.runs == nil {
+		tr.runs = make(map[string]*activeRun)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	tr.runs[runID] = &activeRun{branch: job.Branch, commit: job.Commit, ctx: ctx, cancel: cancel, leased: leased}
+	return true
+}
+
+// releaseRun ends the reservation of a run ID, the jobs waiting for it can start
+func (tr *TestRunner) releaseRun(branch, commit string) {
+	runID := tr.config.GetRunID(branch, commit)
+
+	tr.runsMu.Lock()
+	run, ok := tr.runs[runID]
+	delete(tr.runs, runID)
+	tr.runsMu.Unlock()
+
+	if ok {
+		run.cancel()
+		tr.testQueue.Notify()
+	}
+}
+
+// runContext returns the context of a reserved run, cancelled by CancelRun
+func (tr *TestRunner) runContext(branch, commit string) context.Context {
+	tr.runsMu.Lock()
+	defer tr.runsMu.Unlock()
+	if run, ok := tr.runs[tr.config.GetRunID(branch, commit)]; ok {
+		return run.ctx
+	}
+	return context.Background()
+}
+
+// CancelRun removes the queued jobs of a run and stops it if it is running. It returns
+// the number of jobs removed from the queue, and whether a running run was cancelled.
+func (tr *TestRunner) CancelRun(runID string) (int, bool, error) {
+	dequeued := tr.testQueue.Remove(func(job TestJob) bool {
+		return tr.config.GetRunID(job.Branch, job.Commit) == runID
+	})
+	if dequeued > 0 {
+		tr.publishQueue()
+	}
+
+	tr.runsMu.Lock()
+	run, ok := tr.runs[runID]
+	tr.runsMu.Unlock()
+	switch {
+	case ok && run.leased:
+		return dequeued, false, ErrRunLeased
+	case ok:
+		run.cancel()
+		return dequeued, true, nil
+	case dequeued == 0:
+		return 0, false, ErrRunNotFound
+	}
+	return dequeued, false, nil
+}
+
+// QueueManualRun queues a run of a commit requested by a user. A run already queued
+// for the commit is not queued twice.
+func (tr *TestRunner) QueueManualRun(branch, commit string) (TestJob, error) {
+	for _, queued := range tr.testQueue.List() {
+		if queued.Branch == branch && queued.Commit == commit {
+			return TestJob{}, ErrRunQueued
+		}
+	}
+
+	job := TestJob{Branch: branch, Commit: commit, Trigger: TriggerManual}
+	if !tr.QueueTestJob(job) {
+		return TestJob{}, fmt.Errorf("queue is full")
+	}
+	return job, nil
+}
+
+// FindRun returns the branch and commit of a run from the active runs, the queue, or
+// the run.json of its workspace or of the artifact store
+func (tr *TestRunner) FindRun(runID string) (string, string, error) {
+	tr.runsMu.Lock()
+	run, ok := tr.runs[runID]
+	tr.runsMu.Unlock()
+	if ok {
+		return run.branch, run.commit, nil
+	}
+
+	for _, queued := range tr.testQueue.List() {
+		if tr.config.GetRunID(queued.Branch, queued.Commit) == runID {
+			return queued.Branch, queued.Commit, nil
+		}
+	}
+
+	paths := []string{filepath.Join(tr.config.GetRunsDir(), runID, "logs", "run.json")}
+	if stored, err := artifacts.NewStore(tr.config.GetArtifactsDir()).Path(runID, "logs/run.json"); err == nil {
+		paths = append(paths, stored)
+	}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		var result TestResult
+		if err := json.Unmarshal(data, &result); err != nil {
+			return "", "", fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		if result.Branch != "" && result.Commit != "" {
+			return result.Branch, result.Commit, nil
+		}
+	}
+	return "", "", ErrRunNotFound
+}
+
+// context returns the context of the commands of the run, cancelled when a user cancels it
+func (te *TestExecution) context() context.Context {
+	if te.ctx == nil {
+		return context.Background()
+	}
+	return te.ctx
+}
+
+// cancelled reports whether a user cancelled the run
+func (te *TestExecution) cancelled() bool {
+	return te.ctx != nil && te.ctx.Err() != nil
+}
+
+// markCancelled records the cancellation of the run
+func (te *TestExecution) markCancelled() {
+	te.testResult.Success = false
+	te.testResult.FailureReason = FailureReasonCancelled
+	te.testResult.ErrorMessage = "run cancelled"
+
+	fmt.Fprintf(te.logFile, "\n=== Run Cancelled ===\n")
+	fmt.Fprintf(te.logFile, "The run was cancelled with home-ci cancel\n")
+	fmt.Fprintf(te.logFile, "=====================\n")
+}
@@ -0,0 +1,118 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCancelRun(t *testing.T) {
+	tr := newCoordinatorRunner(t)
+	tr.QueueTestJob(TestJob{Branch: "main", Commit: "0123456789abcdef"})
+	tr.QueueTestJob(TestJob{Branch: "release/1.0", Commit: "fedcba9876543210"})
+
+	// A queued run is removed from the queue
+	dequeued, stopped, err := tr.CancelRun("main_01234567")
+	if err != nil || dequeued != 1 || stopped {
+		t.Fatalf("CancelRun() = %d, %v, %v, want 1, false, nil", dequeued, stopped, err)
+	}
+	if n := len(tr.GetQueuedJobs()); n != 1 {
+		t.Errorf("expected 1 queued job, got %d", n)
+	}
+
+	// A running run is stopped through its context
+	job := TestJob{Branch: "feature/x", Commit: "aaaaaaaabbbbbbbb"}
+	if !tr.reserveRun(job, false) {
+		t.Fatal("reserveRun() failed")
+	}
+	ctx := tr.runContext(job.Branch, job.Commit)
+	if _, stopped, err := tr.CancelRun("feature_x_aaaaaaaa"); err != nil || !stopped {
+		t.Fatalf("CancelRun() = %v, %v, want true, nil", stopped, err)
+	}
+	if ctx.Err() == nil {
+		t.Error("the context of the cancelled run is not done")
+	}
+	tr.releaseRun(job.Branch, job.Commit)
+
+	// A leased run cannot be cancelled
+	leased := TestJob{Branch: "gpu/cuda", Commit: "cccccccccccccccc"}
+	tr.reserveRun(leased, true)
+	if _, _, err := tr.CancelRun("gpu_cuda_cccccccc"); !errors.Is(err, ErrRunLeased) {
+		t.Errorf("CancelRun() of a leased run = %v, want %v", err, ErrRunLeased)
+	}
+
+	if _, _, err := tr.CancelRun("unknown_00000000"); !errors.Is(err, ErrRunNotFound) {
+		t.Errorf("CancelRun() of an unknown run = %v, want %v", err, ErrRunNotFound)
+	}
+}
+
+func TestReserveRunBlocksSameCommit(t *testing.T) {
+	tr := newCoordinatorRunner(t)
+	job := TestJob{Branch: "main", Commit: "0123456789abcdef"}
+	if !tr.reserveRun(job, false) {
+		t.Fatal("reserveRun() failed")
+	}
+	if tr.reserveRun(job, false) {
+		t.Fatal("a run ID must not be reserved twice")
+	}
+
+	// A job of the active run is leased once the run is over
+	tr.QueueTestJob(job)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, ok := tr.LeaseJob(ctx, "agent-1", nil); ok {
+		t.Fatal("a job of an active run must wait for the end of the run")
+	}
+
+	tr.releaseRun(job.Branch, job.Commit)
+	run, ok := tr.LeaseJob(context.Background(), "agent-1", nil)
+	if !ok || run.Job().Commit != job.Commit {
+		t.Fatalf("expected the job to be leased once the run is over, got ok=%v", ok)
+	}
+}
+
+func TestQueueManualRun(t *testing.T) {
+	tr := newCoordinatorRunner(t)
+	job, err := tr.QueueManualRun("main", "0123456789abcdef")
+	if err != nil {
+		t.Fatalf("QueueManualRun() failed: %v", err)
+	}
+	if job.Trigger != TriggerManual {
+		t.Errorf("Trigger = %q, want %q", job.Trigger, TriggerManual)
+	}
+	if _, err := tr.QueueManualRun("main", "0123456789abcdef"); !errors.Is(err, ErrRunQueued) {
+		t.Errorf("QueueManualRun() of a queued commit = %v, want %v", err, ErrRunQueued)
+	}
+}
+
+func TestFindRun(t *testing.T) {
+	tr := newCoordinatorRunner(t)
+	tr.QueueTestJob(TestJob{Branch: "main", Commit: "0123456789abcdef"})
+
+	branch, commit, err := tr.FindRun("main_01234567")
+	if err != nil || branch != "main" || commit != "0123456789abcdef" {
+		t.Errorf("FindRun() of a queued run = %s, %s, %v", branch, commit, err)
+	}
+
+	// A past run is found from the run.json of its workspace
+	logsDir := filepath.Join(tr.config.GetRunsDir(), "feature_x_aaaaaaaa", "logs")
+	if err := os.MkdirAll(logsDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := json.Marshal(TestResult{Branch: "feature/x", Commit: "aaaaaaaabbbbbbbb"})
+	if err := os.WriteFile(filepath.Join(logsDir, "run.json"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	branch, commit, err = tr.FindRun("feature_x_aaaaaaaa")
+	if err != nil || branch != "feature/x" || commit != "aaaaaaaabbbbbbbb" {
+		t.Errorf("FindRun() of a past run = %s, %s, %v", branch, commit, err)
+	}
+
+	if _, _, err := tr.FindRun("unknown_00000000"); !errors.Is(err, ErrRunNotFound) {
+		t.Errorf("FindRun() of an unknown run = %v, want %v", err, ErrRunNotFound)
+	}
+}
@@ -0,0 +1,171 @@
+package runner
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strconv"
+
+	"github.com/k8s-school/home-ci/internal/artifacts"
+	"github.com/k8s-school/home-ci/internal/e2ereport"
+	"github.com/k8s-school/home-ci/internal/sandbox"
+)
+
+// Directories of a sandboxed attempt, below sandboxDir and owned by the sandbox user
+const (
+	sandboxHome   = "home"   // HOME of the commands
+	sandboxTmp    = "tmp"    // TMPDIR of the commands, the writable scratch directory
+	sandboxReport = "report" // Holds HOME_CI_RESULT_FILE, copied to the logs directory afterwards
+)
+
+// maxSandboxReportBytes bounds the size of the report imported from the sandbox
+const maxSandboxReportBytes = 16 << 20
+
+// sandboxDir returns the directory holding the writable directories of a sandboxed run
+func (te *TestExecution) sandboxDir() string {
+	return filepath.Join(te.workspaceDir, "sandbox")
+}
+
+// resultFile returns the path given to the commands as HOME_CI_RESULT_FILE
+func (te *TestExecution) resultFile() string {
+	if te.cfg().Sandbox.Enabled {
+		return filepath.Join(te.sandboxDir(), sandboxReport, e2ereport.FileName)
+	}
+	return filepath.Join(te.cfg().GetLogsDir(te.branch, te.commit), e2ereport.FileName)
+}
+
+// setupSandbox prepares the directories of a sandboxed attempt. Without mount
+// namespace, the project tree is given to the sandbox user so that it stays writable.
+func (te *TestExecution) setupSandbox() error {
+	sb := te.cfg().Sandbox
+	if !sb.Enabled {
+		return nil
+	}
+	if err := sandbox.Supported(); err != nil {
+		return err
+	}
+	if os.Geteuid() != 0 {
+		return fmt.Errorf("the sandbox requires the daemon to run as root")
+	}
+
+	if err := os.RemoveAll(te.sandboxDir()); err != nil {
+		return fmt.Errorf("failed to clean sandbox directory: %w", err)
+	}
+	if err := os.MkdirAll(te.sandboxDir(), 0755); err != nil {
+		return fmt.Errorf("failed to create sandbox directory: %w", err)
+	}
+	owned := []string{te.artifactsDir}
+	for _, name := range []string{sandboxHome, sandboxTmp, sandboxReport} {
+		dir := filepath.Join(te.sandboxDir(), name)
+		if err := os.Mkdir(dir, 0700); err != nil {
+			return fmt.Errorf("failed to create sandbox directory: %w", err)
+		}
+		owned = append(owned, dir)
+	}
+	if !sb.MountNamespace {
+		owned = append(owned, te.projectDir)
+	}
+	for _, dir := range owned {
+		if err := chownTree(dir, sb.UID, sb.GID); err != nil {
+			return fmt.Errorf("failed to give %s to the sandbox user: %w", dir, err)
+		}
+	}
+
+	fmt.Fprintf(te.logFile, "=== Sandbox ===\n")
+	fmt.Fprintf(te.logFile, "User: %d:%d\n", sb.UID, sb.GID)
+	fmt.Fprintf(te.logFile, "Home: %s\n", filepath.Join(te.sandboxDir(), sandboxHome))
+	fmt.Fprintf(te.logFile, "Environment: %v\n", sb.EnvAllow)
+	fmt.Fprintf(te.logFile, "Mount namespace: %t\n", sb.MountNamespace)
+	fmt.Fprintf(te.logFile, "===============\n\n")
+	return nil
+}
+
+// chownTree changes the owner of dir and of all the files below it
+func chownTree(dir string, uid, gid int) error {
+	return filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		return os.Lchown(path, uid, gid)
+	})
+}
+
+// sandboxEnv returns the base environment of sandboxed commands: the allowed
+// variables of the daemon environment and the sandbox user directories
+func (te *TestExecution) sandboxEnv() []string {
+	sb := te.cfg().Sandbox
+	env := sandbox.FilterEnv(os.Environ(), sb.EnvAllow)
+	env = append(env,
+		"HOME="+filepath.Join(te.sandboxDir(), sandboxHome),
+		"TMPDIR="+filepath.Join(te.sandboxDir(), sandboxTmp))
+	if u, err := user.LookupId(strconv.Itoa(sb.UID)); err == nil {
+		env = append(env, "USER="+u.Username, "LOGNAME="+u.Username)
+	}
+	return env
+}
+
+// sandboxCommand makes cmd run as the sandbox user. With a mount namespace, cmd
+// goes through the sandbox-exec subcommand of the daemon executable, which makes
+// the work directory read-only except the directories of the run.
+func (te *TestExecution) sandboxCommand(cmd *exec.Cmd) error {
+	sb := te.cfg().Sandbox
+	if !sb.Enabled {
+		return nil
+	}
+	if !sb.MountNamespace {
+		sandbox.SetCredential(cmd, sb.UID, sb.GID)
+		return nil
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to find the home-ci executable: %w", err)
+	}
+	opts := sandbox.Options{
+		UID:      sb.UID,
+		GID:      sb.GID,
+		ReadOnly: []string{te.cfg().WorkDir},
+		Writable: []string{te.sandboxDir(), te.artifactsDir},
+	}
+	argv := append([]string{cmd.Path}, cmd.Args[1:]...)
+	cmd.Path = exe
+	cmd.Args = append([]string{exe}, opts.Args(argv)...)
+	sandbox.SetMountNamespace(cmd)
+	return nil
+}
+
+// importSandboxReport copies the report written by a sandboxed command to the logs directory
+func (te *TestExecution) importSandboxReport() {
+	if !te.cfg().Sandbox.Enabled {
+		return
+	}
+	dst := filepath.Join(te.cfg().GetLogsDir(te.branch, te.commit), e2ereport.FileName)
+	os.Remove(dst)
+
+	// The report directory belongs to the sandbox user, the report is only read
+	// when it is a regular file of a bounded size
+	file, err := artifacts.OpenRegular(te.resultFile())
+	if err != nil {
+		if !os.IsNotExist(err) {
+			fmt.Fprintf(te.logFile, "Ignoring %s from the sandbox: %v\n", e2ereport.FileName, err)
+		}
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, maxSandboxReportBytes+1))
+	if err != nil {
+		fmt.Fprintf(te.logFile, "Failed to read %s from the sandbox: %v\n", e2ereport.FileName, err)
+		return
+	}
+	if len(data) > maxSandboxReportBytes {
+		fmt.Fprintf(te.logFile, "Ignoring %s from the sandbox: larger than %d bytes\n", e2ereport.FileName, maxSandboxReportBytes)
+		return
+	}
+	if err := os.WriteFile(dst, data, 0644); err != nil {
+		fmt.Fprintf(te.logFile, "Failed to copy %s from the sandbox: %v\n", e2ereport.FileName, err)
+	}
+}
@@ -0,0 +1,124 @@
+package runner
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/k8s-school/home-ci/internal/config"
+)
+
+func TestSandboxedStep(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("the sandbox requires root")
+	}
+
+	// The sandbox user must be able to reach the workspace
+	workDir := t.TempDir()
+	for _, dir := range []string{filepath.Dir(workDir), workDir} {
+		if err := os.Chmod(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("HOME_CI_TEST_LEAK", "daemon-only")
+
+	cfg := config.Config{
+		RepoName:    "test-repo",
+		WorkDir:     workDir,
+		TestTimeout: time.Minute,
+		Sandbox:     config.Sandbox{Enabled: true, UID: 65534, GID: 65534, EnvAllow: config.DefaultSandboxEnv},
+		Steps:       []config.Step{{Name: "probe", Command: `id -u > probe.txt; echo "$HOME" >> probe.txt; env >> probe.txt; echo "status: passed" > "$HOME_CI_RESULT_FILE"`}},
+	}
+
+	execution := &TestExecution{
+		runner:       &TestRunner{config: cfg},
+		branch:       "main",
+		commit:       "0123456789abcdef",
+		workspaceDir: cfg.GetWorkspaceDir("main", "0123456789abcdef"),
+		projectDir:   cfg.GetProjectDir("main", "0123456789abcdef"),
+		artifactsDir: cfg.GetRunArtifactsDir("main", "0123456789abcdef"),
+		testResult:   &TestResult{},
+	}
+	for _, dir := range []string{execution.projectDir, execution.artifactsDir, cfg.GetLogsDir("main", "0123456789abcdef")} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	logFile, err := os.Create(filepath.Join(cfg.GetLogsDir("main", "0123456789abcdef"), "run.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logFile.Close()
+	execution.logFile = newRunLog(logFile, nil)
+
+	if err := execution.setupSandbox(); err != nil {
+		t.Fatal(err)
+	}
+	if err := execution.executeTest(); err != nil {
+		t.Fatalf("sandboxed step failed: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(execution.projectDir, "probe.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(string(content), "\n")
+	if lines[0] != "65534" {
+		t.Errorf("step ran as uid %s, want 65534", lines[0])
+	}
+	if lines[1] != filepath.Join(execution.sandboxDir(), sandboxHome) {
+		t.Errorf("HOME = %s, want the sandbox home", lines[1])
+	}
+	if strings.Contains(string(content), "HOME_CI_TEST_LEAK") {
+		t.Error("a variable outside env_allow was passed to the step")
+	}
+
+	// The report written in the sandbox is imported into the logs directory
+	execution.importSandboxReport()
+	if _, err := os.Stat(filepath.Join(cfg.GetLogsDir("main", "0123456789abcdef"), "e2e-report.yaml")); err != nil {
+		t.Errorf("report not imported: %v", err)
+	}
+}
+
+func TestImportSandboxReportSymlink(t *testing.T) {
+	cfg := config.Config{
+		RepoName: "test-repo",
+		WorkDir:  t.TempDir(),
+		Sandbox:  config.Sandbox{Enabled: true, UID: 65534, GID: 65534},
+	}
+	execution := &TestExecution{
+		runner:       &TestRunner{config: cfg},
+		branch:       "main",
+		commit:       "0123456789abcdef",
+		workspaceDir: cfg.GetWorkspaceDir("main", "0123456789abcdef"),
+		testResult:   &TestResult{},
+	}
+	logsDir := cfg.GetLogsDir("main", "0123456789abcdef")
+	for _, dir := range []string{logsDir, filepath.Dir(execution.resultFile())} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	logFile, err := os.Create(filepath.Join(logsDir, "run.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logFile.Close()
+	execution.logFile = newRunLog(logFile, nil)
+
+	// A sandboxed command replaces its report with a link to a file of the daemon
+	secret := filepath.Join(t.TempDir(), "shadow")
+	if err := os.WriteFile(secret, []byte("root:secret"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(secret, execution.resultFile()); err != nil {
+		t.Fatal(err)
+	}
+
+	execution.importSandboxReport()
+	if _, err := os.Lstat(filepath.Join(logsDir, "e2e-report.yaml")); !os.IsNotExist(err) {
+		t.Errorf("the target of a symbolic link was imported: %v", err)
+	}
+}
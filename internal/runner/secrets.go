@@ -0,0 +1,50 @@
+package runner
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/k8s-school/home-ci/internal/secrets"
+)
+
+// loadSecrets reads the secrets injected in the environment of the test commands.
+// They are read for every run, so that rotated files are picked up.
+func (te *TestExecution) loadSecrets() error {
+	configDir := ""
+	if te.runner.configPath != "" {
+		configDir = filepath.Dir(te.runner.configPath)
+	}
+
+	loaded, err := secrets.Load(te.runner.config.Secrets, configDir)
+	if err != nil {
+		return fmt.Errorf("failed to load secrets: %w", err)
+	}
+	te.secretEnv = secrets.Env(loaded)
+	te.masker = secrets.NewMasker(secrets.Values(loaded)...)
+
+	if len(loaded) > 0 {
+		slog.Debug("Secrets loaded", "count", len(loaded))
+	}
+	return nil
+}
+
+// setCommandOutput copies the output of cmd to run.log and the console, with the
+// secret values masked. The returned function must be called once cmd exited.
+func (te *TestExecution) setCommandOutput(cmd *exec.Cmd) func() {
+	stdoutConsole := te.consoleOutput(os.Stdout)
+	stderrConsole := te.consoleOutput(os.Stderr)
+	stdout := te.masker.Writer(te.withConsole(stdoutConsole, StreamStdout))
+	stderr := te.masker.Writer(te.withConsole(stderrConsole, StreamStderr))
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	return func() {
+		stdout.Flush()
+		stderr.Flush()
+		stdoutConsole.Flush()
+		stderrConsole.Flush()
+	}
+}
@@ -0,0 +1,87 @@
+package runner
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/k8s-school/home-ci/internal/config"
+	"github.com/k8s-school/home-ci/internal/secrets"
+)
+
+func TestSecretsInjectedAndMasked(t *testing.T) {
+	configDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(configDir, "token"), []byte("tok-123456\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := config.Config{
+		RepoName:    "test-repo",
+		WorkDir:     t.TempDir(),
+		TestTimeout: time.Minute,
+		Secrets:     []config.Secret{{Name: "DEPLOY_TOKEN", File: "token"}},
+		Steps: []config.Step{
+			{Name: "deploy", Command: `echo "using $DEPLOY_TOKEN"; test "$DEPLOY_TOKEN" = tok-123456`},
+		},
+	}
+
+	logPath := filepath.Join(t.TempDir(), "run.log")
+	logFile, err := os.Create(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logFile.Close()
+
+	execution := &TestExecution{
+		runner:     &TestRunner{config: cfg, configPath: filepath.Join(configDir, "home-ci.yaml")},
+		branch:     "main",
+		commit:     "0123456789abcdef",
+		projectDir: t.TempDir(),
+		logFile:    newRunLog(logFile, nil),
+		testResult: &TestResult{},
+	}
+
+	if err := execution.loadSecrets(); err != nil {
+		t.Fatal(err)
+	}
+	if err := execution.executeTest(); err != nil {
+		t.Fatalf("expected the secret in the step environment: %v", err)
+	}
+
+	content, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(content), "tok-123456") {
+		t.Error("secret value found in run.log")
+	}
+	if !strings.Contains(string(content), "using ***") {
+		t.Errorf("masked output not found in run.log:\n%s", content)
+	}
+}
+
+func TestMaskPayload(t *testing.T) {
+	payload := map[string]interface{}{
+		"report_error":  "bad token tok-123456",
+		"failed_checks": []string{"login with tok-123456"},
+		"metadata": map[string]interface{}{
+			"steps": []map[string]interface{}{{"name": "tok-123456"}},
+		},
+		"attempt": 1,
+	}
+
+	maskPayload(payload, secrets.NewMasker("tok-123456"))
+
+	if payload["report_error"] != "bad token ***" {
+		t.Errorf("report_error = %v", payload["report_error"])
+	}
+	if payload["failed_checks"].([]string)[0] != "login with ***" {
+		t.Errorf("failed_checks = %v", payload["failed_checks"])
+	}
+	steps := payload["metadata"].(map[string]interface{})["steps"].([]map[string]interface{})
+	if steps[0]["name"] != "***" {
+		t.Errorf("step name = %v", steps[0]["name"])
+	}
+}
@@ -0,0 +1,198 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"sort"
+	"time"
+
+	"github.com/k8s-school/home-ci/internal/config"
+	"github.com/k8s-school/home-ci/internal/utils"
+)
+
+// Step statuses recorded in StepResult
+const (
+	StepSuccess = "success"
+	StepFailure = "failure"
+	StepTimeout = "timeout"
+	StepSkipped = "skipped"
+	StepOOM     = "oom" // Killed by the memory limit of the run
+)
+
+// StepResult is the outcome of one step of the test pipeline
+type StepResult struct {
+	Name         string        `json:"name"`
+	Status       string        `json:"status"`
+	StartTime    time.Time     `json:"start_time,omitempty"`
+	Duration     time.Duration `json:"duration"`
+	ExitCode     int           `json:"exit_code"`
+	ErrorMessage string        `json:"error_message,omitempty"`
+	LogStart     int64         `json:"log_start"` // Byte offset of the first line of the step in run.log
+	LogEnd       int64         `json:"log_end"`   // Byte offset following the last line of the step in run.log
+}
+
+// logOffset returns the current write position in run.log, see runLog.Offset
+func (te *TestExecution) logOffset() int64 {
+	return te.logFile.Offset()
+}
+
+// startStep begins the record of a step, the log offset is taken before its banner.
+// The lines written until finishStep are recorded with the step in the structured log.
+func (te *TestExecution) startStep(name string) StepResult {
+	step := StepResult{
+		Name:      name,
+		StartTime: time.Now(),
+		LogStart:  te.logOffset(),
+	}
+	te.logFile.SetStep(name)
+	return step
+}
+
+// finishStep completes the record of a step and adds it to the test result
+func (te *TestExecution) finishStep(step StepResult, err error, timedOut, oomKilled bool) StepResult {
+	step.Duration = time.Since(step.StartTime)
+	step.LogEnd = te.logOffset()
+	te.logFile.SetStep("")
+
+	switch {
+	case timedOut:
+		step.Status = StepTimeout
+		step.ExitCode = -1
+		if step.ErrorMessage == "" {
+			step.ErrorMessage = fmt.Sprintf("step timeout after %s", step.Duration.Round(time.Second))
+		}
+	case oomKilled && err != nil:
+		step.Status = StepOOM
+		step.ExitCode = -1
+		step.ErrorMessage = te.oomMessage()
+	case err != nil:
+		step.Status = StepFailure
+		step.ExitCode = -1
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			step.ExitCode = exitErr.ExitCode()
+		}
+		step.ErrorMessage = err.Error()
+	default:
+		step.Status = StepSuccess
+	}
+
+	te.testResult.Steps = append(te.testResult.Steps, step)
+	return step
+}
+
+// skipStep records a step that did not run because a previous step failed
+func (te *TestExecution) skipStep(name string) {
+	offset := te.logOffset()
+	te.testResult.Steps = append(te.testResult.Steps, StepResult{
+		Name:     name,
+		Status:   StepSkipped,
+		LogStart: offset,
+		LogEnd:   offset,
+	})
+	fmt.Fprintf(te.logFile, "\n=== Step %s skipped (previous step failed) ===\n", name)
+}
+
+// executePipeline runs the configured steps in order.
+// After a failure, only the steps marked always_run are executed.
+// test_timeout bounds the whole pipeline, except the steps marked always_run that
+// still run after it or after a cancel with their own timeout, so that they can clean up.
+func (te *TestExecution) executePipeline() error {
+	var pipelineErr error
+
+	ctx, cancel := context.WithTimeout(te.context(), te.cfg().TestTimeout)
+	defer cancel()
+
+	for _, step := range te.cfg().Steps {
+		if pipelineErr != nil && !step.AlwaysRun {
+			te.skipStep(step.Name)
+			continue
+		}
+
+		stepCtx := ctx
+		if step.AlwaysRun {
+			stepCtx = context.WithoutCancel(te.context())
+		}
+		result := te.runStep(stepCtx, step)
+		if result.Status == StepSuccess {
+			continue
+		}
+
+		if step.ContinueOnError {
+			fmt.Fprintf(te.logFile, "Step %s failed, continuing (continue_on_error)\n", step.Name)
+			continue
+		}
+
+		if pipelineErr == nil {
+			pipelineErr = fmt.Errorf("step '%s' failed: %s", step.Name, result.ErrorMessage)
+			te.testResult.FailedStep = step.Name
+			te.testResult.TimedOut = result.Status == StepTimeout
+			if result.Status == StepOOM {
+				te.testResult.FailureReason = FailureReasonOOM
+			}
+			te.testResult.ErrorMessage = pipelineErr.Error()
+		}
+	}
+
+	te.testResult.Success = pipelineErr == nil
+	return pipelineErr
+}
+
+// runStep executes a single step of the pipeline, ctx bounds the run of the whole pipeline
+func (te *TestExecution) runStep(ctx context.Context, step config.Step) StepResult {
+	timeout := step.Timeout
+	if timeout == 0 {
+		timeout = te.cfg().TestTimeout
+	}
+
+	record := te.startStep(step.Name)
+
+	fmt.Fprintf(te.logFile, "\n=== Step: %s ===\n", step.Name)
+	fmt.Fprintf(te.logFile, "Command: %s\n", te.masker.String(step.Command))
+	fmt.Fprintf(te.logFile, "Working Directory: %s\n", te.projectDir)
+	fmt.Fprintf(te.logFile, "Timeout: %s\n", timeout)
+	fmt.Fprintf(te.logFile, "Timestamp: %s\n", time.Now().Format(time.RFC3339))
+	fmt.Fprintf(te.logFile, "==================\n\n")
+
+	slog.Debug("Running pipeline step", "branch", te.branch, "commit", utils.ShortCommit(te.commit), "step", step.Name, "command", te.masker.String(step.Command))
+
+	stepCtx, stepCancel := context.WithTimeout(ctx, timeout)
+	defer stepCancel()
+
+	oomKilled, err := te.runCommand(stepCtx, CommandSpec{
+		Args: []string{"/bin/sh", "-c", step.Command},
+		Dir:  te.projectDir,
+		Env:  append(te.runEnv(), stepEnv(step.Env)...),
+	})
+	timedOut := err != nil && stepCtx.Err() == context.DeadlineExceeded
+	if timedOut && ctx.Err() == context.DeadlineExceeded {
+		record.ErrorMessage = fmt.Sprintf("pipeline timeout, test_timeout of %s reached", te.cfg().TestTimeout)
+	}
+
+	result := te.finishStep(record, err, timedOut, oomKilled)
+
+	fmt.Fprintf(te.logFile, "\n=== Step %s: %s (%s) ===\n", step.Name, result.Status, result.Duration.Round(time.Millisecond))
+	if result.ErrorMessage != "" {
+		fmt.Fprintf(te.logFile, "Error: %s\n", result.ErrorMessage)
+	}
+
+	return result
+}
+
+// stepEnv converts the environment of a step to KEY=value entries, sorted for stable logs
+func stepEnv(env map[string]string) []string {
+	keys := make([]string, 0, len(env))
+	for key := range env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	entries := make([]string, 0, len(keys))
+	for _, key := range keys {
+		entries = append(entries, fmt.Sprintf("%s=%s", key, env[key]))
+	}
+	return entries
+}
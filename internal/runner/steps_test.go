@@ -0,0 +1,224 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/k8s-school/home-ci/internal/config"
+)
+
+func TestExecutePipeline(t *testing.T) {
+	projectDir := t.TempDir()
+	cfg := config.Config{
+		RepoName:    "test-repo",
+		WorkDir:     t.TempDir(),
+		TestTimeout: time.Minute,
+		Steps: []config.Step{
+			{Name: "build", Command: "echo building $STAGE", Env: map[string]string{"STAGE": "one"}},
+			{Name: "lint", Command: "exit 3", ContinueOnError: true},
+			{Name: "test", Command: "echo testing; exit 1"},
+			{Name: "collect", Command: "echo collecting"},
+			{Name: "cleanup", Command: "echo cleaning", AlwaysRun: true},
+		},
+	}
+
+	logPath := filepath.Join(t.TempDir(), "run.log")
+	logFile, err := os.Create(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logFile.Close()
+
+	execution := &TestExecution{
+		runner:     &TestRunner{config: cfg},
+		branch:     "main",
+		commit:     "0123456789abcdef",
+		projectDir: projectDir,
+		logFile:    newRunLog(logFile, nil),
+		testResult: &TestResult{},
+	}
+
+	if err := execution.executeTest(); err == nil {
+		t.Fatal("expected the pipeline to fail")
+	}
+
+	result := execution.testResult
+	if result.Success || result.FailedStep != "test" {
+		t.Fatalf("expected failure at step test, got success=%v failed_step=%q", result.Success, result.FailedStep)
+	}
+
+	expected := map[string]string{
+		"build":   StepSuccess,
+		"lint":    StepFailure,
+		"test":    StepFailure,
+		"collect": StepSkipped,
+		"cleanup": StepSuccess,
+	}
+	if len(result.Steps) != len(expected) {
+		t.Fatalf("expected %d steps, got %d", len(expected), len(result.Steps))
+	}
+
+	content, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, step := range result.Steps {
+		if step.Status != expected[step.Name] {
+			t.Errorf("step %s: status = %s, want %s", step.Name, step.Status, expected[step.Name])
+		}
+		if step.LogEnd < step.LogStart || step.LogEnd > int64(len(content)) {
+			t.Errorf("step %s: invalid log offsets %d-%d", step.Name, step.LogStart, step.LogEnd)
+		}
+	}
+
+	// The offsets delimit the output of each step
+	build := result.Steps[0]
+	if !strings.Contains(string(content[build.LogStart:build.LogEnd]), "building one") {
+		t.Errorf("build step output not found between its log offsets")
+	}
+	if result.Steps[1].ExitCode != 3 {
+		t.Errorf("lint exit code = %d, want 3", result.Steps[1].ExitCode)
+	}
+}
+
+func TestExecutePipelineStepTimeout(t *testing.T) {
+	logFile, err := os.Create(filepath.Join(t.TempDir(), "run.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logFile.Close()
+
+	execution := &TestExecution{
+		runner: &TestRunner{config: config.Config{
+			RepoName:    "test-repo",
+			WorkDir:     t.TempDir(),
+			TestTimeout: time.Minute,
+			Steps:       []config.Step{{Name: "deploy", Command: "sleep 5", Timeout: 100 * time.Millisecond}},
+		}},
+		branch:     "main",
+		commit:     "0123456789abcdef",
+		projectDir: t.TempDir(),
+		logFile:    newRunLog(logFile, nil),
+		testResult: &TestResult{},
+	}
+
+	execution.executeTest()
+	if !execution.testResult.TimedOut || execution.testResult.Steps[0].Status != StepTimeout {
+		t.Fatalf("expected the deploy step to time out, got %+v", execution.testResult.Steps[0])
+	}
+}
+
+func TestExecutePipelineTimeout(t *testing.T) {
+	logFile, err := os.Create(filepath.Join(t.TempDir(), "run.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logFile.Close()
+
+	// Each step is within its own timeout, but test_timeout bounds the whole pipeline
+	execution := &TestExecution{
+		runner: &TestRunner{config: config.Config{
+			RepoName:    "test-repo",
+			WorkDir:     t.TempDir(),
+			TestTimeout: 300 * time.Millisecond,
+			Steps: []config.Step{
+				{Name: "build", Command: "sleep 0.2", Timeout: time.Minute},
+				{Name: "test", Command: "sleep 0.2", Timeout: time.Minute},
+				{Name: "e2e", Command: "true"},
+				{Name: "cleanup", Command: "sleep 0.2", AlwaysRun: true},
+			},
+		}},
+		branch:     "main",
+		commit:     "0123456789abcdef",
+		projectDir: t.TempDir(),
+		logFile:    newRunLog(logFile, nil),
+		testResult: &TestResult{},
+	}
+
+	start := time.Now()
+	if err := execution.executeTest(); err == nil {
+		t.Fatal("expected the pipeline to time out")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("pipeline ran for %s", elapsed)
+	}
+
+	result := execution.testResult
+	if !result.TimedOut || result.FailedStep != "test" {
+		t.Fatalf("expected a timeout at step test, got timed_out=%v failed_step=%q", result.TimedOut, result.FailedStep)
+	}
+	for i, expected := range []string{StepSuccess, StepTimeout, StepSkipped, StepSuccess} {
+		if status := result.Steps[i].Status; status != expected {
+			t.Errorf("step %s: status = %s, want %s", result.Steps[i].Name, status, expected)
+		}
+	}
+	if !strings.Contains(result.Steps[1].ErrorMessage, "pipeline timeout") {
+		t.Errorf("unexpected error message %q", result.Steps[1].ErrorMessage)
+	}
+}
+
+func TestExecutePipelineCancelled(t *testing.T) {
+	logFile, err := os.Create(filepath.Join(t.TempDir(), "run.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logFile.Close()
+
+	// The cleanup step runs after a cancel, with its own timeout
+	ctx, cancel := context.WithCancel(context.Background())
+	execution := &TestExecution{
+		runner: &TestRunner{config: config.Config{
+			RepoName:    "test-repo",
+			WorkDir:     t.TempDir(),
+			TestTimeout: time.Minute,
+			Steps: []config.Step{
+				{Name: "test", Command: "sleep 5"},
+				{Name: "cleanup", Command: "sleep 0.1", AlwaysRun: true},
+				{Name: "hang", Command: "sleep 5", Timeout: 100 * time.Millisecond, AlwaysRun: true},
+			},
+		}},
+		branch:     "main",
+		commit:     "0123456789abcdef",
+		projectDir: t.TempDir(),
+		logFile:    newRunLog(logFile, nil),
+		testResult: &TestResult{},
+		ctx:        ctx,
+	}
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	start := time.Now()
+	if err := execution.executeTest(); err == nil {
+		t.Fatal("expected the cancelled pipeline to fail")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("pipeline ran for %s", elapsed)
+	}
+	for i, expected := range []string{StepFailure, StepSuccess, StepTimeout} {
+		if status := execution.testResult.Steps[i].Status; status != expected {
+			t.Errorf("step %s: status = %s, want %s", execution.testResult.Steps[i].Name, status, expected)
+		}
+	}
+}
+
+func TestFinishStepOOMKill(t *testing.T) {
+	execution := &TestExecution{
+		runner:     &TestRunner{config: config.Config{Limits: config.Limits{Memory: "1G"}}},
+		testResult: &TestResult{},
+	}
+
+	step := execution.finishStep(execution.startStep("load"), errors.New("signal: killed"), false, true)
+	if step.Status != StepOOM || !strings.Contains(step.ErrorMessage, "memory limit 1G") {
+		t.Errorf("expected an OOM step, got %+v", step)
+	}
+
+	// A process killed by the OOM killer does not fail a command that succeeded
+	step = execution.finishStep(execution.startStep("load"), nil, false, true)
+	if step.Status != StepSuccess {
+		t.Errorf("status = %s, want %s", step.Status, StepSuccess)
+	}
+}
@@ -0,0 +1,77 @@
+package runner
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/k8s-school/home-ci/internal/config"
+)
+
+// resourceCapacities returns the capacities of the named resources and of the
+// workers, whose slots are held like resource slots
+func resourceCapacities(cfg config.Config) map[string]int {
+	capacities := make(map[string]int, len(cfg.Resources.Capacities)+len(cfg.Workers))
+	for name, capacity := range cfg.Resources.Capacities {
+		capacities[name] = capacity
+	}
+	for _, worker := range cfg.Workers {
+		capacities[config.WorkerResourcePrefix+worker.Name] = worker.Capacity
+	}
+	return capacities
+}
+
+// workerByName returns the configured worker with the given name, nil if there is none
+func (tr *TestRunner) workerByName(name string) *config.Worker {
+	for i := range tr.config.Workers {
+		if tr.config.Workers[i].Name == name {
+			return &tr.config.Workers[i]
+		}
+	}
+	return nil
+}
+
+// tryAcquireWorker takes a slot of the first worker able to run the jobs of a
+// branch, without blocking. It returns an empty name when the branch does not
+// use the ssh executor.
+func (tr *TestRunner) tryAcquireWorker(branch string) (string, *ResourceLease, bool) {
+	settings := tr.config.Executor.For(branch)
+	if settings.Type != config.ExecutorSSH || tr.resources == nil {
+		return "", nil, true
+	}
+
+	for _, worker := range tr.config.WorkersFor(settings.Labels) {
+		lease, ok, err := tr.resources.TryAcquire([]string{config.WorkerResourcePrefix + worker.Name})
+		if err != nil {
+			slog.Error("Failed to acquire worker", "branch", branch, "worker", worker.Name, "error", err)
+			continue
+		}
+		if ok {
+			return worker.Name, lease, true
+		}
+	}
+	return "", nil, false
+}
+
+// acquireWorker takes a slot of a worker able to run the jobs of a branch, waiting
+// until one is available. It returns a nil worker when the branch does not use the
+// ssh executor.
+func (tr *TestRunner) acquireWorker(ctx context.Context, branch string) (*config.Worker, *ResourceLease, error) {
+	logged := false
+	for {
+		name, lease, ok := tr.tryAcquireWorker(branch)
+		if ok {
+			return tr.workerByName(name), lease, nil
+		}
+		if !logged {
+			slog.Info("Waiting for a worker", "labels", tr.config.Executor.For(branch).Labels)
+			logged = true
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		case <-time.After(resourcePollInterval):
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+
+	"github.com/k8s-school/home-ci/internal/config"
+	"github.com/k8s-school/home-ci/internal/gitrepo"
+)
+
+// setupSubmodules initializes the submodules of the workspace according to workspace.submodules
+func (te *TestExecution) setupSubmodules(repo *git.Repository) error {
+	mode := te.cfg().Workspace.Submodules
+	if mode == "" || mode == config.SubmodulesNone {
+		return nil
+	}
+
+	workTree, err := repo.Worktree()
+	if err != nil {
+		return fmt.Errorf("failed to get working tree: %w", err)
+	}
+
+	submodules, err := workTree.Submodules()
+	if err != nil {
+		fmt.Fprintf(te.logFile, "Failed to list submodules: %v\n", err)
+		return fmt.Errorf("failed to list submodules: %w", err)
+	}
+
+	fmt.Fprintf(te.logFile, "\n=== Updating Submodules ===\n")
+	fmt.Fprintf(te.logFile, "Mode: %s\n", mode)
+	fmt.Fprintf(te.logFile, "Submodules: %d\n", len(submodules))
+
+	recursion := git.NoRecurseSubmodules
+	if mode == config.SubmodulesRecursive {
+		recursion = git.DefaultSubmoduleRecursionDepth
+	}
+
+	ctx, cancel := context.WithTimeout(te.context(), te.cfg().TestTimeout)
+	defer cancel()
+
+	for _, submodule := range submodules {
+		subConfig := submodule.Config()
+		fmt.Fprintf(te.logFile, "Updating submodule %s (%s) from %s...\n", subConfig.Name, subConfig.Path, subConfig.URL)
+
+		err := submodule.UpdateContext(ctx, &git.SubmoduleUpdateOptions{
+			Init:              true,
+			RecurseSubmodules: recursion,
+			Auth:              te.submoduleAuth(subConfig.URL),
+		})
+		if err != nil {
+			fmt.Fprintf(te.logFile, "Failed to update submodule %s: %v\n", subConfig.Name, err)
+			slog.Error("Submodule update failed",
+				"branch", te.branch,
+				"submodule", subConfig.Name,
+				"url", subConfig.URL,
+				"error", err)
+			return fmt.Errorf("failed to update submodule %s: %w", subConfig.Name, err)
+		}
+
+		if status, err := submodule.Status(); err == nil {
+			fmt.Fprintf(te.logFile, "Submodule %s checked out at %s\n", subConfig.Name, status.Current)
+		}
+	}
+
+	fmt.Fprintf(te.logFile, "===========================\n\n")
+	return nil
+}
+
+// submoduleAuth returns the repository credentials only for submodules hosted on the same server,
+// so that they are never sent to a third-party host
+func (te *TestExecution) submoduleAuth(submoduleURL string) transport.AuthMethod {
+	auth := te.runner.mirror.Auth()
+	if auth == nil || strings.HasPrefix(submoduleURL, "./") || strings.HasPrefix(submoduleURL, "../") {
+		return auth
+	}
+
+	subEndpoint, err := transport.NewEndpoint(submoduleURL)
+	if err != nil {
+		return nil
+	}
+	repoEndpoint, err := transport.NewEndpoint(te.runner.mirror.URL())
+	if err != nil {
+		return nil
+	}
+
+	if subEndpoint.Protocol != repoEndpoint.Protocol || subEndpoint.Host != repoEndpoint.Host {
+		return nil
+	}
+	return auth
+}
+
+// setupLFS fetches and checks out Git LFS objects using the git-lfs command
+func (te *TestExecution) setupLFS() error {
+	if !te.cfg().Workspace.LFS {
+		return nil
+	}
+
+	fmt.Fprintf(te.logFile, "\n=== Fetching Git LFS Objects ===\n")
+
+	if _, err := exec.LookPath("git-lfs"); err != nil {
+		fmt.Fprintf(te.logFile, "git-lfs is not installed: %v\n", err)
+		return fmt.Errorf("workspace.lfs is enabled but git-lfs is not installed: %w", err)
+	}
+
+	// git lfs downloads from the repository server, with the same credentials as the mirror
+	authEnv, err := gitrepo.CommandEnvForConfig(*te.cfg(), te.runner.configPath)
+	if err != nil {
+		return fmt.Errorf("failed to configure git authentication for git lfs: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(te.context(), te.cfg().TestTimeout)
+	defer cancel()
+
+	for _, args := range [][]string{
+		{"lfs", "install", "--local"},
+		{"lfs", "pull"},
+	} {
+		fmt.Fprintf(te.logFile, "Running: git %s\n", strings.Join(args, " "))
+
+		cmd := exec.CommandContext(ctx, "git", args...)
+		cmd.Dir = te.projectDir
+		cmd.Env = append(os.Environ(), authEnv...)
+		cmd.Stdout = te.logFile
+		cmd.Stderr = te.logFile
+
+		if err := cmd.Run(); err != nil {
+			fmt.Fprintf(te.logFile, "git %s failed: %v\n", strings.Join(args, " "), err)
+			slog.Error("Git LFS step failed",
+				"branch", te.branch,
+				"command", "git "+strings.Join(args, " "),
+				"error", err)
+			return fmt.Errorf("git %s failed: %w", strings.Join(args, " "), err)
+		}
+	}
+
+	fmt.Fprintf(te.logFile, "================================\n\n")
+	return nil
+}
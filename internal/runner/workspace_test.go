@@ -0,0 +1,207 @@
+package runner
+
+import (
+	"context"
+	nethttp "net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+
+	"github.com/k8s-school/home-ci/internal/config"
+	"github.com/k8s-school/home-ci/internal/gitrepo"
+)
+
+// runGit runs a git command in dir and fails the test on error
+func runGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-c", "protocol.file.allow=always"}, args...)...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=Test User", "GIT_AUTHOR_EMAIL=test@example.com",
+		"GIT_COMMITTER_NAME=Test User", "GIT_COMMITTER_EMAIL=test@example.com")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s failed: %v\n%s", strings.Join(args, " "), err, output)
+	}
+	return strings.TrimSpace(string(output))
+}
+
+func TestCloneFromMirrorWithSubmodules(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git command not available")
+	}
+
+	tempDir := t.TempDir()
+
+	// Submodule repository
+	subDir := filepath.Join(tempDir, "charts")
+	runGit(t, tempDir, "init", "-q", "-b", "main", subDir)
+	if err := os.WriteFile(filepath.Join(subDir, "Chart.yaml"), []byte("name: test\n"), 0644); err != nil {
+		t.Fatalf("Failed to write chart: %v", err)
+	}
+	runGit(t, subDir, "add", "Chart.yaml")
+	runGit(t, subDir, "commit", "-q", "-m", "Add chart")
+
+	// Main repository vendoring the submodule
+	repoDir := filepath.Join(tempDir, "project")
+	runGit(t, tempDir, "init", "-q", "-b", "main", repoDir)
+	runGit(t, repoDir, "submodule", "add", "-q", subDir, "charts")
+	runGit(t, repoDir, "commit", "-q", "-m", "Add charts submodule")
+	commit := runGit(t, repoDir, "rev-parse", "HEAD")
+
+	for _, tt := range []struct {
+		mode          string
+		expectChecked bool
+	}{
+		{config.SubmodulesNone, false},
+		{config.SubmodulesTop, true},
+		{config.SubmodulesRecursive, true},
+	} {
+		t.Run(tt.mode, func(t *testing.T) {
+			cfg := config.Config{
+				Repository:  repoDir,
+				RepoName:    "project",
+				TestTimeout: time.Minute,
+				WorkDir:     filepath.Join(tempDir, "work-"+tt.mode),
+				Workspace:   config.Workspace{Submodules: tt.mode},
+			}
+
+			logFile, err := os.Create(filepath.Join(tempDir, "run-"+tt.mode+".log"))
+			if err != nil {
+				t.Fatalf("Failed to create log file: %v", err)
+			}
+			defer logFile.Close()
+
+			execution := &TestExecution{
+				runner: &TestRunner{
+					config: cfg,
+					mirror: gitrepo.NewMirror(cfg.Repository, cfg.GetCacheDir(), nil),
+				},
+				branch:     "main",
+				commit:     commit,
+				projectDir: cfg.GetProjectDir("main", commit),
+				logFile:    newRunLog(logFile, nil),
+				testResult: &TestResult{},
+			}
+
+			if err := execution.cloneFromMirror(); err != nil {
+				t.Fatalf("cloneFromMirror() failed: %v", err)
+			}
+
+			_, err = os.Stat(filepath.Join(execution.projectDir, "charts", "Chart.yaml"))
+			if checked := err == nil; checked != tt.expectChecked {
+				t.Errorf("Submodule checked out = %v, want %v", checked, tt.expectChecked)
+			}
+
+			logContent, _ := os.ReadFile(logFile.Name())
+			if tt.expectChecked && !strings.Contains(string(logContent), "Updating submodule charts") {
+				t.Errorf("Expected submodule progress in run.log, got:\n%s", logContent)
+			}
+		})
+	}
+
+	// The update of the submodules stops with the run
+	t.Run("cancelled", func(t *testing.T) {
+		cfg := config.Config{
+			Repository:  repoDir,
+			RepoName:    "project",
+			TestTimeout: time.Minute,
+			WorkDir:     filepath.Join(tempDir, "work-cancelled"),
+			Workspace:   config.Workspace{Submodules: config.SubmodulesTop},
+		}
+		logFile, err := os.Create(filepath.Join(tempDir, "run-cancelled.log"))
+		if err != nil {
+			t.Fatalf("Failed to create log file: %v", err)
+		}
+		defer logFile.Close()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		execution := &TestExecution{
+			runner: &TestRunner{
+				config: cfg,
+				mirror: gitrepo.NewMirror(cfg.Repository, cfg.GetCacheDir(), nil),
+			},
+			branch:     "main",
+			commit:     commit,
+			projectDir: cfg.GetProjectDir("main", commit),
+			logFile:    newRunLog(logFile, nil),
+			testResult: &TestResult{},
+			ctx:        ctx,
+		}
+		if err := execution.cloneFromMirror(); err == nil {
+			t.Error("expected the update of the submodules of a cancelled run to fail")
+		}
+	})
+}
+
+func TestSubmoduleAuthSameHostOnly(t *testing.T) {
+	auth := &http.BasicAuth{Username: "git", Password: "token"}
+	execution := &TestExecution{
+		runner: &TestRunner{
+			mirror: gitrepo.NewMirror("https://github.com/org/repo.git", t.TempDir(), auth),
+		},
+	}
+
+	tests := []struct {
+		url      string
+		expected bool
+	}{
+		{"../charts.git", true},
+		{"https://github.com/org/charts.git", true},
+		{"https://gitlab.com/org/charts.git", false},
+		{"git@github.com:org/charts.git", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			got := execution.submoduleAuth(tt.url) != nil
+			if got != tt.expected {
+				t.Errorf("submoduleAuth(%q) returned credentials = %v, want %v", tt.url, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestEnsureCommitInMirrorCancel(t *testing.T) {
+	// The upstream does not answer before the fetch is cancelled
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(10 * time.Second):
+		}
+	}))
+	defer server.Close()
+
+	logFile, err := os.Create(filepath.Join(t.TempDir(), "run.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logFile.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	execution := &TestExecution{
+		runner: &TestRunner{
+			config: config.Config{Repository: server.URL + "/repo.git"},
+			mirror: gitrepo.NewMirror(server.URL+"/repo.git", t.TempDir(), nil),
+		},
+		commit:  "0123456789abcdef",
+		logFile: newRunLog(logFile, nil),
+		ctx:     ctx,
+	}
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	start := time.Now()
+	if err := execution.ensureCommitInMirror(); err == nil {
+		t.Error("expected the creation of the mirror to fail")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("the fetch of a cancelled run lasted %s", elapsed)
+	}
+}
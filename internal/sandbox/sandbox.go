@@ -0,0 +1,45 @@
+package sandbox
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Command is the hidden home-ci subcommand running a command in a private mount namespace
+const Command = "sandbox-exec"
+
+// Options of a command run in a private mount namespace
+type Options struct {
+	UID      int
+	GID      int
+	ReadOnly []string // Directories mounted read-only
+	Writable []string // Directories below the read-only ones that stay writable
+}
+
+// Args returns the arguments of the sandbox-exec subcommand running argv with the options
+func (o Options) Args(argv []string) []string {
+	args := []string{Command, "--uid", strconv.Itoa(o.UID), "--gid", strconv.Itoa(o.GID)}
+	for _, dir := range o.ReadOnly {
+		args = append(args, "--read-only", dir)
+	}
+	for _, dir := range o.Writable {
+		args = append(args, "--writable", dir)
+	}
+	return append(append(args, "--"), argv...)
+}
+
+// FilterEnv keeps the KEY=value entries whose key is allowed. An allowed name
+// ending with * matches the keys starting with the rest of the name.
+func FilterEnv(env []string, allow []string) []string {
+	var filtered []string
+	for _, entry := range env {
+		key, _, _ := strings.Cut(entry, "=")
+		for _, name := range allow {
+			if prefix, ok := strings.CutSuffix(name, "*"); ok && strings.HasPrefix(key, prefix) || key == name {
+				filtered = append(filtered, entry)
+				break
+			}
+		}
+	}
+	return filtered
+}
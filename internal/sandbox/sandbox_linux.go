@@ -0,0 +1,83 @@
+package sandbox
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// Supported reports whether the sandbox can run on this platform
+func Supported() error {
+	return nil
+}
+
+// SetCredential makes cmd run as the given user and group, without supplementary groups
+func SetCredential(cmd *exec.Cmd, uid, gid int) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Credential = &syscall.Credential{Uid: uint32(uid), Gid: uint32(gid), Groups: []uint32{}}
+}
+
+// SetMountNamespace makes cmd start in a new mount namespace
+func SetMountNamespace(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Cloneflags |= syscall.CLONE_NEWNS
+}
+
+// Exec sets up the mounts of the namespace, drops the privileges and replaces the
+// current process with argv. It runs in the process started by SetMountNamespace.
+func Exec(opts Options, argv []string) error {
+	if len(argv) == 0 {
+		return fmt.Errorf("no command to run")
+	}
+	path, err := exec.LookPath(argv[0])
+	if err != nil {
+		return err
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+
+	// Keep the mounts below private to the namespace
+	if err := syscall.Mount("", "/", "", syscall.MS_REC|syscall.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("failed to make the mounts private: %w", err)
+	}
+
+	// The writable directories are bound first, so that the recursive bind of the
+	// read-only directories copies them as writable submounts
+	for _, dir := range opts.Writable {
+		if err := syscall.Mount(dir, dir, "", syscall.MS_BIND, ""); err != nil {
+			return fmt.Errorf("failed to bind %s: %w", dir, err)
+		}
+	}
+	for _, dir := range opts.ReadOnly {
+		if err := syscall.Mount(dir, dir, "", syscall.MS_BIND|syscall.MS_REC, ""); err != nil {
+			return fmt.Errorf("failed to bind %s: %w", dir, err)
+		}
+		if err := syscall.Mount("", dir, "", syscall.MS_BIND|syscall.MS_REMOUNT|syscall.MS_RDONLY, ""); err != nil {
+			return fmt.Errorf("failed to make %s read-only: %w", dir, err)
+		}
+	}
+
+	// Enter the new mounts, the working directory still refers to the previous ones
+	if err := os.Chdir(cwd); err != nil {
+		return err
+	}
+
+	if err := syscall.Setgroups([]int{}); err != nil {
+		return fmt.Errorf("failed to drop the supplementary groups: %w", err)
+	}
+	if err := syscall.Setgid(opts.GID); err != nil {
+		return fmt.Errorf("failed to set gid %d: %w", opts.GID, err)
+	}
+	if err := syscall.Setuid(opts.UID); err != nil {
+		return fmt.Errorf("failed to set uid %d: %w", opts.UID, err)
+	}
+
+	return syscall.Exec(path, argv, os.Environ())
+}
@@ -0,0 +1,24 @@
+//go:build !linux
+
+package sandbox
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+// Supported reports whether the sandbox can run on this platform
+func Supported() error {
+	return fmt.Errorf("the sandbox is only supported on Linux")
+}
+
+// SetCredential is a no-op on platforms where Supported fails
+func SetCredential(cmd *exec.Cmd, uid, gid int) {}
+
+// SetMountNamespace is a no-op on platforms where Supported fails
+func SetMountNamespace(cmd *exec.Cmd) {}
+
+// Exec is not supported on platforms without mount namespaces
+func Exec(opts Options, argv []string) error {
+	return Supported()
+}
@@ -0,0 +1,26 @@
+package sandbox
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFilterEnv(t *testing.T) {
+	env := []string{"PATH=/usr/bin", "HOME=/root", "LC_ALL=C", "LC_TIME=C", "GOPATH=/root/go", "PATHS=x"}
+
+	got := FilterEnv(env, []string{"PATH", "LC_*"})
+	want := "PATH=/usr/bin LC_ALL=C LC_TIME=C"
+	if strings.Join(got, " ") != want {
+		t.Errorf("FilterEnv() = %v, want %s", got, want)
+	}
+}
+
+func TestOptionsArgs(t *testing.T) {
+	opts := Options{UID: 1001, GID: 1002, ReadOnly: []string{"/work"}, Writable: []string{"/work/run/sandbox"}}
+
+	got := strings.Join(opts.Args([]string{"/bin/sh", "-c", "make test"}), " ")
+	want := "sandbox-exec --uid 1001 --gid 1002 --read-only /work --writable /work/run/sandbox -- /bin/sh -c make test"
+	if got != want {
+		t.Errorf("Args() = %s, want %s", got, want)
+	}
+}
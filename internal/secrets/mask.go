@@ -0,0 +1,143 @@
+package secrets
+
+import (
+	"bytes"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Mask replaces the secret values in the masked output
+const Mask = "***"
+
+// Masker replaces the secret values in text. A nil Masker leaves text unchanged.
+type Masker struct {
+	values [][]byte // Sorted by decreasing length, so that the longest value wins
+}
+
+// NewMasker returns a masker for the given values, nil when there is nothing to mask.
+// Each line of a multi-line value is also masked on its own.
+func NewMasker(values ...string) *Masker {
+	seen := make(map[string]bool)
+	var unique []string
+	add := func(value string) {
+		if value != "" && !seen[value] {
+			seen[value] = true
+			unique = append(unique, value)
+		}
+	}
+	for _, value := range values {
+		add(value)
+		if strings.Contains(value, "\n") {
+			for _, line := range strings.Split(value, "\n") {
+				add(strings.TrimSpace(line))
+			}
+		}
+	}
+	if len(unique) == 0 {
+		return nil
+	}
+
+	sort.SliceStable(unique, func(i, j int) bool { return len(unique[i]) > len(unique[j]) })
+	m := &Masker{}
+	for _, value := range unique {
+		m.values = append(m.values, []byte(value))
+	}
+	return m
+}
+
+// String masks the secret values in s
+func (m *Masker) String(s string) string {
+	if m == nil {
+		return s
+	}
+	return string(m.Bytes([]byte(s)))
+}
+
+// Bytes masks the secret values in data
+func (m *Masker) Bytes(data []byte) []byte {
+	if m == nil {
+		return data
+	}
+	masked, _ := m.mask(data, true)
+	return masked
+}
+
+// mask masks data up to the point where a secret value may continue in the
+// next chunk, unless final is set. It returns the masked output and the
+// number of bytes of data consumed.
+func (m *Masker) mask(data []byte, final bool) ([]byte, int) {
+	var out bytes.Buffer
+	i := 0
+scan:
+	for i < len(data) {
+		if !final {
+			for _, value := range m.values {
+				if len(data)-i < len(value) && bytes.HasPrefix(value, data[i:]) {
+					// The rest of data may be the beginning of a secret
+					break scan
+				}
+			}
+		}
+		for _, value := range m.values {
+			if bytes.HasPrefix(data[i:], value) {
+				out.WriteString(Mask)
+				i += len(value)
+				continue scan
+			}
+		}
+		out.WriteByte(data[i])
+		i++
+	}
+	return out.Bytes(), i
+}
+
+// Writer masks the secret values written to an underlying writer. Values split
+// across writes are masked too: the bytes that may start a secret are held back
+// until the next write or Flush.
+type Writer struct {
+	mu      sync.Mutex
+	w       io.Writer
+	masker  *Masker
+	pending []byte
+}
+
+// Writer returns a writer masking the secret values written to w
+func (m *Masker) Writer(w io.Writer) *Writer {
+	return &Writer{w: w, masker: m}
+}
+
+// Write masks p and writes it to the underlying writer
+func (mw *Writer) Write(p []byte) (int, error) {
+	mw.mu.Lock()
+	defer mw.mu.Unlock()
+
+	if mw.masker == nil {
+		return mw.w.Write(p)
+	}
+
+	data := append(mw.pending, p...)
+	masked, consumed := mw.masker.mask(data, false)
+	mw.pending = append([]byte(nil), data[consumed:]...)
+	if len(masked) > 0 {
+		if _, err := mw.w.Write(masked); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Flush writes the bytes held back by the writer
+func (mw *Writer) Flush() error {
+	mw.mu.Lock()
+	defer mw.mu.Unlock()
+
+	if mw.masker == nil || len(mw.pending) == 0 {
+		return nil
+	}
+	masked, _ := mw.masker.mask(mw.pending, true)
+	mw.pending = nil
+	_, err := mw.w.Write(masked)
+	return err
+}
@@ -0,0 +1,63 @@
+package secrets
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/k8s-school/home-ci/internal/config"
+)
+
+// Secret is a loaded secret value
+type Secret struct {
+	Name  string
+	Value string
+}
+
+// Load reads the values of the configured secrets, relative files are resolved
+// from baseDir. The surrounding whitespace of the files is trimmed.
+func Load(specs []config.Secret, baseDir string) ([]Secret, error) {
+	loaded := make([]Secret, 0, len(specs))
+	for _, spec := range specs {
+		var value string
+		if spec.File != "" {
+			path := spec.File
+			if !filepath.IsAbs(path) && baseDir != "" {
+				path = filepath.Join(baseDir, path)
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read secret %s: %w", spec.Name, err)
+			}
+			value = strings.TrimSpace(string(data))
+		} else {
+			value = os.Getenv(spec.Env)
+		}
+		if value == "" {
+			return nil, fmt.Errorf("secret %s is empty", spec.Name)
+		}
+		loaded = append(loaded, Secret{Name: spec.Name, Value: value})
+	}
+	return loaded, nil
+}
+
+// Env returns the secrets as KEY=value entries, sorted by name
+func Env(secrets []Secret) []string {
+	env := make([]string, 0, len(secrets))
+	for _, secret := range secrets {
+		env = append(env, secret.Name+"="+secret.Value)
+	}
+	sort.Strings(env)
+	return env
+}
+
+// Values returns the values of the secrets
+func Values(secrets []Secret) []string {
+	values := make([]string, 0, len(secrets))
+	for _, secret := range secrets {
+		values = append(values, secret.Value)
+	}
+	return values
+}
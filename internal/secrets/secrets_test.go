@@ -0,0 +1,85 @@
+package secrets
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/k8s-school/home-ci/internal/config"
+)
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "token"), []byte("file-secret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("HOME_CI_TEST_SECRET", "env-secret")
+
+	loaded, err := Load([]config.Secret{
+		{Name: "TOKEN", File: "token"},
+		{Name: "API_KEY", Env: "HOME_CI_TEST_SECRET"},
+	}, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	env := Env(loaded)
+	if len(env) != 2 || env[0] != "API_KEY=env-secret" || env[1] != "TOKEN=file-secret" {
+		t.Errorf("unexpected env %v", env)
+	}
+
+	if _, err := Load([]config.Secret{{Name: "MISSING", Env: "HOME_CI_TEST_UNSET"}}, dir); err == nil {
+		t.Error("expected an error for an empty secret")
+	}
+	if _, err := Load([]config.Secret{{Name: "MISSING", File: "absent"}}, dir); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
+
+func TestMaskerString(t *testing.T) {
+	m := NewMasker("s3cr3t", "s3cr3t-longer", "line-one\nline-two")
+
+	cases := map[string]string{
+		"token=s3cr3t":           "token=***",
+		"token=s3cr3t-longer!":   "token=***!",
+		"s3cr3ts3cr3t":           "******",
+		"key: line-two":          "key: ***",
+		"nothing to hide":        "nothing to hide",
+		"line-one\nline-two end": "*** end",
+	}
+	for input, want := range cases {
+		if got := m.String(input); got != want {
+			t.Errorf("String(%q) = %q, want %q", input, got, want)
+		}
+	}
+
+	var none *Masker
+	if got := none.String("s3cr3t"); got != "s3cr3t" {
+		t.Errorf("nil masker changed the text: %q", got)
+	}
+	if NewMasker("", "") != nil {
+		t.Error("expected a nil masker without values")
+	}
+}
+
+func TestWriterMasksAcrossWrites(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewMasker("s3cr3t").Writer(&buf)
+
+	for _, chunk := range []string{"token=s3", "cr", "3t and s3cr", "x then s3"} {
+		if _, err := w.Write([]byte(chunk)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := buf.String(); got != "token=*** and s3crx then " {
+		t.Errorf("before flush got %q", got)
+	}
+
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != "token=*** and s3crx then s3" {
+		t.Errorf("after flush got %q", got)
+	}
+}
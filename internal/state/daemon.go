@@ -0,0 +1,67 @@
+package state
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ErrDaemonRunning is returned by LockDaemon when another daemon runs for the repository
+var ErrDaemonRunning = errors.New("another daemon is running for the repository")
+
+// daemonLockPath returns the lock file held by the daemon of the repository while it runs
+func (sm *StateManager) daemonLockPath() string {
+	return filepath.Join(sm.stateDir, fmt.Sprintf("%s.lock", sm.repoName))
+}
+
+// LockDaemon takes the lock of the daemon of the repository and writes the PID of the
+// process to it. The lock is held until the file is closed, or the process exits.
+func (sm *StateManager) LockDaemon() (*os.File, error) {
+	if err := os.MkdirAll(sm.stateDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create state directory %s: %w", sm.stateDir, err)
+	}
+	path := sm.daemonLockPath()
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open daemon lock: %w", err)
+	}
+	locked, err := lockFile(file, true)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to lock %s: %w", path, err)
+	}
+	if !locked {
+		file.Close()
+		return nil, fmt.Errorf("%w, it holds %s", ErrDaemonRunning, path)
+	}
+
+	if err := file.Truncate(0); err == nil {
+		file.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	}
+	return file, nil
+}
+
+// DaemonPID reports whether a daemon holds the lock of the repository, with its PID,
+// 0 when it is not known
+func (sm *StateManager) DaemonPID() (int, bool) {
+	file, err := os.Open(sm.daemonLockPath())
+	if err != nil {
+		return 0, false
+	}
+	defer file.Close()
+
+	// The shared lock is only refused while the daemon holds its lock
+	if locked, err := lockFile(file, false); err != nil || locked {
+		return 0, false
+	}
+	data, err := io.ReadAll(io.LimitReader(file, 32))
+	if err != nil {
+		return 0, true
+	}
+	pid, _ := strconv.Atoi(strings.TrimSpace(string(data)))
+	return pid, true
+}
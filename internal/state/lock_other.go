@@ -0,0 +1,13 @@
+//go:build !unix
+
+package state
+
+import (
+	"errors"
+	"os"
+)
+
+// lockFile is not supported on this platform
+func lockFile(file *os.File, exclusive bool) (bool, error) {
+	return false, errors.New("daemon locks are not supported on this platform")
+}
@@ -10,12 +10,16 @@ import (
 	"time"
 
 	"github.com/k8s-school/home-ci/internal/runner"
+	"github.com/k8s-school/home-ci/internal/utils"
 )
 
 // RepositoryState represents the state for a single repository
 type RepositoryState struct {
 	BranchStates map[string]*runner.BranchState `json:"branch_states"`
 	RunningTests []runner.RunningTest           `json:"running_tests"`
+	QueuedJobs   []runner.QueuedJob             `json:"queued_jobs"`
+	LastPoll     time.Time                      `json:"last_poll,omitempty"`
+	PollError    string                         `json:"poll_error,omitempty"`
 	LastUpdated  time.Time                      `json:"last_updated"`
 }
 
@@ -35,6 +39,7 @@ func NewStateManager(stateDir, repoName string) *StateManager {
 		state: &RepositoryState{
 			BranchStates: make(map[string]*runner.BranchState),
 			RunningTests: make([]runner.RunningTest, 0),
+			QueuedJobs:   make([]runner.QueuedJob, 0),
 			LastUpdated:  time.Now(),
 		},
 	}
@@ -149,6 +154,49 @@ func (sm *StateManager) UpdateBranchState(branch, commit string) {
 	sm.state.BranchStates[branch].LatestCommit = commit
 }
 
+// RecordBranchResult records the result of the last finished run of a branch
+func (sm *StateManager) RecordBranchResult(branch string, result runner.BranchResult) {
+	sm.stateMutex.Lock()
+	defer sm.stateMutex.Unlock()
+
+	if sm.state.BranchStates[branch] == nil {
+		sm.state.BranchStates[branch] = &runner.BranchState{}
+	}
+	sm.state.BranchStates[branch].LastResult = &result
+}
+
+// GetBranchStates returns a copy of the states of all branches
+func (sm *StateManager) GetBranchStates() map[string]runner.BranchState {
+	sm.stateMutex.RLock()
+	defer sm.stateMutex.RUnlock()
+
+	states := make(map[string]runner.BranchState, len(sm.state.BranchStates))
+	for branch, branchState := range sm.state.BranchStates {
+		states[branch] = *branchState
+	}
+	return states
+}
+
+// SetPollStatus records the time and the error of the last check of the remote branches
+func (sm *StateManager) SetPollStatus(at time.Time, err error) {
+	sm.stateMutex.Lock()
+	defer sm.stateMutex.Unlock()
+
+	sm.state.LastPoll = at
+	sm.state.PollError = ""
+	if err != nil {
+		sm.state.PollError = err.Error()
+	}
+}
+
+// GetPollStatus returns the time and the error of the last check of the remote branches
+func (sm *StateManager) GetPollStatus() (time.Time, string) {
+	sm.stateMutex.RLock()
+	defer sm.stateMutex.RUnlock()
+
+	return sm.state.LastPoll, sm.state.PollError
+}
+
 // AddRunningTest adds a test to the running tests list
 func (sm *StateManager) AddRunningTest(test runner.RunningTest) {
 	sm.stateMutex.Lock()
@@ -181,6 +229,24 @@ func (sm *StateManager) GetRunningTests() []runner.RunningTest {
 	return tests
 }
 
+// SetQueuedJobs replaces the snapshot of the job queue
+func (sm *StateManager) SetQueuedJobs(jobs []runner.QueuedJob) {
+	sm.stateMutex.Lock()
+	defer sm.stateMutex.Unlock()
+
+	sm.state.QueuedJobs = jobs
+}
+
+// GetQueuedJobs returns a copy of the last snapshot of the job queue
+func (sm *StateManager) GetQueuedJobs() []runner.QueuedJob {
+	sm.stateMutex.RLock()
+	defer sm.stateMutex.RUnlock()
+
+	jobs := make([]runner.QueuedJob, len(sm.state.QueuedJobs))
+	copy(jobs, sm.state.QueuedJobs)
+	return jobs
+}
+
 // CleanupOldRunningTests removes tests older than maxAge from the running tests list
 func (sm *StateManager) CleanupOldRunningTests(maxAge time.Duration) {
 	sm.stateMutex.Lock()
@@ -196,7 +262,7 @@ func (sm *StateManager) CleanupOldRunningTests(maxAge time.Duration) {
 			slog.Debug("Removing stale running test",
 				"repo", sm.repoName,
 				"branch", test.Branch,
-				"commit", test.Commit[:8],
+				"commit", utils.ShortCommit(test.Commit),
 				"age", time.Since(test.StartTime))
 		}
 	}
@@ -0,0 +1,94 @@
+package testreport
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// junitSuite is a <testsuite> element, suites can be nested
+type junitSuite struct {
+	Name   string       `xml:"name,attr"`
+	Suites []junitSuite `xml:"testsuite"`
+	Cases  []junitCase  `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failures  []junitResult `xml:"failure"`
+	Errors    []junitResult `xml:"error"`
+	Skipped   *junitResult  `xml:"skipped"`
+}
+
+type junitResult struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// ParseJUnit reads a JUnit XML report, with either a <testsuites> or a <testsuite> root element
+func ParseJUnit(r io.Reader) ([]Case, error) {
+	var root struct {
+		XMLName xml.Name
+		junitSuite
+	}
+	if err := xml.NewDecoder(r).Decode(&root); err != nil {
+		return nil, fmt.Errorf("invalid JUnit XML: %w", err)
+	}
+
+	switch root.XMLName.Local {
+	case "testsuites", "testsuite":
+	default:
+		return nil, fmt.Errorf("invalid JUnit XML: unexpected root element <%s>", root.XMLName.Local)
+	}
+
+	return junitCases(root.junitSuite, ""), nil
+}
+
+// junitCases flattens the test cases of a suite and its nested suites
+func junitCases(suite junitSuite, parent string) []Case {
+	name := suite.Name
+	if name == "" {
+		name = parent
+	}
+
+	var cases []Case
+	for _, tc := range suite.Cases {
+		c := Case{Suite: tc.ClassName, Name: tc.Name, Status: StatusPassed}
+		if c.Suite == "" {
+			c.Suite = name
+		}
+		if seconds, err := time.ParseDuration(strings.TrimSpace(tc.Time) + "s"); err == nil {
+			c.Duration = seconds
+		}
+
+		switch {
+		case len(tc.Failures) > 0:
+			c.Status = StatusFailed
+			c.Message = junitMessage(tc.Failures[0])
+		case len(tc.Errors) > 0:
+			c.Status = StatusFailed
+			c.Message = junitMessage(tc.Errors[0])
+		case tc.Skipped != nil:
+			c.Status = StatusSkipped
+			c.Message = junitMessage(*tc.Skipped)
+		}
+		cases = append(cases, c)
+	}
+
+	for _, nested := range suite.Suites {
+		cases = append(cases, junitCases(nested, name)...)
+	}
+	return cases
+}
+
+// junitMessage returns the message attribute, or the element text when it is empty
+func junitMessage(result junitResult) string {
+	if result.Message != "" {
+		return result.Message
+	}
+	return strings.TrimSpace(result.Text)
+}
@@ -0,0 +1,75 @@
+package testreport
+
+import (
+	"time"
+	"unicode/utf8"
+)
+
+// Test case statuses
+const (
+	StatusPassed  = "passed"
+	StatusFailed  = "failed"
+	StatusSkipped = "skipped"
+)
+
+// maxMessageLength caps the failure messages kept for a test case
+const maxMessageLength = 1024
+
+// Case is the outcome of a single test case
+type Case struct {
+	Suite    string        `json:"suite,omitempty"`
+	Name     string        `json:"name"`
+	Status   string        `json:"status"`
+	Duration time.Duration `json:"duration,omitempty"`
+	Message  string        `json:"message,omitempty"` // Failure message, truncated
+	File     string        `json:"file,omitempty"`    // Report file the case comes from
+}
+
+// Summary counts the test cases of a run
+type Summary struct {
+	Total   int    `json:"total"`
+	Passed  int    `json:"passed"`
+	Failed  int    `json:"failed"`
+	Skipped int    `json:"skipped"`
+	Cases   []Case `json:"cases,omitempty"`
+}
+
+// Add appends test cases to the summary and updates the counts
+func (s *Summary) Add(cases ...Case) {
+	for _, c := range cases {
+		c.Message = truncate(c.Message)
+		s.Cases = append(s.Cases, c)
+		s.Total++
+		switch c.Status {
+		case StatusPassed:
+			s.Passed++
+		case StatusFailed:
+			s.Failed++
+		case StatusSkipped:
+			s.Skipped++
+		}
+	}
+}
+
+// Failures returns the failed test cases
+func (s *Summary) Failures() []Case {
+	var failures []Case
+	for _, c := range s.Cases {
+		if c.Status == StatusFailed {
+			failures = append(failures, c)
+		}
+	}
+	return failures
+}
+
+// truncate limits the length of a failure message, without cutting a UTF-8 character
+func truncate(message string) string {
+	if len(message) <= maxMessageLength {
+		return message
+	}
+	end := maxMessageLength
+	for end > 0 && !utf8.RuneStart(message[end]) {
+		end--
+	}
+	return message[:end] + "... (truncated)"
+}
@@ -0,0 +1,124 @@
+package testreport
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestParseJUnit(t *testing.T) {
+	report := `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites>
+  <testsuite name="e2e">
+    <testcase classname="e2e.install" name="deploy operator" time="12.5"/>
+    <testcase classname="e2e.install" name="check pods" time="1">
+      <failure message="pod api not ready">timeout waiting for pod</failure>
+    </testcase>
+    <testcase name="gpu test"><skipped/></testcase>
+    <testsuite name="nested">
+      <testcase name="broken"><error>panic: nil pointer</error></testcase>
+    </testsuite>
+  </testsuite>
+</testsuites>`
+
+	cases, err := ParseJUnit(strings.NewReader(report))
+	if err != nil {
+		t.Fatalf("ParseJUnit() failed: %v", err)
+	}
+
+	summary := &Summary{}
+	summary.Add(cases...)
+	if summary.Total != 4 || summary.Passed != 1 || summary.Failed != 2 || summary.Skipped != 1 {
+		t.Fatalf("unexpected summary %+v", summary)
+	}
+
+	if cases[0].Duration != 12500*time.Millisecond || cases[0].Suite != "e2e.install" {
+		t.Errorf("unexpected first case %+v", cases[0])
+	}
+	if cases[1].Message != "pod api not ready" {
+		t.Errorf("Message = %q, want the failure message attribute", cases[1].Message)
+	}
+	if cases[2].Suite != "e2e" {
+		t.Errorf("Suite = %q, want the suite name when classname is missing", cases[2].Suite)
+	}
+	if cases[3].Suite != "nested" || cases[3].Message != "panic: nil pointer" {
+		t.Errorf("unexpected nested case %+v", cases[3])
+	}
+}
+
+func TestParseJUnitSingleSuite(t *testing.T) {
+	cases, err := ParseJUnit(strings.NewReader(`<testsuite name="unit"><testcase name="a"/></testsuite>`))
+	if err != nil || len(cases) != 1 || cases[0].Suite != "unit" {
+		t.Fatalf("ParseJUnit() = %+v, %v", cases, err)
+	}
+
+	if _, err := ParseJUnit(strings.NewReader(`<html></html>`)); err == nil {
+		t.Error("expected an error for a non JUnit document")
+	}
+}
+
+func TestParseTAP(t *testing.T) {
+	stream := `TAP version 13
+1..6
+ok 1 - cluster created
+not ok 2 - api reachable
+  ---
+  message: connection refused
+  severity: fail
+  ...
+ok 3 - gpu checks # SKIP no gpu on this node
+not ok 4 - new feature # TODO not implemented
+    ok 1 - subtest is ignored
+ok 5
+not ok 6 - cleanup
+`
+
+	cases, err := ParseTAP(strings.NewReader(stream))
+	if err != nil {
+		t.Fatalf("ParseTAP() failed: %v", err)
+	}
+
+	expected := []struct {
+		name   string
+		status string
+	}{
+		{"cluster created", StatusPassed},
+		{"api reachable", StatusFailed},
+		{"gpu checks", StatusSkipped},
+		{"new feature", StatusSkipped},
+		{"test 5", StatusPassed},
+		{"cleanup", StatusFailed},
+	}
+	if len(cases) != len(expected) {
+		t.Fatalf("expected %d cases, got %+v", len(expected), cases)
+	}
+	for i, want := range expected {
+		if cases[i].Name != want.name || cases[i].Status != want.status {
+			t.Errorf("case %d = %q %s, want %q %s", i, cases[i].Name, cases[i].Status, want.name, want.status)
+		}
+	}
+	if !strings.Contains(cases[1].Message, "connection refused") {
+		t.Errorf("Message = %q, want the YAML diagnostic", cases[1].Message)
+	}
+	if cases[2].Message != "no gpu on this node" {
+		t.Errorf("skip reason = %q", cases[2].Message)
+	}
+}
+
+func TestSummaryTruncatesMessages(t *testing.T) {
+	summary := &Summary{}
+	summary.Add(Case{Name: "long", Status: StatusFailed, Message: strings.Repeat("x", 5000)})
+	if len(summary.Cases[0].Message) > maxMessageLength+20 {
+		t.Errorf("message not truncated, length %d", len(summary.Cases[0].Message))
+	}
+	if len(summary.Failures()) != 1 {
+		t.Error("expected one failure")
+	}
+
+	// A multi-byte character across the limit is dropped, not cut
+	summary.Add(Case{Name: "utf8", Status: StatusFailed, Message: strings.Repeat("x", maxMessageLength-1) + strings.Repeat("é", 10)})
+	if message := summary.Cases[1].Message; !utf8.ValidString(message) || !strings.HasPrefix(message, strings.Repeat("x", maxMessageLength-1)+"...") {
+		t.Errorf("message not truncated at a character boundary: %q", message[maxMessageLength-10:])
+	}
+}
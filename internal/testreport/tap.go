@@ -0,0 +1,80 @@
+package testreport
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// tapLine matches a top-level TAP test line: "ok 1 - description # directive"
+var tapLine = regexp.MustCompile(`^(not ok|ok)\b\s*(\d+)?\s*(?:-\s*)?([^#]*)(?:#\s*(\w+)\s*(.*))?$`)
+
+// ParseTAP reads a TAP (Test Anything Protocol) stream.
+// Tests marked SKIP or TODO are reported as skipped, and the YAML diagnostic
+// block following a failed test is used as its failure message.
+func ParseTAP(r io.Reader) ([]Case, error) {
+	var cases []Case
+	var diagnostic []string
+	inDiagnostic := false
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+
+		// YAML diagnostic block of the previous test
+		if inDiagnostic {
+			if trimmed == "..." {
+				inDiagnostic = false
+				if n := len(cases); n > 0 && cases[n-1].Status == StatusFailed {
+					cases[n-1].Message = strings.Join(diagnostic, "\n")
+				}
+				diagnostic = nil
+			} else {
+				diagnostic = append(diagnostic, trimmed)
+			}
+			continue
+		}
+		if trimmed == "---" && len(cases) > 0 {
+			inDiagnostic = true
+			continue
+		}
+
+		if strings.HasPrefix(line, "Bail out!") {
+			cases = append(cases, Case{
+				Name:    "Bail out",
+				Status:  StatusFailed,
+				Message: strings.TrimSpace(strings.TrimPrefix(line, "Bail out!")),
+			})
+			continue
+		}
+
+		// Indented lines belong to subtests, which are summarized by their parent line
+		match := tapLine.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+
+		c := Case{Name: strings.TrimSpace(match[3]), Status: StatusPassed}
+		if c.Name == "" {
+			c.Name = fmt.Sprintf("test %s", match[2])
+		}
+		if match[1] == "not ok" {
+			c.Status = StatusFailed
+		}
+		directive := strings.ToUpper(match[4])
+		if strings.HasPrefix(directive, "SKIP") || strings.HasPrefix(directive, "TODO") {
+			c.Status = StatusSkipped
+			c.Message = strings.TrimSpace(match[5])
+		}
+		cases = append(cases, c)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return cases, fmt.Errorf("failed to read TAP stream: %w", err)
+	}
+	return cases, nil
+}
@@ -12,8 +12,9 @@ while [[ $# -gt 0 ]]; do
 done
 
 # Create unique result file in the run's data directory for cleanup validation
-COMMIT_HASH=$(git rev-parse HEAD 2>/dev/null | head -c 8 || echo "unknown")
-BRANCH_NAME=$(git branch --show-current 2>/dev/null || echo "detached")
+# home-ci exports the branch and commit, git is only a fallback for runs outside home-ci
+COMMIT_HASH="${HOME_CI_COMMIT_SHORT:-$(git rev-parse HEAD 2>/dev/null | head -c 8 || echo "unknown")}"
+BRANCH_NAME="${HOME_CI_BRANCH:-$(git branch --show-current 2>/dev/null || echo "detached")}"
 COMMIT_MESSAGE=$(git log -1 --pretty=format:"%s" 2>/dev/null || echo "unknown")
 
 # E2E tests use the standardized data directory (can be overridden by environment)